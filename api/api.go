@@ -1,41 +1,163 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	log "log/slog"
 
 	"github.com/gin-gonic/gin"
 	"github.com/phllpmcphrsn/voice-quips/config"
 	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/health"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/metrics"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/ratelimit"
 	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-const MegaByte int64 = 10 << 10
+// uploadMemory is how much of an upload's form is held in memory, the rest spills to temporary files
+const uploadMemory int64 = 1 << 20
 
 type APIServer struct {
 	// API properties
 	basePath   string
 	listenAddr string
 	env        string
+	apiKeys    []apiKey
+	bucket     string
+	settings   serverSettings
 
 	// (Dependency) Inject the services
-	s3Service   s3.DownloadUploader
-	fileService file.Storer
+	s3Service       s3.Storage
+	fileService     file.Storer
+	categoryService file.CategoryStorer
+	feedbackService file.FeedbackStorer
+	playlistService playlist.Storer
+	statsRecorder   stats.EventRecorder
+	statsService    stats.Reporter
+	jobQueue        jobs.Queue
+	transcripts     transcript.Finder
+	metrics         *metrics.Metrics
+	metricsPath     string
+	serviceName     string
+	health          *health.Health
+	limiter         ratelimit.Limiter
+	quota           ratelimit.Quota
+	limits          RateLimits
+	storageUsage    s3.UsageReporter
+	dashboard       bool
+	listener        bool
+	shareService    share.Storer
 }
 
-func NewAPIServer(apiConfig config.APIConfig, s3Service s3.DownloadUploader, fileService file.Storer) *APIServer {
-	return &APIServer{
+// Option sets one of the APIServer's optional dependencies. Routes backed by a dependency
+// that wasn't given aren't registered
+type Option func(*APIServer)
+
+// WithCategoryService enables the category catalogue routes
+func WithCategoryService(categoryService file.CategoryStorer) Option {
+	return func(a *APIServer) {
+		a.categoryService = categoryService
+	}
+}
+
+// WithFeedbackService enables the favorite and rating routes
+func WithFeedbackService(feedbackService file.FeedbackStorer) Option {
+	return func(a *APIServer) {
+		a.feedbackService = feedbackService
+	}
+}
+
+// WithBucket sets the bucket audio is uploaded to and streamed from
+func WithBucket(bucket string) Option {
+	return func(a *APIServer) {
+		a.bucket = bucket
+	}
+}
+
+// WithPlaylistService enables the playlist and soundboard routes
+func WithPlaylistService(playlistService playlist.Storer) Option {
+	return func(a *APIServer) {
+		a.playlistService = playlistService
+	}
+}
+
+// WithStats records plays and downloads with the recorder and enables the analytics routes
+func WithStats(recorder stats.EventRecorder, reporter stats.Reporter) Option {
+	return func(a *APIServer) {
+		a.statsRecorder = recorder
+		a.statsService = reporter
+	}
+}
+
+// WithJobs processes uploads in the background through the queue and enables the job status route
+func WithJobs(queue jobs.Queue) Option {
+	return func(a *APIServer) {
+		a.jobQueue = queue
+	}
+}
+
+// WithTranscripts enables the transcript route
+func WithTranscripts(transcripts transcript.Finder) Option {
+	return func(a *APIServer) {
+		a.transcripts = transcripts
+	}
+}
+
+// WithMetrics counts and times every request. The metrics are served at the path unless it's
+// empty, when they have a listener of their own
+func WithMetrics(m *metrics.Metrics, path string) Option {
+	return func(a *APIServer) {
+		a.metrics = m
+		a.metricsPath = path
+	}
+}
+
+// WithTracing starts a span for every request, continuing the trace of callers sending a W3C
+// traceparent header
+func WithTracing(serviceName string) Option {
+	return func(a *APIServer) {
+		a.serviceName = serviceName
+	}
+}
+
+// WithHealth makes /readyz run the checks. It always reports ready without them
+func WithHealth(h *health.Health) Option {
+	return func(a *APIServer) {
+		a.health = h
+	}
+}
+
+func NewAPIServer(apiConfig config.APIConfig, s3Service s3.Storage, fileService file.Storer, opts ...Option) *APIServer {
+	server := &APIServer{
 		basePath:    apiConfig.Path,
 		listenAddr:  apiConfig.Address,
 		env:         apiConfig.Env,
+		apiKeys:     newAPIKeys(apiConfig.Auth),
+		settings:    newServerSettings(apiConfig),
 		s3Service:   s3Service,
 		fileService: fileService,
 	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+	return server
 }
 
 // Ping godoc
@@ -46,102 +168,489 @@ func (a *APIServer) ping(c *gin.Context) {
 // GET /api/v1/audio
 // This endpoint will simply return the metadata of all files; no audio will be returned here
 func (a *APIServer) getAudio(c *gin.Context) {
+	sort, err := file.ParseSort(c.Query("sort"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	// tags can be given multiple times (?tag=funny&tag=short); every tag must match unless match=any
+	filter := file.Filter{
+		Tags:         c.QueryArray("tag"),
+		MatchAllTags: !strings.EqualFold(c.Query("match"), "any"),
+		Category:     file.Slugify(c.Query("category")),
+		Search:       c.Query("q"),
+		Sort:         sort,
+		Limit:        limit,
+		Offset:       offset,
+	}
+
 	// call to fileService to get a list of filenames (or perhaps s3links)
-	metadatum, err := a.fileService.FindAll(c)
+	metadatum, err := a.fileService.FindAll(c, filter)
 	if err != nil {
-		log.Error("Could not retrieve entries", "err", err)
-		c.AbortWithError(http.StatusInternalServerError, InternalServerError(""))
+		log.ErrorContext(c, "Could not retrieve entries", "err", err)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if notModified(c, a.settings.cacheControl.Metadata, listingETag(metadatum), time.Time{}) {
 		return
 	}
 
 	c.IndentedJSON(http.StatusOK, metadatum)
 }
 
-// GET /audio/{id, name}
-func (a *APIServer) getAudioByIdAndName(c *gin.Context) {
-	var err error
+// GET /api/v1/audio/{id}/download
+// Sends the audio of a file as an attachment
+func (a *APIServer) downloadAudio(c *gin.Context) {
+	id := c.Param("id")
 
-	id := c.Query("id")
-	name := c.Query("name")
-
-	if id == "" && name == "" {
-		err = errors.New("neither id or name params given")
-		log.Error("request failed", "err", err, "request", c.Request.RequestURI)
-		c.AbortWithError(http.StatusBadRequest, err)
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
+		abortWithDomainError(c, err)
 		return
 	}
-
-	file_info, err := a.fileService.FindById(c, id)
-	if err != nil {
-		log.Error("request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
-		c.AbortWithError(http.StatusNotFound, err)
+	object, ok := a.openAudio(c, fileInfo, a.settings.cacheControl.Audio)
+	if !ok {
 		return
 	}
+	defer object.Body.Close()
 
-	file, err := a.s3Service.DownloadObject(c, name, file_info.S3Link)
-	print(file)
-
+	a.recordEvent(fileInfo.ID, stats.KindDownload)
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, strings.ReplaceAll(fileInfo.Filename, `"`, "")),
+	}
+	c.DataFromReader(http.StatusOK, object.Size, audioContentType(object, fileInfo), object.Body, headers)
 }
 
 // POST /audio
 func (a *APIServer) createAudio(c *gin.Context) {
-	
-	err := c.Request.ParseMultipartForm(MegaByte) // 1 MB
+
+	// the body is capped at maxBodyBytes by limitBody, ParseMultipartForm reports going over it
+	err := c.Request.ParseMultipartForm(uploadMemory)
 	if err != nil {
-		log.Error("could not parse form in request", "err", err)
-		c.AbortWithError(http.StatusBadRequest, err)
+		log.ErrorContext(c, "could not parse form in request", "err", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithDomainError(c, err)
+			return
+		}
+		abortWithError(c, http.StatusBadRequest, err)
+		return
 	}
-	
-	file, header, err := c.Request.FormFile("file")
+
+	audioFile, header, err := c.Request.FormFile("file")
 	if err != nil {
-		log.Error("Could not retrieve upload file from request", "err", err)
-		c.AbortWithError(http.StatusInternalServerError, InternalServerError("Could not retrieve upload file from request"))
+		log.ErrorContext(c, "Could not retrieve upload file from request", "err", err)
+		abortWithError(c, http.StatusBadRequest, fmt.Errorf("the audio must be uploaded in the file form field: %w", err))
 		return
 	}
 
-	defer file.Close()
+	defer audioFile.Close()
 
 	// content goes to S3
-	content, err := io.ReadAll(file)
+	content, err := io.ReadAll(audioFile)
+	if err != nil {
+		log.ErrorContext(c, "could not read file", "err", err)
+	}
+
+	if int64(len(content)) > a.settings.maxBodyBytes {
+		log.ErrorContext(c, ErrFileTooLarge.Error())
+		abortWithError(c, http.StatusRequestEntityTooLarge, ErrFileTooLarge)
+		return
+	}
+
+	if !a.consumeQuota(c, int64(len(content))) {
+		return
+	}
+	defer a.refundFailedUpload(c, int64(len(content)))
+
+	// tags may be sent as repeated form fields and/or as a comma separated list
+	fileInfo := fileRecordFromForm(header, c.Request.MultipartForm.Value)
+	fileInfo.Checksum, _ = file.Checksum(bytes.NewReader(content))
+	fileInfo.Size = int64(len(content))
+
+	// make call to s3Service first so that a saved record always points at an existing object
+	fileInfo.S3Link = s3.NewObjectName(header.Filename)
+	contentType := s3.GetContentType(filepath.Ext(header.Filename))
+	err = a.s3Service.UploadStream(c, fileInfo.S3Link, a.bucket, bytes.NewReader(content), int64(len(content)), contentType)
 	if err != nil {
-		log.Error("could not read file", "err", err)
+		log.ErrorContext(c, "could not upload file to storage", "err", err, "file", header.Filename)
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not upload file to storage: %w", err))
+		return
 	}
 
-	if len(content) > int(MegaByte) {
-		err = errors.New("file size too large")
-		log.Error(err.Error())
-		c.AbortWithError(http.StatusBadRequest, err)
+	if a.jobQueue != nil {
+		a.saveForProcessing(c, fileInfo)
+		return
 	}
 
 	// make call to fileInfo DB, returns required fileInfo object
-	a.fileService.Save(c, file)
-	// make call to s3Service, get bucket and filename from returned fileInfo object, supply content
-	// a.s3Service.UploadObject(c, )
-	log.Info("file related stuff", "file", string(content), "header", header)
-	c.IndentedJSON(http.StatusCreated, nil)
+	saved, err := a.fileService.Save(c, audioFile, fileInfo)
+	if errors.Is(err, file.ErrUnknownCategory) {
+		log.ErrorContext(c, "upload given an unknown category", "err", err, "category", fileInfo.Category)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.ErrorContext(c, "could not save file information", "err", err, "file", header.Filename)
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not save file information: %w", err))
+		return
+	}
+	log.InfoContext(c, "file related stuff", "size", len(content), "header", header)
+	c.IndentedJSON(http.StatusCreated, saved)
+}
+
+// GET /api/v1/audio/{id}/stream
+// Streams the audio of a file. Range requests are honoured when storage allows seeking so players can scrub
+func (a *APIServer) streamAudio(c *gin.Context) {
+	id := c.Param("id")
+
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	a.serveAudio(c, fileInfo, a.settings.cacheControl.Audio)
+}
+
+// serveAudio streams the file's audio from storage, answering range and conditional requests
+func (a *APIServer) serveAudio(c *gin.Context, fileInfo *file.FileRecord, cacheControl string) {
+	object, ok := a.openAudio(c, fileInfo, cacheControl)
+	if !ok {
+		return
+	}
+	defer object.Body.Close()
+
+	if isFirstRange(c) {
+		a.recordEvent(fileInfo.ID, stats.KindPlay)
+	}
+
+	contentType := audioContentType(object, fileInfo)
+	c.Header("Content-Type", contentType)
+
+	if seeker, ok := object.Body.(io.ReadSeeker); ok {
+		// the ETag and upload date set above also answer If-Range
+		http.ServeContent(c.Writer, c.Request, fileInfo.Filename, fileInfo.UploadDate, seeker)
+		return
+	}
+	c.DataFromReader(http.StatusOK, object.Size, contentType, object.Body, nil)
+}
+
+// openAudio opens the file's audio in storage unless the client has it already. It's false once the
+// request has been answered, with a 304 or an error; otherwise the caller closes the object's body
+func (a *APIServer) openAudio(c *gin.Context, fileInfo *file.FileRecord, cacheControl string) (*s3.Object, bool) {
+	// files with a checksum are revalidated without a trip to storage
+	if fileInfo.Checksum != "" && notModified(c, cacheControl, audioETag(fileInfo, nil), fileInfo.UploadDate) {
+		return nil, false
+	}
+
+	object, err := a.s3Service.StreamObject(c, fileInfo.S3Link, a.bucket)
+	if err != nil {
+		log.ErrorContext(c, "could not retrieve audio from storage", "err", err, "id", fileInfo.ID, "object", fileInfo.S3Link)
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not retrieve audio from storage: %w", err))
+		return nil, false
+	}
+	if fileInfo.Checksum == "" && notModified(c, cacheControl, audioETag(fileInfo, object), fileInfo.UploadDate) {
+		object.Body.Close()
+		return nil, false
+	}
+	return object, true
+}
+
+// audioContentType prefers the content type stored with the object, falling back to the file's extension
+func audioContentType(object *s3.Object, fileInfo *file.FileRecord) string {
+	if object.ContentType != "" && object.ContentType != "application/octet-stream" {
+		return object.ContentType
+	}
+	return s3.GetContentType(filepath.Ext(fileInfo.S3Link))
+}
+
+// absoluteURL turns a path under the API's base path into an absolute URL for the request's host
+func (a *APIServer) absoluteURL(c *gin.Context, path string) string {
+	return a.siteURL(c, a.basePath+path)
+}
+
+// siteURL turns a path into an absolute URL on the configured public URL, or else the request's
+// host. Like X-Forwarded-For, X-Forwarded-Proto is only believed from the trusted proxies
+func (a *APIServer) siteURL(c *gin.Context, path string) string {
+	if a.settings.publicURL != "" {
+		return a.settings.publicURL + path
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); (forwarded == "http" || forwarded == "https") && a.fromTrustedProxy(c) {
+		scheme = forwarded
+	}
+	return scheme + "://" + c.Request.Host + path
+}
+
+// fileRecordFromForm builds the information known about an upload before its metadata is parsed
+func fileRecordFromForm(header *multipart.FileHeader, values map[string][]string) file.FileRecord {
+	var category string
+	if len(values["category"]) > 0 {
+		category = values["category"][0]
+	}
+
+	return file.FileRecord{
+		Filename: header.Filename,
+		FileType: strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), "."),
+		Category: category,
+		Tags:     values["tags"],
+	}
+}
+
+// GET /api/v1/tags
+// Returns every tag along with how many files use it
+func (a *APIServer) getTags(c *gin.Context) {
+	tags, err := a.fileService.FindAllTags(c)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve tags", "err", err)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, tags)
+}
+
+type tagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// POST /api/v1/audio/{id}/tags
+func (a *APIServer) addAudioTags(c *gin.Context) {
+	id := c.Param("id")
+
+	var request tagsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.ErrorContext(c, "invalid tags request", "err", err, "id", id)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.fileService.AddTags(c, id, request.Tags); err != nil {
+		log.ErrorContext(c, "could not tag file", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	a.respondWithFile(c, id, http.StatusOK)
+}
+
+// DELETE /api/v1/audio/{id}/tags/{tag}
+func (a *APIServer) deleteAudioTag(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := a.fileService.RemoveTags(c, id, []string{c.Param("tag")}); err != nil {
+		log.ErrorContext(c, "could not remove tag from file", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondWithFile looks up the file with the given id and writes it out with the given status
+func (a *APIServer) respondWithFile(c *gin.Context, id string, status int) {
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
+		abortWithDomainError(c, err)
+		return
+	}
+	if encoded, err := json.Marshal(fileInfo); err == nil && notModified(c, a.settings.cacheControl.Metadata, weakETag(string(encoded)), time.Time{}) {
+		return
+	}
+
+	c.IndentedJSON(status, fileInfo)
+}
+
+// GET /api/v1/audio/{id}
+// Returns the metadata of a single file
+func (a *APIServer) getAudioById(c *gin.Context) {
+	a.respondWithFile(c, c.Param("id"), http.StatusOK)
+}
+
+// PATCH /api/v1/audio/{id}
+// Corrects the metadata of a file. Admins only
+func (a *APIServer) updateAudio(c *gin.Context) {
+	id := c.Param("id")
+
+	var update file.MetadataUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		log.ErrorContext(c, "invalid metadata update", "err", err, "id", id)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := a.fileService.UpdateMetadata(c, id, update)
+	if err != nil {
+		log.ErrorContext(c, "could not update the metadata", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, updated)
 }
 
 // DELETE /api/v1/audio/{id}
+// Deletes the information of a file. Its audio is left in storage
+func (a *APIServer) deleteAudio(c *gin.Context) {
+	id := c.Param("id")
 
-// StartRouter starts up a Gin router for the API
-func (a *APIServer) StartRouter() {
-	r := gin.Default()
-	if os.Getenv(gin.EnvGinMode) == "" {
-		mode := ginEnvMode(a.env)
-		gin.SetMode(mode)
+	if err := a.fileService.Delete(c, id); err != nil {
+		log.ErrorContext(c, "Could not delete file", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// router registers the routes backed by the server's dependencies
+func (a *APIServer) router() (*gin.Engine, error) {
+	spec, err := loadOpenAPI(a.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("loading the OpenAPI document: %w", err)
+	}
+	openAPI, err := serveOpenAPI(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	r := gin.New()
+	// handlers pass the gin.Context along as the context, it must carry the request's span
+	r.ContextWithFallback = true
+	// ClientIP keys rate limits and quotas, only the configured proxies may say who the client is
+	if err := r.SetTrustedProxies(a.settings.trustedProxies); err != nil {
+		return nil, fmt.Errorf("setting the trusted proxies: %w", err)
+	}
+	// probes would drown out the requests worth reading
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/healthz", "/readyz"}}))
+	if a.serviceName != "" {
+		r.Use(otelgin.Middleware(a.serviceName))
+	}
+	if a.metrics != nil {
+		r.Use(a.metrics.Middleware())
+	}
+	if a.env == "prod" {
+		r.Use(strictTransportSecurity(a.settings.tls.HSTSMaxAge))
+	}
+	r.Use(requestID, a.handleErrors, gin.CustomRecovery(recoverPanic), limitBody(a.settings.maxBodyBytes))
+	r.NoRoute(routeNotFound)
+	r.GET("/healthz", liveness)
+	r.GET("/readyz", a.readiness)
+	r.GET("/openapi.json", openAPI)
+	r.GET("/docs", serveDocs)
+	if a.dashboard {
+		r.GET(dashboardPath, func(c *gin.Context) { c.Redirect(http.StatusMovedPermanently, dashboardPath+"/") })
+		r.GET(dashboardPath+"/*filepath", a.serveDashboard)
+	}
+	if a.listener {
+		r.GET(quipsPath, a.browseQuips)
+		r.GET(quipsPath+"/:id", a.showQuip)
+		r.GET(embedPath+"/:id", a.embedQuip)
+		r.GET(oEmbedPath, a.getOEmbed)
+		r.GET(listenerAssetsPath+"/*filepath", serveListenerAssets)
+	}
+	if a.shareService != nil {
+		r.GET(sharePath+"/:token", a.openShareLink)
+	}
+	if a.metrics != nil && a.metricsPath != "" {
+		r.GET(a.metricsPath, gin.WrapH(a.metrics.Handler()))
 	}
 
 	// setup v1 routes
 	v1 := r.Group(a.basePath)
+	v1.Use(a.authenticate)
+	if a.limiter != nil {
+		v1.Use(a.rateLimit)
+	}
+	v1.Use(validateRequests(spec, a.basePath))
 	{
 		v1.GET("/ping", a.ping)
 		v1.GET("/audio/", a.getAudio)
-		// v1.GET("/audio/:id", a.getAudioById)
+		v1.GET("/audio/:id", a.getAudioById)
 		v1.POST("/audio", a.createAudio)
-		// v1.DELETE("/audio/:id", a.deleteAudio)
+		v1.GET("/audio/:id/stream", a.streamAudio)
+		v1.GET("/audio/:id/download", a.downloadAudio)
+		v1.GET("/audio/:id/cover", a.getAudioCover)
+		v1.POST("/audio/:id/tags", requireRole(RoleUser), a.addAudioTags)
+		v1.DELETE("/audio/:id/tags/:tag", requireRole(RoleUser), a.deleteAudioTag)
+		v1.GET("/tags", a.getTags)
+		v1.PATCH("/audio/:id", requireRole(RoleAdmin), a.updateAudio)
+		v1.DELETE("/audio/:id", requireRole(RoleAdmin), a.deleteAudio)
+	}
+
+	if a.storageUsage != nil {
+		v1.GET("/admin/storage", requireRole(RoleAdmin), a.getStorageUsage)
+	}
+
+	if a.categoryService != nil {
+		v1.GET("/categories", a.getCategories)
+		v1.GET("/categories/:slug", a.getCategory)
+		v1.GET("/feeds/category/:feed", a.getCategoryFeed)
+
+		admin := v1.Group("", requireRole(RoleAdmin))
+		admin.POST("/categories", a.createCategory)
+		admin.PUT("/categories/:slug", a.updateCategory)
+		admin.DELETE("/categories/:slug", a.deleteCategory)
+	}
+
+	if a.feedbackService != nil {
+		users := v1.Group("", requireRole(RoleUser))
+		users.PUT("/audio/:id/favorite", a.addFavorite)
+		users.DELETE("/audio/:id/favorite", a.removeFavorite)
+		users.PUT("/audio/:id/rating", a.rateAudio)
+		users.DELETE("/audio/:id/rating", a.removeRating)
+		users.GET("/me/favorites", a.getFavorites)
+	}
+
+	if a.playlistService != nil {
+		v1.GET("/playlists", a.getPlaylists)
+		v1.GET("/playlists/:id", a.getPlaylist)
+		v1.GET("/playlists/:id/export", a.exportPlaylist)
+		v1.GET("/feeds/playlist/:feed", a.getPlaylistFeed)
+
+		users := v1.Group("", requireRole(RoleUser))
+		users.POST("/playlists", a.createPlaylist)
+		users.PATCH("/playlists/:id", a.updatePlaylist)
+		users.DELETE("/playlists/:id", a.deletePlaylist)
+		users.POST("/playlists/:id/items", a.addPlaylistItem)
+		users.PUT("/playlists/:id/items", a.replacePlaylistItems)
+		users.DELETE("/playlists/:id/items/:position", a.removePlaylistItem)
+	}
+
+	if a.shareService != nil {
+		users := v1.Group("", requireRole(RoleUser))
+		users.POST("/audio/:id/share", a.createShareLink)
+		users.GET("/me/shares", a.getShareLinks)
+		users.DELETE("/me/shares/:id", a.revokeShareLink)
+	}
+
+	if a.jobQueue != nil {
+		v1.GET("/jobs/:id", a.getJob)
+	}
+
+	if a.transcripts != nil {
+		v1.GET("/audio/:id/transcript", a.getTranscript)
+	}
+
+	if a.statsService != nil {
+		v1.GET("/audio/:id/stats", a.getAudioStats)
+		v1.GET("/stats/top", a.getTopStats)
+		v1.GET("/stats/trending", a.getTrendingStats)
 	}
 
-	r.Run(a.listenAddr)
+	return r, nil
 }
 
 func ginEnvMode(env string) string {
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// taggingStorer tags the quip with ID 1, the others don't exist
+type taggingStorer struct {
+	quipStorer
+	tagged  []string
+	removed []string
+}
+
+func (s *taggingStorer) AddTags(ctx context.Context, id string, tags []string) error {
+	if id != "1" {
+		return file.NoRowsFoundError("")
+	}
+	s.tagged = append(s.tagged, tags...)
+	return nil
+}
+
+func (s *taggingStorer) RemoveTags(ctx context.Context, id string, tags []string) error {
+	if id != "1" {
+		return file.NoRowsFoundError("")
+	}
+	s.removed = append(s.removed, tags...)
+	return nil
+}
+
+func TestAudioTagRoutes(t *testing.T) {
+	testCases := []struct {
+		name            string
+		method          string
+		path            string
+		apiKey          string
+		expectedCode    int
+		expectedTagged  []string
+		expectedRemoved []string
+	}{
+		{name: "AddAnonymous", method: http.MethodPost, path: "/audio/1/tags", expectedCode: http.StatusUnauthorized},
+		{name: "Add", method: http.MethodPost, path: "/audio/1/tags", apiKey: "user-key", expectedCode: http.StatusOK, expectedTagged: []string{"calm"}},
+		{name: "AddUnknownQuip", method: http.MethodPost, path: "/audio/2/tags", apiKey: "user-key", expectedCode: http.StatusNotFound},
+		{name: "AddNonNumericID", method: http.MethodPost, path: "/audio/abc/tags", apiKey: "user-key", expectedCode: http.StatusBadRequest},
+		{name: "RemoveAnonymous", method: http.MethodDelete, path: "/audio/1/tags/calm", expectedCode: http.StatusUnauthorized},
+		{name: "Remove", method: http.MethodDelete, path: "/audio/1/tags/calm", apiKey: "user-key", expectedCode: http.StatusNoContent, expectedRemoved: []string{"calm"}},
+		{name: "RemoveUnknownQuip", method: http.MethodDelete, path: "/audio/2/tags/calm", apiKey: "user-key", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			storer := &taggingStorer{}
+			r, err := NewAPIServer(config.APIConfig{Path: testBasePath, Auth: testAuth}, nil, storer).router()
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(tc.method, testBasePath+tc.path, strings.NewReader(`{"tags":["calm"]}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedTagged, storer.tagged)
+			assert.Equal(t, tc.expectedRemoved, storer.removed)
+		})
+	}
+}
+
+// uploadStorage keeps the size of the last object uploaded
+type uploadStorage struct {
+	s3.Storage
+	uploaded int64
+}
+
+func (s *uploadStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	s.uploaded = size
+	return nil
+}
+
+// savingStorer saves every record it's given
+type savingStorer struct {
+	file.Storer
+}
+
+func (savingStorer) Save(ctx context.Context, audioFile multipart.File, fileInfo file.FileRecord) (*file.FileRecord, error) {
+	fileInfo.ID = 1
+	return &fileInfo, nil
+}
+
+func TestCreateAudio_Size(t *testing.T) {
+	testCases := []struct {
+		name         string
+		size         int
+		maxBodyBytes int64
+		expectedCode int
+	}{
+		// audio runs to megabytes
+		{name: "WithinDefaultLimit", size: 5 << 20, expectedCode: http.StatusCreated},
+		{name: "OverConfiguredLimit", size: 64 << 10, maxBodyBytes: 32 << 10, expectedCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			storage := &uploadStorage{}
+			r, err := NewAPIServer(config.APIConfig{Path: testBasePath, MaxBodyBytes: tc.maxBodyBytes}, storage, savingStorer{}).router()
+			assert.NoError(t, err)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, _ := writer.CreateFormFile("file", "quip.mp3")
+			part.Write(bytes.Repeat([]byte("a"), tc.size))
+			writer.Close()
+			req := httptest.NewRequest(http.MethodPost, testBasePath+"/audio", &body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode == http.StatusCreated {
+				assert.Equal(t, int64(tc.size), storage.uploaded)
+			}
+		})
+	}
+}
+
+func TestSiteURL(t *testing.T) {
+	testCases := []struct {
+		name           string
+		publicURL      string
+		trustedProxies []string
+		forwardedProto string
+		expectedURL    string
+	}{
+		{name: "RequestHost", expectedURL: "http://example.com/s/token"},
+		// httptest requests come from 192.0.2.1
+		{name: "UntrustedForwardedProto", forwardedProto: "https", expectedURL: "http://example.com/s/token"},
+		{name: "TrustedForwardedProto", trustedProxies: []string{"192.0.2.1"}, forwardedProto: "https", expectedURL: "https://example.com/s/token"},
+		{name: "TrustedInvalidProto", trustedProxies: []string{"192.0.2.0/24"}, forwardedProto: "javascript", expectedURL: "http://example.com/s/token"},
+		{name: "PublicURL", publicURL: "https://quips.example.org/", forwardedProto: "http", expectedURL: "https://quips.example.org/s/token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			server := NewAPIServer(config.APIConfig{Path: testBasePath, PublicURL: tc.publicURL, TrustedProxies: tc.trustedProxies}, nil, quipStorer{})
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/s/token", nil)
+			if tc.forwardedProto != "" {
+				c.Request.Header.Set("X-Forwarded-Proto", tc.forwardedProto)
+			}
+
+			assert.Equal(t, tc.expectedURL, server.siteURL(c, "/s/token"))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+)
+
+// Roles a user can have. Admins can do everything a user can
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const (
+	apiKeyHeader   = "X-API-Key"
+	userContextKey = "user"
+)
+
+var ErrUnauthenticated = errors.New("a valid API key is required")
+var ErrForbidden = errors.New("the API key's role isn't allowed to do this")
+
+// User is the identity an API key authenticates as
+type User struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type apiKey struct {
+	key  []byte
+	user User
+}
+
+func newAPIKeys(cfg config.AuthConfig) []apiKey {
+	keys := []apiKey{}
+	for _, k := range cfg.Keys {
+		if k.Key == "" {
+			log.Warn("skipping API key without a value", "user", k.User, "keyVar", k.KeyVar)
+			continue
+		}
+
+		role := strings.ToLower(k.Role)
+		if role == "" {
+			role = RoleUser
+		}
+		keys = append(keys, apiKey{key: []byte(k.Key), user: User{Name: k.User, Role: role}})
+	}
+	return keys
+}
+
+// requestAPIKey reads the key from the X-API-Key header or a bearer Authorization header
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		return key
+	}
+
+	authorization := c.GetHeader("Authorization")
+	if scheme, token, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// authenticate identifies the caller from their API key. Anonymous requests are let through,
+// routes that need a user are guarded by requireRole
+func (a *APIServer) authenticate(c *gin.Context) {
+	key := requestAPIKey(c)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare(k.key, []byte(key)) == 1 {
+			c.Set(userContextKey, k.user)
+			c.Next()
+			return
+		}
+	}
+
+	log.WarnContext(c, "request made with an unknown API key", "request", c.Request.RequestURI)
+	abortWithError(c, http.StatusUnauthorized, ErrUnauthenticated)
+}
+
+// requireRole rejects requests that aren't authenticated as a user with the given role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			abortWithError(c, http.StatusUnauthorized, ErrUnauthenticated)
+			return
+		}
+
+		if user.Role != role && user.Role != RoleAdmin {
+			log.Warn("request made without the required role", "user", user.Name, "role", user.Role, "required", role)
+			abortWithError(c, http.StatusForbidden, ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUser returns the user the request was authenticated as
+func currentUser(c *gin.Context) (User, bool) {
+	value, ok := c.Get(userContextKey)
+	if !ok {
+		return User{}, false
+	}
+	user, ok := value.(User)
+	return user, ok
+}
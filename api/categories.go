@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// GET /api/v1/categories
+// Returns the category tree, or a flat list sorted by sort order when flat=true
+func (a *APIServer) getCategories(c *gin.Context) {
+	var categories []*file.Category
+	var err error
+
+	if c.Query("flat") == "true" {
+		categories, err = a.categoryService.FindAllCategories(c)
+	} else {
+		categories, err = a.categoryService.CategoryTree(c)
+	}
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve categories", "err", err)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, categories)
+}
+
+// GET /api/v1/categories/{slug}
+func (a *APIServer) getCategory(c *gin.Context) {
+	slug := c.Param("slug")
+
+	category, err := a.categoryService.FindCategoryBySlug(c, slug)
+	if err != nil {
+		log.ErrorContext(c, "request for the following category was not found", "err", err, "slug", slug)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, category)
+}
+
+// POST /api/v1/categories
+func (a *APIServer) createCategory(c *gin.Context) {
+	var category file.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		log.ErrorContext(c, "invalid category request", "err", err)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.categoryService.CreateCategory(c, category)
+	if err != nil {
+		log.ErrorContext(c, "could not create category", "err", err, "category", category.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, saved)
+}
+
+// PUT /api/v1/categories/{slug}
+func (a *APIServer) updateCategory(c *gin.Context) {
+	slug := c.Param("slug")
+
+	var category file.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		log.ErrorContext(c, "invalid category request", "err", err, "slug", slug)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.categoryService.UpdateCategory(c, slug, category)
+	if err != nil {
+		log.ErrorContext(c, "could not update category", "err", err, "slug", slug)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, saved)
+}
+
+// DELETE /api/v1/categories/{slug}
+func (a *APIServer) deleteCategory(c *gin.Context) {
+	slug := c.Param("slug")
+
+	if err := a.categoryService.DeleteCategory(c, slug); err != nil {
+		log.ErrorContext(c, "could not delete category", "err", err, "slug", slug)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
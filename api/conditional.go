@@ -0,0 +1,96 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// audioETag is the strong validator of a file's audio: its checksum, or the ETag storage gave
+// the object for files stored without one. Audio never changes, so either identifies its bytes
+func audioETag(fileInfo *file.FileRecord, object *s3.Object) string {
+	if fileInfo.Checksum != "" {
+		return `"` + fileInfo.Checksum + `"`
+	}
+	if object != nil && object.ETag != "" {
+		return `"` + strings.Trim(object.ETag, `"`) + `"`
+	}
+	return ""
+}
+
+// listingETag is the weak validator of a listing, a hash of the records as they're answered so
+// that it changes with any of them. The newest upload date and the row count would miss edits,
+// ratings and processing, and a delete paired with an upload, so listings get no Last-Modified
+// either. None is given when they can't be encoded
+func listingETag(records []*file.FileRecord) string {
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return ""
+	}
+	return weakETag(string(encoded))
+}
+
+// weakETag hashes the parts into a weak validator
+func weakETag(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the Cache-Control and validators of a GET response, leaving out the empty
+// ones, then answers 304 when the request's preconditions show the client has it already.
+// Handlers must return without writing a body when it does
+func notModified(c *gin.Context, cacheControl, etag string, lastModified time.Time) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	c.Header("Cache-Control", cacheControl)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !fresh(c.Request, etag, lastModified) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// fresh evaluates If-None-Match, or If-Modified-Since when it's absent (RFC 9110 13.2.2)
+func fresh(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etag != "" && etagMatches(match, etag)
+	}
+	since := r.Header.Get("If-Modified-Since")
+	if since == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(since)
+	// Last-Modified is sent to the second, a client sending it back has the same version
+	return err == nil && !lastModified.Truncate(time.Second).After(t)
+}
+
+// etagMatches compares weakly, as If-None-Match does, against a list of ETags or *
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
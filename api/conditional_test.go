@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+var uploadDate = time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)
+
+// recordsStorer answers lookups with its records, the other methods must not be called
+type recordsStorer struct {
+	file.Storer
+	records []*file.FileRecord
+}
+
+func (r recordsStorer) FindById(ctx context.Context, id string) (*file.FileRecord, error) {
+	return r.records[0], nil
+}
+
+func (r recordsStorer) FindAll(ctx context.Context, filter file.Filter) ([]*file.FileRecord, error) {
+	return r.records, nil
+}
+
+// countingStorage serves the same audio every time, counting how often it's asked to
+type countingStorage struct {
+	s3.Storage
+	streams int
+}
+
+func (s *countingStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	s.streams++
+	return &s3.Object{
+		Body:        io.NopCloser(strings.NewReader("audio")),
+		Size:        5,
+		ContentType: s3.MP3Header,
+		ETag:        "object-etag",
+	}, nil
+}
+
+func TestConditionalAudio(t *testing.T) {
+	testCases := []struct {
+		name            string
+		checksum        string
+		headers         map[string]string
+		expectedCode    int
+		expectedETag    string
+		expectedStreams int
+	}{
+		{
+			name:            "NoPreconditions",
+			checksum:        "abc",
+			expectedCode:    http.StatusOK,
+			expectedETag:    `"abc"`,
+			expectedStreams: 1,
+		},
+		{
+			name:         "ETagMatches",
+			checksum:     "abc",
+			headers:      map[string]string{"If-None-Match": `"xyz", "abc"`},
+			expectedCode: http.StatusNotModified,
+			expectedETag: `"abc"`,
+		},
+		{
+			name:         "WeakETagMatches",
+			checksum:     "abc",
+			headers:      map[string]string{"If-None-Match": `W/"abc"`},
+			expectedCode: http.StatusNotModified,
+			expectedETag: `"abc"`,
+		},
+		{
+			name:            "ETagChanged",
+			checksum:        "abc",
+			headers:         map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": uploadDate.Format(http.TimeFormat)},
+			expectedCode:    http.StatusOK,
+			expectedETag:    `"abc"`,
+			expectedStreams: 1,
+		},
+		{
+			name:         "NotModifiedSince",
+			checksum:     "abc",
+			headers:      map[string]string{"If-Modified-Since": uploadDate.Add(time.Hour).Format(http.TimeFormat)},
+			expectedCode: http.StatusNotModified,
+			expectedETag: `"abc"`,
+		},
+		{
+			name:            "ModifiedSince",
+			checksum:        "abc",
+			headers:         map[string]string{"If-Modified-Since": uploadDate.Add(-time.Hour).Format(http.TimeFormat)},
+			expectedCode:    http.StatusOK,
+			expectedETag:    `"abc"`,
+			expectedStreams: 1,
+		},
+		{
+			name:            "NoChecksumFallsBackToObjectETag",
+			headers:         map[string]string{"If-None-Match": `"object-etag"`},
+			expectedCode:    http.StatusNotModified,
+			expectedETag:    `"object-etag"`,
+			expectedStreams: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, route := range []string{"/stream", "/download"} {
+			t.Run(tc.name+route, func(t *testing.T) {
+				gin.SetMode(gin.TestMode)
+				storage := &countingStorage{}
+				storer := recordsStorer{records: []*file.FileRecord{{ID: 1, S3Link: "a.mp3", Checksum: tc.checksum, UploadDate: uploadDate}}}
+				server := NewAPIServer(config.APIConfig{Path: testBasePath}, storage, storer)
+				r, err := server.router()
+				assert.NoError(t, err)
+
+				req := httptest.NewRequest(http.MethodGet, testBasePath+"/audio/1"+route, nil)
+				for name, value := range tc.headers {
+					req.Header.Set(name, value)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.expectedCode, w.Code)
+				assert.Equal(t, tc.expectedETag, w.Header().Get("ETag"))
+				assert.Equal(t, DefaultAudioCacheControl, w.Header().Get("Cache-Control"))
+				assert.Equal(t, uploadDate.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+				assert.Equal(t, tc.expectedStreams, storage.streams)
+				if tc.expectedCode == http.StatusNotModified {
+					assert.Empty(t, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestConditionalMetadata(t *testing.T) {
+	records := []*file.FileRecord{
+		{ID: 1, Filename: "a.mp3", UploadDate: uploadDate},
+		{ID: 2, Filename: "b.mp3", UploadDate: uploadDate.Add(time.Hour)},
+	}
+
+	testCases := []struct {
+		name    string
+		path    string
+		changed []*file.FileRecord
+	}{
+		{
+			name:    "ListingFileAdded",
+			path:    "/audio/",
+			changed: append(records, &file.FileRecord{ID: 3, UploadDate: uploadDate.Add(2 * time.Hour)}),
+		},
+		{
+			name:    "ListingFileRemoved",
+			path:    "/audio/",
+			changed: records[1:],
+		},
+		{
+			name:    "ListingFileRetitled",
+			path:    "/audio/",
+			changed: []*file.FileRecord{records[0], {ID: 2, Filename: "b.mp3", Metadata: file.Metadata{Title: "Bee"}, UploadDate: uploadDate.Add(time.Hour)}},
+		},
+		{
+			name:    "ListingFileReady",
+			path:    "/audio/",
+			changed: []*file.FileRecord{records[0], {ID: 2, Filename: "b.mp3", Status: file.StatusReady, UploadDate: uploadDate.Add(time.Hour)}},
+		},
+		{
+			name:    "ListingFileRated",
+			path:    "/audio/",
+			changed: []*file.FileRecord{records[0], {ID: 2, Filename: "b.mp3", Rating: file.Rating{Average: 5, Count: 1}, UploadDate: uploadDate.Add(time.Hour)}},
+		},
+		{
+			name:    "FileChanged",
+			path:    "/audio/1",
+			changed: []*file.FileRecord{{ID: 1, Filename: "a.mp3", Tags: []string{"calm"}, UploadDate: uploadDate}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			get := func(records []*file.FileRecord, etag string) *httptest.ResponseRecorder {
+				r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, recordsStorer{records: records}).router()
+				assert.NoError(t, err)
+				req := httptest.NewRequest(http.MethodGet, testBasePath+tc.path, nil)
+				if etag != "" {
+					req.Header.Set("If-None-Match", etag)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+
+			w := get(records, "")
+			etag := w.Header().Get("ETag")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.True(t, strings.HasPrefix(etag, `W/"`), "metadata ETags are weak")
+			assert.Equal(t, DefaultMetadataCacheControl, w.Header().Get("Cache-Control"))
+
+			w = get(records, etag)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Empty(t, w.Body.String())
+
+			w = get(tc.changed, etag)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestConditionalListing_IfModifiedSince(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	records := []*file.FileRecord{{ID: 1, Filename: "a.mp3", UploadDate: uploadDate}}
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, recordsStorer{records: records}).router()
+	assert.NoError(t, err)
+
+	// a file edited since its upload would be missed by a date, so listings are revalidated by ETag only
+	req := httptest.NewRequest(http.MethodGet, testBasePath+"/audio/", nil)
+	req.Header.Set("If-Modified-Since", uploadDate.Add(time.Hour).Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// dashboardPath is where the admin dashboard is served, outside of the API's base path
+const dashboardPath = "/admin"
+
+// dashboardFiles is the admin dashboard, a page calling the API with the admin's API key. The
+// page itself holds no data, everything it shows comes from routes guarded by the admin role
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardPage = template.Must(template.ParseFS(dashboardFiles, "dashboard/index.html"))
+
+// dashboardCSP keeps the page to its own scripts and styles, audio is streamed from the API
+const dashboardCSP = "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// WithDashboard serves the admin dashboard. Signing in reads the storage usage, so it's given with
+// WithStorageUsage too
+func WithDashboard() Option {
+	return func(a *APIServer) {
+		a.dashboard = true
+	}
+}
+
+// WithStorageUsage reports the storage used by the bucket to admins with usage
+func WithStorageUsage(usage s3.UsageReporter) Option {
+	return func(a *APIServer) {
+		a.storageUsage = usage
+	}
+}
+
+// storageReport is the answer of GET /admin/storage
+type storageReport struct {
+	Bucket string `json:"bucket"`
+	s3.Usage
+}
+
+// GET /api/v1/admin/storage
+// Adds up the objects of the bucket. Admins only
+func (a *APIServer) getStorageUsage(c *gin.Context) {
+	usage, err := a.storageUsage.Usage(c, a.bucket)
+	if err != nil {
+		log.ErrorContext(c, "could not add up the storage used", "err", err, "bucket", a.bucket)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, storageReport{Bucket: a.bucket, Usage: usage})
+}
+
+// GET /admin/*filepath
+// Serves the dashboard's page, which is given the API's base path, and its assets
+func (a *APIServer) serveDashboard(c *gin.Context) {
+	c.Header("Content-Security-Policy", dashboardCSP)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// deploys must reach browsers right away
+	c.Header("Cache-Control", "no-cache")
+
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	if name == "" || name == "index.html" {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardPage.Execute(c.Writer, map[string]string{"BasePath": a.basePath}); err != nil {
+			log.ErrorContext(c, "could not render the dashboard", "err", err)
+		}
+		return
+	}
+
+	assets, _ := fs.Sub(dashboardFiles, "dashboard")
+	if _, err := fs.Stat(assets, name); err != nil {
+		routeNotFound(c)
+		return
+	}
+	c.FileFromFS(name, http.FS(assets))
+}
@@ -0,0 +1,358 @@
+// The admin dashboard. Every call to the API carries the admin's API key, kept for the session only
+"use strict";
+
+const basePath = document.querySelector('meta[name="base-path"]').content;
+const keyStorage = "voice-quips-api-key";
+const pageSize = 25;
+
+const state = {
+  page: 0,
+  filters: new URLSearchParams(),
+  files: new Map(),
+  editing: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+class APIError extends Error {
+  constructor(status, problem) {
+    super(problem.detail || problem.title || `request failed with ${status}`);
+    this.status = status;
+  }
+}
+
+// api calls the route under the base path, answering with the decoded JSON body
+async function api(method, path, body) {
+  const headers = { "X-API-Key": sessionStorage.getItem(keyStorage) || "" };
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+  const response = await fetch(basePath + path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (response.status === 401) {
+    signOut();
+  }
+  const text = await response.text();
+  const decoded = text ? JSON.parse(text) : null;
+  if (!response.ok) {
+    throw new APIError(response.status, decoded || {});
+  }
+  return decoded;
+}
+
+function splitTags(value) {
+  return value.split(",").map((tag) => tag.trim()).filter((tag) => tag !== "");
+}
+
+function formatBytes(bytes) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let unit = 0;
+  while (bytes >= 1024 && unit < units.length - 1) {
+    bytes /= 1024;
+    unit++;
+  }
+  return `${bytes.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+}
+
+function cell(row, text) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  row.appendChild(td);
+  return td;
+}
+
+function button(label, onClick) {
+  const b = document.createElement("button");
+  b.type = "button";
+  b.textContent = label;
+  b.addEventListener("click", onClick);
+  return b;
+}
+
+// Sign in
+
+async function signIn(event) {
+  event.preventDefault();
+  sessionStorage.setItem(keyStorage, $("api-key").value);
+  $("sign-in-error").textContent = "";
+  try {
+    // only admins may read the storage usage, which tells whether the key is an admin's
+    await loadStorage();
+  } catch (err) {
+    sessionStorage.removeItem(keyStorage);
+    $("sign-in-error").textContent = err.status === 403 ? "This API key isn't an admin's" : err.message;
+    return;
+  }
+  $("api-key").value = "";
+  showDashboard();
+}
+
+function signOut() {
+  sessionStorage.removeItem(keyStorage);
+  $("dashboard").hidden = true;
+  $("sign-out").hidden = true;
+  $("sign-in").hidden = false;
+}
+
+function showDashboard() {
+  $("sign-in").hidden = true;
+  $("dashboard").hidden = false;
+  $("sign-out").hidden = false;
+  loadCategories();
+  loadFiles();
+}
+
+// Storage
+
+async function loadStorage() {
+  const usage = await api("GET", "/admin/storage");
+  $("stat-objects").textContent = usage.objects.toLocaleString();
+  $("stat-bytes").textContent = formatBytes(usage.bytes);
+  $("stat-bucket").textContent = usage.bucket;
+}
+
+async function loadCategories() {
+  let categories;
+  try {
+    categories = await api("GET", "/categories");
+  } catch (err) {
+    // the catalogue may not be enabled
+    return;
+  }
+  const select = $("filter-category");
+  const flatten = (list, depth) => {
+    for (const category of list || []) {
+      const option = document.createElement("option");
+      option.value = category.slug;
+      option.textContent = "\u2003\u2003".repeat(depth) + category.name;
+      select.appendChild(option);
+      flatten(category.children, depth + 1);
+    }
+  };
+  flatten(categories, 0);
+}
+
+// Files
+
+function applyFilters(event) {
+  event.preventDefault();
+  const filters = new URLSearchParams();
+  if ($("filter-q").value.trim()) {
+    filters.set("q", $("filter-q").value.trim());
+  }
+  if ($("filter-category").value) {
+    filters.set("category", $("filter-category").value);
+  }
+  for (const tag of splitTags($("filter-tag").value)) {
+    filters.append("tag", tag);
+  }
+  if ($("filter-sort").value) {
+    filters.set("sort", $("filter-sort").value);
+  }
+  state.filters = filters;
+  state.page = 0;
+  loadFiles();
+}
+
+async function loadFiles() {
+  const query = new URLSearchParams(state.filters);
+  // one more than a page tells whether there's a next one
+  query.set("limit", pageSize + 1);
+  query.set("offset", state.page * pageSize);
+
+  $("files-error").textContent = "";
+  let files;
+  try {
+    files = await api("GET", "/audio/?" + query.toString());
+  } catch (err) {
+    $("files-error").textContent = err.message;
+    return;
+  }
+
+  const rows = $("file-rows");
+  rows.replaceChildren();
+  state.files.clear();
+  for (const file of files.slice(0, pageSize)) {
+    state.files.set(file.id, file);
+    rows.appendChild(fileRow(file));
+  }
+  if (files.length === 0) {
+    const row = document.createElement("tr");
+    cell(row, "No files match").colSpan = 9;
+    rows.appendChild(row);
+  }
+
+  $("page-number").textContent = `Page ${state.page + 1}`;
+  $("previous-page").disabled = state.page === 0;
+  $("next-page").disabled = files.length <= pageSize;
+}
+
+function fileRow(file) {
+  const row = document.createElement("tr");
+  cell(row, file.id);
+  cell(row, file.metadata.title);
+  cell(row, file.name);
+  cell(row, file.category);
+  cell(row, (file.tags || []).join(", "));
+  cell(row, file.status).className = `status status-${file.status}`;
+  cell(row, file.rating.count ? `${file.rating.average.toFixed(1)} (${file.rating.count})` : "");
+  cell(row, new Date(file.uploadDate).toLocaleString());
+
+  const actions = cell(row, "");
+  actions.className = "actions";
+  actions.append(
+    button("Play", () => togglePlayer(row, file)),
+    button("Edit", () => openEditor(file)),
+    button("Delete", () => deleteFile(file)),
+  );
+  return row;
+}
+
+// togglePlayer opens a player under the file's row, streaming its audio only once asked to
+function togglePlayer(row, file) {
+  const next = row.nextElementSibling;
+  if (next && next.classList.contains("player")) {
+    next.remove();
+    return;
+  }
+  const playerRow = document.createElement("tr");
+  playerRow.className = "player";
+  const td = cell(playerRow, "");
+  td.colSpan = 9;
+  const audio = document.createElement("audio");
+  audio.controls = true;
+  audio.autoplay = true;
+  audio.src = `${basePath}/audio/${file.id}/stream`;
+  td.appendChild(audio);
+  row.after(playerRow);
+}
+
+async function deleteFile(file) {
+  if (!confirm(`Delete ${file.name}? Its audio is left in storage.`)) {
+    return;
+  }
+  try {
+    await api("DELETE", `/audio/${file.id}`);
+  } catch (err) {
+    $("files-error").textContent = err.message;
+    return;
+  }
+  loadFiles();
+}
+
+// Editing
+
+function openEditor(file) {
+  state.editing = file;
+  $("edit-name").textContent = file.name;
+  $("edit-title").value = file.metadata.title;
+  $("edit-artist").value = file.metadata.artist;
+  $("edit-album").value = file.metadata.album;
+  $("edit-year").value = file.metadata.year || "";
+  $("edit-tags").value = (file.tags || []).join(", ");
+  $("edit-error").textContent = "";
+  $("edit-dialog").showModal();
+}
+
+async function saveEdit(event) {
+  event.preventDefault();
+  const file = state.editing;
+  const tags = splitTags($("edit-tags").value.toLowerCase());
+  const added = tags.filter((tag) => !(file.tags || []).includes(tag));
+  const removed = (file.tags || []).filter((tag) => !tags.includes(tag));
+
+  try {
+    await api("PATCH", `/audio/${file.id}`, {
+      title: $("edit-title").value,
+      artist: $("edit-artist").value,
+      album: $("edit-album").value,
+      year: Number($("edit-year").value) || 0,
+    });
+    if (added.length > 0) {
+      await api("POST", `/audio/${file.id}/tags`, { tags: added });
+    }
+    for (const tag of removed) {
+      await api("DELETE", `/audio/${file.id}/tags/${encodeURIComponent(tag)}`);
+    }
+  } catch (err) {
+    $("edit-error").textContent = err.message;
+    return;
+  }
+  $("edit-dialog").close();
+  loadFiles();
+}
+
+// Upload
+
+function upload(event) {
+  event.preventDefault();
+  const form = new FormData();
+  form.append("file", $("upload-file").files[0]);
+  if ($("upload-category").value.trim()) {
+    form.append("category", $("upload-category").value.trim());
+  }
+  for (const tag of splitTags($("upload-tags").value)) {
+    form.append("tags", tag);
+  }
+
+  // fetch can't report the progress of an upload
+  const request = new XMLHttpRequest();
+  request.open("POST", basePath + "/audio");
+  request.setRequestHeader("X-API-Key", sessionStorage.getItem(keyStorage) || "");
+  const progress = $("upload-progress");
+  progress.hidden = false;
+  progress.value = 0;
+  $("upload-status").textContent = "";
+
+  request.upload.addEventListener("progress", (e) => {
+    if (e.lengthComputable) {
+      progress.value = (e.loaded / e.total) * 100;
+    }
+  });
+  request.addEventListener("load", () => {
+    progress.hidden = true;
+    if (request.status >= 200 && request.status < 300) {
+      $("upload-status").textContent = request.status === 202 ? "Uploaded, processing" : "Uploaded";
+      $("upload-form").reset();
+      loadFiles();
+      loadStorage().catch(() => {});
+      return;
+    }
+    let problem = {};
+    try {
+      problem = JSON.parse(request.responseText);
+    } catch (err) {
+      // not a problem document
+    }
+    $("upload-status").textContent = new APIError(request.status, problem).message;
+  });
+  request.addEventListener("error", () => {
+    progress.hidden = true;
+    $("upload-status").textContent = "The upload failed, check your connection";
+  });
+  request.send(form);
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  $("sign-in-form").addEventListener("submit", signIn);
+  $("sign-out").addEventListener("click", signOut);
+  $("filters").addEventListener("submit", applyFilters);
+  $("upload-form").addEventListener("submit", upload);
+  $("edit-form").addEventListener("submit", saveEdit);
+  $("edit-cancel").addEventListener("click", () => $("edit-dialog").close());
+  $("previous-page").addEventListener("click", () => {
+    state.page--;
+    loadFiles();
+  });
+  $("next-page").addEventListener("click", () => {
+    state.page++;
+    loadFiles();
+  });
+
+  if (sessionStorage.getItem(keyStorage)) {
+    loadStorage().then(showDashboard, signOut);
+  }
+});
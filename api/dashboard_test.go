@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedUsage reports the same usage for every bucket
+type fixedUsage s3.Usage
+
+func (f fixedUsage) Usage(ctx context.Context, bucket string) (s3.Usage, error) {
+	return s3.Usage(f), nil
+}
+
+// editingStorer applies metadata updates to its record
+type editingStorer struct {
+	file.Storer
+	record file.FileRecord
+}
+
+func (e *editingStorer) UpdateMetadata(ctx context.Context, id string, update file.MetadataUpdate) (*file.FileRecord, error) {
+	if update.Title != nil {
+		e.record.Title = *update.Title
+	}
+	return &e.record, nil
+}
+
+var testAuth = config.AuthConfig{Keys: []config.APIKey{
+	{Key: "admin-key", User: "admin", Role: RoleAdmin},
+	{Key: "user-key", User: "alice", Role: RoleUser},
+}}
+
+func TestServeDashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, nil, WithDashboard()).router()
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name                string
+		path                string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{name: "Redirect", path: "/admin", expectedCode: http.StatusMovedPermanently},
+		{name: "Page", path: "/admin/", expectedCode: http.StatusOK, expectedContentType: "text/html", expectedBody: `<meta name="base-path" content="` + testBasePath + `">`},
+		{name: "Script", path: "/admin/app.js", expectedCode: http.StatusOK, expectedContentType: "javascript", expectedBody: "X-API-Key"},
+		{name: "Missing", path: "/admin/missing.js", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tc.expectedContentType)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, dashboardCSP, w.Header().Get("Content-Security-Policy"))
+			}
+		})
+	}
+}
+
+func TestServeDashboard_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// reporting storage usage alone doesn't serve the dashboard
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, nil, WithStorageUsage(fixedUsage{})).router()
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		apiKey       string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "StorageAnonymous", method: http.MethodGet, path: "/admin/storage", expectedCode: http.StatusUnauthorized},
+		{name: "StorageUser", method: http.MethodGet, path: "/admin/storage", apiKey: "user-key", expectedCode: http.StatusForbidden},
+		{name: "StorageAdmin", method: http.MethodGet, path: "/admin/storage", apiKey: "admin-key", expectedCode: http.StatusOK, expectedBody: `{"bucket":"quips","objects":3,"bytes":1024}`},
+		{name: "UpdateUser", method: http.MethodPatch, path: "/audio/1", body: `{"title":"Hello"}`, apiKey: "user-key", expectedCode: http.StatusForbidden},
+		{name: "UpdateAdmin", method: http.MethodPatch, path: "/audio/1", body: `{"title":"Hello"}`, apiKey: "admin-key", expectedCode: http.StatusOK, expectedBody: `"title":"Hello"`},
+		{name: "UpdateInvalid", method: http.MethodPatch, path: "/audio/1", body: `{"year":"last year"}`, apiKey: "admin-key", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			storer := &editingStorer{record: file.FileRecord{ID: 1, Filename: "a.mp3"}}
+			r, err := NewAPIServer(config.APIConfig{Path: testBasePath, Auth: testAuth}, nil, storer,
+				WithBucket("quips"), WithStorageUsage(fixedUsage{Objects: 3, Bytes: 1024})).router()
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(tc.method, testBasePath+tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedBody != "" {
+				var compact bytes.Buffer
+				assert.NoError(t, json.Compact(&compact, w.Body.Bytes()))
+				assert.Contains(t, compact.String(), tc.expectedBody)
+			}
+		})
+	}
+}
@@ -7,9 +7,16 @@ import (
 
 var ErrDbUsernameMissing = errors.New("database username not given or found (usage: --dbuser <user> or DBUSER=<user>)")
 var ErrDbPasswordMissing = errors.New("database password not given or found (usage: --dbpass <password> or DBPASS=<password>)")
+var ErrFileTooLarge = errors.New("file size too large")
+var ErrBodyTooLarge = errors.New("request body too large")
+var ErrRateLimited = errors.New("too many requests, slow down")
+var ErrQuotaExceeded = errors.New("daily upload quota exceeded")
 
+// APIError is an error along with the status it should be answered with. Code overrides the
+// machine-readable code the status or the wrapped error would map to
 type APIError struct {
 	StatusCode int
+	Code       string
 	Err        error
 }
 
@@ -21,10 +28,14 @@ func (ae *APIError) Error() string {
 	return fmt.Sprintf("status %d: err %v", ae.StatusCode, ae.Err)
 }
 
+func (ae *APIError) Unwrap() error {
+	return ae.Err
+}
+
 // TODO determine if we actually need to return a pointer
 func InternalServerError(message string) *APIError {
 	if message == "" {
-		return &APIError{StatusCode: 500, Err: errors.New("an issue occurred server-side")}
+		return &APIError{StatusCode: 500, Err: errors.New(internalErrorDetail)}
 	}
 	return &APIError{StatusCode: 500, Err: errors.New(message)}
 }
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ratingRequest struct {
+	Score int `json:"score"`
+}
+
+// PUT /api/v1/audio/{id}/favorite
+func (a *APIServer) addFavorite(c *gin.Context) {
+	user, _ := currentUser(c)
+	id := c.Param("id")
+
+	if err := a.feedbackService.AddFavorite(c, user.Name, id); err != nil {
+		log.ErrorContext(c, "Could not add favorite", "err", err, "id", id, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DELETE /api/v1/audio/{id}/favorite
+func (a *APIServer) removeFavorite(c *gin.Context) {
+	user, _ := currentUser(c)
+	id := c.Param("id")
+
+	if err := a.feedbackService.RemoveFavorite(c, user.Name, id); err != nil {
+		log.ErrorContext(c, "Could not remove favorite", "err", err, "id", id, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /api/v1/me/favorites
+func (a *APIServer) getFavorites(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	favorites, err := a.feedbackService.FindFavorites(c, user.Name)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve favorites", "err", err, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, favorites)
+}
+
+// PUT /api/v1/audio/{id}/rating
+// Sets the caller's 1-5 score for a quip and returns its new average
+func (a *APIServer) rateAudio(c *gin.Context) {
+	user, _ := currentUser(c)
+	id := c.Param("id")
+
+	var request ratingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.ErrorContext(c, "invalid rating request", "err", err)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rating, err := a.feedbackService.Rate(c, user.Name, id, request.Score)
+	if err != nil {
+		log.ErrorContext(c, "Could not rate file", "err", err, "id", id, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rating)
+}
+
+// DELETE /api/v1/audio/{id}/rating
+func (a *APIServer) removeRating(c *gin.Context) {
+	user, _ := currentUser(c)
+	id := c.Param("id")
+
+	rating, err := a.feedbackService.RemoveRating(c, user.Name, id)
+	if err != nil {
+		log.ErrorContext(c, "Could not remove rating", "err", err, "id", id, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, rating)
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/feed"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+const (
+	feedExtension = ".rss"
+
+	// feedLength caps the episodes of a category feed, podcast apps only look at the latest ones
+	feedLength = 100
+)
+
+// GET /api/v1/feeds/category/{slug}.rss
+// A podcast of the newest quips of the category and its subcategories
+func (a *APIServer) getCategoryFeed(c *gin.Context) {
+	slug, ok := feedName(c)
+	if !ok {
+		return
+	}
+
+	category, err := a.categoryService.FindCategoryBySlug(c, slug)
+	if err != nil {
+		log.ErrorContext(c, "request for the following category feed was not found", "err", err, "slug", slug)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	records, err := a.fileService.FindAll(c, file.Filter{Category: category.Slug, Sort: file.SortNewest, Limit: feedLength})
+	if err != nil {
+		log.ErrorContext(c, "could not retrieve the files of the category feed", "err", err, "slug", slug)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	description := category.Description
+	if description == "" {
+		description = "Quips filed under " + category.Name
+	}
+	a.serveFeed(c, feed.Channel{
+		Title:       category.Name,
+		Description: description,
+	}, records, time.Time{})
+}
+
+// GET /api/v1/feeds/playlist/{id}.rss
+// A podcast of the quips of the playlist, in its order
+func (a *APIServer) getPlaylistFeed(c *gin.Context) {
+	name, ok := feedName(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(name, 10, 0)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("playlist id must be a number"))
+		return
+	}
+
+	user, _ := currentUser(c)
+	found, err := a.playlistService.FindById(c, user.Name, uint(id))
+	if err != nil {
+		log.ErrorContext(c, "request for the following playlist feed failed", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	records, err := a.playlistFiles(c, found)
+	if err != nil {
+		log.ErrorContext(c, "could not retrieve the files of the playlist feed", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	a.serveFeed(c, feed.Channel{
+		Title:       found.Name,
+		Description: fmt.Sprintf("Quips of the %s playlist by %s", found.Name, found.Owner),
+		Author:      found.Owner,
+	}, records, found.UpdatedAt)
+}
+
+// playlistFiles looks up the files of the playlist's items
+func (a *APIServer) playlistFiles(c *gin.Context, found *playlist.Playlist) ([]*file.FileRecord, error) {
+	records := make([]*file.FileRecord, 0, len(found.Items))
+	for _, item := range found.Items {
+		record, err := a.fileService.FindById(c, strconv.FormatUint(uint64(item.FileID), 10))
+		if errors.Is(err, file.ErrNoRowsFound) {
+			// removed since it was added
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// serveFeed answers with the channel's RSS, its episodes being the records that are ready to
+// play. The ETag is a hash of the document so feed readers polling it mostly get 304s
+func (a *APIServer) serveFeed(c *gin.Context, channel feed.Channel, records []*file.FileRecord, lastModified time.Time) {
+	channel.SelfURL = a.siteURL(c, c.Request.URL.Path)
+	channel.Link = channel.SelfURL
+	if a.listener {
+		channel.Link = a.siteURL(c, quipsPath)
+	}
+
+	for _, record := range records {
+		if record.Status != "" && record.Status != file.StatusReady {
+			continue
+		}
+		episode := a.newEpisode(c, record)
+		if channel.ImageURL == "" {
+			channel.ImageURL = episode.ImageURL
+		}
+		channel.Episodes = append(channel.Episodes, episode)
+	}
+
+	document, err := feed.RSS(channel)
+	if err != nil {
+		log.ErrorContext(c, "could not write feed", "err", err, "feed", c.Request.URL.Path)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if notModified(c, a.settings.cacheControl.Metadata, weakETag(string(document)), lastModified) {
+		return
+	}
+	c.Data(http.StatusOK, feed.ContentType, document)
+}
+
+func (a *APIServer) newEpisode(c *gin.Context, record *file.FileRecord) feed.Episode {
+	id := strconv.FormatUint(uint64(record.ID), 10)
+	title := record.Title
+	if title == "" {
+		title = record.Filename
+	}
+
+	episode := feed.Episode{
+		GUID:        providerName + ":" + id,
+		Title:       title,
+		Author:      record.Artist,
+		URL:         a.absoluteURL(c, "/audio/"+id+"/stream"),
+		Size:        record.Size,
+		ContentType: s3.GetContentType(filepath.Ext(record.S3Link)),
+		Duration:    time.Duration(record.DurationMs) * time.Millisecond,
+		Published:   record.UploadDate,
+	}
+	if a.listener {
+		episode.Link = a.siteURL(c, quipsPath+"/"+id)
+	}
+	if record.CoverType != "" {
+		episode.ImageURL = a.absoluteURL(c, "/audio/"+id+"/cover")
+	}
+	return episode
+}
+
+// feedName returns the slug or ID a feed is requested by, answering 404 when it isn't asked for as RSS
+func feedName(c *gin.Context) (string, bool) {
+	name, ok := strings.CutSuffix(c.Param("feed"), feedExtension)
+	if !ok || name == "" {
+		routeNotFound(c)
+		c.Abort()
+		return "", false
+	}
+	return name, true
+}
+
+// GET /api/v1/audio/{id}/cover
+// The cover art extracted from the file's tags
+func (a *APIServer) getAudioCover(c *gin.Context) {
+	id := c.Param("id")
+
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
+		abortWithDomainError(c, err)
+		return
+	}
+	if fileInfo.CoverType == "" {
+		abortWithDomainError(c, file.ErrNoCover)
+		return
+	}
+	// the cover is part of the audio, which never changes
+	if notModified(c, a.settings.cacheControl.Audio, weakETag("cover", fileInfo.S3Link), fileInfo.UploadDate) {
+		return
+	}
+
+	object, err := a.s3Service.StreamObject(c, fileInfo.S3Link, a.bucket)
+	if err != nil {
+		log.ErrorContext(c, "could not retrieve audio from storage", "err", err, "id", id, "object", fileInfo.S3Link)
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not retrieve audio from storage: %w", err))
+		return
+	}
+	defer object.Body.Close()
+
+	content, err := io.ReadAll(object.Body)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not retrieve audio from storage: %w", err))
+		return
+	}
+	contentType, cover, err := file.Cover(bytes.NewReader(content))
+	if err != nil {
+		log.ErrorContext(c, "could not extract the cover art", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, contentType, cover)
+}
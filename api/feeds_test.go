@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/feed"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// feedCategories only knows the greetings category
+type feedCategories struct {
+	file.CategoryStorer
+}
+
+func (feedCategories) FindCategoryBySlug(ctx context.Context, slug string) (*file.Category, error) {
+	if slug != "greetings" {
+		return nil, file.NoRowsFoundError("")
+	}
+	return &file.Category{ID: 1, Name: "Greetings", Slug: "greetings"}, nil
+}
+
+// feedPlaylists only knows the public playlist with ID 1
+type feedPlaylists struct {
+	playlist.Storer
+}
+
+func (feedPlaylists) FindById(ctx context.Context, user string, id uint) (*playlist.Playlist, error) {
+	if id != 1 {
+		return nil, playlist.ErrNotFound
+	}
+	return &playlist.Playlist{ID: 1, Name: "Soundboard", Owner: "phllp", Public: true,
+		Items: []playlist.Item{{Position: 1, FileID: 1}}, UpdatedAt: uploadDate}, nil
+}
+
+func newFeedRouter(t *testing.T, records []*file.FileRecord, storage s3.Storage) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, storage, recordsStorer{records: records},
+		WithCategoryService(feedCategories{}), WithPlaylistService(feedPlaylists{})).router()
+	assert.NoError(t, err)
+	return r
+}
+
+func TestFeeds(t *testing.T) {
+	records := []*file.FileRecord{
+		{ID: 1, Filename: "hello.mp3", S3Link: "a.mp3", Size: 2048, UploadDate: uploadDate, Status: file.StatusReady,
+			Metadata: file.Metadata{Title: "Hello", Artist: "Phllp", DurationMs: 1500, CoverType: "image/png"}},
+		{ID: 2, Filename: "pending.mp3", S3Link: "b.mp3", UploadDate: uploadDate, Status: file.StatusProcessing},
+	}
+	r := newFeedRouter(t, records, nil)
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedBody []string
+	}{
+		{
+			name:         "Category",
+			path:         "/feeds/category/greetings.rss",
+			expectedCode: http.StatusOK,
+			expectedBody: []string{
+				"<title>Greetings</title>",
+				`<atom:link href="http://example.com` + testBasePath + `/feeds/category/greetings.rss" rel="self"`,
+				`<enclosure url="http://example.com` + testBasePath + `/audio/1/stream" length="2048" type="audio/mpeg">`,
+				"<itunes:duration>2</itunes:duration>",
+				`<itunes:image href="http://example.com` + testBasePath + `/audio/1/cover">`,
+			},
+		},
+		{
+			name:         "Playlist",
+			path:         "/feeds/playlist/1.rss",
+			expectedCode: http.StatusOK,
+			expectedBody: []string{"<title>Soundboard</title>", "<itunes:author>phllp</itunes:author>", "<title>Hello</title>"},
+		},
+		{name: "UnknownCategory", path: "/feeds/category/farewells.rss", expectedCode: http.StatusNotFound},
+		{name: "UnknownPlaylist", path: "/feeds/playlist/2.rss", expectedCode: http.StatusNotFound},
+		{name: "NotRSS", path: "/feeds/category/greetings", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testBasePath+tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, feed.ContentType, w.Header().Get("Content-Type"))
+			assert.NotContains(t, w.Body.String(), "pending.mp3", "files still processing are left out")
+			for _, expected := range tc.expectedBody {
+				assert.Contains(t, w.Body.String(), expected)
+			}
+		})
+	}
+}
+
+func TestConditionalFeeds(t *testing.T) {
+	records := []*file.FileRecord{{ID: 1, Filename: "hello.mp3", UploadDate: uploadDate}}
+	changed := []*file.FileRecord{{ID: 1, Filename: "hello.mp3", Metadata: file.Metadata{Title: "Hello"}, UploadDate: uploadDate}}
+
+	for _, path := range []string{"/feeds/category/greetings.rss", "/feeds/playlist/1.rss"} {
+		t.Run(path, func(t *testing.T) {
+			get := func(records []*file.FileRecord, etag string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, testBasePath+path, nil)
+				if etag != "" {
+					req.Header.Set("If-None-Match", etag)
+				}
+				w := httptest.NewRecorder()
+				newFeedRouter(t, records, nil).ServeHTTP(w, req)
+				return w
+			}
+
+			w := get(records, "")
+			etag := w.Header().Get("ETag")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEmpty(t, etag)
+			assert.Equal(t, DefaultMetadataCacheControl, w.Header().Get("Cache-Control"))
+
+			w = get(records, etag)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Empty(t, w.Body.String())
+
+			w = get(changed, etag)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+// coverStorage serves audio made of an ID3v2.3 tag holding a PNG cover
+type coverStorage struct {
+	s3.Storage
+	streams int
+}
+
+func (s *coverStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	s.streams++
+	audio := id3WithCover("image/png", []byte("png"))
+	return &s3.Object{Body: io.NopCloser(bytes.NewReader(audio)), Size: int64(len(audio)), ContentType: s3.MP3Header}, nil
+}
+
+func id3WithCover(mimeType string, picture []byte) []byte {
+	var frame bytes.Buffer
+	frame.WriteByte(0) // ISO-8859-1
+	frame.WriteString(mimeType + "\x00")
+	frame.WriteByte(3) // front cover
+	frame.WriteString("\x00")
+	frame.Write(picture)
+
+	var tag bytes.Buffer
+	tag.WriteString("APIC")
+	binary.Write(&tag, binary.BigEndian, uint32(frame.Len()))
+	tag.Write([]byte{0, 0})
+	tag.Write(frame.Bytes())
+
+	size := tag.Len()
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, tag.Bytes()...)
+}
+
+func TestAudioCover(t *testing.T) {
+	testCases := []struct {
+		name                string
+		coverType           string
+		etag                string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+		expectedStreams     int
+	}{
+		{name: "Cover", coverType: "image/png", expectedCode: http.StatusOK, expectedContentType: "image/png", expectedBody: "png", expectedStreams: 1},
+		{name: "NoCover", expectedCode: http.StatusNotFound},
+		{name: "NotModified", coverType: "image/png", etag: weakETag("cover", "a.mp3"), expectedCode: http.StatusNotModified},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := &coverStorage{}
+			records := []*file.FileRecord{{ID: 1, S3Link: "a.mp3", UploadDate: uploadDate, Metadata: file.Metadata{CoverType: tc.coverType}}}
+			r := newFeedRouter(t, records, storage)
+
+			req := httptest.NewRequest(http.MethodGet, testBasePath+"/audio/1/cover", nil)
+			if tc.etag != "" {
+				req.Header.Set("If-None-Match", tc.etag)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedStreams, storage.streams)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedBody, w.Body.String())
+				assert.Equal(t, DefaultAudioCacheControl, w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestNewEpisode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	server := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, nil)
+
+	episode := server.newEpisode(c, &file.FileRecord{ID: 7, Filename: "bye.wav", S3Link: "b.wav", UploadDate: uploadDate})
+
+	assert.Equal(t, "bye.wav", episode.Title, "the filename stands in for a missing title")
+	assert.Equal(t, "audio/wav", episode.ContentType)
+	assert.Equal(t, time.Duration(0), episode.Duration)
+	assert.Empty(t, episode.ImageURL)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/health"
+)
+
+// liveness answers as long as the process is serving requests. It checks no dependency, so
+// that an outage of one doesn't get the pod restarted
+func liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// readiness runs the dependency checks, answering 503 when one of them fails so that traffic
+// is routed elsewhere
+func (a *APIServer) readiness(c *gin.Context) {
+	if a.health == nil {
+		c.JSON(http.StatusOK, health.Report{Status: health.StatusUp, Checks: map[string]health.Result{}})
+		return
+	}
+
+	report := a.health.Run(c)
+	if !report.Up() {
+		log.WarnContext(c, "readiness check failed", "checks", report.Checks)
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
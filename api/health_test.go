@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phllpmcphrsn/voice-quips/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	up := health.CheckerFunc(func(ctx context.Context) error { return nil })
+	down := health.CheckerFunc(func(ctx context.Context) error { return errors.New("bucket quips does not exist") })
+
+	testCases := []struct {
+		name           string
+		path           string
+		checkers       map[string]health.Checker
+		expectedCode   int
+		expectedStatus string
+	}{
+		{name: "Liveness", path: "/healthz", checkers: map[string]health.Checker{"storage": down}, expectedCode: http.StatusOK, expectedStatus: health.StatusUp},
+		{name: "Ready", path: "/readyz", checkers: map[string]health.Checker{"postgres": up, "storage": up}, expectedCode: http.StatusOK, expectedStatus: health.StatusUp},
+		{name: "NotReady", path: "/readyz", checkers: map[string]health.Checker{"postgres": up, "storage": down}, expectedCode: http.StatusServiceUnavailable, expectedStatus: health.StatusDown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := health.New(0)
+			for name, checker := range tc.checkers {
+				h.Add(name, checker)
+			}
+			server := newTestServer()
+			WithHealth(h)(server)
+			r, err := server.router()
+			assert.NoError(t, err)
+			w := httptest.NewRecorder()
+
+			// probes don't authenticate
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			var report health.Report
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tc.expectedStatus, report.Status)
+			if tc.path == "/readyz" {
+				assert.Len(t, report.Checks, len(tc.checkers))
+			}
+		})
+	}
+}
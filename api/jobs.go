@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+)
+
+// processingResponse is the body of an upload accepted for background processing
+type processingResponse struct {
+	*file.FileRecord
+	JobID  int64  `json:"jobId"`
+	JobURL string `json:"jobUrl"`
+}
+
+// saveForProcessing stores the upload as processing and enqueues the job that reads its metadata.
+// The client polls the job, or the file, until the processing is done
+func (a *APIServer) saveForProcessing(c *gin.Context, fileInfo file.FileRecord) {
+	saved, err := a.fileService.SavePending(c, fileInfo)
+	if errors.Is(err, file.ErrUnknownCategory) {
+		log.ErrorContext(c, "upload given an unknown category", "err", err, "category", fileInfo.Category)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.ErrorContext(c, "could not save file information", "err", err, "file", fileInfo.Filename)
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not save file information: %w", err))
+		return
+	}
+
+	job, err := jobs.NewJob(file.JobProcessUpload, saved.ID, jobs.ObjectPayload{Object: saved.S3Link, Filename: saved.Filename})
+	if err == nil {
+		var enqueued *jobs.Job
+		enqueued, err = a.jobQueue.Enqueue(c, job)
+		if err == nil {
+			job = *enqueued
+		}
+	}
+	if err != nil {
+		log.ErrorContext(c, "could not enqueue upload processing", "err", err, "id", saved.ID)
+		if err := a.fileService.MarkFailed(c, strconv.FormatUint(uint64(saved.ID), 10)); err != nil {
+			log.ErrorContext(c, "could not mark upload as failed", "err", err, "id", saved.ID)
+		}
+		abortWithError(c, http.StatusInternalServerError, fmt.Errorf("could not schedule processing of the upload: %w", err))
+		return
+	}
+
+	jobURL := a.absoluteURL(c, fmt.Sprintf("/jobs/%d", job.ID))
+	c.Header("Location", jobURL)
+	c.IndentedJSON(http.StatusAccepted, processingResponse{FileRecord: saved, JobID: job.ID, JobURL: jobURL})
+}
+
+// GET /api/v1/jobs/{id}
+// Returns the status of a background job, its attempts and the last error when it failed
+func (a *APIServer) getJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("id must be a number"))
+		return
+	}
+
+	job, err := a.jobQueue.Find(c, id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		abortWithError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve job", "err", err, "id", id)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, job)
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// Paths of the public listener app, outside of the API's base path
+const (
+	quipsPath  = "/quips"
+	embedPath  = "/embed"
+	oEmbedPath = "/oembed"
+	// listenerAssetsPath serves the scripts and styles shared by the listener pages
+	listenerAssetsPath = "/listener"
+)
+
+// providerName is how quips are credited in unfurled links
+const providerName = "Voice Quips"
+
+// Size of the embedded player, shrunk to the maxwidth and maxheight consumers ask for
+const (
+	embedWidth  = 480
+	embedHeight = 120
+)
+
+// listenerFiles holds the templates of the listener pages and their assets
+//
+//go:embed listener
+var listenerFiles embed.FS
+
+var listenerPages = template.Must(template.ParseFS(listenerFiles, "listener/*.html"))
+
+// listenerCSP keeps the pages to their own scripts and styles. Players play the audio they fetched from blob URLs
+const listenerCSP = "default-src 'self'; img-src 'self' data:; media-src 'self' blob:; base-uri 'none'; form-action 'self'"
+
+// embedCSP lets any site frame the player, which is what it's for
+const embedCSP = "default-src 'self'; img-src 'self' data:; media-src 'self' blob:; base-uri 'none'; form-action 'none'; frame-ancestors *"
+
+var ErrUnsupportedFormat = errors.New("only the json format is supported")
+var ErrNotAQuipURL = errors.New("the url isn't the page of a quip on this site")
+
+// WithListener serves the public listener app: a page to browse quips, a page per quip, an
+// embeddable player and the oEmbed endpoint unfurling links to quips
+func WithListener() Option {
+	return func(a *APIServer) {
+		a.listener = true
+	}
+}
+
+// quipPage is what the quip and embed templates render
+type quipPage struct {
+	BasePath string
+	File     *file.FileRecord
+	// Title falls back to the filename for files without one in their metadata
+	Title     string
+	PageURL   string
+	EmbedURL  string
+	StreamURL string
+	OEmbedURL string
+	Width     int
+	Height    int
+}
+
+func (a *APIServer) newQuipPage(c *gin.Context, fileInfo *file.FileRecord) quipPage {
+	id := strconv.FormatUint(uint64(fileInfo.ID), 10)
+	pageURL := a.siteURL(c, quipsPath+"/"+id)
+
+	title := fileInfo.Title
+	if title == "" {
+		title = fileInfo.Filename
+	}
+	return quipPage{
+		BasePath:  a.basePath,
+		File:      fileInfo,
+		Title:     title,
+		PageURL:   pageURL,
+		EmbedURL:  a.siteURL(c, embedPath+"/"+id),
+		StreamURL: a.absoluteURL(c, "/audio/"+id+"/stream"),
+		OEmbedURL: a.siteURL(c, oEmbedPath) + "?" + url.Values{"url": {pageURL}, "format": {"json"}}.Encode(),
+		Width:     embedWidth,
+		Height:    embedHeight,
+	}
+}
+
+// renderPage writes out the listener template, logging the error of a page cut short
+func renderPage(c *gin.Context, status int, csp, name string, data any) {
+	c.Header("Content-Security-Policy", csp)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := listenerPages.ExecuteTemplate(c.Writer, name, data); err != nil {
+		log.ErrorContext(c, "could not render the page", "err", err, "page", name)
+	}
+}
+
+// findQuip looks up the quip of the page, rendering the missing page when it doesn't exist
+func (a *APIServer) findQuip(c *gin.Context, csp string) (*file.FileRecord, bool) {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 0); err != nil {
+		renderPage(c, http.StatusNotFound, csp, "missing.html", nil)
+		return nil, false
+	}
+
+	fileInfo, err := a.fileService.FindById(c, id)
+	if errors.Is(err, file.ErrNoRowsFound) {
+		renderPage(c, http.StatusNotFound, csp, "missing.html", nil)
+		return nil, false
+	}
+	if err != nil {
+		log.ErrorContext(c, "could not look up the quip", "err", err, "id", id)
+		renderPage(c, http.StatusInternalServerError, csp, "missing.html", nil)
+		return nil, false
+	}
+	return fileInfo, true
+}
+
+// GET /quips
+// The page listeners browse and search quips from, through the API
+func (a *APIServer) browseQuips(c *gin.Context) {
+	renderPage(c, http.StatusOK, listenerCSP, "browse.html", map[string]string{"BasePath": a.basePath})
+}
+
+// GET /quips/{id}
+// The page of a quip, with the metadata chat tools and blogs unfurl links with
+func (a *APIServer) showQuip(c *gin.Context) {
+	fileInfo, ok := a.findQuip(c, listenerCSP)
+	if !ok {
+		return
+	}
+	renderPage(c, http.StatusOK, listenerCSP, "quip.html", a.newQuipPage(c, fileInfo))
+}
+
+// GET /embed/{id}
+// The player other sites frame: title, artist, waveform and a play button
+func (a *APIServer) embedQuip(c *gin.Context) {
+	fileInfo, ok := a.findQuip(c, embedCSP)
+	if !ok {
+		return
+	}
+	renderPage(c, http.StatusOK, embedCSP, "embed.html", a.newQuipPage(c, fileInfo))
+}
+
+// oEmbed is an oEmbed 1.0 response of the rich type, a card with the player
+type oEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// GET /oembed?url={quip page}&maxwidth={n}&maxheight={n}&format=json
+// Describes the card to unfurl a link to a quip with (https://oembed.com)
+func (a *APIServer) getOEmbed(c *gin.Context) {
+	if format := c.Query("format"); format != "" && format != "json" {
+		abortWithDomainError(c, ErrUnsupportedFormat)
+		return
+	}
+
+	id, err := quipID(c, c.Query("url"))
+	if err != nil {
+		abortWithDomainError(c, err)
+		return
+	}
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "could not look up the quip to embed", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	page := a.newQuipPage(c, fileInfo)
+	page.Width = fitWithin(page.Width, c.Query("maxwidth"))
+	page.Height = fitWithin(page.Height, c.Query("maxheight"))
+	c.JSON(http.StatusOK, oEmbed{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: providerName,
+		ProviderURL:  a.siteURL(c, quipsPath),
+		Title:        page.Title,
+		AuthorName:   fileInfo.Artist,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allow="autoplay" title="%s"></iframe>`,
+			html.EscapeString(page.EmbedURL), page.Width, page.Height, html.EscapeString(page.Title)),
+		Width:    page.Width,
+		Height:   page.Height,
+		CacheAge: 24 * 60 * 60,
+	})
+}
+
+// quipID returns the ID of the quip a link to a quip or embed page on this site points at
+func quipID(c *gin.Context, link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil || !strings.EqualFold(u.Host, c.Request.Host) {
+		return "", ErrNotAQuipURL
+	}
+
+	for _, prefix := range []string{quipsPath + "/", embedPath + "/"} {
+		id, ok := strings.CutPrefix(strings.TrimSuffix(u.Path, "/"), prefix)
+		if !ok {
+			continue
+		}
+		if _, err := strconv.ParseUint(id, 10, 0); err == nil {
+			return id, nil
+		}
+	}
+	return "", ErrNotAQuipURL
+}
+
+// fitWithin shrinks the size to the maximum asked for, ignoring maximums that aren't positive numbers
+func fitWithin(size int, maximum string) int {
+	if limit, err := strconv.Atoi(maximum); err == nil && limit > 0 && limit < size {
+		return limit
+	}
+	return size
+}
+
+// GET /listener/*filepath
+// Serves the scripts and styles of the listener pages
+func serveListenerAssets(c *gin.Context) {
+	assets, _ := fs.Sub(listenerFiles, "listener")
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	if strings.HasSuffix(name, ".html") {
+		routeNotFound(c)
+		return
+	}
+	if _, err := fs.Stat(assets, name); err != nil || name == "" {
+		routeNotFound(c)
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.FileFromFS(name, http.FS(assets))
+}
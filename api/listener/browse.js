@@ -0,0 +1,97 @@
+// The browse page, listing quips from the API a page at a time with a player for each
+"use strict";
+
+const basePath = document.querySelector('meta[name="base-path"]').content;
+const pageSize = 20;
+
+const state = {
+  page: 0,
+  query: "",
+};
+
+const $ = (id) => document.getElementById(id);
+
+// quipPlayer builds the same player markup the quip and embed pages render
+function quipPlayer(quip) {
+  const streamURL = `${basePath}/audio/${quip.id}/stream`;
+
+  const player = document.createElement("div");
+  player.className = "player";
+  player.dataset.stream = streamURL;
+
+  const button = document.createElement("button");
+  button.className = "play";
+  button.type = "button";
+  button.setAttribute("aria-label", "Play");
+  button.innerHTML = "&#9654;";
+
+  const track = document.createElement("div");
+  track.className = "track";
+  const title = document.createElement("a");
+  title.className = "title";
+  title.href = `/quips/${quip.id}`;
+  title.textContent = quip.metadata.title || quip.name;
+  const artist = document.createElement("span");
+  artist.className = "artist";
+  artist.textContent = quip.metadata.artist;
+  const canvas = document.createElement("canvas");
+  canvas.className = "waveform";
+  canvas.setAttribute("aria-hidden", "true");
+  track.append(title, artist, canvas);
+
+  const audio = document.createElement("audio");
+  audio.preload = "none";
+  audio.src = streamURL;
+
+  player.append(button, track, audio);
+  return player;
+}
+
+async function load() {
+  const params = new URLSearchParams({ limit: pageSize + 1, offset: state.page * pageSize });
+  if (state.query) {
+    params.set("q", state.query);
+  }
+
+  $("error").textContent = "";
+  try {
+    const response = await fetch(`${basePath}/audio/?${params}`);
+    if (!response.ok) {
+      throw new Error(`listing the quips failed with ${response.status}`);
+    }
+    // one more than a page is asked for to know whether there's a next page
+    const quips = (await response.json()) || [];
+
+    const list = $("quips");
+    list.replaceChildren();
+    quips.slice(0, pageSize).forEach((quip) => {
+      const item = document.createElement("li");
+      const player = quipPlayer(quip);
+      item.append(player);
+      list.append(item);
+      setUpPlayer(player);
+    });
+    $("empty").hidden = quips.length > 0;
+    $("previous").disabled = state.page === 0;
+    $("next").disabled = quips.length <= pageSize;
+  } catch (error) {
+    $("error").textContent = error.message;
+  }
+}
+
+$("search").addEventListener("submit", (event) => {
+  event.preventDefault();
+  state.query = $("search-query").value.trim();
+  state.page = 0;
+  load();
+});
+$("previous").addEventListener("click", () => {
+  state.page--;
+  load();
+});
+$("next").addEventListener("click", () => {
+  state.page++;
+  load();
+});
+
+load();
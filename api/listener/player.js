@@ -0,0 +1,115 @@
+// The quip player: a play button and a waveform of the quip, drawn from its stream, seeking where it's clicked
+"use strict";
+
+const bars = 64;
+const played = "#2f5fd0";
+const unplayed = "#c3cad4";
+
+// fetchAudio downloads the audio once, for the waveform and for playback
+async function fetchAudio(url) {
+  const response = await fetch(url);
+  if (!response.ok) {
+    throw new Error(`fetching the audio failed with ${response.status}`);
+  }
+  return response.blob();
+}
+
+// peaks returns the loudest sample of each of the bars the audio is split into, from 0 to 1
+async function peaks(blob) {
+  const context = new (window.AudioContext || window.webkitAudioContext)();
+  try {
+    const audio = await context.decodeAudioData(await blob.arrayBuffer());
+    const samples = audio.getChannelData(0);
+    const size = Math.max(1, Math.floor(samples.length / bars));
+    const result = [];
+    let loudest = 0;
+    for (let bar = 0; bar < bars; bar++) {
+      let peak = 0;
+      for (let i = bar * size; i < (bar + 1) * size && i < samples.length; i++) {
+        peak = Math.max(peak, Math.abs(samples[i]));
+      }
+      result.push(peak);
+      loudest = Math.max(loudest, peak);
+    }
+    return result.map((peak) => (loudest > 0 ? peak / loudest : 0));
+  } finally {
+    context.close();
+  }
+}
+
+function draw(canvas, levels, progress) {
+  const ratio = window.devicePixelRatio || 1;
+  canvas.width = canvas.clientWidth * ratio;
+  canvas.height = canvas.clientHeight * ratio;
+  const context = canvas.getContext("2d");
+  context.clearRect(0, 0, canvas.width, canvas.height);
+
+  const width = canvas.width / levels.length;
+  levels.forEach((level, bar) => {
+    const height = Math.max(2 * ratio, level * canvas.height);
+    context.fillStyle = (bar + 0.5) / levels.length <= progress ? played : unplayed;
+    context.fillRect(bar * width + ratio, (canvas.height - height) / 2, Math.max(ratio, width - 2 * ratio), height);
+  });
+}
+
+// setUpPlayer wires up the player markup of a quip
+function setUpPlayer(player) {
+  const audio = player.querySelector("audio");
+  const button = player.querySelector(".play");
+  const canvas = player.querySelector(".waveform");
+  // a flat line until the waveform is drawn, or when the audio can't be decoded
+  let levels = new Array(bars).fill(0);
+
+  const progress = () => (audio.duration ? audio.currentTime / audio.duration : 0);
+  const redraw = () => draw(canvas, levels, progress());
+
+  fetchAudio(player.dataset.stream)
+    .then((blob) => {
+      // play what was downloaded rather than fetching it again, unless playback already started
+      if (audio.paused && audio.currentTime === 0) {
+        audio.src = URL.createObjectURL(blob);
+      }
+      return peaks(blob);
+    })
+    .then((result) => {
+      levels = result;
+      redraw();
+    })
+    .catch(() => player.classList.add("no-waveform"));
+
+  button.addEventListener("click", () => {
+    if (audio.paused) {
+      // only one quip plays at a time
+      document.querySelectorAll(".player audio").forEach((other) => other !== audio && other.pause());
+      audio.play();
+    } else {
+      audio.pause();
+    }
+  });
+  audio.addEventListener("play", () => {
+    button.innerHTML = "&#10074;&#10074;";
+    button.setAttribute("aria-label", "Pause");
+  });
+  audio.addEventListener("pause", () => {
+    button.innerHTML = "&#9654;";
+    button.setAttribute("aria-label", "Play");
+  });
+  audio.addEventListener("timeupdate", redraw);
+  audio.addEventListener("ended", redraw);
+
+  canvas.addEventListener("click", (event) => {
+    const seek = () => {
+      audio.currentTime = ((event.clientX - canvas.getBoundingClientRect().left) / canvas.clientWidth) * audio.duration;
+    };
+    if (audio.readyState > 0) {
+      seek();
+    } else {
+      audio.addEventListener("loadedmetadata", seek, { once: true });
+      audio.load();
+    }
+  });
+  window.addEventListener("resize", redraw);
+  redraw();
+}
+
+document.querySelectorAll(".player").forEach(setUpPlayer);
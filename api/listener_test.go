@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/stretchr/testify/assert"
+)
+
+// quipStorer only knows the quip with ID 1
+type quipStorer struct {
+	file.Storer
+}
+
+func (quipStorer) FindById(ctx context.Context, id string) (*file.FileRecord, error) {
+	if id != "1" {
+		return nil, file.ErrNoRowsFound
+	}
+	return &file.FileRecord{ID: 1, Filename: "gm.mp3", FileType: "audio/mpeg",
+		Metadata: file.Metadata{Title: `Good "morning"`, Artist: "Phillip"}}, nil
+}
+
+func newListenerRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, nil, quipStorer{}, WithListener()).router()
+	assert.NoError(t, err)
+	return r
+}
+
+func TestListenerPages(t *testing.T) {
+	r := newListenerRouter(t)
+
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedCSP  string
+		expectedBody []string
+	}{
+		{
+			name:         "Browse",
+			path:         "/quips",
+			expectedCode: http.StatusOK,
+			expectedCSP:  listenerCSP,
+			expectedBody: []string{`<meta name="base-path" content="` + testBasePath + `">`, "/listener/browse.js"},
+		},
+		{
+			name:         "Quip",
+			path:         "/quips/1",
+			expectedCode: http.StatusOK,
+			expectedCSP:  listenerCSP,
+			expectedBody: []string{
+				`<meta property="og:title" content="Good &#34;morning&#34;">`,
+				`<meta name="twitter:player" content="http://example.com/embed/1">`,
+				`<meta property="og:audio" content="http://example.com` + testBasePath + `/audio/1/stream">`,
+				`type="application/json+oembed" href="http://example.com/oembed?format=json&amp;url=http%3A%2F%2Fexample.com%2Fquips%2F1"`,
+			},
+		},
+		{
+			name:         "Embed",
+			path:         "/embed/1",
+			expectedCode: http.StatusOK,
+			expectedCSP:  embedCSP,
+			expectedBody: []string{`data-stream="http://example.com` + testBasePath + `/audio/1/stream"`, "Phillip", `class="waveform"`},
+		},
+		{name: "UnknownQuip", path: "/quips/2", expectedCode: http.StatusNotFound, expectedCSP: listenerCSP, expectedBody: []string{"doesn't exist"}},
+		{name: "MalformedID", path: "/embed/gm", expectedCode: http.StatusNotFound, expectedCSP: embedCSP, expectedBody: []string{"doesn't exist"}},
+		{name: "Script", path: "/listener/player.js", expectedCode: http.StatusOK, expectedBody: []string{"decodeAudioData"}},
+		{name: "Template", path: "/listener/quip.html", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedCSP, w.Header().Get("Content-Security-Policy"))
+			for _, fragment := range tc.expectedBody {
+				assert.Contains(t, w.Body.String(), fragment)
+			}
+		})
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	r := newListenerRouter(t)
+
+	testCases := []struct {
+		name           string
+		query          url.Values
+		expectedCode   int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{name: "QuipPage", query: url.Values{"url": {"http://example.com/quips/1"}}, expectedCode: http.StatusOK, expectedWidth: embedWidth, expectedHeight: embedHeight},
+		{name: "EmbedPage", query: url.Values{"url": {"http://example.com/embed/1/"}, "format": {"json"}}, expectedCode: http.StatusOK, expectedWidth: embedWidth, expectedHeight: embedHeight},
+		{name: "MaxSize", query: url.Values{"url": {"http://example.com/quips/1"}, "maxwidth": {"300"}, "maxheight": {"500"}}, expectedCode: http.StatusOK, expectedWidth: 300, expectedHeight: embedHeight},
+		{name: "OtherSite", query: url.Values{"url": {"http://elsewhere.com/quips/1"}}, expectedCode: http.StatusNotFound},
+		{name: "NotAQuip", query: url.Values{"url": {"http://example.com/admin/"}}, expectedCode: http.StatusNotFound},
+		{name: "UnknownQuip", query: url.Values{"url": {"http://example.com/quips/2"}}, expectedCode: http.StatusNotFound},
+		{name: "XML", query: url.Values{"url": {"http://example.com/quips/1"}, "format": {"xml"}}, expectedCode: http.StatusNotImplemented},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oembed?"+tc.query.Encode(), nil))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			var response oEmbed
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "rich", response.Type)
+			assert.Equal(t, `Good "morning"`, response.Title)
+			assert.Equal(t, "Phillip", response.AuthorName)
+			assert.Equal(t, tc.expectedWidth, response.Width)
+			assert.Equal(t, tc.expectedHeight, response.Height)
+			assert.Contains(t, response.HTML, `<iframe src="http://example.com/embed/1"`)
+			assert.Contains(t, response.HTML, `title="Good &#34;morning&#34;"`)
+		})
+	}
+}
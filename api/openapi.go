@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIDocument describes every route registered by StartRouter. TestOpenAPI_CoversRoutes
+// fails when they drift apart
+//
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// loadOpenAPI parses and validates the OpenAPI document, pointing its server at the base path
+func loadOpenAPI(basePath string) (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	spec.Servers = openapi3.Servers{{URL: basePath}}
+	return spec, nil
+}
+
+// serveOpenAPI answers with the OpenAPI document as JSON
+func serveOpenAPI(spec *openapi3.T) (gin.HandlerFunc, error) {
+	document, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", document)
+	}, nil
+}
+
+// serveDocs answers with a Redoc page rendering /openapi.json
+func serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// openAPIPath turns a Gin route ("/audio/:id") into its OpenAPI path ("/audio/{id}")
+func openAPIPath(route string) string {
+	segments := strings.Split(route, "/")
+	for n, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[n] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// validateRequests rejects requests whose parameters or body don't match the operation the
+// OpenAPI document describes for their route. Routes it doesn't describe are let through
+func validateRequests(spec *openapi3.T, basePath string) gin.HandlerFunc {
+	options := &openapi3filter.Options{
+		// authentication is left to authenticate and requireRole
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults: true,
+	}
+	options.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+		if pointer := err.JSONPointer(); len(pointer) > 0 {
+			return "/" + strings.Join(pointer, "/") + ": " + err.Reason
+		}
+		return err.Reason
+	})
+
+	return func(c *gin.Context) {
+		path := openAPIPath(strings.TrimPrefix(c.FullPath(), basePath))
+		pathItem := spec.Paths[path]
+		if pathItem == nil || pathItem.GetOperation(c.Request.Method) == nil {
+			c.Next()
+			return
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for _, param := range c.Params {
+			params[param.Key] = param.Value
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route: &routers.Route{
+				Spec:      spec,
+				Server:    spec.Servers[0],
+				Path:      path,
+				PathItem:  pathItem,
+				Method:    c.Request.Method,
+				Operation: pathItem.GetOperation(c.Request.Method),
+			},
+			Options: options,
+		}
+		if err := openapi3filter.ValidateRequest(c, input); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortWithDomainError(c, err)
+				return
+			}
+			c.Error(&APIError{StatusCode: http.StatusBadRequest, Code: CodeValidationFailed, Err: err})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBasePath = "/api/v1/voice-quips"
+
+// newTestServer returns a server with every optional dependency set, so that all routes are
+// registered. The dependencies are nil and must not be called
+func newTestServer() *APIServer {
+	gin.SetMode(gin.TestMode)
+	return NewAPIServer(config.APIConfig{Path: testBasePath, Env: "dev"}, nil, (*file.FileInformationService)(nil),
+		WithCategoryService((*file.CategoryService)(nil)),
+		WithFeedbackService((*file.FeedbackService)(nil)),
+		WithPlaylistService((*playlist.PlaylistService)(nil)),
+		WithStats(nil, (*stats.StatsService)(nil)),
+		WithJobs((*jobs.PostgresQueue)(nil)),
+		WithTranscripts((*transcript.PostgresStore)(nil)),
+		WithDashboard(),
+		WithStorageUsage((*s3.MinioClient)(nil)),
+		WithShareService((*share.Service)(nil)),
+	)
+}
+
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	r, err := newTestServer().router()
+	assert.NoError(t, err)
+	spec, err := loadOpenAPI(testBasePath)
+	assert.NoError(t, err)
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		if !strings.HasPrefix(route.Path, testBasePath) {
+			continue
+		}
+		path := openAPIPath(strings.TrimPrefix(route.Path, testBasePath))
+		registered[route.Method+" "+path] = true
+
+		pathItem := spec.Paths[path]
+		if assert.NotNil(t, pathItem, "%s %s is missing from the OpenAPI document", route.Method, path) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), "%s %s is missing from the OpenAPI document", route.Method, path)
+		}
+	}
+
+	for path, pathItem := range spec.Paths {
+		for method := range pathItem.Operations() {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	r, err := newTestServer().router()
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+	assert.Equal(t, testBasePath, document.Servers[0].URL)
+}
+
+func TestValidateRequests(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		route          string
+		target         string
+		body           string
+		expectedStatus int
+	}{
+		{name: "ValidQuery", method: http.MethodGet, route: "/audio/", target: "/audio/?sort=rating&tag=funny&tag=short", expectedStatus: http.StatusOK},
+		{name: "UnknownSort", method: http.MethodGet, route: "/audio/", target: "/audio/?sort=longest", expectedStatus: http.StatusBadRequest},
+		{name: "NonNumericID", method: http.MethodGet, route: "/audio/:id", target: "/audio/abc", expectedStatus: http.StatusBadRequest},
+		{name: "ValidBody", method: http.MethodPut, route: "/audio/:id/rating", target: "/audio/1/rating", body: `{"score": 4}`, expectedStatus: http.StatusOK},
+		{name: "ScoreOutOfRange", method: http.MethodPut, route: "/audio/:id/rating", target: "/audio/1/rating", body: `{"score": 9}`, expectedStatus: http.StatusBadRequest},
+		{name: "MissingRequiredField", method: http.MethodPost, route: "/playlists", target: "/playlists", body: `{"public": true}`, expectedStatus: http.StatusBadRequest},
+		{name: "UndocumentedRoute", method: http.MethodGet, route: "/undocumented", target: "/undocumented?anything=goes", expectedStatus: http.StatusOK},
+	}
+
+	spec, err := loadOpenAPI(testBasePath)
+	assert.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			a := &APIServer{env: "dev"}
+			r := gin.New()
+			r.Use(requestID, a.handleErrors)
+			v1 := r.Group(testBasePath, validateRequests(spec, testBasePath))
+			// the handler echoes the body to check the validation left it readable
+			v1.Handle(tc.method, tc.route, func(c *gin.Context) {
+				body, _ := io.ReadAll(c.Request.Body)
+				c.Data(http.StatusOK, "application/json", body)
+			})
+			req := httptest.NewRequest(tc.method, testBasePath+tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.body, w.Body.String())
+				return
+			}
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, CodeValidationFailed, problem.Code)
+		})
+	}
+}
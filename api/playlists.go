@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+)
+
+// GET /api/v1/playlists
+// Returns the public playlists, plus the caller's own private ones
+func (a *APIServer) getPlaylists(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	playlists, err := a.playlistService.FindAll(c, user.Name)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve playlists", "err", err)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, playlists)
+}
+
+// GET /api/v1/playlists/{id}
+func (a *APIServer) getPlaylist(c *gin.Context) {
+	found, ok := a.findPlaylist(c)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, found)
+}
+
+// GET /api/v1/playlists/{id}/export?format={m3u8,xspf}
+// Exports the playlist in a format media players understand, pointing at the stream URLs
+func (a *APIServer) exportPlaylist(c *gin.Context) {
+	found, ok := a.findPlaylist(c)
+	if !ok {
+		return
+	}
+
+	streamURL := func(fileID uint) string {
+		return a.absoluteURL(c, fmt.Sprintf("/audio/%d/stream", fileID))
+	}
+
+	filename := fmt.Sprintf("playlist-%d", found.ID)
+	switch strings.ToLower(c.DefaultQuery("format", "m3u8")) {
+	case "m3u8", "m3u":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.m3u8"`, filename))
+		c.Data(http.StatusOK, playlist.M3U8ContentType, playlist.ExportM3U8(found, streamURL))
+	case "xspf":
+		document, err := playlist.ExportXSPF(found, streamURL)
+		if err != nil {
+			log.ErrorContext(c, "could not export playlist", "err", err, "id", found.ID)
+			abortWithError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xspf"`, filename))
+		c.Data(http.StatusOK, playlist.XSPFContentType, document)
+	default:
+		abortWithError(c, http.StatusBadRequest, errors.New("supported export formats are m3u8 and xspf"))
+	}
+}
+
+// POST /api/v1/playlists
+func (a *APIServer) createPlaylist(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	var request playlist.Playlist
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.ErrorContext(c, "invalid playlist request", "err", err)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.playlistService.Create(c, user.Name, request)
+	if err != nil {
+		log.ErrorContext(c, "could not create playlist", "err", err, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, saved)
+}
+
+// PATCH /api/v1/playlists/{id}
+// Renames the playlist and/or changes its visibility
+func (a *APIServer) updatePlaylist(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return
+	}
+
+	var update playlist.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		log.ErrorContext(c, "invalid playlist request", "err", err, "id", id)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.playlistService.Update(c, user.Name, id, update)
+	if err != nil {
+		log.ErrorContext(c, "could not update playlist", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, saved)
+}
+
+// DELETE /api/v1/playlists/{id}
+func (a *APIServer) deletePlaylist(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return
+	}
+
+	if err := a.playlistService.Delete(c, user.Name, id); err != nil {
+		log.ErrorContext(c, "could not delete playlist", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /api/v1/playlists/{id}/items
+// Adds a quip at the given position, or at the end when no position is given
+func (a *APIServer) addPlaylistItem(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return
+	}
+
+	var item playlist.Item
+	if err := c.ShouldBindJSON(&item); err != nil {
+		log.ErrorContext(c, "invalid playlist item request", "err", err, "id", id)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.playlistService.AddItem(c, user.Name, id, item)
+	if err != nil {
+		log.ErrorContext(c, "could not add playlist item", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, saved)
+}
+
+// PUT /api/v1/playlists/{id}/items
+// Replaces every item; used to reorder the playlist and to assign soundboard hotkeys
+func (a *APIServer) replacePlaylistItems(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return
+	}
+
+	var items []playlist.Item
+	if err := c.ShouldBindJSON(&items); err != nil {
+		log.ErrorContext(c, "invalid playlist items request", "err", err, "id", id)
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	saved, err := a.playlistService.ReplaceItems(c, user.Name, id, items)
+	if err != nil {
+		log.ErrorContext(c, "could not replace playlist items", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, saved)
+}
+
+// DELETE /api/v1/playlists/{id}/items/{position}
+func (a *APIServer) removePlaylistItem(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return
+	}
+
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("position must be a number"))
+		return
+	}
+
+	saved, err := a.playlistService.RemoveItem(c, user.Name, id, position)
+	if err != nil {
+		log.ErrorContext(c, "could not remove playlist item", "err", err, "id", id, "position", position)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, saved)
+}
+
+// findPlaylist looks up the playlist of the request, writing the error response when it can't be shown
+func (a *APIServer) findPlaylist(c *gin.Context) (*playlist.Playlist, bool) {
+	user, _ := currentUser(c)
+	id, ok := playlistID(c)
+	if !ok {
+		return nil, false
+	}
+
+	found, err := a.playlistService.FindById(c, user.Name, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following playlist failed", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return nil, false
+	}
+	return found, true
+}
+
+func playlistID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("playlist id must be a number"))
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	requestIDHeader    = "X-Request-Id"
+	requestIDKey       = "requestId"
+
+	// internalErrorDetail replaces the detail of server-side errors in prod
+	internalErrorDetail = "an issue occurred server-side"
+)
+
+// Machine-readable error codes. Clients can rely on these not changing
+const (
+	CodeBadRequest            = "bad_request"
+	CodeValidationFailed      = "validation_failed"
+	CodeUnauthenticated       = "unauthenticated"
+	CodeForbidden             = "forbidden"
+	CodeNotFound              = "not_found"
+	CodeRouteNotFound         = "route_not_found"
+	CodeConflict              = "conflict"
+	CodeUnknownCategory       = "unknown_category"
+	CodePayloadTooLarge       = "payload_too_large"
+	CodeRateLimited           = "rate_limited"
+	CodeQuotaExceeded         = "quota_exceeded"
+	CodeNotImplemented        = "not_implemented"
+	CodeLinkUnavailable       = "link_unavailable"
+	CodeDatabaseError         = "database_error"
+	CodeStorageUploadFailed   = "storage_upload_failed"
+	CodeStorageDownloadFailed = "storage_download_failed"
+	CodeInternalError         = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details body. Every error the API answers with is one
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type errorMapping struct {
+	target error
+	status int
+	code   string
+}
+
+// errorMappings maps the domain errors handlers pass along to the status and code they're
+// answered with. The first match wins
+var errorMappings = []errorMapping{
+	{file.ErrNoRowsFound, http.StatusNotFound, CodeNotFound},
+	{playlist.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{playlist.ErrItemNotFound, http.StatusNotFound, CodeNotFound},
+	{stats.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{jobs.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{transcript.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{ErrNotAQuipURL, http.StatusNotFound, CodeNotFound},
+	{file.ErrNoCover, http.StatusNotFound, CodeNotFound},
+	{share.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{share.ErrInvalidToken, http.StatusNotFound, CodeNotFound},
+	{share.ErrUnknownFile, http.StatusNotFound, CodeNotFound},
+	{share.ErrExpired, http.StatusGone, CodeLinkUnavailable},
+	{share.ErrRevoked, http.StatusGone, CodeLinkUnavailable},
+	{share.ErrExhausted, http.StatusGone, CodeLinkUnavailable},
+	{file.ErrDuplicateKey, http.StatusConflict, CodeConflict},
+	{ErrUnauthenticated, http.StatusUnauthorized, CodeUnauthenticated},
+	{file.ErrUserMissing, http.StatusUnauthorized, CodeUnauthenticated},
+	{ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{share.ErrNotOwner, http.StatusForbidden, CodeForbidden},
+	{playlist.ErrNotOwner, http.StatusForbidden, CodeForbidden},
+	{ErrFileTooLarge, http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+	{ErrRateLimited, http.StatusTooManyRequests, CodeRateLimited},
+	{ErrQuotaExceeded, http.StatusTooManyRequests, CodeQuotaExceeded},
+	{file.ErrUnknownCategory, http.StatusBadRequest, CodeUnknownCategory},
+	{file.ErrUnknownSort, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrInvalidID, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrCategoryNameMissing, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrCategorySlugInvalid, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrCategoryCycle, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrParentCategoryNotFound, http.StatusBadRequest, CodeValidationFailed},
+	{file.ErrScoreOutOfRange, http.StatusBadRequest, CodeValidationFailed},
+	{playlist.ErrNameMissing, http.StatusBadRequest, CodeValidationFailed},
+	{playlist.ErrNameTooLong, http.StatusBadRequest, CodeValidationFailed},
+	{playlist.ErrUnknownFile, http.StatusBadRequest, CodeValidationFailed},
+	{playlist.ErrHotkeyTooLong, http.StatusBadRequest, CodeValidationFailed},
+	{playlist.ErrDuplicateHotkey, http.StatusBadRequest, CodeValidationFailed},
+	{stats.ErrUnknownPeriod, http.StatusBadRequest, CodeValidationFailed},
+	{stats.ErrUnknownMetric, http.StatusBadRequest, CodeValidationFailed},
+	{share.ErrExpiryOutOfRange, http.StatusBadRequest, CodeValidationFailed},
+	{share.ErrMaxDownloadsNegative, http.StatusBadRequest, CodeValidationFailed},
+	{ErrUnsupportedFormat, http.StatusNotImplemented, CodeNotImplemented},
+	{jobs.ErrDatabase, http.StatusInternalServerError, CodeDatabaseError},
+}
+
+// statusCodes are the codes of errors nothing more specific is known about
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusTooManyRequests:       CodeRateLimited,
+}
+
+// mapError returns the status and code a domain error is answered with, if it's known
+func mapError(err error) (int, string, bool) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.target) {
+			return m.status, m.code, true
+		}
+	}
+
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var uploadErr *s3.UploadError
+	var downloadErr *s3.DownloadError
+	var dbErr *file.DBError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, CodePayloadTooLarge, true
+	case errors.As(err, &validationErrs), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return http.StatusBadRequest, CodeValidationFailed, true
+	case errors.As(err, &uploadErr):
+		return http.StatusInternalServerError, CodeStorageUploadFailed, true
+	case errors.As(err, &downloadErr):
+		return http.StatusInternalServerError, CodeStorageDownloadFailed, true
+	case errors.As(err, &dbErr):
+		return http.StatusInternalServerError, CodeDatabaseError, true
+	}
+	return 0, "", false
+}
+
+// abortWithError stops the request, leaving the answer to handleErrors
+func abortWithError(c *gin.Context, status int, err error) {
+	c.Error(NewAPIError(status, err))
+	c.Abort()
+}
+
+// abortWithDomainError stops the request with the status its error maps to
+func abortWithDomainError(c *gin.Context, err error) {
+	abortWithError(c, 0, err)
+}
+
+// newProblem describes the error. Server-side details are hidden unless showDetails is set
+func newProblem(err error, showDetails bool) Problem {
+	status, code := 0, ""
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		status, code, err = apiErr.StatusCode, apiErr.Code, apiErr.Err
+	}
+
+	mappedStatus, mappedCode, mapped := mapError(err)
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if mapped {
+			status = mappedStatus
+		}
+	}
+	if code == "" && mapped && mappedStatus == status {
+		code = mappedCode
+	}
+	if code == "" {
+		code = CodeInternalError
+		if statusCode, ok := statusCodes[status]; ok {
+			code = statusCode
+		}
+	}
+
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+	}
+	if status >= http.StatusInternalServerError && !showDetails {
+		detail = internalErrorDetail
+	}
+
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// requestID tags every request with an id, reusing the one the caller sent if it looks sane
+func requestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Error("could not generate a request id", "err", err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// handleErrors answers requests that stopped with an error with a problem+json body
+func (a *APIServer) handleErrors(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	problem := newProblem(err, a.env != "prod")
+	problem.Instance = c.Request.URL.Path
+	problem.RequestID = c.GetString(requestIDKey)
+	if problem.Status >= http.StatusInternalServerError {
+		log.ErrorContext(c, "request failed", "err", err, "status", problem.Status, "code", problem.Code, "requestId", problem.RequestID)
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// recoverPanic turns a panicking handler into a 500
+func recoverPanic(c *gin.Context, recovered any) {
+	log.ErrorContext(c, "handler panicked", "panic", recovered, "requestId", c.GetString(requestIDKey))
+	abortWithError(c, http.StatusInternalServerError, errors.New("handler panicked"))
+}
+
+// routeNotFound answers requests for routes that don't exist
+func routeNotFound(c *gin.Context) {
+	c.Error(&APIError{StatusCode: http.StatusNotFound, Code: CodeRouteNotFound, Err: errors.New("no route matches " + c.Request.Method + " " + c.Request.URL.Path)})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProblemRouter(env string, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	a := &APIServer{env: env}
+	r := gin.New()
+	r.Use(requestID, a.handleErrors, gin.CustomRecovery(recoverPanic))
+	r.NoRoute(routeNotFound)
+	r.GET("/test", handler)
+	return r
+}
+
+func TestHandleErrors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            string
+		path           string
+		handler        gin.HandlerFunc
+		expectedStatus int
+		expectedCode   string
+		expectedDetail string
+	}{
+		{
+			name:           "NotFound",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, file.NoRowsFoundError("id 1")) },
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   CodeNotFound,
+		},
+		{
+			name:           "WrappedDomainError",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, fmt.Errorf("saving: %w", playlist.ErrNotOwner)) },
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   CodeForbidden,
+			expectedDetail: "saving: only the playlist's owner can change it",
+		},
+		{
+			name:           "Validation",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, file.ErrScoreOutOfRange) },
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   CodeValidationFailed,
+			expectedDetail: file.ErrScoreOutOfRange.Error(),
+		},
+		{
+			name:           "InvalidID",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, file.ErrInvalidID) },
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   CodeValidationFailed,
+			expectedDetail: "id must be a number",
+		},
+		{
+			name:           "ExplicitStatus",
+			handler:        func(c *gin.Context) { abortWithError(c, http.StatusBadRequest, errors.New("id must be a number")) },
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   CodeBadRequest,
+			expectedDetail: "id must be a number",
+		},
+		{
+			name:           "StorageUpload",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, &s3.UploadError{Err: errors.New("bucket gone")}) },
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeStorageUploadFailed,
+			expectedDetail: "an error occurred while uploading to storage: bucket gone",
+		},
+		{
+			name:           "DetailsHiddenInProd",
+			env:            "prod",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, file.NewDBError(errors.New("connection refused"))) },
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeDatabaseError,
+			expectedDetail: internalErrorDetail,
+		},
+		{
+			name:           "JobsDatabase",
+			handler:        func(c *gin.Context) { abortWithDomainError(c, fmt.Errorf("counting: %w", jobs.ErrDatabase)) },
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeDatabaseError,
+		},
+		{
+			name:           "Panic",
+			handler:        func(c *gin.Context) { panic("boom") },
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   CodeInternalError,
+		},
+		{
+			name:           "RouteNotFound",
+			path:           "/missing",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   CodeRouteNotFound,
+			expectedDetail: "no route matches GET /missing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := tc.path
+			if path == "" {
+				path = "/test"
+			}
+			r := newProblemRouter(tc.env, tc.handler)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tc.expectedStatus, problem.Status)
+			assert.Equal(t, tc.expectedCode, problem.Code)
+			assert.Equal(t, path, problem.Instance)
+			assert.Equal(t, w.Header().Get(requestIDHeader), problem.RequestID)
+			assert.NotEmpty(t, problem.RequestID)
+			if tc.expectedDetail != "" {
+				assert.Equal(t, tc.expectedDetail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{name: "Generated", incoming: "", reused: false},
+		{name: "Reused", incoming: "abc-123", reused: true},
+		{name: "InvalidReplaced", incoming: "bad id\n", reused: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newProblemRouter("dev", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(requestIDHeader, tc.incoming)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(requestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, tc.reused, id == tc.incoming)
+		})
+	}
+}
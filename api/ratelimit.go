@@ -0,0 +1,121 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/ratelimit"
+)
+
+// RateLimits are the limits callers are held to
+type RateLimits struct {
+	// Default applies to the routes without a limit of their own, which share one bucket per caller
+	Default ratelimit.Limit
+	// Routes are keyed by method and route as registered (eg. "POST /audio"). Each gets a bucket
+	// of its own per caller
+	Routes map[string]ratelimit.Limit
+	// Quota caps the uploads of each caller per day
+	Quota ratelimit.QuotaLimit
+}
+
+// WithRateLimit holds every caller to the limits. The quota is left out when quota is nil
+func WithRateLimit(limiter ratelimit.Limiter, quota ratelimit.Quota, limits RateLimits) Option {
+	return func(a *APIServer) {
+		a.limiter = limiter
+		a.quota = quota
+		a.limits = limits
+	}
+}
+
+// callerKey tells callers apart: by the user their API key authenticates as, or by IP
+func callerKey(c *gin.Context) string {
+	if user, ok := currentUser(c); ok {
+		return "user:" + user.Name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// limitFor returns the limit of the route and the bucket it's counted in
+func (a *APIServer) limitFor(method, route string) (ratelimit.Limit, string) {
+	routeKey := method + " " + route
+	if limit, ok := a.limits.Routes[routeKey]; ok {
+		return limit, routeKey
+	}
+	return a.limits.Default, "default"
+}
+
+// rateLimit answers callers that went over the limit of the route with 429. The limiter failing
+// lets requests through, an outage of its backend shouldn't take the API down with it
+func (a *APIServer) rateLimit(c *gin.Context) {
+	limit, bucket := a.limitFor(c.Request.Method, strings.TrimPrefix(c.FullPath(), a.basePath))
+	if limit.Unlimited() {
+		c.Next()
+		return
+	}
+
+	key := callerKey(c)
+	result, err := a.limiter.Allow(c, key+":"+bucket, limit)
+	if err != nil {
+		log.ErrorContext(c, "could not check the rate limit, letting the request through", "err", err, "caller", key)
+		c.Next()
+		return
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", headerSeconds(result.Reset))
+	if !result.Allowed {
+		log.WarnContext(c, "request rate limited", "caller", key, "bucket", bucket)
+		c.Header("Retry-After", headerSeconds(result.RetryAfter))
+		abortWithError(c, http.StatusTooManyRequests, ErrRateLimited)
+		return
+	}
+	c.Next()
+}
+
+// consumeQuota counts the upload against the caller's daily quota, answering 429 when it doesn't
+// fit. It reports whether the upload can go ahead; if it does, uploads that fail are given back
+func (a *APIServer) consumeQuota(c *gin.Context, size int64) bool {
+	if a.quota == nil || a.limits.Quota.Unlimited() {
+		return true
+	}
+
+	key := callerKey(c)
+	result, err := a.quota.Consume(c, key, size, a.limits.Quota)
+	if err != nil {
+		log.ErrorContext(c, "could not check the upload quota, letting the upload through", "err", err, "caller", key)
+		return true
+	}
+	if !result.Allowed {
+		log.WarnContext(c, "upload quota exceeded", "caller", key, "uploads", result.Usage.Uploads, "bytes", result.Usage.Bytes)
+		c.Header("Retry-After", headerSeconds(result.Reset))
+		abortWithError(c, http.StatusTooManyRequests, ErrQuotaExceeded)
+		return false
+	}
+	return true
+}
+
+// refundFailedUpload gives back the quota consumed by an upload that didn't go through
+func (a *APIServer) refundFailedUpload(c *gin.Context, size int64) {
+	if a.quota == nil || a.limits.Quota.Unlimited() {
+		return
+	}
+	if len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest {
+		return
+	}
+
+	if err := a.quota.Refund(c, callerKey(c), size); err != nil {
+		log.ErrorContext(c, "could not refund the upload quota", "err", err, "caller", callerKey(c))
+	}
+}
+
+// headerSeconds rounds up to whole seconds, the unit of the RateLimit-Reset and Retry-After headers
+func headerSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingLimiter stands in for a limiter whose backend is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// tagsStorer only answers FindAllTags, the other methods must not be called
+type tagsStorer struct {
+	file.Storer
+}
+
+func (tagsStorer) FindAllTags(ctx context.Context) ([]*file.Tag, error) {
+	return []*file.Tag{}, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	limits := RateLimits{
+		Default: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		Routes:  map[string]ratelimit.Limit{"GET /tags": {Requests: 1, Period: time.Minute}},
+	}
+
+	testCases := []struct {
+		name           string
+		limiter        ratelimit.Limiter
+		requests       []string
+		apiKeys        []string
+		forwardedFor   []string
+		trustedProxies []string
+		expectedCodes  []int
+	}{
+		{
+			name:          "DefaultLimit",
+			limiter:       ratelimit.NewMemoryLimiter(),
+			requests:      []string{"/ping", "/ping", "/ping"},
+			expectedCodes: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:          "RouteLimitHasItsOwnBucket",
+			limiter:       ratelimit.NewMemoryLimiter(),
+			requests:      []string{"/tags", "/ping", "/ping", "/tags"},
+			expectedCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:          "UsersHaveTheirOwnBuckets",
+			limiter:       ratelimit.NewMemoryLimiter(),
+			requests:      []string{"/ping", "/ping", "/ping"},
+			apiKeys:       []string{"alice-key", "alice-key", "bob-key"},
+			expectedCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:          "ForwardedForIgnored",
+			limiter:       ratelimit.NewMemoryLimiter(),
+			requests:      []string{"/ping", "/ping", "/ping"},
+			forwardedFor:  []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+			expectedCodes: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		// httptest requests come from 192.0.2.1
+		{
+			name:           "TrustedProxyForwardsCallers",
+			limiter:        ratelimit.NewMemoryLimiter(),
+			requests:       []string{"/ping", "/ping", "/ping"},
+			forwardedFor:   []string{"198.51.100.1", "198.51.100.1", "198.51.100.2"},
+			trustedProxies: []string{"192.0.2.0/24"},
+			expectedCodes:  []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:          "LimiterDown",
+			limiter:       failingLimiter{},
+			requests:      []string{"/ping", "/ping", "/ping"},
+			expectedCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			server := NewAPIServer(config.APIConfig{Path: testBasePath, Auth: config.AuthConfig{Keys: []config.APIKey{
+				{Key: "alice-key", User: "alice"},
+				{Key: "bob-key", User: "bob"},
+			}}, TrustedProxies: tc.trustedProxies}, nil, tagsStorer{}, WithRateLimit(tc.limiter, nil, limits))
+			r, err := server.router()
+			assert.NoError(t, err)
+
+			for n, path := range tc.requests {
+				req := httptest.NewRequest(http.MethodGet, testBasePath+path, nil)
+				if n < len(tc.apiKeys) {
+					req.Header.Set(apiKeyHeader, tc.apiKeys[n])
+				}
+				if n < len(tc.forwardedFor) {
+					req.Header.Set("X-Forwarded-For", tc.forwardedFor[n])
+				}
+				w := httptest.NewRecorder()
+
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.expectedCodes[n], w.Code, "request %d to %s", n, path)
+				if w.Code != http.StatusTooManyRequests {
+					continue
+				}
+				var problem Problem
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, CodeRateLimited, problem.Code)
+				assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestConsumeQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := newTestServer()
+	WithRateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewMemoryQuota(), RateLimits{Quota: ratelimit.QuotaLimit{Uploads: 2}})(server)
+
+	// uploads named "fail" go wrong after the quota was consumed
+	r := gin.New()
+	r.Use(requestID, server.handleErrors)
+	r.POST("/upload/:name", func(c *gin.Context) {
+		if !server.consumeQuota(c, 10) {
+			return
+		}
+		defer server.refundFailedUpload(c, 10)
+		if c.Param("name") == "fail" {
+			abortWithError(c, http.StatusInternalServerError, errors.New("storage is down"))
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	expected := []struct {
+		name string
+		code int
+	}{
+		{"first", http.StatusCreated},
+		{"fail", http.StatusInternalServerError},
+		{"second", http.StatusCreated},
+		{"third", http.StatusTooManyRequests},
+	}
+	for _, e := range expected {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload/"+e.name, nil))
+
+		assert.Equal(t, e.code, w.Code, e.name)
+		if e.code == http.StatusTooManyRequests {
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, CodeQuotaExceeded, problem.Code)
+			assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		}
+	}
+}
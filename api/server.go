@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/certs"
+	"github.com/phllpmcphrsn/voice-quips/config"
+)
+
+// Defaults of the HTTP server settings left out of the config
+const (
+	DefaultReadTimeout       = 5 * time.Minute
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 5 * time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+	DefaultMaxBodyBytes      = 32 << 20
+	DefaultHSTSMaxAge        = 365 * 24 * time.Hour
+	// audio never changes once stored, metadata may so clients must check it's still current
+	DefaultAudioCacheControl    = "public, max-age=31536000, immutable"
+	DefaultMetadataCacheControl = "no-cache"
+)
+
+// serverSettings are the limits of the HTTP server, with defaults filled in
+type serverSettings struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	maxHeaderBytes    int
+	maxBodyBytes      int64
+	trustedProxies    []string
+	trustedNetworks   []*net.IPNet
+	publicURL         string
+	tls               config.TLSConfig
+	cacheControl      config.CacheControlConfig
+}
+
+func newServerSettings(cfg config.APIConfig) serverSettings {
+	s := serverSettings{
+		readTimeout:       cfg.ReadTimeout,
+		readHeaderTimeout: cfg.ReadHeaderTimeout,
+		writeTimeout:      cfg.WriteTimeout,
+		idleTimeout:       cfg.IdleTimeout,
+		shutdownTimeout:   cfg.ShutdownTimeout,
+		maxHeaderBytes:    cfg.MaxHeaderBytes,
+		maxBodyBytes:      cfg.MaxBodyBytes,
+		trustedProxies:    cfg.TrustedProxies,
+		trustedNetworks:   parseNetworks(cfg.TrustedProxies),
+		publicURL:         strings.TrimSuffix(cfg.PublicURL, "/"),
+		tls:               cfg.TLS,
+		cacheControl:      cfg.CacheControl,
+	}
+	if s.readTimeout <= 0 {
+		s.readTimeout = DefaultReadTimeout
+	}
+	if s.readHeaderTimeout <= 0 {
+		s.readHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if s.writeTimeout <= 0 {
+		s.writeTimeout = DefaultWriteTimeout
+	}
+	if s.idleTimeout <= 0 {
+		s.idleTimeout = DefaultIdleTimeout
+	}
+	if s.shutdownTimeout <= 0 {
+		s.shutdownTimeout = DefaultShutdownTimeout
+	}
+	if s.maxHeaderBytes <= 0 {
+		s.maxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if s.maxBodyBytes <= 0 {
+		s.maxBodyBytes = DefaultMaxBodyBytes
+	}
+	if s.tls.HSTSMaxAge <= 0 {
+		s.tls.HSTSMaxAge = DefaultHSTSMaxAge
+	}
+	if s.cacheControl.Audio == "" {
+		s.cacheControl.Audio = DefaultAudioCacheControl
+	}
+	if s.cacheControl.Metadata == "" {
+		s.cacheControl.Metadata = DefaultMetadataCacheControl
+	}
+	return s
+}
+
+// parseNetworks turns the addresses and CIDRs into networks, single addresses being networks of
+// one. Invalid entries are left out, the router refuses them when given the same list
+func parseNetworks(entries []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// fromTrustedProxy reports whether the request was sent by one of the trusted proxies
+func (a *APIServer) fromTrustedProxy(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.settings.trustedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShutdownTimeout is the grace period given to in-flight requests once Run's context is done
+func (a *APIServer) ShutdownTimeout() time.Duration {
+	return a.settings.shutdownTimeout
+}
+
+// httpServer serves the handler with the configured timeouts and limits
+func (a *APIServer) httpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              a.listenAddr,
+		Handler:           handler,
+		ReadTimeout:       a.settings.readTimeout,
+		ReadHeaderTimeout: a.settings.readHeaderTimeout,
+		WriteTimeout:      a.settings.writeTimeout,
+		IdleTimeout:       a.settings.idleTimeout,
+		MaxHeaderBytes:    a.settings.maxHeaderBytes,
+	}
+}
+
+// servesTLS reports whether the API terminates TLS itself
+func (a *APIServer) servesTLS() bool {
+	return a.settings.tls.CertFile != ""
+}
+
+// Run serves the API until the context is done. It then stops accepting connections and waits
+// up to the shutdown timeout for in-flight requests to finish
+func (a *APIServer) Run(ctx context.Context) error {
+	server, err := a.newServer()
+	if err != nil {
+		return err
+	}
+
+	listen := server.ListenAndServe
+	if a.servesTLS() {
+		reloader, err := a.loadCertificate(ctx)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = reloader.TLSConfig()
+		listen = func() error { return server.ListenAndServeTLS("", "") }
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("API server listening", "address", a.listenAddr, "tls", a.servesTLS())
+		errs <- listen()
+	}()
+
+	var redirect *http.Server
+	if a.servesTLS() && a.settings.tls.RedirectAddress != "" {
+		redirect = a.redirectServer()
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("The HTTPS redirect listener stopped", "err", err, "address", redirect.Addr)
+			}
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Info("shutting down the API server", "gracePeriod", a.settings.shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.settings.shutdownTimeout)
+	defer cancel()
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// the requests still running are cut off
+		server.Close()
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServer builds the router and the HTTP server in front of it
+func (a *APIServer) newServer() (*http.Server, error) {
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(ginEnvMode(a.env))
+	}
+
+	r, err := a.router()
+	if err != nil {
+		return nil, err
+	}
+	return a.httpServer(r), nil
+}
+
+// limitBody answers requests whose body is larger than max with 413. Bodies sent without a
+// length are cut off once they reach it
+func limitBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > max {
+			abortWithError(c, http.StatusRequestEntityTooLarge, ErrBodyTooLarge)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		c.Next()
+	}
+}
+
+// loadCertificate reads the certificate and keeps reloading it until the context is done
+func (a *APIServer) loadCertificate(ctx context.Context) (*certs.Reloader, error) {
+	reloader, err := certs.New(certs.Config{
+		CertFile:     a.settings.tls.CertFile,
+		KeyFile:      a.settings.tls.KeyFile,
+		ClientCAFile: a.settings.tls.ClientCAFile,
+		ClientAuth:   a.settings.tls.ClientAuth,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := reloader.Watch(ctx); err != nil {
+			log.Error("The TLS certificate won't be reloaded", "err", err, "certFile", a.settings.tls.CertFile)
+		}
+	}()
+	return reloader, nil
+}
+
+// redirectServer answers plain HTTP requests with a redirect to the same URL over HTTPS
+func (a *APIServer) redirectServer() *http.Server {
+	return &http.Server{
+		Addr:              a.settings.tls.RedirectAddress,
+		Handler:           redirectToHTTPS(a.listenAddr),
+		ReadHeaderTimeout: a.settings.readHeaderTimeout,
+		IdleTimeout:       a.settings.idleTimeout,
+	}
+}
+
+// redirectToHTTPS redirects to the host the request was sent to, on the port of the HTTPS
+// listener. 308 keeps the method and body of the request
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// strictTransportSecurity tells browsers reaching the API over TLS to never use plain HTTP for it
+func strictTransportSecurity(maxAge time.Duration) gin.HandlerFunc {
+	value := fmt.Sprintf("max-age=%d; includeSubDomains", int64(maxAge.Seconds()))
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", value)
+		}
+		c.Next()
+	}
+}
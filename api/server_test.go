@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewServerSettings(t *testing.T) {
+	defaults := newServerSettings(config.APIConfig{})
+	assert.Equal(t, DefaultReadTimeout, defaults.readTimeout)
+	assert.Equal(t, DefaultShutdownTimeout, defaults.shutdownTimeout)
+	assert.Equal(t, int64(DefaultMaxBodyBytes), defaults.maxBodyBytes)
+
+	configured := newServerSettings(config.APIConfig{WriteTimeout: time.Second, MaxHeaderBytes: 512})
+	assert.Equal(t, time.Second, configured.writeTimeout)
+	assert.Equal(t, 512, configured.maxHeaderBytes)
+	assert.Equal(t, DefaultIdleTimeout, configured.idleTimeout)
+}
+
+func TestLimitBody(t *testing.T) {
+	upload := func() (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "quip.mp3")
+		part.Write(bytes.Repeat([]byte("a"), 1024))
+		writer.Close()
+		return &body, writer.FormDataContentType()
+	}
+
+	testCases := []struct {
+		name          string
+		contentLength bool
+	}{
+		// rejected before the handler runs
+		{name: "KnownLength", contentLength: true},
+		// cut off while the body is read
+		{name: "Chunked", contentLength: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer()
+			server.settings.maxBodyBytes = 512
+			r, err := server.router()
+			assert.NoError(t, err)
+
+			body, contentType := upload()
+			var reader io.Reader = body
+			if !tc.contentLength {
+				reader = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, testBasePath+"/audio", reader)
+			req.Header.Set("Content-Type", contentType)
+			if !tc.contentLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, CodePayloadTooLarge, problem.Code)
+		})
+	}
+}
+
+func TestRun_DrainsRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	// the readiness check holds the request open while the server is told to stop
+	started := make(chan struct{})
+	h := health.New(time.Second)
+	h.Add("slow", health.CheckerFunc(func(ctx context.Context) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}))
+	server := newTestServer()
+	server.listenAddr = address
+	WithHealth(h)(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Run(ctx) }()
+
+	var response *http.Response
+	requestDone := make(chan error, 1)
+	go func() {
+		var err error
+		// retried until the server is listening
+		for i := 0; i < 50; i++ {
+			if response, err = http.Get("http://" + address + "/readyz"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		requestDone <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the request never reached the server")
+	}
+	cancel()
+
+	assert.NoError(t, <-requestDone)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.NoError(t, <-stopped)
+
+	_, err = http.Get("http://" + address + "/healthz")
+	assert.Error(t, err, "the server should not accept requests once stopped")
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	testCases := []struct {
+		name             string
+		httpsAddr        string
+		target           string
+		expectedLocation string
+	}{
+		{name: "DefaultPort", httpsAddr: ":443", target: "http://quips.example.com/api/v1/voice-quips/audio?tag=funny", expectedLocation: "https://quips.example.com/api/v1/voice-quips/audio?tag=funny"},
+		{name: "OtherPort", httpsAddr: ":9443", target: "http://quips.example.com:8080/docs", expectedLocation: "https://quips.example.com:9443/docs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			redirectToHTTPS(tc.httpsAddr).ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.target, nil))
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tc.expectedLocation, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestStrictTransportSecurity(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            string
+		tls            bool
+		expectedHeader string
+	}{
+		{name: "ProdOverTLS", env: "prod", tls: true, expectedHeader: "max-age=31536000; includeSubDomains"},
+		{name: "ProdPlainHTTP", env: "prod", tls: false, expectedHeader: ""},
+		{name: "DevOverTLS", env: "dev", tls: true, expectedHeader: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer()
+			server.env = tc.env
+			r, err := server.router()
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedHeader, w.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+)
+
+// sharePath is where share links are opened, outside of the API's base path
+const sharePath = "/s"
+
+// shareCacheControl keeps shared audio out of caches, links can be revoked and limited to a number of downloads
+const shareCacheControl = "private, no-store"
+
+// WithShareService enables the routes creating, listing and revoking share links, and the one opening them
+func WithShareService(shareService share.Storer) Option {
+	return func(a *APIServer) {
+		a.shareService = shareService
+	}
+}
+
+// sharedLink is a share link along with the URL to give out
+type sharedLink struct {
+	*share.Link
+	URL string `json:"url"`
+}
+
+func (a *APIServer) newSharedLink(c *gin.Context, link *share.Link) sharedLink {
+	return sharedLink{Link: link, URL: a.siteURL(c, sharePath+"/"+link.Token)}
+}
+
+// POST /api/v1/audio/{id}/share
+// Creates a link letting anyone holding it listen to the quip, without an account
+func (a *APIServer) createShareLink(c *gin.Context) {
+	user, _ := currentUser(c)
+	id := c.Param("id")
+
+	// every setting of the link is optional, and so is the body
+	var request share.Request
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.ErrorContext(c, "invalid share link request", "err", err, "id", id)
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	fileInfo, err := a.fileService.FindById(c, id)
+	if err != nil {
+		log.ErrorContext(c, "request for the following ID was not found", "err", err, "id", id, "request", c.Request.RequestURI)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	link, err := a.shareService.Create(c, user.Name, fileInfo.ID, request)
+	if err != nil {
+		log.ErrorContext(c, "could not create share link", "err", err, "id", id, "user", user.Name)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, a.newSharedLink(c, link))
+}
+
+// GET /api/v1/me/shares
+// Returns the share links the caller created, revoked and expired ones included
+func (a *APIServer) getShareLinks(c *gin.Context) {
+	user, _ := currentUser(c)
+
+	links, err := a.shareService.FindAll(c, user.Name)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve share links", "err", err, "user", user.Name)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	shared := make([]sharedLink, 0, len(links))
+	for _, link := range links {
+		shared = append(shared, a.newSharedLink(c, link))
+	}
+	c.IndentedJSON(http.StatusOK, shared)
+}
+
+// DELETE /api/v1/me/shares/{id}
+func (a *APIServer) revokeShareLink(c *gin.Context) {
+	user, _ := currentUser(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("share link id must be a number"))
+		return
+	}
+
+	if err := a.shareService.Revoke(c, user.Name, uint(id)); err != nil {
+		log.ErrorContext(c, "could not revoke share link", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /s/{token}
+// Renders the player of the shared quip for browsers, and streams its audio to everything else.
+// Requests for the audio from its start count as a download. Links with a download limit count
+// every request and don't support ranges, so a listen fetches the audio once
+func (a *APIServer) openShareLink(c *gin.Context) {
+	token := c.Param("token")
+	// the token is the only thing standing between the quip and everyone else
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Header("Vary", "Accept")
+	c.Header("Cache-Control", shareCacheControl)
+
+	if wantsPage(c) {
+		a.showSharedQuip(c, token)
+		return
+	}
+
+	link, err := a.useShareLink(c, token)
+	if err != nil {
+		log.WarnContext(c, "share link can't be used", "err", err)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	fileInfo, err := a.fileService.FindById(c, strconv.FormatUint(uint64(link.FileID), 10))
+	if err != nil {
+		log.ErrorContext(c, "could not look up the shared quip", "err", err, "id", link.FileID, "link", link.ID)
+		abortWithDomainError(c, err)
+		return
+	}
+	if link.MaxDownloads > 0 {
+		a.sendWholeAudio(c, fileInfo)
+		return
+	}
+	a.serveAudio(c, fileInfo, shareCacheControl)
+}
+
+// useShareLink resolves the link the audio is requested through, counting a download when the
+// start of the audio is asked for. Links with a download limit count every request, ranges would
+// otherwise fetch the audio piece by piece without using the link up
+func (a *APIServer) useShareLink(c *gin.Context, token string) (*share.Link, error) {
+	if !isFirstRange(c) {
+		link, err := a.shareService.Resolve(c, token)
+		if err != nil || link.MaxDownloads == 0 {
+			return link, err
+		}
+	}
+	return a.shareService.Use(c, token)
+}
+
+// sendWholeAudio answers with all of the audio, whatever range was asked for, and tells players
+// that ranges aren't supported. They then seek within what they fetched instead of coming back
+// for more, which would use up another download
+func (a *APIServer) sendWholeAudio(c *gin.Context, fileInfo *file.FileRecord) {
+	object, ok := a.openAudio(c, fileInfo, shareCacheControl)
+	if !ok {
+		return
+	}
+	defer object.Body.Close()
+
+	a.recordEvent(fileInfo.ID, stats.KindPlay)
+	c.Header("Accept-Ranges", "none")
+	c.DataFromReader(http.StatusOK, object.Size, audioContentType(object, fileInfo), object.Body, nil)
+}
+
+// showSharedQuip renders the embed player of the shared quip, playing it through the link
+func (a *APIServer) showSharedQuip(c *gin.Context, token string) {
+	link, err := a.shareService.Resolve(c, token)
+	if err != nil {
+		log.WarnContext(c, "share link can't be used", "err", err)
+		status, _, mapped := mapError(err)
+		if !mapped {
+			status = http.StatusInternalServerError
+		}
+		renderPage(c, status, embedCSP, "missing.html", unusableLinkMessage(err))
+		return
+	}
+
+	fileInfo, err := a.fileService.FindById(c, strconv.FormatUint(uint64(link.FileID), 10))
+	if err != nil {
+		log.ErrorContext(c, "could not look up the shared quip", "err", err, "id", link.FileID, "link", link.ID)
+		renderPage(c, http.StatusNotFound, embedCSP, "missing.html", nil)
+		return
+	}
+
+	page := a.newQuipPage(c, fileInfo)
+	page.PageURL = a.siteURL(c, sharePath+"/"+token)
+	page.StreamURL = page.PageURL
+	renderPage(c, http.StatusOK, embedCSP, "embed.html", page)
+}
+
+// wantsPage tells browsers navigating to a link apart from players fetching the audio
+func wantsPage(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// unusableLinkMessage explains to the holder of a link why it doesn't work
+func unusableLinkMessage(err error) string {
+	switch {
+	case errors.Is(err, share.ErrExpired):
+		return "This link has expired."
+	case errors.Is(err, share.ErrRevoked):
+		return "This link was revoked."
+	case errors.Is(err, share.ErrExhausted):
+		return "This link has been used up."
+	case errors.Is(err, share.ErrInvalidToken):
+		return "This link isn't valid."
+	}
+	return ""
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeShares resolves every token to its link, or fails with its error
+type fakeShares struct {
+	share.Storer
+	link    *share.Link
+	err     error
+	uses    int
+	revoked []uint
+}
+
+func (f *fakeShares) Create(ctx context.Context, user string, fileID uint, request share.Request) (*share.Link, error) {
+	return &share.Link{ID: 1, FileID: fileID, Owner: user, Token: "token", MaxDownloads: request.MaxDownloads}, nil
+}
+
+func (f *fakeShares) FindAll(ctx context.Context, user string) ([]*share.Link, error) {
+	return []*share.Link{f.link}, nil
+}
+
+func (f *fakeShares) Revoke(ctx context.Context, user string, id uint) error {
+	f.revoked = append(f.revoked, id)
+	return f.err
+}
+
+func (f *fakeShares) Resolve(ctx context.Context, token string) (*share.Link, error) {
+	return f.link, f.err
+}
+
+func (f *fakeShares) Use(ctx context.Context, token string) (*share.Link, error) {
+	f.uses++
+	return f.link, f.err
+}
+
+// seekableStorage streams audio that can be seeked, like the objects of MinIO, so ranges are honoured
+type seekableStorage struct {
+	countingStorage
+}
+
+type seekableBody struct {
+	*strings.Reader
+}
+
+func (seekableBody) Close() error {
+	return nil
+}
+
+func (s *seekableStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	object, err := s.countingStorage.StreamObject(ctx, objectName, bucket)
+	if err != nil {
+		return nil, err
+	}
+	object.Body = seekableBody{strings.NewReader("audio")}
+	return object, nil
+}
+
+func newShareRouter(t *testing.T, shares *fakeShares, storage *countingStorage) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r, err := NewAPIServer(config.APIConfig{Path: testBasePath, Auth: testAuth}, storage, quipStorer{}, WithShareService(shares)).router()
+	assert.NoError(t, err)
+	return r
+}
+
+func TestShareLinkRoutes(t *testing.T) {
+	testCases := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		apiKey          string
+		expectedCode    int
+		expectedBody    string
+		expectedRevoked []uint
+	}{
+		{name: "CreateAnonymous", method: http.MethodPost, path: "/audio/1/share", expectedCode: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/audio/1/share", body: `{"expiresIn":3600,"maxDownloads":2}`, apiKey: "user-key", expectedCode: http.StatusCreated, expectedBody: `"maxDownloads":2,"downloads":0`},
+		{name: "CreateWithoutBody", method: http.MethodPost, path: "/audio/1/share", apiKey: "user-key", expectedCode: http.StatusCreated, expectedBody: `"url":"http://example.com/s/token"`},
+		{name: "CreateInvalid", method: http.MethodPost, path: "/audio/1/share", body: `{"maxDownloads":-1}`, apiKey: "user-key", expectedCode: http.StatusBadRequest},
+		{name: "CreateUnknownQuip", method: http.MethodPost, path: "/audio/2/share", apiKey: "user-key", expectedCode: http.StatusNotFound},
+		{name: "List", method: http.MethodGet, path: "/me/shares", apiKey: "user-key", expectedCode: http.StatusOK, expectedBody: `"url":"http://example.com/s/listed"`},
+		{name: "Revoke", method: http.MethodDelete, path: "/me/shares/3", apiKey: "user-key", expectedCode: http.StatusNoContent, expectedRevoked: []uint{3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shares := &fakeShares{link: &share.Link{ID: 3, FileID: 1, Token: "listed"}}
+			r := newShareRouter(t, shares, &countingStorage{})
+
+			req := httptest.NewRequest(tc.method, testBasePath+tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedBody != "" {
+				var compact bytes.Buffer
+				assert.NoError(t, json.Compact(&compact, w.Body.Bytes()))
+				assert.Contains(t, compact.String(), tc.expectedBody)
+			}
+			assert.Equal(t, tc.expectedRevoked, shares.revoked)
+		})
+	}
+}
+
+func TestOpenShareLink(t *testing.T) {
+	link := &share.Link{ID: 3, FileID: 1, Token: "token", ExpiresAt: time.Now().Add(time.Hour)}
+
+	testCases := []struct {
+		name            string
+		maxDownloads    int
+		err             error
+		headers         map[string]string
+		expectedCode    int
+		expectedBody    string
+		expectedUses    int
+		expectedStreams int
+	}{
+		{
+			name:         "Page",
+			headers:      map[string]string{"Accept": "text/html,application/xhtml+xml"},
+			expectedCode: http.StatusOK,
+			expectedBody: `data-stream="http://example.com/s/token"`,
+		},
+		{
+			name:            "Audio",
+			headers:         map[string]string{"Accept": "*/*"},
+			expectedCode:    http.StatusOK,
+			expectedBody:    "audio",
+			expectedUses:    1,
+			expectedStreams: 1,
+		},
+		{
+			name:            "LaterRange",
+			headers:         map[string]string{"Range": "bytes=2-"},
+			expectedCode:    http.StatusOK,
+			expectedUses:    0,
+			expectedStreams: 1,
+		},
+		{
+			name:            "SuffixRangeOfLimitedLink",
+			maxDownloads:    1,
+			headers:         map[string]string{"Range": "bytes=-999999999"},
+			expectedCode:    http.StatusOK,
+			expectedBody:    "audio",
+			expectedUses:    1,
+			expectedStreams: 1,
+		},
+		{
+			name:            "LaterRangeOfLimitedLink",
+			maxDownloads:    1,
+			headers:         map[string]string{"Range": "bytes=1-"},
+			expectedCode:    http.StatusOK,
+			expectedBody:    "audio",
+			expectedUses:    1,
+			expectedStreams: 1,
+		},
+		{
+			name:         "ExpiredAudio",
+			err:          share.ErrExpired,
+			expectedCode: http.StatusGone,
+			expectedBody: CodeLinkUnavailable,
+			expectedUses: 1,
+		},
+		{
+			name:         "ExpiredPage",
+			err:          share.ErrExpired,
+			headers:      map[string]string{"Accept": "text/html"},
+			expectedCode: http.StatusGone,
+			expectedBody: "This link has expired.",
+		},
+		{
+			name:         "Forged",
+			err:          share.ErrInvalidToken,
+			expectedCode: http.StatusNotFound,
+			expectedUses: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shared := *link
+			shared.MaxDownloads = tc.maxDownloads
+			shares := &fakeShares{link: &shared, err: tc.err}
+			storage := &countingStorage{}
+			r := newShareRouter(t, shares, storage)
+
+			req := httptest.NewRequest(http.MethodGet, "/s/token", nil)
+			for header, value := range tc.headers {
+				req.Header.Set(header, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+			assert.Equal(t, shareCacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tc.expectedUses, shares.uses)
+			assert.Equal(t, tc.expectedStreams, storage.streams)
+		})
+	}
+}
+
+func TestSharedQuipListen(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		maxDownloads         int
+		expectedCode         int
+		expectedBody         string
+		expectedAcceptRanges string
+	}{
+		{name: "Unlimited", expectedCode: http.StatusPartialContent, expectedBody: "aud", expectedAcceptRanges: "bytes"},
+		{name: "Limited", maxDownloads: 1, expectedCode: http.StatusOK, expectedBody: "audio", expectedAcceptRanges: "none"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			shares := &fakeShares{link: &share.Link{ID: 3, FileID: 1, Token: "token", MaxDownloads: tc.maxDownloads}}
+			storage := &seekableStorage{}
+			r, err := NewAPIServer(config.APIConfig{Path: testBasePath}, storage, quipStorer{}, WithShareService(shares)).router()
+			assert.NoError(t, err)
+
+			// the browser opens the link and is given the embed player
+			req := httptest.NewRequest(http.MethodGet, "/s/token", nil)
+			req.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), `<audio preload="none" src="http://example.com/s/token">`)
+			assert.Equal(t, 0, shares.uses)
+
+			// its audio element fetches the stream, asking for ranges as it does
+			req = httptest.NewRequest(http.MethodGet, "http://example.com/s/token", nil)
+			req.Header.Set("Accept", "*/*")
+			req.Header.Set("Range", "bytes=0-2")
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+			assert.Equal(t, tc.expectedAcceptRanges, w.Header().Get("Accept-Ranges"))
+			assert.Equal(t, 1, shares.uses)
+		})
+	}
+}
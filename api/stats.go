@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+)
+
+// GET /api/v1/audio/{id}/stats?days={n}
+// Returns a quip's total plays and downloads along with its daily counts
+func (a *APIServer) getAudioStats(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("id must be a number"))
+		return
+	}
+	days, _ := strconv.Atoi(c.Query("days"))
+
+	fileStats, err := a.statsService.FileStats(c, uint(id), days)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve stats", "err", err, "id", id)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, fileStats)
+}
+
+// GET /api/v1/stats/top?period={day,week,month,year,all}&by={plays,downloads}&limit={n}
+func (a *APIServer) getTopStats(c *gin.Context) {
+	period, err := stats.ParsePeriod(c.Query("period"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	metric, err := stats.ParseMetric(c.Query("by"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	entries, err := a.statsService.Top(c, period, metric, limit)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve top quips", "err", err, "period", period)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, entries)
+}
+
+// GET /api/v1/stats/trending?period={day,week,month,year}&limit={n}
+// Returns the quips whose plays grew the most compared to the previous period
+func (a *APIServer) getTrendingStats(c *gin.Context) {
+	period, err := stats.ParsePeriod(c.Query("period"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	entries, err := a.statsService.Trending(c, period, limit)
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve trending quips", "err", err, "period", period)
+		abortWithDomainError(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, entries)
+}
+
+// recordEvent hands a play or download of the file to the analytics recorder, if there is one
+func (a *APIServer) recordEvent(fileID uint, kind string) {
+	if a.statsRecorder == nil {
+		return
+	}
+	a.statsRecorder.Record(stats.Event{FileID: fileID, Kind: kind})
+}
+
+// isFirstRange reports whether the request asks for the start of the audio. Players issue many
+// range requests while seeking, only the one starting at byte 0 counts as a play
+func isFirstRange(c *gin.Context) bool {
+	rangeHeader := c.GetHeader("Range")
+	return rangeHeader == "" || strings.HasPrefix(strings.ReplaceAll(rangeHeader, " ", ""), "bytes=0-")
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+)
+
+// GET /api/v1/audio/{id}/transcript?format={json,vtt,srt}
+// Returns what's said in a quip, with word timings as JSON or as subtitles
+func (a *APIServer) getTranscript(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, errors.New("id must be a number"))
+		return
+	}
+
+	found, err := a.transcripts.FindByFileID(c, uint(id))
+	if errors.Is(err, transcript.ErrNotFound) {
+		abortWithError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		log.ErrorContext(c, "Could not retrieve transcript", "err", err, "id", id)
+		abortWithError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	switch strings.ToLower(c.DefaultQuery("format", "json")) {
+	case "json":
+		c.IndentedJSON(http.StatusOK, found)
+	case "vtt", "webvtt":
+		c.Data(http.StatusOK, transcript.WebVTTContentType, transcript.WebVTT(found))
+	case "srt":
+		c.Data(http.StatusOK, transcript.SRTContentType, transcript.SRT(found))
+	default:
+		abortWithError(c, http.StatusBadRequest, errors.New("supported transcript formats are json, vtt and srt"))
+	}
+}
@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache holds values by key for up to their TTL. Values may be evicted before then, callers must
+// be able to load them again
+type Cache interface {
+	// Get reports whether the key was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set keeps the value for the TTL, or until evicted when the TTL is 0
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)
+	lru := NewLRU(10)
+	lru.now = func() time.Time { return now }
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	backends := map[string]struct {
+		cache   Cache
+		advance func(time.Duration)
+	}{
+		"LRU":   {lru, func(d time.Duration) { now = now.Add(d) }},
+		"Redis": {NewRedis(client), server.FastForward},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c := backend.cache
+
+			_, found, err := c.Get(ctx, "missing")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			assert.NoError(t, c.Set(ctx, "short", []byte("a"), time.Minute))
+			assert.NoError(t, c.Set(ctx, "forever", []byte("b"), 0))
+			value, found, err := c.Get(ctx, "short")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, []byte("a"), value)
+
+			backend.advance(time.Minute)
+			_, found, err = c.Get(ctx, "short")
+			assert.NoError(t, err)
+			assert.False(t, found, "the entry expired")
+			_, found, err = c.Get(ctx, "forever")
+			assert.NoError(t, err)
+			assert.True(t, found, "entries without a TTL don't expire")
+
+			assert.NoError(t, c.Delete(ctx, "forever", "missing"))
+			_, found, err = c.Get(ctx, "forever")
+			assert.NoError(t, err)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+	lru.Set(ctx, "a", []byte("a"), 0)
+	lru.Set(ctx, "b", []byte("b"), 0)
+	// a is used again, b becomes the least recently used
+	lru.Get(ctx, "a")
+	lru.Set(ctx, "c", []byte("c"), 0)
+
+	_, found, _ := lru.Get(ctx, "b")
+	assert.False(t, found)
+	_, found, _ = lru.Get(ctx, "a")
+	assert.True(t, found)
+	_, found, _ = lru.Get(ctx, "c")
+	assert.True(t, found)
+	assert.Equal(t, 2, lru.Len())
+}
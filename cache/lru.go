@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of entries an LRU holds unless told otherwise
+const DefaultSize = 10000
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU keeps up to size entries in memory, evicting the least recently used first. Entries
+// aren't shared between instances
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+// NewLRU creates a cache of the given size, DefaultSize when it isn't positive
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &LRU{size: size, entries: map[string]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := element.Value.(*entry)
+	if !e.expires.IsZero() && !l.now().Before(e.expires) {
+		l.remove(element)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(element)
+	return e.value, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = l.now().Add(ttl)
+	}
+	if element, ok := l.entries[key]; ok {
+		element.Value = &entry{key: key, value: value, expires: expires}
+		l.order.MoveToFront(element)
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) Delete(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := l.entries[key]; ok {
+			l.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, expired ones included until they're looked up or evicted
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*entry).key)
+}
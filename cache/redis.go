@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "voice-quips:cache:"
+
+// Redis keeps the entries in Redis, sharing them between instances. Eviction is left to the
+// server's maxmemory policy
+type Redis struct {
+	client redis.Cmdable
+}
+
+func NewRedis(client redis.Cmdable) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, keyPrefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = keyPrefix + key
+	}
+	return r.client.Del(ctx, prefixed...).Err()
+}
@@ -0,0 +1,188 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "log/slog"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Client authentication modes, when a client CA is given
+const (
+	// ClientAuthRequire rejects clients without a certificate signed by the CA, the default
+	ClientAuthRequire = "require"
+	// ClientAuthOptional verifies the certificates clients send but lets clients without one in
+	ClientAuthOptional = "optional"
+)
+
+var ErrKeyPairMissing = errors.New("both a certificate and a key file are needed to serve TLS")
+var ErrNoClientCAs = errors.New("no certificates found in the client CA file")
+var ErrUnknownClientAuth = errors.New("supported client auth modes are require and optional")
+
+// Config names the PEM files the server's certificate comes from
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the CAs client certificates must be signed by. Clients aren't asked for
+	// a certificate when it's empty
+	ClientCAFile string
+	ClientAuth   string
+}
+
+// Reloader serves the certificate and client CAs last read from disk, reading them again when
+// the files change so that certificates can be rotated without a restart
+type Reloader struct {
+	cfg        Config
+	clientAuth tls.ClientAuthType
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// New reads the certificate, key and client CAs. It fails when any of them can't be used
+func New(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, ErrKeyPairMissing
+	}
+
+	r := &Reloader{cfg: cfg, clientAuth: tls.NoClientCert}
+	if cfg.ClientCAFile != "" {
+		switch strings.ToLower(cfg.ClientAuth) {
+		case "", ClientAuthRequire:
+			r.clientAuth = tls.RequireAndVerifyClientCert
+		case ClientAuthOptional:
+			r.clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, ErrUnknownClientAuth
+		}
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. The certificate in use is kept when they can't be used
+func (r *Reloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading the key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading the client CAs: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return ErrNoClientCAs
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	return nil
+}
+
+// TLSConfig returns a server config handing out whatever was last loaded
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// each handshake gets a config with the current certificate and client CAs
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    r.clientCAs,
+			}, nil
+		},
+	}
+}
+
+// Watch reloads the files whenever they're written, replaced or renamed, until the context is
+// done. The directories holding them are watched since certificates are usually rotated by
+// swapping the files (or, in Kubernetes, a symlink to them) rather than rewriting them
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	directories := map[string]bool{}
+	for _, path := range r.files() {
+		directory := filepath.Dir(path)
+		if directories[directory] {
+			continue
+		}
+		if err := watcher.Add(directory); err != nil {
+			return err
+		}
+		directories[directory] = true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod || !r.concerns(event.Name) {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				// files are often written one after the other, the next event picks up the rest
+				log.Warn("could not reload the TLS certificate, keeping the current one", "err", err, "file", event.Name)
+				continue
+			}
+			log.Info("reloaded the TLS certificate", "file", event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error("error while watching the TLS certificate", "err", err)
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// concerns reports whether a change to the path may have changed the files. Kubernetes mounts
+// secrets through a ..data symlink that is swapped on updates
+func (r *Reloader) concerns(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), "..") {
+		return true
+	}
+	for _, file := range r.files() {
+		if filepath.Clean(file) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
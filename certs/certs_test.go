@@ -0,0 +1,209 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// authority signs the certificates of a test
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a leaf, for the server or a client
+func (a *authority) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "voice-quips"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// servedSerial is the serial number of the certificate handed out to the next client
+func servedSerial(t *testing.T, r *Reloader) int64 {
+	config, err := r.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, filepath.Join(dir, "tls.crt"), cert)
+	writeFile(t, filepath.Join(dir, "tls.key"), key)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	writeFile(t, filepath.Join(dir, "empty.crt"), []byte("not a certificate"))
+
+	testCases := []struct {
+		name               string
+		cfg                Config
+		expectedErr        error
+		expectedClientAuth tls.ClientAuthType
+	}{
+		{name: "ServerOnly", cfg: Config{CertFile: "tls.crt", KeyFile: "tls.key"}, expectedClientAuth: tls.NoClientCert},
+		{name: "MutualTLS", cfg: Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt"}, expectedClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "OptionalClientCert", cfg: Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt", ClientAuth: ClientAuthOptional}, expectedClientAuth: tls.VerifyClientCertIfGiven},
+		{name: "KeyMissing", cfg: Config{CertFile: "tls.crt"}, expectedErr: ErrKeyPairMissing},
+		{name: "UnknownClientAuth", cfg: Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt", ClientAuth: "sometimes"}, expectedErr: ErrUnknownClientAuth},
+		{name: "NoClientCAs", cfg: Config{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "empty.crt"}, expectedErr: ErrNoClientCAs},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			for _, path := range []*string{&cfg.CertFile, &cfg.KeyFile, &cfg.ClientCAFile} {
+				if *path != "" {
+					*path = filepath.Join(dir, *path)
+				}
+			}
+
+			reloader, err := New(cfg)
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if err == nil {
+				config, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedClientAuth, config.ClientAuth)
+			}
+		})
+	}
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	serverCert, serverKey := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, filepath.Join(dir, "tls.crt"), serverCert)
+	writeFile(t, filepath.Join(dir, "tls.key"), serverKey)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+
+	reloader, err := New(Config{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key"), ClientCAFile: filepath.Join(dir, "ca.crt")})
+	assert.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCert, clientKey := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	keyPair, err := tls.X509KeyPair(clientCert, clientKey)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		certificates []tls.Certificate
+		expectError  bool
+	}{
+		{name: "WithClientCertificate", certificates: []tls.Certificate{keyPair}},
+		{name: "WithoutClientCertificate", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: tc.certificates,
+				ServerName:   "localhost",
+			}}}
+
+			response, err := client.Get(server.URL)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+				response.Body.Close()
+			}
+		})
+	}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	reloader, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), servedSerial(t, reloader))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watching := make(chan error, 1)
+	go func() { watching <- reloader.Watch(ctx) }()
+	// give the watcher time to start before rotating
+	time.Sleep(50 * time.Millisecond)
+
+	// rotated by renaming new files into place, the way most tools do it
+	rotated, rotatedKey := ca.issue(t, 4, x509.ExtKeyUsageServerAuth)
+	writeFile(t, filepath.Join(dir, "tls.key.new"), rotatedKey)
+	writeFile(t, filepath.Join(dir, "tls.crt.new"), rotated)
+	assert.NoError(t, os.Rename(filepath.Join(dir, "tls.key.new"), keyFile))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "tls.crt.new"), certFile))
+
+	assert.Eventually(t, func() bool { return servedSerial(t, reloader) == 4 }, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-watching)
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// ListOptions narrows down the quips returned by List. The zero value lists everything
+type ListOptions struct {
+	// Tags the quips must carry, all of them unless MatchAnyTag is set
+	Tags        []string
+	MatchAnyTag bool
+	Category    string
+	// Search matches titles, filenames and transcripts
+	Search string
+	// Sort is "rating" or "newest", the API's default order when empty
+	Sort string
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	for _, tag := range o.Tags {
+		query.Add("tag", tag)
+	}
+	if o.MatchAnyTag {
+		query.Set("match", "any")
+	}
+	if o.Category != "" {
+		query.Set("category", o.Category)
+	}
+	if o.Search != "" {
+		query.Set("q", o.Search)
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	return query
+}
+
+// Upload is an audio file to upload. Uploads are only retried when Body is an io.Seeker, as it's
+// read again from where it started
+type Upload struct {
+	Filename string
+	Body     io.Reader
+	Category string
+	Tags     []string
+	// Size of the body, used to report progress. Worked out for files and in-memory readers when 0
+	Size int64
+	// Progress, if set, is called as the body is sent
+	Progress ProgressFunc
+}
+
+// Audio is the audio of a quip being streamed. Body must be closed by the caller
+type Audio struct {
+	Body        io.ReadCloser
+	ContentType string
+	// Size is the length of the body, -1 when the server didn't tell
+	Size int64
+}
+
+// Uploaded is the answer to an upload. When the server processes uploads in the background the
+// record is still processing and JobID identifies the job to poll
+type Uploaded struct {
+	*file.FileRecord
+	JobID  int64  `json:"jobId,omitempty"`
+	JobURL string `json:"jobUrl,omitempty"`
+}
+
+// List returns the quips matching the options
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]*file.FileRecord, error) {
+	records := []*file.FileRecord{}
+	err := c.doJSON(ctx, http.MethodGet, "/audio/", opts.query(), nil, &records)
+	return records, err
+}
+
+// Get returns the quip with the given id
+func (c *Client) Get(ctx context.Context, id uint) (*file.FileRecord, error) {
+	var record file.FileRecord
+	if err := c.doJSON(ctx, http.MethodGet, audioPath(id, ""), nil, nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Delete removes the quip with the given id. It needs an admin API key
+func (c *Client) Delete(ctx context.Context, id uint) error {
+	return c.doJSON(ctx, http.MethodDelete, audioPath(id, ""), nil, nil, nil)
+}
+
+// Download writes the audio of the quip to w, returning the number of bytes written. Progress,
+// if given, is called as the audio arrives
+func (c *Client) Download(ctx context.Context, id uint, w io.Writer, progress ProgressFunc) (int64, error) {
+	audio, err := c.audio(ctx, audioPath(id, "/download"))
+	if err != nil {
+		return 0, err
+	}
+	defer audio.Body.Close()
+
+	return io.Copy(w, newProgressReader(audio.Body, audio.Size, progress))
+}
+
+// Stream opens the audio of the quip for playing, counting as a play rather than a download
+func (c *Client) Stream(ctx context.Context, id uint) (*Audio, error) {
+	return c.audio(ctx, audioPath(id, "/stream"))
+}
+
+func (c *Client) audio(ctx context.Context, path string) (*Audio, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return &Audio{Body: resp.Body, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}, nil
+}
+
+// Upload sends the audio as a multipart form, streaming it rather than buffering it
+func (c *Client) Upload(ctx context.Context, upload Upload) (*Uploaded, error) {
+	if upload.Size <= 0 {
+		upload.Size = sizeOf(upload.Body)
+	}
+
+	var start int64
+	seeker, replayable := upload.Body.(io.Seeker)
+	if replayable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			replayable = false
+		}
+	}
+
+	// the boundary is chosen up front so that retries send the same form
+	boundary := multipart.NewWriter(nil).Boundary()
+	body := uploadForm(upload, boundary)
+	req, err := c.newRequest(ctx, http.MethodPost, "/audio", nil, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	req.Header.Set("Accept", "application/json")
+	if replayable {
+		current := body
+		req.GetBody = func() (io.ReadCloser, error) {
+			// the previous form must stop reading the body before it's rewound
+			current.Close()
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			current = uploadForm(upload, boundary)
+			return current, nil
+		}
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var uploaded Uploaded
+	if err := decodeJSON(resp, &uploaded); err != nil {
+		return nil, err
+	}
+	return &uploaded, nil
+}
+
+// formBody is the read end of a form being written by another goroutine. Closing it waits for
+// the writer to stop
+type formBody struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+func (f *formBody) Close() error {
+	err := f.PipeReader.Close()
+	<-f.done
+	return err
+}
+
+// uploadForm streams the multipart form of the upload through a pipe
+func uploadForm(upload Upload, boundary string) *formBody {
+	reader, writer := io.Pipe()
+	body := &formBody{PipeReader: reader, done: make(chan struct{})}
+	form := multipart.NewWriter(writer)
+	form.SetBoundary(boundary)
+	go func() {
+		defer close(body.done)
+		writer.CloseWithError(writeUploadForm(form, upload))
+	}()
+	return body
+}
+
+func writeUploadForm(form *multipart.Writer, upload Upload) error {
+	if upload.Category != "" {
+		if err := form.WriteField("category", upload.Category); err != nil {
+			return err
+		}
+	}
+	for _, tag := range upload.Tags {
+		if err := form.WriteField("tags", tag); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile("file", upload.Filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, newProgressReader(upload.Body, upload.Size, upload.Progress)); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// AddTags tags the quip, returning it with its new tags
+func (c *Client) AddTags(ctx context.Context, id uint, tags ...string) (*file.FileRecord, error) {
+	var record file.FileRecord
+	request := map[string][]string{"tags": tags}
+	if err := c.doJSON(ctx, http.MethodPost, audioPath(id, "/tags"), nil, request, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RemoveTag removes a tag from the quip
+func (c *Client) RemoveTag(ctx context.Context, id uint, tag string) error {
+	return c.doJSON(ctx, http.MethodDelete, audioPath(id, "/tags/"+url.PathEscape(strings.ToLower(tag))), nil, nil, nil)
+}
+
+// Tags returns every tag along with the number of quips carrying it
+func (c *Client) Tags(ctx context.Context) ([]*file.Tag, error) {
+	tags := []*file.Tag{}
+	err := c.doJSON(ctx, http.MethodGet, "/tags", nil, nil, &tags)
+	return tags, err
+}
+
+func audioPath(id uint, suffix string) string {
+	return fmt.Sprintf("/audio/%d%s", id, suffix)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// Categories returns the category tree, top-level categories holding their children
+func (c *Client) Categories(ctx context.Context) ([]*file.Category, error) {
+	return c.categories(ctx, nil)
+}
+
+// CategoriesFlat returns every category in a flat list sorted by sort order
+func (c *Client) CategoriesFlat(ctx context.Context) ([]*file.Category, error) {
+	return c.categories(ctx, url.Values{"flat": {"true"}})
+}
+
+func (c *Client) categories(ctx context.Context, query url.Values) ([]*file.Category, error) {
+	categories := []*file.Category{}
+	err := c.doJSON(ctx, http.MethodGet, "/categories", query, nil, &categories)
+	return categories, err
+}
+
+func (c *Client) Category(ctx context.Context, slug string) (*file.Category, error) {
+	return c.categoryRequest(ctx, http.MethodGet, categoryPath(slug), nil)
+}
+
+// CreateCategory adds a category to the catalogue. It needs an admin API key
+func (c *Client) CreateCategory(ctx context.Context, category file.Category) (*file.Category, error) {
+	return c.categoryRequest(ctx, http.MethodPost, "/categories", category)
+}
+
+// UpdateCategory replaces the category with the given slug. It needs an admin API key
+func (c *Client) UpdateCategory(ctx context.Context, slug string, category file.Category) (*file.Category, error) {
+	return c.categoryRequest(ctx, http.MethodPut, categoryPath(slug), category)
+}
+
+// DeleteCategory removes the category with the given slug. It needs an admin API key
+func (c *Client) DeleteCategory(ctx context.Context, slug string) error {
+	return c.doJSON(ctx, http.MethodDelete, categoryPath(slug), nil, nil, nil)
+}
+
+func (c *Client) categoryRequest(ctx context.Context, method, path string, in any) (*file.Category, error) {
+	var category file.Category
+	if err := c.doJSON(ctx, method, path, nil, in, &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func categoryPath(slug string) string {
+	return "/categories/" + url.PathEscape(slug)
+}
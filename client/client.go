@@ -0,0 +1,218 @@
+// Package client is a Go client for the voice-quips REST API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// RetryPolicy tells how requests answered with 429 or a 5xx status are retried. The delay
+// doubles after every attempt, unless the server says how long to wait with Retry-After
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, none when 0
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy says otherwise
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// Client calls the voice-quips REST API. It's safe for concurrent use
+type Client struct {
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey authenticates every request with the key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient sends the requests through the given HTTP client instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy changes how failed requests are retried. RetryPolicy{} disables retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New returns a client for the API served at the endpoint, base path included
+// (eg. http://localhost:9090/api/v1/voice-quips)
+func New(endpoint string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %q must be an http or https URL", endpoint)
+	}
+
+	c := &Client{
+		endpoint:   strings.TrimSuffix(parsed.String(), "/"),
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// newRequest builds a request for a path under the endpoint
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := c.endpoint + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set(apiKeyHeader, c.apiKey)
+	}
+	return req, nil
+}
+
+// send sends the request, turning error statuses into an *Error. Requests answered with 429 or
+// a 5xx status are retried when their body can be sent again. The caller closes the body
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		apiErr := readError(resp)
+		resp.Body.Close()
+		if !c.retryable(req, resp, attempt) {
+			return nil, apiErr
+		}
+
+		delay := jobs.Backoff(attempt, c.retry.BaseDelay, c.retry.MaxDelay)
+		if wait := retryAfter(resp); wait > 0 {
+			delay = wait
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) retryable(req *http.Request, resp *http.Response, attempt int) bool {
+	if attempt > c.retry.MaxRetries {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rewind returns a copy of the request with a fresh body, for sending it again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+// sleep waits for the delay unless the context is done first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// doJSON sends the value as JSON, when given, and decodes the response into out, when given
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return decodeJSON(resp, out)
+}
+
+func decodeJSON(resp *http.Response, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding the %s %s response: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// Ping checks that the API is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	return c.doJSON(ctx, http.MethodGet, "/ping", nil, nil, nil)
+}
@@ -0,0 +1,429 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingServer answers every request with the status and body, remembering the last request
+type recordingServer struct {
+	status  int
+	body    string
+	request *http.Request
+	payload []byte
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.request = r
+	s.payload, _ = io.ReadAll(r.Body)
+	w.WriteHeader(s.status)
+	io.WriteString(w, s.body)
+}
+
+func newTestClient(t *testing.T, server *recordingServer) *Client {
+	t.Helper()
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	c, err := New(httpServer.URL+"/api/v1/voice-quips/", WithAPIKey("secret"))
+	assert.NoError(t, err)
+	return c
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name          string
+		endpoint      string
+		expectedError bool
+	}{
+		{name: "HTTP", endpoint: "http://localhost:9090/api/v1/voice-quips"},
+		{name: "HTTPS", endpoint: "https://quips.example.com/api"},
+		{name: "NoScheme", endpoint: "localhost:9090", expectedError: true},
+		{name: "Invalid", endpoint: "http://%zz", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.endpoint)
+			assert.Equal(t, tc.expectedError, err != nil)
+		})
+	}
+}
+
+func TestClient_Requests(t *testing.T) {
+	testCases := []struct {
+		name            string
+		status          int
+		body            string
+		call            func(context.Context, *Client) (any, error)
+		expectedMethod  string
+		expectedPath    string
+		expectedQuery   string
+		expectedPayload string
+		expected        any
+	}{
+		{
+			name: "List",
+			body: `[{"id": 1, "name": "hi.mp3", "tags": ["funny"]}]`,
+			call: func(ctx context.Context, c *Client) (any, error) {
+				return c.List(ctx, ListOptions{Tags: []string{"funny", "short"}, MatchAnyTag: true, Search: "good morning", Sort: "rating"})
+			},
+			expectedMethod: http.MethodGet,
+			expectedPath:   "/api/v1/voice-quips/audio/",
+			expectedQuery:  "match=any&q=good+morning&sort=rating&tag=funny&tag=short",
+			expected:       []*file.FileRecord{{ID: 1, Filename: "hi.mp3", Tags: []string{"funny"}}},
+		},
+		{
+			name:           "Get",
+			body:           `{"id": 4, "metadata": {"title": "Hi"}}`,
+			call:           func(ctx context.Context, c *Client) (any, error) { return c.Get(ctx, 4) },
+			expectedMethod: http.MethodGet,
+			expectedPath:   "/api/v1/voice-quips/audio/4",
+			expected:       &file.FileRecord{ID: 4, Metadata: file.Metadata{Title: "Hi"}},
+		},
+		{
+			name:           "Delete",
+			status:         http.StatusNoContent,
+			call:           func(ctx context.Context, c *Client) (any, error) { return nil, c.Delete(ctx, 4) },
+			expectedMethod: http.MethodDelete,
+			expectedPath:   "/api/v1/voice-quips/audio/4",
+		},
+		{
+			name:            "AddTags",
+			body:            `{"id": 4, "tags": ["funny"]}`,
+			call:            func(ctx context.Context, c *Client) (any, error) { return c.AddTags(ctx, 4, "funny") },
+			expectedMethod:  http.MethodPost,
+			expectedPath:    "/api/v1/voice-quips/audio/4/tags",
+			expectedPayload: `{"tags":["funny"]}`,
+			expected:        &file.FileRecord{ID: 4, Tags: []string{"funny"}},
+		},
+		{
+			name:           "RemoveTag",
+			status:         http.StatusNoContent,
+			call:           func(ctx context.Context, c *Client) (any, error) { return nil, c.RemoveTag(ctx, 4, "Good Vibes") },
+			expectedMethod: http.MethodDelete,
+			expectedPath:   "/api/v1/voice-quips/audio/4/tags/good vibes",
+		},
+		{
+			name: "AddPlaylistItem",
+			body: `{"id": 2, "name": "board", "items": [{"position": 1, "fileId": 4}]}`,
+			call: func(ctx context.Context, c *Client) (any, error) {
+				return c.AddPlaylistItem(ctx, 2, playlist.Item{FileID: 4})
+			},
+			expectedMethod:  http.MethodPost,
+			expectedPath:    "/api/v1/voice-quips/playlists/2/items",
+			expectedPayload: `{"position":0,"fileId":4}`,
+			expected:        &playlist.Playlist{ID: 2, Name: "board", Items: []playlist.Item{{Position: 1, FileID: 4}}},
+		},
+		{
+			name:   "Share",
+			status: http.StatusCreated,
+			body:   `{"id": 3, "fileId": 4, "token": "abc", "maxDownloads": 5, "url": "http://localhost/s/abc"}`,
+			call: func(ctx context.Context, c *Client) (any, error) {
+				return c.Share(ctx, 4, share.Request{MaxDownloads: 5})
+			},
+			expectedMethod:  http.MethodPost,
+			expectedPath:    "/api/v1/voice-quips/audio/4/share",
+			expectedPayload: `{"maxDownloads":5}`,
+			expected:        &ShareLink{Link: share.Link{ID: 3, FileID: 4, Token: "abc", MaxDownloads: 5}, URL: "http://localhost/s/abc"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &recordingServer{status: http.StatusOK, body: tc.body}
+			if tc.status != 0 {
+				server.status = tc.status
+			}
+			c := newTestClient(t, server)
+
+			result, err := tc.call(context.Background(), c)
+
+			assert.NoError(t, err)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expected, result)
+			}
+			assert.Equal(t, tc.expectedMethod, server.request.Method)
+			assert.Equal(t, tc.expectedPath, server.request.URL.Path)
+			assert.Equal(t, tc.expectedQuery, server.request.URL.RawQuery)
+			assert.Equal(t, tc.expectedPayload, string(server.payload))
+			assert.Equal(t, "secret", server.request.Header.Get(apiKeyHeader))
+		})
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	server := &recordingServer{status: http.StatusNotFound, body: "no rows found\n"}
+	c := newTestClient(t, server)
+
+	_, err := c.Get(context.Background(), 4)
+
+	var apiErr *Error
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "no rows found", apiErr.Message)
+}
+
+func TestClient_Upload(t *testing.T) {
+	server := &recordingServer{status: http.StatusAccepted, body: `{"id": 7, "status": "processing", "jobId": 3, "jobUrl": "http://x/jobs/3"}`}
+	c := newTestClient(t, server)
+
+	uploaded, err := c.Upload(context.Background(), Upload{
+		Filename: "hi.mp3",
+		Body:     strings.NewReader("audio"),
+		Category: "greetings",
+		Tags:     []string{"funny", "short"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), uploaded.ID)
+	assert.Equal(t, file.StatusProcessing, uploaded.Status)
+	assert.Equal(t, int64(3), uploaded.JobID)
+
+	// parse the form the server received
+	request := httptest.NewRequest(http.MethodPost, "/audio", bytes.NewReader(server.payload))
+	request.Header = server.request.Header
+	assert.NoError(t, request.ParseMultipartForm(1<<20))
+	assert.Equal(t, []string{"greetings"}, request.MultipartForm.Value["category"])
+	assert.Equal(t, []string{"funny", "short"}, request.MultipartForm.Value["tags"])
+	audio, header, err := request.FormFile("file")
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(audio)
+	assert.Equal(t, "hi.mp3", header.Filename)
+	assert.Equal(t, "audio", string(content))
+}
+
+func TestClient_Download(t *testing.T) {
+	server := &recordingServer{status: http.StatusOK, body: "audio bytes"}
+	c := newTestClient(t, server)
+	out := &bytes.Buffer{}
+
+	written, err := c.Download(context.Background(), 4, out, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), written)
+	assert.Equal(t, "audio bytes", out.String())
+	assert.Equal(t, "/api/v1/voice-quips/audio/4/download", server.request.URL.Path)
+}
+
+// the JSON tags of the upload response must match the API's
+func TestUploaded_JSON(t *testing.T) {
+	encoded, err := json.Marshal(Uploaded{FileRecord: &file.FileRecord{ID: 1}, JobID: 2})
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"jobId":2`)
+}
+
+// flakyServer fails the first requests with the status before answering with the body
+type flakyServer struct {
+	failures int
+	status   int
+	body     string
+	payloads []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, _ := io.ReadAll(r.Body)
+	s.payloads = append(s.payloads, string(payload))
+	if len(s.payloads) <= s.failures {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(s.status)
+		return
+	}
+	io.WriteString(w, s.body)
+}
+
+func TestClient_Retry(t *testing.T) {
+	testCases := []struct {
+		name             string
+		failures         int
+		status           int
+		call             func(context.Context, *Client) error
+		expectedRequests int
+		expectedError    error
+	}{
+		{
+			name:     "ServerErrorRetried",
+			failures: 2,
+			status:   http.StatusServiceUnavailable,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Get(ctx, 1)
+				return err
+			},
+			expectedRequests: 3,
+		},
+		{
+			name:     "RateLimitedJSONBodyRetried",
+			failures: 1,
+			status:   http.StatusTooManyRequests,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.AddTags(ctx, 1, "funny")
+				return err
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:     "GivesUp",
+			failures: 10,
+			status:   http.StatusBadGateway,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Get(ctx, 1)
+				return err
+			},
+			expectedRequests: 4,
+			expectedError:    ErrServer,
+		},
+		{
+			name:     "ClientErrorNotRetried",
+			failures: 1,
+			status:   http.StatusNotFound,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Get(ctx, 1)
+				return err
+			},
+			expectedRequests: 1,
+			expectedError:    ErrNotFound,
+		},
+		{
+			name:     "SeekableUploadRetried",
+			failures: 1,
+			status:   http.StatusInternalServerError,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Upload(ctx, Upload{Filename: "hi.mp3", Body: strings.NewReader("audio")})
+				return err
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:     "StreamedUploadNotRetried",
+			failures: 1,
+			status:   http.StatusInternalServerError,
+			call: func(ctx context.Context, c *Client) error {
+				_, err := c.Upload(ctx, Upload{Filename: "hi.mp3", Body: io.MultiReader(strings.NewReader("audio"))})
+				return err
+			},
+			expectedRequests: 1,
+			expectedError:    ErrServer,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &flakyServer{failures: tc.failures, status: tc.status, body: `{"id": 1}`}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+			c, _ := New(httpServer.URL, WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+
+			err := tc.call(context.Background(), c)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, server.payloads, tc.expectedRequests)
+			// retries send the same body
+			for _, payload := range server.payloads {
+				assert.Equal(t, server.payloads[0], payload)
+			}
+		})
+	}
+}
+
+func TestClient_RetryCancelled(t *testing.T) {
+	server := &flakyServer{failures: 10, status: http.StatusServiceUnavailable}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	c, _ := New(httpServer.URL, WithRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Get(ctx, 1)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestReadError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		contentType   string
+		body          string
+		header        http.Header
+		expectedError Error
+	}{
+		{
+			name:          "ProblemDetails",
+			contentType:   "application/problem+json",
+			body:          `{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "no quip with id 4", "code": "not_found", "requestId": "abc"}`,
+			expectedError: Error{StatusCode: http.StatusNotFound, Code: "not_found", Message: "no quip with id 4", RequestID: "abc"},
+		},
+		{
+			name:          "JSONError",
+			contentType:   "application/json; charset=utf-8",
+			body:          `{"error": "position must be a number"}`,
+			header:        http.Header{"X-Request-Id": {"def"}},
+			expectedError: Error{StatusCode: http.StatusNotFound, Message: "position must be a number", RequestID: "def"},
+		},
+		{
+			name:          "PlainText",
+			contentType:   "text/plain",
+			body:          "no rows found\n",
+			expectedError: Error{StatusCode: http.StatusNotFound, Message: "no rows found"},
+		},
+		{
+			name:          "RetryAfter",
+			header:        http.Header{"Retry-After": {"7"}},
+			expectedError: Error{StatusCode: http.StatusNotFound, RetryAfter: 7 * time.Second},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {tc.contentType}}
+			for key, values := range tc.header {
+				header[key] = values
+			}
+			resp := &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(strings.NewReader(tc.body))}
+
+			err := readError(resp)
+
+			assert.Equal(t, &tc.expectedError, err)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestClient_Progress(t *testing.T) {
+	server := &recordingServer{status: http.StatusOK, body: strings.Repeat("a", 1000)}
+	c := newTestClient(t, server)
+	reported := []int64{}
+
+	_, err := c.Download(context.Background(), 4, io.Discard, func(transferred, total int64) {
+		assert.Equal(t, int64(1000), total)
+		reported = append(reported, transferred)
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), reported[len(reported)-1])
+
+	server.body = `{"id": 1}`
+	uploadTotal := int64(0)
+	_, err = c.Upload(context.Background(), Upload{Filename: "hi.mp3", Body: bytes.NewReader(make([]byte, 300)), Progress: func(transferred, total int64) {
+		assert.Equal(t, int64(300), total)
+		uploadTotal = transferred
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), uploadTotal)
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinels matched by errors.Is against an *Error, by status code
+var (
+	ErrBadRequest   = errors.New("the request was invalid")
+	ErrUnauthorized = errors.New("a valid API key is required")
+	ErrForbidden    = errors.New("the API key isn't allowed to do this")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflicts with the current state")
+	ErrRateLimited  = errors.New("too many requests")
+	ErrServer       = errors.New("the server failed to handle the request")
+)
+
+// Error is returned when the API answers with an error status. The fields other than StatusCode
+// are filled in from the error body when the server sent one (RFC 7807 problem details)
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error code, stable across releases
+	Code string
+	// Message tells what went wrong
+	Message string
+	// RequestID identifies the request in the server's logs
+	RequestID string
+	// RetryAfter is how long the server asked to wait before retrying, if it did
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("voice-quips API: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		message += " (" + e.Code + ")"
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		message += " [request " + e.RequestID + "]"
+	}
+	return message
+}
+
+// Is matches the sentinel errors of the status code
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// problem is an RFC 7807 problem details document. Error covers servers answering {"error": "..."}
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId"`
+	Error     string `json:"error"`
+}
+
+// readError builds the error of a failed response from its body, which may be problem details,
+// another JSON error or plain text
+func readError(resp *http.Response) error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		RetryAfter: retryAfter(resp),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<10))
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		var details problem
+		if err := json.Unmarshal(body, &details); err == nil {
+			apiErr.Code = details.Code
+			apiErr.Message = firstNonEmpty(details.Detail, details.Error, details.Title)
+			apiErr.RequestID = firstNonEmpty(details.RequestID, apiErr.RequestID)
+			return apiErr
+		}
+	}
+
+	apiErr.Message = strings.TrimSpace(string(body))
+	return apiErr
+}
+
+// retryAfter reads the Retry-After header, given in seconds or as a date
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
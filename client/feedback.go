@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// Favorite adds the quip to the caller's favorites
+func (c *Client) Favorite(ctx context.Context, id uint) error {
+	return c.doJSON(ctx, http.MethodPut, audioPath(id, "/favorite"), nil, nil, nil)
+}
+
+// Unfavorite removes the quip from the caller's favorites
+func (c *Client) Unfavorite(ctx context.Context, id uint) error {
+	return c.doJSON(ctx, http.MethodDelete, audioPath(id, "/favorite"), nil, nil, nil)
+}
+
+// Favorites returns the caller's favorites, most recently favorited first
+func (c *Client) Favorites(ctx context.Context) ([]*file.FileRecord, error) {
+	records := []*file.FileRecord{}
+	err := c.doJSON(ctx, http.MethodGet, "/me/favorites", nil, nil, &records)
+	return records, err
+}
+
+// Rate sets the caller's 1-5 score for the quip, returning its new rating
+func (c *Client) Rate(ctx context.Context, id uint, score int) (*file.Rating, error) {
+	return c.ratingRequest(ctx, http.MethodPut, id, map[string]int{"score": score})
+}
+
+// RemoveRating withdraws the caller's score for the quip, returning its new rating
+func (c *Client) RemoveRating(ctx context.Context, id uint) (*file.Rating, error) {
+	return c.ratingRequest(ctx, http.MethodDelete, id, nil)
+}
+
+func (c *Client) ratingRequest(ctx context.Context, method string, id uint, in any) (*file.Rating, error) {
+	var rating file.Rating
+	if err := c.doJSON(ctx, method, audioPath(id, "/rating"), nil, in, &rating); err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+)
+
+// Job returns the status of a background job, such as the processing of an upload
+func (c *Client) Job(ctx context.Context, id int64) (*jobs.Job, error) {
+	var job jobs.Job
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/jobs/%d", id), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+)
+
+// Playlists returns the public playlists along with the caller's own
+func (c *Client) Playlists(ctx context.Context) ([]*playlist.Playlist, error) {
+	playlists := []*playlist.Playlist{}
+	err := c.doJSON(ctx, http.MethodGet, "/playlists", nil, nil, &playlists)
+	return playlists, err
+}
+
+func (c *Client) Playlist(ctx context.Context, id uint) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodGet, playlistPath(id, ""), nil)
+}
+
+// CreatePlaylist creates a playlist owned by the caller
+func (c *Client) CreatePlaylist(ctx context.Context, created playlist.Playlist) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodPost, "/playlists", created)
+}
+
+// UpdatePlaylist renames the playlist and/or changes its visibility
+func (c *Client) UpdatePlaylist(ctx context.Context, id uint, update playlist.Update) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodPatch, playlistPath(id, ""), update)
+}
+
+func (c *Client) DeletePlaylist(ctx context.Context, id uint) error {
+	return c.doJSON(ctx, http.MethodDelete, playlistPath(id, ""), nil, nil, nil)
+}
+
+// AddPlaylistItem adds a quip to the playlist, at the end unless the item has a position
+func (c *Client) AddPlaylistItem(ctx context.Context, id uint, item playlist.Item) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodPost, playlistPath(id, "/items"), item)
+}
+
+func (c *Client) RemovePlaylistItem(ctx context.Context, id uint, position int) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodDelete, playlistPath(id, fmt.Sprintf("/items/%d", position)), nil)
+}
+
+// ReplacePlaylistItems replaces every item of the playlist, to reorder it or assign hotkeys
+func (c *Client) ReplacePlaylistItems(ctx context.Context, id uint, items []playlist.Item) (*playlist.Playlist, error) {
+	return c.playlistRequest(ctx, http.MethodPut, playlistPath(id, "/items"), items)
+}
+
+// ExportPlaylist writes the playlist as an m3u8 or xspf document pointing at the stream URLs
+func (c *Client) ExportPlaylist(ctx context.Context, id uint, format string, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, playlistPath(id, "/export"), url.Values{"format": {format}}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *Client) playlistRequest(ctx context.Context, method, path string, in any) (*playlist.Playlist, error) {
+	var found playlist.Playlist
+	if err := c.doJSON(ctx, method, path, nil, in, &found); err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func playlistPath(id uint, suffix string) string {
+	return fmt.Sprintf("/playlists/%d%s", id, suffix)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/phllpmcphrsn/voice-quips/share"
+)
+
+// ShareLink is a share link along with the URL to give out
+type ShareLink struct {
+	share.Link
+	URL string `json:"url"`
+}
+
+// Share creates a link letting anyone holding it listen to the quip. The zero request uses the
+// server's default lifetime and doesn't limit downloads
+func (c *Client) Share(ctx context.Context, id uint, request share.Request) (*ShareLink, error) {
+	var link ShareLink
+	if err := c.doJSON(ctx, http.MethodPost, audioPath(id, "/share"), nil, request, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ShareLinks returns the links the caller created, newest first
+func (c *Client) ShareLinks(ctx context.Context) ([]*ShareLink, error) {
+	links := []*ShareLink{}
+	err := c.doJSON(ctx, http.MethodGet, "/me/shares", nil, nil, &links)
+	return links, err
+}
+
+// RevokeShareLink stops the link from working
+func (c *Client) RevokeShareLink(ctx context.Context, id uint) error {
+	return c.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/me/shares/%d", id), nil, nil, nil)
+}
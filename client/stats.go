@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/phllpmcphrsn/voice-quips/stats"
+)
+
+// AudioStats returns the plays and downloads of the quip, with daily counts for the last days
+// (the server's default when 0)
+func (c *Client) AudioStats(ctx context.Context, id uint, days int) (*stats.FileStats, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+
+	var fileStats stats.FileStats
+	if err := c.doJSON(ctx, http.MethodGet, audioPath(id, "/stats"), query, nil, &fileStats); err != nil {
+		return nil, err
+	}
+	return &fileStats, nil
+}
+
+// TopStats ranks the quips by plays or downloads over the period. Empty values and a 0 limit use
+// the server's defaults
+func (c *Client) TopStats(ctx context.Context, period stats.Period, metric stats.Metric, limit int) ([]*stats.TopEntry, error) {
+	query := statsQuery(period, limit)
+	if metric != "" {
+		query.Set("by", string(metric))
+	}
+	return c.rankings(ctx, "/stats/top", query)
+}
+
+// TrendingStats ranks the quips gaining the most listeners over the period compared to the one before
+func (c *Client) TrendingStats(ctx context.Context, period stats.Period, limit int) ([]*stats.TopEntry, error) {
+	return c.rankings(ctx, "/stats/trending", statsQuery(period, limit))
+}
+
+func (c *Client) rankings(ctx context.Context, path string, query url.Values) ([]*stats.TopEntry, error) {
+	entries := []*stats.TopEntry{}
+	err := c.doJSON(ctx, http.MethodGet, path, query, nil, &entries)
+	return entries, err
+}
+
+func statsQuery(period stats.Period, limit int) url.Values {
+	query := url.Values{}
+	if period != "" {
+		query.Set("period", string(period))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	return query
+}
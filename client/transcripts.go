@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+)
+
+// Subtitle formats of a transcript
+const (
+	SubtitlesWebVTT = "vtt"
+	SubtitlesSRT    = "srt"
+)
+
+// Transcript returns what's said in the quip, with the timing of every word
+func (c *Client) Transcript(ctx context.Context, id uint) (*transcript.Transcript, error) {
+	var found transcript.Transcript
+	if err := c.doJSON(ctx, http.MethodGet, audioPath(id, "/transcript"), nil, nil, &found); err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// Subtitles returns the transcript of the quip as WebVTT or SRT subtitles
+func (c *Client) Subtitles(ctx context.Context, id uint, format string) ([]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, audioPath(id, "/transcript"), url.Values{"format": {format}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
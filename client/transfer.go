@@ -0,0 +1,53 @@
+package client
+
+import (
+	"io"
+	"os"
+)
+
+// ProgressFunc is called as audio is uploaded or downloaded with the number of bytes transferred
+// so far and the total, -1 when it isn't known
+type ProgressFunc func(transferred, total int64)
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	reader      io.Reader
+	total       int64
+	transferred int64
+	progress    ProgressFunc
+}
+
+func newProgressReader(reader io.Reader, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return reader
+	}
+	return &progressReader{reader: reader, total: total, progress: progress}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.transferred += int64(n)
+		p.progress(p.transferred, p.total)
+	}
+	return n, err
+}
+
+// sizeOf returns the number of bytes left in the reader when it can tell, -1 otherwise
+func sizeOf(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
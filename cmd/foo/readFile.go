@@ -53,6 +53,5 @@ func main() {
 	println("title: ", m.Title())
 	println("artist: ", m.Artist())
 	println("album: ", m.Year())
-	print(m.FileType())
-	print(m.)
+	println("file type: ", m.FileType())
 }
\ No newline at end of file
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/phllpmcphrsn/voice-quips/client"
+)
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// newFlags returns the flag set of a subcommand, printing the usage line before its flags
+func newFlags(e *env, name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: quips %s %s\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseArgs parses the flags and checks the number of remaining arguments, -1 meaning at least one
+func parseArgs(flags *flag.FlagSet, args []string, count int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (count < 0 && flags.NArg() == 0) || (count >= 0 && flags.NArg() != count) {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a quip or playlist id", value)
+	}
+	return uint(id), nil
+}
+
+// listFlags adds the flags filtering quips to the flag set
+func listFlags(flags *flag.FlagSet, opts *client.ListOptions) {
+	flags.Var((*stringList)(&opts.Tags), "tag", "Only list quips with this tag, can be given several times")
+	flags.BoolVar(&opts.MatchAnyTag, "any", false, "List quips with any of the tags rather than all of them")
+	flags.StringVar(&opts.Category, "category", "", "Only list quips of this category")
+	flags.StringVar(&opts.Sort, "sort", "", "Sort by rating or newest")
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	var opts client.ListOptions
+	flags := newFlags(e, "list", "[flags]")
+	listFlags(flags, &opts)
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	records, err := e.client.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return e.out.records(records)
+}
+
+func runSearch(ctx context.Context, e *env, args []string) error {
+	var opts client.ListOptions
+	flags := newFlags(e, "search", "[flags] <query>")
+	listFlags(flags, &opts)
+	if err := parseArgs(flags, args, -1); err != nil {
+		return err
+	}
+
+	opts.Search = strings.Join(flags.Args(), " ")
+	records, err := e.client.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return e.out.records(records)
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "get", "<id>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	record, err := e.client.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.out.record(record)
+}
+
+func runDownload(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "download", "[flags] <id>")
+	output := flags.String("o", "", "File to write the audio to, - for stdout. Defaults to the quip's filename")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err := e.client.Download(ctx, id, e.stdout, nil)
+		return err
+	}
+	if *output == "" {
+		record, err := e.client.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		*output = filepath.Base(record.Filename)
+	}
+
+	destination, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	written, err := e.client.Download(ctx, id, destination, progressTo(e.stderr, *output))
+	fmt.Fprintln(e.stderr)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	fmt.Fprintf(e.stderr, "downloaded %s (%d bytes)\n", *output, written)
+	return nil
+}
+
+func runUpload(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "upload", "[flags] <file>...")
+	category := flags.String("category", "", "Category of the uploaded quips")
+	var tags stringList
+	flags.Var(&tags, "tag", "Tag of the uploaded quips, can be given several times")
+	if err := parseArgs(flags, args, -1); err != nil {
+		return err
+	}
+
+	uploaded := []*client.Uploaded{}
+	for _, path := range flags.Args() {
+		result, err := upload(ctx, e.client, path, client.Upload{Category: *category, Tags: tags, Progress: progressTo(e.stderr, path)})
+		fmt.Fprintln(e.stderr)
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", path, err)
+		}
+		uploaded = append(uploaded, result)
+	}
+
+	return e.out.print(uploaded, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tFILENAME\tSTATUS\tJOB")
+		for _, result := range uploaded {
+			job := "-"
+			if result.JobID != 0 {
+				job = strconv.FormatInt(result.JobID, 10)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", result.ID, result.Filename, result.Status, job)
+		}
+	})
+}
+
+func upload(ctx context.Context, c *client.Client, path string, request client.Upload) (*client.Uploaded, error) {
+	audio, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	request.Filename = filepath.Base(path)
+	request.Body = audio
+	return c.Upload(ctx, request)
+}
+
+// progressTo rewrites a progress line for the file on w as it's transferred
+func progressTo(w io.Writer, name string) client.ProgressFunc {
+	return func(transferred, total int64) {
+		if total > 0 {
+			fmt.Fprintf(w, "\r%s: %3d%%", name, transferred*100/total)
+			return
+		}
+		fmt.Fprintf(w, "\r%s: %d bytes", name, transferred)
+	}
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "delete", "<id>...")
+	if err := parseArgs(flags, args, -1); err != nil {
+		return err
+	}
+
+	deleted := []uint{}
+	for _, arg := range flags.Args() {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		if err := e.client.Delete(ctx, id); err != nil {
+			return fmt.Errorf("deleting %d: %w", id, err)
+		}
+		deleted = append(deleted, id)
+	}
+	return e.out.message(map[string][]uint{"deleted": deleted}, "deleted %d quip(s)", len(deleted))
+}
+
+func runTag(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "tag", "<id> add|rm <tag>...")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 3 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	tags := flags.Args()[2:]
+
+	switch flags.Arg(1) {
+	case "add":
+		if _, err := e.client.AddTags(ctx, id, tags...); err != nil {
+			return err
+		}
+	case "rm", "remove":
+		for _, tag := range tags {
+			if err := e.client.RemoveTag(ctx, id, tag); err != nil {
+				return fmt.Errorf("removing %s: %w", tag, err)
+			}
+		}
+	default:
+		flags.Usage()
+		return errors.New("tags can be added (add) or removed (rm)")
+	}
+
+	record, err := e.client.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.out.record(record)
+}
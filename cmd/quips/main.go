@@ -0,0 +1,124 @@
+// Command quips manages voice quips through the REST API
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/phllpmcphrsn/voice-quips/client"
+)
+
+const usage = `Usage: quips [flags] <command> [arguments]
+
+Commands:
+  list                      list quips, filtered with -tag, -category and -sort
+  search <query>            search titles, filenames and transcripts
+  get <id>                  show a quip
+  download <id>             download the audio of a quip
+  upload <file>...          upload audio files
+  delete <id>...            delete quips (admin)
+  tag <id> add|rm <tag>...  add or remove tags
+  playlist <command>        list, show, create, delete, add and rm playlists and their items
+
+Profiles are read from %s (or -config) and select the endpoint and API key.
+QUIPS_ENDPOINT and QUIPS_API_KEY override the profile.
+
+Flags:
+`
+
+// env is what a command needs to run
+type env struct {
+	client *client.Client
+	out    *printer
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command runs a subcommand with the arguments following its name
+type command func(ctx context.Context, e *env, args []string) error
+
+var commands = map[string]command{
+	"list":     runList,
+	"search":   runSearch,
+	"get":      runGet,
+	"download": runDownload,
+	"upload":   runUpload,
+	"delete":   runDelete,
+	"tag":      runTag,
+	"playlist": runPlaylist,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "quips:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("quips", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, defaultConfigPath())
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", defaultConfigPath(), "CLI config file holding the profiles")
+	profileName := flags.String("profile", os.Getenv("QUIPS_PROFILE"), "Profile to use, the config's default profile when empty")
+	endpoint := flags.String("endpoint", "", "API endpoint, base path included, overriding the profile's")
+	apiKey := flags.String("api-key", "", "API key, overriding the profile's")
+	output := flags.String("output", "", "Output format, table or json")
+	flags.StringVar(output, "o", "", "Shorthand for -output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		return err
+	}
+	profile.Endpoint = firstSet(*endpoint, os.Getenv("QUIPS_ENDPOINT"), profile.Endpoint)
+	profile.APIKey = firstSet(*apiKey, os.Getenv("QUIPS_API_KEY"), profile.APIKey)
+
+	out, err := newPrinter(stdout, firstSet(*output, profile.Output))
+	if err != nil {
+		return err
+	}
+	c, err := client.New(profile.Endpoint, client.WithAPIKey(profile.APIKey))
+	if err != nil {
+		return err
+	}
+
+	return cmd(ctx, &env{client: c, out: out, stdout: stdout, stderr: stderr}, flags.Args()[1:])
+}
+
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
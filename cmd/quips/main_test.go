@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `default: local
+profiles:
+  local:
+    endpoint: http://localhost:9090/api/v1/voice-quips
+    apiKey: local-key
+  Prod:
+    endpoint: https://quips.example.com/api/v1/voice-quips
+    apiKeyVar: QUIPS_TEST_PROD_KEY
+    output: json
+`
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	assert.NoError(t, os.WriteFile(path, []byte(testConfig), 0o600))
+	t.Setenv("QUIPS_TEST_PROD_KEY", "prod-key")
+
+	testCases := []struct {
+		name            string
+		path            string
+		profile         string
+		expectedProfile Profile
+		expectedError   bool
+	}{
+		{
+			name:            "Default",
+			path:            path,
+			expectedProfile: Profile{Endpoint: "http://localhost:9090/api/v1/voice-quips", APIKey: "local-key"},
+		},
+		{
+			name:    "NamedWithKeyVar",
+			path:    path,
+			profile: "Prod",
+			expectedProfile: Profile{
+				Endpoint:  "https://quips.example.com/api/v1/voice-quips",
+				APIKey:    "prod-key",
+				APIKeyVar: "QUIPS_TEST_PROD_KEY",
+				Output:    "json",
+			},
+		},
+		{name: "Unknown", path: path, profile: "staging", expectedError: true},
+		{name: "NoConfigFile", path: filepath.Join(t.TempDir(), "missing.yml"), expectedProfile: Profile{Endpoint: defaultEndpoint}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile, err := loadProfile(tc.path, tc.profile)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedProfile, profile)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	quips := `[{"id": 1, "name": "hi.mp3", "category": "greetings", "tags": ["funny", "short"],
+		"status": "ready", "rating": {"average": 4.5, "count": 2}, "metadata": {"title": "Hi"}}]`
+
+	testCases := []struct {
+		name           string
+		args           []string
+		expectedPath   string
+		expectedQuery  string
+		expectedOutput string
+		expectedError  bool
+	}{
+		{
+			name:          "ListTable",
+			args:          []string{"list", "-tag", "funny", "-sort", "rating"},
+			expectedPath:  "/audio/",
+			expectedQuery: "sort=rating&tag=funny",
+			expectedOutput: "ID  TITLE  FILENAME  CATEGORY   TAGS         RATING   STATUS\n" +
+				"1   Hi     hi.mp3    greetings  funny,short  4.5 (2)  ready\n",
+		},
+		{
+			name:          "SearchJSON",
+			args:          []string{"-o", "json", "search", "good", "morning"},
+			expectedPath:  "/audio/",
+			expectedQuery: "q=good+morning",
+			expectedOutput: "[\n  {\n    \"id\": 1,\n    \"name\": \"hi.mp3\",\n    \"type\": \"\",\n    \"link\": \"\",\n" +
+				"    \"category\": \"greetings\",\n    \"tags\": [\n      \"funny\",\n      \"short\"\n    ],\n" +
+				"    \"uploadDate\": \"0001-01-01T00:00:00Z\",\n    \"status\": \"ready\",\n" +
+				"    \"rating\": {\n      \"average\": 4.5,\n      \"count\": 2\n    },\n" +
+				"    \"metadata\": {\n      \"title\": \"Hi\",\n      \"artist\": \"\",\n      \"album\": \"\",\n      \"year\": 0\n    }\n  }\n]\n",
+		},
+		{name: "UnknownCommand", args: []string{"fly"}, expectedError: true},
+		{name: "UnknownOutput", args: []string{"-o", "yaml", "list"}, expectedError: true},
+		{name: "GetWithoutID", args: []string{"get"}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var request *http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request = r
+				io.WriteString(w, quips)
+			}))
+			defer server.Close()
+
+			stdout := &bytes.Buffer{}
+			args := append([]string{"-config", "", "-endpoint", server.URL}, tc.args...)
+			err := run(context.Background(), args, stdout, io.Discard)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedPath, request.URL.Path)
+			assert.Equal(t, tc.expectedQuery, request.URL.RawQuery)
+			assert.Equal(t, tc.expectedOutput, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var ErrUnknownOutput = errors.New("supported outputs are table and json")
+
+// printer writes command results as JSON or as a table
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		format = outputTable
+	}
+	if format != outputTable && format != outputJSON {
+		return nil, ErrUnknownOutput
+	}
+	return &printer{w: w, format: format}, nil
+}
+
+// print writes the value as JSON, or as the table written by table
+func (p *printer) print(value any, table func(io.Writer)) error {
+	if p.format == outputJSON {
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func (p *printer) records(records []*file.FileRecord) error {
+	return p.print(records, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTITLE\tFILENAME\tCATEGORY\tTAGS\tRATING\tSTATUS")
+		for _, record := range records {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", record.ID, record.Title, record.Filename, record.Category,
+				strings.Join(record.Tags, ","), rating(record.Rating), record.Status)
+		}
+	})
+}
+
+func (p *printer) record(record *file.FileRecord) error {
+	return p.print(record, func(w io.Writer) {
+		fmt.Fprintf(w, "ID\t%d\n", record.ID)
+		fmt.Fprintf(w, "Title\t%s\n", record.Title)
+		fmt.Fprintf(w, "Artist\t%s\n", record.Artist)
+		fmt.Fprintf(w, "Album\t%s\n", record.Album)
+		if record.Year != 0 {
+			fmt.Fprintf(w, "Year\t%d\n", record.Year)
+		}
+		fmt.Fprintf(w, "Filename\t%s\n", record.Filename)
+		fmt.Fprintf(w, "Type\t%s\n", record.FileType)
+		fmt.Fprintf(w, "Category\t%s\n", record.Category)
+		fmt.Fprintf(w, "Tags\t%s\n", strings.Join(record.Tags, ","))
+		fmt.Fprintf(w, "Rating\t%s\n", rating(record.Rating))
+		fmt.Fprintf(w, "Status\t%s\n", record.Status)
+		fmt.Fprintf(w, "Uploaded\t%s\n", record.UploadDate.Format("2006-01-02 15:04"))
+	})
+}
+
+func (p *printer) playlists(playlists []*playlist.Playlist) error {
+	return p.print(playlists, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tOWNER\tPUBLIC\tITEMS")
+		for _, found := range playlists {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%d\n", found.ID, found.Name, found.Owner, found.Public, len(found.Items))
+		}
+	})
+}
+
+func (p *printer) playlist(found *playlist.Playlist) error {
+	return p.print(found, func(w io.Writer) {
+		fmt.Fprintf(w, "%d\t%s (%s)\n\n", found.ID, found.Name, found.Owner)
+		fmt.Fprintln(w, "POSITION\tQUIP\tTITLE\tHOTKEY")
+		for _, item := range found.Items {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", item.Position, item.FileID, item.Title, item.Hotkey)
+		}
+	})
+}
+
+// message writes a line in table mode and the value in JSON mode, for commands with nothing else to show
+func (p *printer) message(value any, format string, args ...any) error {
+	return p.print(value, func(w io.Writer) {
+		fmt.Fprintf(w, format+"\n", args...)
+	})
+}
+
+func rating(r file.Rating) string {
+	if r.Count == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f (%d)", r.Average, r.Count)
+}
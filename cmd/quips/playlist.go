@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+)
+
+const playlistUsage = `<command>
+
+Commands:
+  list                                     list the playlists
+  show <id>                                show a playlist and its quips
+  create [-public] <name>                  create a playlist
+  delete <id>                              delete a playlist
+  add [-hotkey h] [-position n] <id> <quip>  add a quip to a playlist
+  rm <id> <position>                       remove the quip at a position`
+
+func runPlaylist(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist", playlistUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	args = flags.Args()[1:]
+	switch flags.Arg(0) {
+	case "list":
+		return runPlaylistList(ctx, e, args)
+	case "show":
+		return runPlaylistShow(ctx, e, args)
+	case "create":
+		return runPlaylistCreate(ctx, e, args)
+	case "delete":
+		return runPlaylistDelete(ctx, e, args)
+	case "add":
+		return runPlaylistAdd(ctx, e, args)
+	case "rm", "remove":
+		return runPlaylistRemove(ctx, e, args)
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown playlist command %q", flags.Arg(0))
+}
+
+func runPlaylistList(ctx context.Context, e *env, args []string) error {
+	if err := parseArgs(newFlags(e, "playlist list", ""), args, 0); err != nil {
+		return err
+	}
+
+	playlists, err := e.client.Playlists(ctx)
+	if err != nil {
+		return err
+	}
+	return e.out.playlists(playlists)
+}
+
+func runPlaylistShow(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist show", "<id>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	found, err := e.client.Playlist(ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.out.playlist(found)
+}
+
+func runPlaylistCreate(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist create", "[flags] <name>")
+	public := flags.Bool("public", false, "Let everyone see the playlist")
+	if err := parseArgs(flags, args, -1); err != nil {
+		return err
+	}
+
+	created, err := e.client.CreatePlaylist(ctx, playlist.Playlist{Name: strings.Join(flags.Args(), " "), Public: *public})
+	if err != nil {
+		return err
+	}
+	return e.out.playlist(created)
+}
+
+func runPlaylistDelete(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist delete", "<id>")
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := e.client.DeletePlaylist(ctx, id); err != nil {
+		return err
+	}
+	return e.out.message(map[string]uint{"deleted": id}, "deleted playlist %d", id)
+}
+
+func runPlaylistAdd(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist add", "[flags] <id> <quip>")
+	hotkey := flags.String("hotkey", "", "Soundboard hotkey label of the quip")
+	position := flags.Int("position", 0, "Position of the quip, the end of the playlist when not given")
+	if err := parseArgs(flags, args, 2); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	fileID, err := parseID(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	updated, err := e.client.AddPlaylistItem(ctx, id, playlist.Item{FileID: fileID, Hotkey: *hotkey, Position: *position})
+	if err != nil {
+		return err
+	}
+	return e.out.playlist(updated)
+}
+
+func runPlaylistRemove(ctx context.Context, e *env, args []string) error {
+	flags := newFlags(e, "playlist rm", "<id> <position>")
+	if err := parseArgs(flags, args, 2); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	position, err := strconv.Atoi(flags.Arg(1))
+	if err != nil {
+		return fmt.Errorf("%q isn't a playlist position", flags.Arg(1))
+	}
+
+	updated, err := e.client.RemovePlaylistItem(ctx, id, position)
+	if err != nil {
+		return err
+	}
+	return e.out.playlist(updated)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const defaultEndpoint = "http://localhost:9090/api/v1/voice-quips"
+
+// Profile is where a set of commands sends its requests and how it authenticates. APIKeyVar names
+// an envvar holding the key so that it doesn't need to be written in the config file
+type Profile struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	APIKey    string `mapstructure:"apiKey"`
+	APIKeyVar string `mapstructure:"apiKeyVar"`
+	Output    string `mapstructure:"output"`
+}
+
+// profiles is the CLI's config file, eg.
+//
+//	default: local
+//	profiles:
+//	  local:
+//	    endpoint: http://localhost:9090/api/v1/voice-quips
+//	    apiKeyVar: VOICE_QUIPS_ADMIN_KEY
+//	  prod:
+//	    endpoint: https://quips.example.com/api/v1/voice-quips
+//	    output: json
+type profiles struct {
+	Default  string             `mapstructure:"default"`
+	Profiles map[string]Profile `mapstructure:"profiles"`
+}
+
+// defaultConfigPath is quips/config.yml under the user's config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "quips", "config.yml")
+}
+
+// loadProfile reads the named profile from the config file, or its default profile when no name
+// is given. Without a config file the default profile points at a local server
+func loadProfile(path, name string) (Profile, error) {
+	var config profiles
+	if path != "" {
+		v := viper.New()
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		err := v.ReadInConfig()
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Profile{}, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if err == nil {
+			if err := v.Unmarshal(&config); err != nil {
+				return Profile{}, fmt.Errorf("reading %s: %w", path, err)
+			}
+		}
+	}
+
+	if name == "" {
+		name = config.Default
+	}
+	if name == "" {
+		return Profile{Endpoint: defaultEndpoint}, nil
+	}
+
+	// viper lowercases keys
+	profile, ok := config.Profiles[name]
+	if !ok {
+		profile, ok = config.Profiles[strings.ToLower(name)]
+	}
+	if !ok {
+		return Profile{}, fmt.Errorf("no profile named %q in %s", name, path)
+	}
+	if profile.APIKey == "" && profile.APIKeyVar != "" {
+		profile.APIKey = os.Getenv(profile.APIKeyVar)
+	}
+	if profile.Endpoint == "" {
+		profile.Endpoint = defaultEndpoint
+	}
+	return profile, nil
+}
@@ -5,25 +5,112 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "log/slog"
 
 	"github.com/spf13/viper"
 )
 
+// ErrEmptyConfig is returned when the config file was read but sets nothing
+var ErrEmptyConfig = errors.New("config file holds no settings")
+
 // Config holds the configuration values
 type Config struct {
 	AudioDirectory string
-	API            APIConfig      `mapstructure:"api"`
-	Log            Log            `mapstructure:"log"`
-	Database       DatabaseConfig `mapstructure:"database"`
+	API            APIConfig           `mapstructure:"api"`
+	Log            Log                 `mapstructure:"log"`
+	Database       DatabaseConfig      `mapstructure:"database"`
+	Stats          StatsConfig         `mapstructure:"stats"`
+	Transcription  TranscriptionConfig `mapstructure:"transcription"`
+	Jobs           JobsConfig          `mapstructure:"jobs"`
+	Importer       ImporterConfig      `mapstructure:"importer"`
+	Metrics        MetricsConfig       `mapstructure:"metrics"`
+	Tracing        TracingConfig       `mapstructure:"tracing"`
+	Health         HealthConfig        `mapstructure:"health"`
+	Redis          RedisConfig         `mapstructure:"redis"`
+	RateLimit      RateLimitConfig     `mapstructure:"rateLimit"`
+	Cache          CacheConfig         `mapstructure:"cache"`
+	Share          ShareConfig         `mapstructure:"share"`
 }
 
-// APIConfig holds the API configuration values
+// APIConfig holds the API configuration values. Zero timeouts and sizes fall back to defaults
 type APIConfig struct {
-	Address string `mapstructure:"address"`
-	Path    string `mapstructure:"path"`
-	Env     string `mapstructure:"env"`
+	Address string     `mapstructure:"address"`
+	Path    string     `mapstructure:"path"`
+	Env     string     `mapstructure:"env"`
+	Auth    AuthConfig `mapstructure:"auth"`
+	// ReadTimeout bounds reading a whole request, body included, so it must leave time for uploads
+	ReadTimeout       time.Duration `mapstructure:"readTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"writeTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout"`
+	// ShutdownTimeout is the grace period in-flight requests get to finish once the server is
+	// told to stop. Background workers get as long again after them
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+	MaxHeaderBytes  int           `mapstructure:"maxHeaderBytes"`
+	// MaxBodyBytes caps request bodies, larger ones are answered with 413
+	MaxBodyBytes int64 `mapstructure:"maxBodyBytes"`
+	// TrustedProxies are the addresses or CIDRs of the proxies whose X-Forwarded-For is believed.
+	// When unset no proxy is trusted and callers are told apart by the address they connect from
+	TrustedProxies []string `mapstructure:"trustedProxies"`
+	// PublicURL is the scheme and host of the links handed out (eg. "https://quips.example.com").
+	// When unset they're built from the request's Host, and X-Forwarded-Proto of trusted proxies
+	PublicURL    string             `mapstructure:"publicURL"`
+	TLS          TLSConfig          `mapstructure:"tls"`
+	CacheControl CacheControlConfig `mapstructure:"cacheControl"`
+	Dashboard    DashboardConfig    `mapstructure:"dashboard"`
+	Listener     ListenerConfig     `mapstructure:"listener"`
+}
+
+// ListenerConfig holds the public listener app configuration values
+type ListenerConfig struct {
+	// Enabled serves the browse page at /quips, the player at /embed and oEmbed at /oembed
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// DashboardConfig holds the admin dashboard configuration values
+type DashboardConfig struct {
+	// Enabled serves the dashboard at /admin. Signing in to it takes an admin's API key
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// CacheControlConfig holds the Cache-Control headers of the responses clients may cache
+type CacheControlConfig struct {
+	// Audio is sent with streamed and downloaded audio, which never changes once stored
+	Audio string `mapstructure:"audio"`
+	// Metadata is sent with files and listings
+	Metadata string `mapstructure:"metadata"`
+}
+
+// TLSConfig holds the values of the API's TLS listener. The API serves plain HTTP, leaving TLS
+// to a proxy in front of it, when no certificate is given
+type TLSConfig struct {
+	// CertFile and KeyFile are PEM files, reloaded whenever they change
+	CertFile string `mapstructure:"certFile"`
+	KeyFile  string `mapstructure:"keyFile"`
+	// ClientCAFile enables mutual TLS: clients must present a certificate signed by one of its CAs
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// ClientAuth is require, the default, or optional to let clients without a certificate in
+	ClientAuth string `mapstructure:"clientAuth"`
+	// RedirectAddress is a plain HTTP listener redirecting every request to HTTPS (eg. ":80")
+	RedirectAddress string `mapstructure:"redirectAddress"`
+	// HSTSMaxAge is how long browsers are told to only use HTTPS, sent in prod. A year by default
+	HSTSMaxAge time.Duration `mapstructure:"hstsMaxAge"`
+}
+
+// AuthConfig holds the API keys allowed to call the API
+type AuthConfig struct {
+	Keys []APIKey `mapstructure:"keys"`
+}
+
+// APIKey maps a key to the user and role it authenticates as. KeyVar names an envvar holding
+// the key so that it doesn't need to be written in the config file
+type APIKey struct {
+	Key    string `mapstructure:"key"`
+	KeyVar string `mapstructure:"keyVar"`
+	User   string `mapstructure:"user"`
+	Role   string `mapstructure:"role"`
 }
 
 // Log holds the log configuration values
@@ -31,6 +118,151 @@ type Log struct {
 	Level string `mapstructure:"level"`
 }
 
+// StatsConfig holds the listening analytics configuration values. Zero values fall back to defaults
+type StatsConfig struct {
+	BatchSize      int           `mapstructure:"batchSize"`
+	BufferSize     int           `mapstructure:"bufferSize"`
+	FlushInterval  time.Duration `mapstructure:"flushInterval"`
+	RollupInterval time.Duration `mapstructure:"rollupInterval"`
+}
+
+// TranscriptionConfig holds the speech-to-text configuration values. Transcription is disabled
+// when no engine is given
+type TranscriptionConfig struct {
+	Engine  string        `mapstructure:"engine"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Whisper WhisperConfig `mapstructure:"whisper"`
+}
+
+// WhisperConfig holds the values used to run a local whisper.cpp binary
+type WhisperConfig struct {
+	Binary   string `mapstructure:"binary"`
+	Model    string `mapstructure:"model"`
+	Language string `mapstructure:"language"`
+	Threads  int    `mapstructure:"threads"`
+}
+
+// JobsConfig holds the background job queue configuration values. Zero values fall back to defaults
+type JobsConfig struct {
+	// Backend is postgres, the default, or memory
+	Backend      string        `mapstructure:"backend"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	PollInterval time.Duration `mapstructure:"pollInterval"`
+	Lease        time.Duration `mapstructure:"lease"`
+	MaxAttempts  int           `mapstructure:"maxAttempts"`
+	BackoffBase  time.Duration `mapstructure:"backoffBase"`
+	BackoffMax   time.Duration `mapstructure:"backoffMax"`
+}
+
+// ImporterConfig holds the values of the importer watching AudioDirectory for new files
+type ImporterConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Debounce is how long a file must go unchanged before it's imported, so partial writes are skipped
+	Debounce time.Duration `mapstructure:"debounce"`
+	// ProcessedDirectory is where imported files are moved to. They're left in place when empty
+	ProcessedDirectory string   `mapstructure:"processedDirectory"`
+	Category           string   `mapstructure:"category"`
+	Tags               []string `mapstructure:"tags"`
+}
+
+// MetricsConfig holds the Prometheus metrics configuration values. Zero values fall back to defaults
+type MetricsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Path is where the metrics are scraped from, /metrics by default
+	Path string `mapstructure:"path"`
+	// Address is a separate listener for the metrics, keeping them off the API's. The API serves
+	// them when it's empty
+	Address string `mapstructure:"address"`
+	// Namespace prefixes every metric name, voice_quips by default
+	Namespace string `mapstructure:"namespace"`
+	// Buckets are the upper bounds, in seconds, of the latency histograms
+	Buckets []float64 `mapstructure:"buckets"`
+}
+
+// TracingConfig holds the OpenTelemetry tracing configuration values. Spans aren't exported when
+// no exporter is given
+type TracingConfig struct {
+	// Exporter is otlp or stdout
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint is the host:port of the OTLP collector
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"serviceName"`
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+}
+
+// HealthConfig holds the readiness check configuration values. Zero values fall back to defaults
+type HealthConfig struct {
+	// Timeout bounds each dependency check run by /readyz
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// RedisConfig holds the values used to reach Redis, for the features given a redis backend.
+// PasswordVar names an envvar holding the password
+type RedisConfig struct {
+	Address     string `mapstructure:"address"`
+	Username    string `mapstructure:"username"`
+	Password    string `mapstructure:"password"`
+	PasswordVar string `mapstructure:"passwordVar"`
+	DB          int    `mapstructure:"db"`
+}
+
+// CacheConfig holds the file metadata cache configuration values. Zero values fall back to defaults
+type CacheConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Backend is memory, the default, or redis to share the cache between instances
+	Backend string `mapstructure:"backend"`
+	// Size is the number of entries the memory cache holds
+	Size int           `mapstructure:"size"`
+	TTL  time.Duration `mapstructure:"ttl"`
+	// NegativeTTL is how long files that weren't found are remembered, below 0 to not remember them
+	NegativeTTL time.Duration `mapstructure:"negativeTTL"`
+}
+
+// ShareConfig holds the share link configuration values. Zero lifetimes fall back to defaults
+type ShareConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Key signs the links' tokens, changing it invalidates every link given out. KeyVar names
+	// an envvar holding the key so that it doesn't need to be written in the config file
+	Key        string        `mapstructure:"key"`
+	KeyVar     string        `mapstructure:"keyVar"`
+	DefaultTTL time.Duration `mapstructure:"defaultTTL"`
+	MaxTTL     time.Duration `mapstructure:"maxTTL"`
+}
+
+// RateLimitConfig holds the request rate limits and upload quotas. Callers are told apart by
+// the user their API key authenticates as, or by IP when anonymous
+type RateLimitConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Backend is memory, the default, or redis to share the limits between instances
+	Backend string `mapstructure:"backend"`
+	// Default applies to the routes without a limit of their own
+	Default LimitConfig        `mapstructure:"default"`
+	Routes  []RouteLimitConfig `mapstructure:"routes"`
+	Quota   QuotaConfig        `mapstructure:"quota"`
+}
+
+// LimitConfig is a token bucket: Requests are allowed every Period, with bursts of up to Burst.
+// Burst defaults to Requests, nothing is limited when Requests is 0
+type LimitConfig struct {
+	Requests int           `mapstructure:"requests"`
+	Period   time.Duration `mapstructure:"period"`
+	Burst    int           `mapstructure:"burst"`
+}
+
+// RouteLimitConfig is the limit of one route, given as registered (eg. POST /audio/:id/tags)
+type RouteLimitConfig struct {
+	Method      string `mapstructure:"method"`
+	Route       string `mapstructure:"route"`
+	LimitConfig `mapstructure:",squash"`
+}
+
+// QuotaConfig caps what each uploader can upload per day (UTC). Zero values aren't capped
+type QuotaConfig struct {
+	DailyUploads int   `mapstructure:"dailyUploads"`
+	DailyBytes   int64 `mapstructure:"dailyBytes"`
+}
+
 // DatabaseConfig holds the database configuration values
 type DatabaseConfig struct {
 	FileInfoConfig FileInformationStoreConfig `mapstructure:"file"`
@@ -87,6 +319,10 @@ func LoadConfig(file string) (*Config, error) {
 		log.Error("an error occurred while reading in config file", "err", err)
 		return nil, err
 	}
+	if len(viper.AllKeys()) == 0 {
+		log.Error("the config file holds no settings", "file", viper.ConfigFileUsed())
+		return nil, ErrEmptyConfig
+	}
 
 	// Unmarshal the configuration values into the Config struct
 	var config Config
@@ -106,7 +342,7 @@ func LoadConfig(file string) (*Config, error) {
 
 	// TODO add if-statements checking for fields' values existence
 	// TODO API if-statements
-	
+
 	// TOOD Database if-statements
 	if config.Database.FileInfoConfig.Credentials.GetFromEnv {
 		config.Database.FileInfoConfig.Credentials.GetCredentialsFromEnv()
@@ -116,10 +352,55 @@ func LoadConfig(file string) (*Config, error) {
 		config.Database.S3Config.Credentials.GetCredentialsFromEnv()
 	}
 
+	if config.Redis.PasswordVar != "" {
+		if password := os.Getenv(config.Redis.PasswordVar); password != "" {
+			config.Redis.Password = password
+		}
+	}
+
+	if config.Share.KeyVar != "" {
+		if key := os.Getenv(config.Share.KeyVar); key != "" {
+			config.Share.Key = key
+		}
+	}
+
+	for i := range config.API.Auth.Keys {
+		config.API.Auth.Keys[i].GetKeyFromEnv()
+	}
+
 	log.Info("unmarshalled config in viper", "config", config)
 	return &config, nil
 }
 
+// redacted stands in for the secrets of a logged config
+const redacted = "[redacted]"
+
+// loggedConfig is the config without its LogValue method, so a redacted copy can be logged as is
+type loggedConfig Config
+
+// LogValue logs the config with its API keys, passwords and signing key replaced
+func (c Config) LogValue() log.Value {
+	keys := make([]APIKey, len(c.API.Auth.Keys))
+	for i, key := range c.API.Auth.Keys {
+		key.Key = redact(key.Key)
+		keys[i] = key
+	}
+	c.API.Auth.Keys = keys
+	c.Redis.Password = redact(c.Redis.Password)
+	c.Share.Key = redact(c.Share.Key)
+	c.Database.FileInfoConfig.Credentials.Password = []byte(redact(string(c.Database.FileInfoConfig.Credentials.Password)))
+	c.Database.S3Config.Credentials.Password = []byte(redact(string(c.Database.S3Config.Credentials.Password)))
+	return log.AnyValue(loggedConfig(c))
+}
+
+// redact hides a secret, leaving unset ones empty so it still shows whether they were set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
 // GetCredentialsFromEnv will set the user and password values to envvar values
 func (c *Credentials) GetCredentialsFromEnv() {
 	user := os.Getenv(c.UserVar)
@@ -133,6 +414,18 @@ func (c *Credentials) GetCredentialsFromEnv() {
 	}
 }
 
+// GetKeyFromEnv will set the key to the value of KeyVar's envvar, if there is one
+func (k *APIKey) GetKeyFromEnv() {
+	if k.KeyVar == "" {
+		return
+	}
+
+	key := os.Getenv(k.KeyVar)
+	if key != "" {
+		k.Key = key
+	}
+}
+
 func PathExists(path string) error {
 	if _, err := os.Stat(path); err == nil {
 		return nil
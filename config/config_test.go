@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -215,3 +216,35 @@ func TestGetLogLevel(t *testing.T) {
 // 	}
 // }
 
+func TestConfigLogValue(t *testing.T) {
+	config := Config{
+		API:   APIConfig{Auth: AuthConfig{Keys: []APIKey{{Key: "api-secret", User: "admin", Role: "admin"}}}},
+		Redis: RedisConfig{Address: "localhost:6379", Password: "redis-secret"},
+		Share: ShareConfig{Key: "share-secret"},
+		Database: DatabaseConfig{
+			FileInfoConfig: FileInformationStoreConfig{Credentials: Credentials{User: "postgres", Password: []byte("postgres-secret")}},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		handler func(*bytes.Buffer) log.Handler
+	}{
+		{name: "Text", handler: func(buf *bytes.Buffer) log.Handler { return log.NewTextHandler(buf, nil) }},
+		{name: "JSON", handler: func(buf *bytes.Buffer) log.Handler { return log.NewJSONHandler(buf, nil) }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.New(tc.handler(&buf)).Info("unmarshalled config in viper", "config", config)
+
+			for _, secret := range []string{"api-secret", "redis-secret", "share-secret", "postgres-secret"} {
+				assert.NotContains(t, buf.String(), secret)
+			}
+			assert.Contains(t, buf.String(), "localhost:6379")
+			assert.Contains(t, buf.String(), "admin")
+			assert.Equal(t, "api-secret", config.API.Auth.Keys[0].Key, "the config itself keeps its secrets")
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// ContentType is the content type feeds are served with
+const ContentType = "application/rss+xml; charset=utf-8"
+
+const (
+	itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+)
+
+// Channel is a podcast: what it's about and its episodes, newest first
+type Channel struct {
+	Title       string
+	Description string
+	// Link is the page of the podcast, SelfURL the URL the feed is served at
+	Link     string
+	SelfURL  string
+	Author   string
+	ImageURL string
+	Episodes []Episode
+}
+
+// Episode is a quip of a podcast. Size is in bytes, unknown when 0
+type Episode struct {
+	GUID        string
+	Title       string
+	Description string
+	Author      string
+	Link        string
+	URL         string
+	Size        int64
+	ContentType string
+	Duration    time.Duration
+	ImageURL    string
+	Published   time.Time
+}
+
+type rss struct {
+	XMLName     xml.Name   `xml:"rss"`
+	Version     string     `xml:"version,attr"`
+	ItunesXMLNS string     `xml:"xmlns:itunes,attr"`
+	AtomXMLNS   string     `xml:"xmlns:atom,attr"`
+	Channel     rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	AtomLink      atomLink     `xml:"atom:link"`
+	LastBuildDate string       `xml:"lastBuildDate,omitempty"`
+	Author        string       `xml:"itunes:author,omitempty"`
+	Explicit      string       `xml:"itunes:explicit"`
+	Image         *itunesImage `xml:"itunes:image"`
+	Items         []rssItem    `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link,omitempty"`
+	Description string       `xml:"description,omitempty"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate,omitempty"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+	Author      string       `xml:"itunes:author,omitempty"`
+	Duration    string       `xml:"itunes:duration,omitempty"`
+	Image       *itunesImage `xml:"itunes:image"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// RSS writes the channel as an RSS 2.0 document with the iTunes podcast tags. lastBuildDate is
+// the date of the newest episode so the document only changes when the episodes do
+func RSS(channel Channel) ([]byte, error) {
+	document := rss{
+		Version:     "2.0",
+		ItunesXMLNS: itunesNamespace,
+		AtomXMLNS:   atomNamespace,
+		Channel: rssChannel{
+			Title:       channel.Title,
+			Link:        channel.Link,
+			Description: channel.Description,
+			AtomLink:    atomLink{Href: channel.SelfURL, Rel: "self", Type: "application/rss+xml"},
+			Author:      channel.Author,
+			Explicit:    "false",
+			Image:       image(channel.ImageURL),
+			Items:       []rssItem{},
+		},
+	}
+	if newest := Newest(channel.Episodes); !newest.IsZero() {
+		document.Channel.LastBuildDate = newest.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, episode := range channel.Episodes {
+		item := rssItem{
+			Title:       episode.Title,
+			Link:        episode.Link,
+			Description: episode.Description,
+			GUID:        rssGUID{Value: episode.GUID},
+			Enclosure:   rssEnclosure{URL: episode.URL, Length: episode.Size, Type: episode.ContentType},
+			Author:      episode.Author,
+			Image:       image(episode.ImageURL),
+		}
+		if !episode.Published.IsZero() {
+			item.PubDate = episode.Published.UTC().Format(time.RFC1123Z)
+		}
+		if episode.Duration > 0 {
+			// podcast apps take the duration in seconds
+			item.Duration = strconv.FormatInt(int64((episode.Duration+time.Second/2)/time.Second), 10)
+		}
+		document.Channel.Items = append(document.Channel.Items, item)
+	}
+
+	output, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+// Newest returns the publication date of the newest episode, zero when there are none
+func Newest(episodes []Episode) time.Time {
+	var newest time.Time
+	for _, episode := range episodes {
+		if episode.Published.After(newest) {
+			newest = episode.Published
+		}
+	}
+	return newest
+}
+
+func image(url string) *itunesImage {
+	if url == "" {
+		return nil
+	}
+	return &itunesImage{Href: url}
+}
@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSS(t *testing.T) {
+	published := time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)
+	channel := Channel{
+		Title:       "Greetings",
+		Description: "Ways to say hello & bye",
+		Link:        "http://localhost:9090/quips",
+		SelfURL:     "http://localhost:9090/api/v1/voice-quips/feeds/category/greetings.rss",
+		Author:      "voice-quips",
+		Episodes: []Episode{
+			{
+				GUID:        "voice-quips:1",
+				Title:       "Hello",
+				Author:      "Phllp",
+				URL:         "http://localhost:9090/api/v1/voice-quips/audio/1/stream",
+				Size:        2048,
+				ContentType: "audio/mpeg",
+				Duration:    1500 * time.Millisecond,
+				ImageURL:    "http://localhost:9090/api/v1/voice-quips/audio/1/cover",
+				Published:   published,
+			},
+			{
+				GUID:        "voice-quips:2",
+				Title:       "bye.wav",
+				URL:         "http://localhost:9090/api/v1/voice-quips/audio/2/stream",
+				ContentType: "audio/wav",
+				Published:   published.Add(-time.Hour),
+			},
+		},
+	}
+
+	document, err := RSS(channel)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Namespaces", expected: `<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:atom="http://www.w3.org/2005/Atom">`},
+		{name: "SelfLink", expected: `<atom:link href="http://localhost:9090/api/v1/voice-quips/feeds/category/greetings.rss" rel="self" type="application/rss+xml"></atom:link>`},
+		{name: "Escaped", expected: `<description>Ways to say hello &amp; bye</description>`},
+		{name: "LastBuildDateIsNewestEpisode", expected: `<lastBuildDate>Sat, 13 Jan 2024 12:00:00 +0000</lastBuildDate>`},
+		{name: "Enclosure", expected: `<enclosure url="http://localhost:9090/api/v1/voice-quips/audio/1/stream" length="2048" type="audio/mpeg"></enclosure>`},
+		{name: "UnknownSize", expected: `<enclosure url="http://localhost:9090/api/v1/voice-quips/audio/2/stream" length="0" type="audio/wav"></enclosure>`},
+		{name: "DurationInSeconds", expected: `<itunes:duration>2</itunes:duration>`},
+		{name: "Cover", expected: `<itunes:image href="http://localhost:9090/api/v1/voice-quips/audio/1/cover"></itunes:image>`},
+		{name: "GUID", expected: `<guid isPermaLink="false">voice-quips:1</guid>`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Contains(t, string(document), tc.expected)
+		})
+	}
+
+	t.Run("WellFormed", func(t *testing.T) {
+		var parsed struct {
+			Items []struct {
+				Title string `xml:"title"`
+			} `xml:"channel>item"`
+		}
+		assert.NoError(t, xml.Unmarshal(document, &parsed))
+		assert.Len(t, parsed.Items, 2)
+	})
+
+	t.Run("NoEpisodes", func(t *testing.T) {
+		document, err := RSS(Channel{Title: "Empty"})
+
+		assert.NoError(t, err)
+		assert.NotContains(t, string(document), "lastBuildDate")
+		assert.NotContains(t, string(document), "itunes:image")
+	})
+}
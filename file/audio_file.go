@@ -1,9 +1,16 @@
 package file
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
 
 // type FileRequest stuct {
-// 	Header 
+// 	Header
 // }
 
 // FileRecord is a DTO
@@ -13,15 +20,137 @@ type FileRecord struct {
 	FileType   string    `json:"type"`
 	S3Link     string    `json:"link"`
 	Category   string    `json:"category"`
+	CategoryID *uint     `json:"categoryId,omitempty"`
+	Tags       []string  `json:"tags"`
 	UploadDate time.Time `json:"uploadDate"`
+	Status     Status    `json:"status"`
+	Checksum   string    `json:"checksum,omitempty"`
+	Size       int64     `json:"size,omitempty"` // bytes, unknown for files uploaded before sizes were recorded
+	Rating     Rating    `json:"rating"`
 	Metadata   `json:"metadata"`
 }
 
+// Status tells whether the background processing of an upload is done
+type Status string
+
+const (
+	StatusReady      Status = "ready"
+	StatusProcessing Status = "processing"
+	StatusFailed     Status = "failed"
+)
+
+// Rating is the aggregate of the 1-5 scores listeners gave a file
+type Rating struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
 
 type Metadata struct {
 	Title  string `json:"title"`
 	Artist string `json:"artist"`
 	Album  string `json:"album"`
 	Year   int `json:"year"`
+
+	// DurationMs is 0 when the length couldn't be worked out from the audio
+	DurationMs int64 `json:"durationMs,omitempty"`
+	// CoverType is the content type of the cover art in the tags, empty when there's none
+	CoverType string `json:"coverType,omitempty"`
+}
+
+// MetadataUpdate holds the metadata to correct. Nil fields are left as they are
+type MetadataUpdate struct {
+	Title  *string `json:"title"`
+	Artist *string `json:"artist"`
+	Album  *string `json:"album"`
+	Year   *int    `json:"year"`
+}
+
+// apply returns the metadata with the update's fields set
+func (u MetadataUpdate) apply(metadata Metadata) Metadata {
+	if u.Title != nil {
+		metadata.Title = *u.Title
+	}
+	if u.Artist != nil {
+		metadata.Artist = *u.Artist
+	}
+	if u.Album != nil {
+		metadata.Album = *u.Album
+	}
+	if u.Year != nil {
+		metadata.Year = *u.Year
+	}
+	return metadata
+}
+
+// Tag is a label (mood, speaker, language, use case...) that can be attached to many files.
+// Count is the number of files currently carrying the tag
+type Tag struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
 }
 
+// Filter narrows down the records returned by FindAll. The zero value returns everything
+type Filter struct {
+	Tags []string
+	// MatchAllTags requires a record to carry every tag in Tags (AND) instead of at least one (OR)
+	MatchAllTags bool
+	// Category is the slug of a category. Files in its subcategories are included as well
+	Category string
+	// Search matches words of the title, filename or transcript
+	Search string
+	// Sort is the order of the records, by ID when empty
+	Sort Sort
+	// Limit caps the number of records returned, all of them when 0. Offset skips the first ones
+	Limit  int
+	Offset int
+}
+
+// Sort is an order FindAll can return records in
+type Sort string
+
+const (
+	SortDefault Sort = ""
+	SortRating  Sort = "rating"
+	SortNewest  Sort = "newest"
+)
+
+var ErrUnknownSort = errors.New("supported sorts are rating and newest")
+
+// ParseSort validates a sort order given by a client
+func ParseSort(value string) (Sort, error) {
+	switch sort := Sort(strings.ToLower(value)); sort {
+	case SortDefault, SortRating, SortNewest:
+		return sort, nil
+	default:
+		return SortDefault, ErrUnknownSort
+	}
+}
+
+// NormalizeTags lowercases and trims the given tags, splitting comma separated values and
+// dropping empty and duplicate entries so that "Funny" and " funny" end up as the same tag
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, value := range tags {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// Checksum returns the hex encoded SHA-256 checksum of the content, used to spot duplicate uploads
+func Checksum(content io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
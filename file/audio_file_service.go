@@ -2,14 +2,24 @@ package file
 
 import (
 	"context"
+	"errors"
+	"io"
 	log "log/slog"
 	"mime/multipart"
+	"strings"
+	"time"
 
 	"github.com/dhowden/tag"
+	"github.com/phllpmcphrsn/voice-quips/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/phllpmcphrsn/voice-quips/file")
+
 type Saver interface {
-	Save(context.Context, multipart.File) (*FileRecord, error)
+	Save(context.Context, multipart.File, FileRecord) (*FileRecord, error)
 }
 
 type Deleter interface {
@@ -20,8 +30,32 @@ type Finder interface {
 	FindById(context.Context, string) (*FileRecord, error)
 }
 
+// ChecksumFinder looks up files by the SHA-256 checksum of their content, to spot duplicates
+type ChecksumFinder interface {
+	FindByChecksum(context.Context, string) (*FileRecord, error)
+}
+
 type AllFinder interface {
-	FindAll(context.Context) ([]*FileRecord, error)
+	FindAll(context.Context, Filter) ([]*FileRecord, error)
+}
+
+// Tagger defines the API for attaching tags to files and browsing them
+type Tagger interface {
+	AddTags(context.Context, string, []string) error
+	RemoveTags(context.Context, string, []string) error
+	FindAllTags(context.Context) ([]*Tag, error)
+}
+
+// Editor defines the API for correcting the metadata read from the audio
+type Editor interface {
+	UpdateMetadata(context.Context, string, MetadataUpdate) (*FileRecord, error)
+}
+
+// Processor defines the API for saving uploads whose audio is processed in the background
+type Processor interface {
+	SavePending(context.Context, FileRecord) (*FileRecord, error)
+	ProcessMetadata(context.Context, string, io.ReadSeeker) error
+	MarkFailed(context.Context, string) error
 }
 
 // Storer defines the API for interacting with NO/SQL storage
@@ -29,7 +63,11 @@ type Storer interface {
 	Saver
 	Deleter
 	Finder
+	ChecksumFinder
 	AllFinder
+	Tagger
+	Editor
+	Processor
 }
 
 type FileInformationService struct {
@@ -40,42 +78,216 @@ func NewFileInformationService(repo FileInformationRepository) *FileInformationS
 	return &FileInformationService{repo: repo}
 }
 
-func (m *FileInformationService) Save(ctx context.Context, file multipart.File) (*FileRecord, error) {
-	var fileInfo FileRecord
+// Save extracts the metadata from the given file and stores it alongside the information
+// already known about the upload (filename, type, category, tags...). Audio without tags is
+// saved without metadata. A title given with the upload wins over the one in the tags
+func (m *FileInformationService) Save(ctx context.Context, file multipart.File, fileInfo FileRecord) (saved *FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.Save", trace.WithAttributes(attribute.String("file.name", fileInfo.Filename)))
+	defer func() { tracing.End(span, err) }()
 
-	metadata, err := GetMetadata(file)
-	if err != nil {
+	metadata, err := getMetadata(ctx, file)
+	if err != nil && !errors.Is(err, tag.ErrNoTagsFound) {
 		return nil, err
 	}
+	if fileInfo.Title != "" {
+		metadata.Title = fileInfo.Title
+	}
 	fileInfo.Metadata = metadata
+	fileInfo.Status = StatusReady
+	fileInfo.Tags = NormalizeTags(fileInfo.Tags)
+	if fileInfo.UploadDate.IsZero() {
+		fileInfo.UploadDate = time.Now().UTC()
+	}
+
+	return m.repo.Create(ctx, fileInfo)
+}
+
+// SavePending stores what's known about an upload without reading its audio. The record stays
+// processing until ProcessMetadata or MarkFailed is called
+func (m *FileInformationService) SavePending(ctx context.Context, fileInfo FileRecord) (saved *FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.SavePending", trace.WithAttributes(attribute.String("file.name", fileInfo.Filename)))
+	defer func() { tracing.End(span, err) }()
+
+	fileInfo.Metadata = Metadata{}
+	fileInfo.Status = StatusProcessing
+	fileInfo.Tags = NormalizeTags(fileInfo.Tags)
+	if fileInfo.UploadDate.IsZero() {
+		fileInfo.UploadDate = time.Now().UTC()
+	}
 
 	return m.repo.Create(ctx, fileInfo)
 }
 
-func GetMetadata(file multipart.File) (Metadata, error) {
-	metadata, err := tag.ReadFrom(file)
+// ProcessMetadata reads the metadata of a pending upload and marks it ready. Audio without tags
+// is ready too, just without metadata
+func (m *FileInformationService) ProcessMetadata(ctx context.Context, id string, audio io.ReadSeeker) (err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.ProcessMetadata", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	metadata, err := getMetadata(ctx, audio)
+	if err != nil && !errors.Is(err, tag.ErrNoTagsFound) {
+		return err
+	}
+	return m.repo.UpdateMetadata(ctx, id, metadata, StatusReady)
+}
+
+// UpdateMetadata corrects the metadata of a file, keeping its processing status
+func (m *FileInformationService) UpdateMetadata(ctx context.Context, id string, update MetadataUpdate) (updated *FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.UpdateMetadata", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	record, err := m.repo.FindById(ctx, id)
 	if err != nil {
-		log.Error("could not parse metadata from file", "err", err)
+		return nil, err
+	}
+	record.Metadata = update.apply(record.Metadata)
+	if err := m.repo.UpdateMetadata(ctx, id, record.Metadata, record.Status); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// MarkFailed flags an upload whose processing gave up
+func (m *FileInformationService) MarkFailed(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.MarkFailed", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	return m.repo.UpdateStatus(ctx, id, StatusFailed)
+}
+
+// getMetadata parses the tags of the file in a span of its own, tag parsing can be slow on large files
+func getMetadata(ctx context.Context, file io.ReadSeeker) (metadata Metadata, err error) {
+	_, span := tracer.Start(ctx, "file.GetMetadata")
+	defer func() {
+		// files without tags are expected
+		if errors.Is(err, tag.ErrNoTagsFound) {
+			span.End()
+			return
+		}
+		tracing.End(span, err)
+	}()
+	return GetMetadata(file)
+}
+
+// GetMetadata reads the tags of the file, and its duration from the audio. The duration is
+// filled in even when ErrNoTagsFound is returned
+func GetMetadata(file io.ReadSeeker) (Metadata, error) {
+	var metadata Metadata
+	if duration, err := Duration(file); err == nil {
+		metadata.DurationMs = duration.Milliseconds()
+	} else {
+		log.Debug("could not work out the duration of the audio", "err", err)
+	}
+
+	// the file may have already been read (eg. to check its size) so rewind before parsing
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Error("could not rewind file before parsing metadata", "err", err)
 		return Metadata{}, err
 	}
-	
-	return Metadata{
-		Title: metadata.Title(), 
-		Artist: metadata.Artist(), 
-		Album: metadata.Album(), 
-		Year: metadata.Year(),
-		}, 
-		nil
+
+	tags, err := tag.ReadFrom(file)
+	if err != nil {
+		log.Error("could not parse metadata from file", "err", err)
+		return metadata, err
+	}
+
+	metadata.Title = tags.Title()
+	metadata.Artist = tags.Artist()
+	metadata.Album = tags.Album()
+	metadata.Year = tags.Year()
+	if picture := tags.Picture(); picture != nil && len(picture.Data) > 0 {
+		metadata.CoverType = pictureType(picture)
+	}
+	return metadata, nil
+}
+
+// Cover returns the cover art in the tags of the file and its content type
+func Cover(file io.ReadSeeker) (string, []byte, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+
+	tags, err := tag.ReadFrom(file)
+	if errors.Is(err, tag.ErrNoTagsFound) {
+		return "", nil, ErrNoCover
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	picture := tags.Picture()
+	if picture == nil || len(picture.Data) == 0 {
+		return "", nil, ErrNoCover
+	}
+	return pictureType(picture), picture.Data, nil
+}
+
+// pictureType returns the content type of the picture, going by its extension when the tags don't say
+func pictureType(picture *tag.Picture) string {
+	if picture.MIMEType != "" && picture.MIMEType != "-->" {
+		return strings.ToLower(picture.MIMEType)
+	}
+	if ext := strings.ToLower(picture.Ext); ext == "jpg" || ext == "jpeg" {
+		return "image/jpeg"
+	} else if ext != "" {
+		return "image/" + ext
+	}
+	return "application/octet-stream"
 }
 
-func (m *FileInformationService) Delete(ctx context.Context, id string) error {
+func (m *FileInformationService) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.Delete", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
 	return m.repo.Delete(ctx, id)
 }
 
-func (m *FileInformationService) FindById(ctx context.Context, id string) (*FileRecord, error) {
+func (m *FileInformationService) FindById(ctx context.Context, id string) (found *FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.FindById", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
 	return m.repo.FindById(ctx, id)
 }
 
-func (m *FileInformationService) FindAll(ctx context.Context) ([]*FileRecord, error) {
-	return m.repo.FindAll(ctx)
+func (m *FileInformationService) FindByChecksum(ctx context.Context, checksum string) (found *FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.FindByChecksum")
+	defer func() { tracing.End(span, err) }()
+
+	return m.repo.FindByChecksum(ctx, checksum)
+}
+
+func (m *FileInformationService) FindAll(ctx context.Context, filter Filter) (found []*FileRecord, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.FindAll")
+	defer func() { tracing.End(span, err) }()
+
+	filter.Tags = NormalizeTags(filter.Tags)
+	return m.repo.FindAll(ctx, filter)
+}
+
+func (m *FileInformationService) AddTags(ctx context.Context, id string, tags []string) (err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.AddTags", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	tags = NormalizeTags(tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	return m.repo.AddTags(ctx, id, tags)
+}
+
+func (m *FileInformationService) RemoveTags(ctx context.Context, id string, tags []string) (err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.RemoveTags", trace.WithAttributes(attribute.String("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	tags = NormalizeTags(tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	return m.repo.RemoveTags(ctx, id, tags)
+}
+
+func (m *FileInformationService) FindAllTags(ctx context.Context) (tags []*Tag, err error) {
+	ctx, span := tracer.Start(ctx, "FileInformationService.FindAllTags")
+	defer func() { tracing.End(span, err) }()
+
+	return m.repo.FindAllTags(ctx)
 }
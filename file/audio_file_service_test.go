@@ -1,9 +1,13 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"io"
 	"testing"
 
+	"github.com/dhowden/tag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -17,6 +21,16 @@ func (m *MockFileInformationRepository) Create(ctx context.Context, audioFile Fi
 	return args.Get(0).(*FileRecord), args.Error(1)
 }
 
+func (m *MockFileInformationRepository) UpdateMetadata(ctx context.Context, id string, metadata Metadata, status Status) error {
+	args := m.Called(ctx, id, metadata, status)
+	return args.Error(0)
+}
+
+func (m *MockFileInformationRepository) UpdateStatus(ctx context.Context, id string, status Status) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
 func (m *MockFileInformationRepository) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -27,16 +41,70 @@ func (m *MockFileInformationRepository) FindById(ctx context.Context, id string)
 	return args.Get(0).(*FileRecord), args.Error(1)
 }
 
-func (m *MockFileInformationRepository) FindAll(ctx context.Context) ([]*FileRecord, error) {
-	args := m.Called(ctx)
+func (m *MockFileInformationRepository) FindByChecksum(ctx context.Context, checksum string) (*FileRecord, error) {
+	args := m.Called(ctx, checksum)
+	return args.Get(0).(*FileRecord), args.Error(1)
+}
+
+func (m *MockFileInformationRepository) FindAll(ctx context.Context, filter Filter) ([]*FileRecord, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]*FileRecord), args.Error(1)
 }
 
+func (m *MockFileInformationRepository) AddTags(ctx context.Context, id string, tags []string) error {
+	args := m.Called(ctx, id, tags)
+	return args.Error(0)
+}
+
+func (m *MockFileInformationRepository) RemoveTags(ctx context.Context, id string, tags []string) error {
+	args := m.Called(ctx, id, tags)
+	return args.Error(0)
+}
+
+func (m *MockFileInformationRepository) FindAllTags(ctx context.Context) ([]*Tag, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*Tag), args.Error(1)
+}
+
+// testAudioFile is an in-memory multipart.File
+type testAudioFile struct {
+	*bytes.Reader
+}
+
+func (f *testAudioFile) Close() error {
+	return nil
+}
+
+// content returns all the bytes of the file
+func (f *testAudioFile) content() []byte {
+	content := make([]byte, f.Size())
+	f.ReadAt(content, 0)
+	return content
+}
+
+// newTestAudioFile returns a file holding nothing but an ID3v2.3 tag with the given title
+func newTestAudioFile(title string) *testAudioFile {
+	text := append([]byte{0x00}, []byte(title)...) // 0x00 is the ISO-8859-1 text encoding
+
+	frame := []byte("TIT2")
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(text)))
+	frame = append(frame, 0x00, 0x00)
+	frame = append(frame, text...)
+
+	// the tag size is stored as a syncsafe integer (7 bits per byte)
+	size := len(frame)
+	header := []byte{'I', 'D', '3', 0x03, 0x00, 0x00,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+
+	return &testAudioFile{bytes.NewReader(append(header, frame...))}
+}
+
 func TestAudioFileService_SaveAudioFile(t *testing.T) {
 	testCases := []struct {
 		name              string
 		mockRepository    *MockFileInformationRepository
 		inputAudioFile    FileRecord
+		savedAudioFile    FileRecord
 		expectedAudioFile *FileRecord
 		expectedError     bool
 		returnedError     error
@@ -44,8 +112,18 @@ func TestAudioFileService_SaveAudioFile(t *testing.T) {
 		{
 			name:              "SuccessfulSave",
 			mockRepository:    new(MockFileInformationRepository),
-			inputAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile"},
-			expectedAudioFile: &FileRecord{ID: 123, Filename: "TestAudioFile"},
+			inputAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile", Tags: []string{"Funny", "short,funny"}},
+			savedAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile", Tags: []string{"funny", "short"}, Status: StatusReady, Metadata: Metadata{Title: "Quip"}},
+			expectedAudioFile: &FileRecord{ID: 123, Filename: "TestAudioFile", Tags: []string{"funny", "short"}, Metadata: Metadata{Title: "Quip"}},
+			expectedError:     false,
+			returnedError:     nil,
+		},
+		{
+			name:              "GivenTitleWins",
+			mockRepository:    new(MockFileInformationRepository),
+			inputAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile", Metadata: Metadata{Title: "Legacy title"}},
+			savedAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile", Tags: []string{}, Status: StatusReady, Metadata: Metadata{Title: "Legacy title"}},
+			expectedAudioFile: &FileRecord{ID: 123, Filename: "TestAudioFile", Metadata: Metadata{Title: "Legacy title"}},
 			expectedError:     false,
 			returnedError:     nil,
 		},
@@ -53,6 +131,7 @@ func TestAudioFileService_SaveAudioFile(t *testing.T) {
 			name:              "SaveError",
 			mockRepository:    new(MockFileInformationRepository),
 			inputAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile"},
+			savedAudioFile:    FileRecord{ID: 123, Filename: "TestAudioFile", Tags: []string{}, Status: StatusReady, Metadata: Metadata{Title: "Quip"}},
 			expectedAudioFile: nil,
 			expectedError:     true,
 			returnedError:     &DBError{},
@@ -64,9 +143,14 @@ func TestAudioFileService_SaveAudioFile(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.Background()
 			service := FileInformationService{repo: tc.mockRepository}
-			tc.mockRepository.On("Create", ctx, tc.inputAudioFile).Return(tc.expectedAudioFile, tc.returnedError)
+			matchesSaved := mock.MatchedBy(func(record FileRecord) bool {
+				// the upload date is stamped by the service
+				record.UploadDate = tc.savedAudioFile.UploadDate
+				return assert.ObjectsAreEqual(tc.savedAudioFile, record)
+			})
+			tc.mockRepository.On("Create", mock.Anything, matchesSaved).Return(tc.expectedAudioFile, tc.returnedError)
 
-			result, err := service.Save(ctx, tc.inputAudioFile)
+			result, err := service.Save(ctx, newTestAudioFile("Quip"), tc.inputAudioFile)
 
 			if tc.expectedError {
 				assert.Error(t, err)
@@ -74,6 +158,251 @@ func TestAudioFileService_SaveAudioFile(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, tc.expectedAudioFile, result)
 			}
+			tc.mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+// newTestAudioWithCover returns an ID3v2.3 tag holding a title and cover art followed by MP3 frames
+func newTestAudioWithCover(mimeType string, picture []byte) []byte {
+	tagged := testAudio(newTestAudioFile("Quip"))
+
+	apic := append([]byte{0x00}, []byte(mimeType+"\x00")...)
+	apic = append(apic, 0x03, 0x00) // front cover, no description
+	apic = append(apic, picture...)
+	frame := []byte("APIC")
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(apic)))
+	frame = append(frame, 0x00, 0x00)
+	frame = append(frame, apic...)
+
+	size := len(tagged) - 10 + len(frame)
+	header := []byte{'I', 'D', '3', 0x03, 0x00, 0x00,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	audio := append(header, tagged[10:]...)
+	audio = append(audio, frame...)
+	return append(audio, mp3Frames(10)...)
+}
+
+func TestGetMetadata(t *testing.T) {
+	testCases := []struct {
+		name             string
+		audio            []byte
+		expectedMetadata Metadata
+		expectedErr      error
+	}{
+		{
+			name:             "TagsDurationAndCover",
+			audio:            newTestAudioWithCover("image/png", []byte("png")),
+			expectedMetadata: Metadata{Title: "Quip", DurationMs: 261, CoverType: "image/png"},
+		},
+		{
+			name:             "NoTags",
+			audio:            mp3Frames(10),
+			expectedMetadata: Metadata{DurationMs: 261},
+			expectedErr:      tag.ErrNoTagsFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata, err := GetMetadata(bytes.NewReader(tc.audio))
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedMetadata, metadata)
+		})
+	}
+}
+
+func TestCover(t *testing.T) {
+	testCases := []struct {
+		name         string
+		audio        []byte
+		expectedType string
+		expectedData []byte
+		expectedErr  error
+	}{
+		{name: "Cover", audio: newTestAudioWithCover("image/JPEG", []byte("jpeg")), expectedType: "image/jpeg", expectedData: []byte("jpeg")},
+		{name: "TagsWithoutCover", audio: testAudio(newTestAudioFile("Quip")), expectedErr: ErrNoCover},
+		{name: "NoTags", audio: mp3Frames(1), expectedErr: ErrNoCover},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeType, data, err := Cover(bytes.NewReader(tc.audio))
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedType, mimeType)
+			assert.Equal(t, tc.expectedData, data)
+		})
+	}
+}
+
+func testAudio(file *testAudioFile) []byte {
+	audio, _ := io.ReadAll(file)
+	return audio
+}
+
+func TestAudioFileService_FindAll(t *testing.T) {
+	testCases := []struct {
+		name           string
+		inputFilter    Filter
+		expectedFilter Filter
+	}{
+		{
+			name:           "NoFilter",
+			inputFilter:    Filter{},
+			expectedFilter: Filter{Tags: []string{}},
+		},
+		{
+			name:           "TagsAreNormalized",
+			inputFilter:    Filter{Tags: []string{" Funny", "SHORT", "funny"}, MatchAllTags: true},
+			expectedFilter: Filter{Tags: []string{"funny", "short"}, MatchAllTags: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			service := FileInformationService{repo: repo}
+			repo.On("FindAll", mock.Anything, tc.expectedFilter).Return([]*FileRecord{}, nil)
+
+			_, err := service.FindAll(ctx, tc.inputFilter)
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAudioFileService_AddTags(t *testing.T) {
+	testCases := []struct {
+		name         string
+		inputTags    []string
+		expectedTags []string
+		expectCall   bool
+	}{
+		{
+			name:         "TagsAdded",
+			inputTags:    []string{"Mood", "speaker, mood"},
+			expectedTags: []string{"mood", "speaker"},
+			expectCall:   true,
+		},
+		{
+			name:       "OnlyEmptyTags",
+			inputTags:  []string{" ", ","},
+			expectCall: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			service := FileInformationService{repo: repo}
+			if tc.expectCall {
+				repo.On("AddTags", mock.Anything, "1", tc.expectedTags).Return(nil)
+			}
+
+			err := service.AddTags(ctx, "1", tc.inputTags)
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAudioFileService_UpdateMetadata(t *testing.T) {
+	title, year := "Good morning", 2023
+	stored := Metadata{Title: "gm", Artist: "me", Year: 2020}
+
+	testCases := []struct {
+		name             string
+		update           MetadataUpdate
+		expectedMetadata Metadata
+	}{
+		{
+			name:             "SetFieldsChange",
+			update:           MetadataUpdate{Title: &title, Year: &year},
+			expectedMetadata: Metadata{Title: "Good morning", Artist: "me", Year: 2023},
+		},
+		{
+			name:             "NothingSet",
+			update:           MetadataUpdate{},
+			expectedMetadata: stored,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			service := FileInformationService{repo: repo}
+			repo.On("FindById", mock.Anything, "1").Return(&FileRecord{ID: 1, Status: StatusProcessing, Metadata: stored}, nil)
+			repo.On("UpdateMetadata", mock.Anything, "1", tc.expectedMetadata, StatusProcessing).Return(nil)
+
+			updated, err := service.UpdateMetadata(ctx, "1", tc.update)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMetadata, updated.Metadata)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBuildFindAllQuery(t *testing.T) {
+	testCases := []struct {
+		name         string
+		filter       Filter
+		contains     []string
+		expectedArgs int
+	}{
+		{
+			name:         "NoFilter",
+			filter:       Filter{},
+			contains:     []string{"FROM file_info ORDER BY file_info.id"},
+			expectedArgs: 0,
+		},
+		{
+			name:         "AnyTag",
+			filter:       Filter{Tags: []string{"funny", "short"}},
+			contains:     []string{"tags.name = ANY($1)"},
+			expectedArgs: 1,
+		},
+		{
+			name:         "AllTags",
+			filter:       Filter{Tags: []string{"funny", "short"}, MatchAllTags: true},
+			contains:     []string{"tags.name = ANY($1)", "HAVING COUNT(DISTINCT tags.name) = $2"},
+			expectedArgs: 2,
+		},
+		{
+			name:         "Search",
+			filter:       Filter{Search: "hello there", Tags: []string{"funny"}},
+			contains:     []string{"@@ plainto_tsquery('simple', $2)", "SELECT file_id FROM transcripts"},
+			expectedArgs: 2,
+		},
+		{
+			name:         "SortByRating",
+			filter:       Filter{Sort: SortRating},
+			contains:     []string{"ORDER BY file_info.rating_average DESC, file_info.rating_count DESC"},
+			expectedArgs: 0,
+		},
+		{
+			name:         "Page",
+			filter:       Filter{Category: "greetings", Limit: 25, Offset: 50},
+			contains:     []string{"ORDER BY file_info.id LIMIT $2 OFFSET $3"},
+			expectedArgs: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stmt, args := buildFindAllQuery(tc.filter)
+
+			for _, fragment := range tc.contains {
+				assert.Contains(t, stmt, fragment)
+			}
+			assert.Len(t, args, tc.expectedArgs)
 		})
 	}
 }
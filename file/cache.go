@@ -0,0 +1,260 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"strconv"
+	"time"
+
+	log "log/slog"
+
+	"github.com/phllpmcphrsn/voice-quips/cache"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache defaults, used when the config leaves them out
+const (
+	DefaultCacheTTL         = time.Minute
+	DefaultCacheNegativeTTL = 10 * time.Second
+)
+
+// generationKey holds the generation of the cached listings. Every write starts a new one,
+// leaving the listings of the old one to expire
+const generationKey = "files:generation"
+
+// recordsGenerationKey holds the generation of the cached records, started anew by writes
+// changing more files than can be named
+const recordsGenerationKey = "file:generation"
+
+// notFound is cached for the records that don't exist
+var notFound = []byte("null")
+
+// Invalidator is told about the writes to files made around the CachedStorer, such as ratings,
+// category changes and transcripts, so that they show up right away
+type Invalidator interface {
+	// Invalidate drops the file's record and every listing
+	Invalidate(ctx context.Context, id string)
+	// InvalidateAll drops every record and listing
+	InvalidateAll(ctx context.Context)
+}
+
+// CachedStorer caches the records and listings read from the storer it wraps. Writes going
+// through it invalidate what they change, writes made elsewhere invalidate through its Invalidator
+// methods
+type CachedStorer struct {
+	next        Storer
+	cache       cache.Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// loads shares a load between the concurrent misses of a key, so that an entry expiring
+	// doesn't send every request after it to the database
+	loads singleflight.Group
+}
+
+// NewCachedStorer wraps the storer with the cache. A negative TTL below 0 turns off the caching
+// of records that weren't found
+func NewCachedStorer(next Storer, c cache.Cache, cfg config.CacheConfig) *CachedStorer {
+	s := &CachedStorer{next: next, cache: c, ttl: cfg.TTL, negativeTTL: cfg.NegativeTTL}
+	if s.ttl <= 0 {
+		s.ttl = DefaultCacheTTL
+	}
+	if s.negativeTTL == 0 {
+		s.negativeTTL = DefaultCacheNegativeTTL
+	}
+	return s
+}
+
+func (s *CachedStorer) recordKey(ctx context.Context, id string) string {
+	return "file:" + s.generation(ctx, recordsGenerationKey) + ":" + id
+}
+
+func (s *CachedStorer) FindById(ctx context.Context, id string) (*FileRecord, error) {
+	var record *FileRecord
+	err := s.read(ctx, s.recordKey(ctx, id), &record, func() (interface{}, error) {
+		found, err := s.next.FindById(ctx, id)
+		if errors.Is(err, ErrNoRowsFound) {
+			// remembered as not found
+			return nil, nil
+		}
+		return found, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, NoRowsFoundError("")
+	}
+	return record, nil
+}
+
+// FindByChecksum isn't cached, duplicates must be spotted as soon as they're uploaded
+func (s *CachedStorer) FindByChecksum(ctx context.Context, checksum string) (*FileRecord, error) {
+	return s.next.FindByChecksum(ctx, checksum)
+}
+
+func (s *CachedStorer) FindAll(ctx context.Context, filter Filter) ([]*FileRecord, error) {
+	filter.Tags = NormalizeTags(filter.Tags)
+	encoded, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(encoded)
+
+	var records []*FileRecord
+	key := "files:" + s.generation(ctx, generationKey) + ":" + hex.EncodeToString(hash[:])
+	err = s.read(ctx, key, &records, func() (interface{}, error) {
+		return s.next.FindAll(ctx, filter)
+	})
+	return records, err
+}
+
+func (s *CachedStorer) FindAllTags(ctx context.Context) ([]*Tag, error) {
+	var tags []*Tag
+	err := s.read(ctx, "tags:"+s.generation(ctx, generationKey), &tags, func() (interface{}, error) {
+		return s.next.FindAllTags(ctx)
+	})
+	return tags, err
+}
+
+func (s *CachedStorer) Save(ctx context.Context, file multipart.File, fileInfo FileRecord) (*FileRecord, error) {
+	saved, err := s.next.Save(ctx, file, fileInfo)
+	if err == nil {
+		s.invalidate(ctx, strconv.FormatUint(uint64(saved.ID), 10))
+	}
+	return saved, err
+}
+
+func (s *CachedStorer) SavePending(ctx context.Context, fileInfo FileRecord) (*FileRecord, error) {
+	saved, err := s.next.SavePending(ctx, fileInfo)
+	if err == nil {
+		s.invalidate(ctx, strconv.FormatUint(uint64(saved.ID), 10))
+	}
+	return saved, err
+}
+
+// The writes below invalidate even when they fail, they may have gone through before failing
+
+func (s *CachedStorer) ProcessMetadata(ctx context.Context, id string, audio io.ReadSeeker) error {
+	defer s.invalidate(ctx, id)
+	return s.next.ProcessMetadata(ctx, id, audio)
+}
+
+func (s *CachedStorer) UpdateMetadata(ctx context.Context, id string, update MetadataUpdate) (*FileRecord, error) {
+	defer s.invalidate(ctx, id)
+	return s.next.UpdateMetadata(ctx, id, update)
+}
+
+func (s *CachedStorer) MarkFailed(ctx context.Context, id string) error {
+	defer s.invalidate(ctx, id)
+	return s.next.MarkFailed(ctx, id)
+}
+
+func (s *CachedStorer) Delete(ctx context.Context, id string) error {
+	defer s.invalidate(ctx, id)
+	return s.next.Delete(ctx, id)
+}
+
+func (s *CachedStorer) AddTags(ctx context.Context, id string, tags []string) error {
+	defer s.invalidate(ctx, id)
+	return s.next.AddTags(ctx, id, tags)
+}
+
+func (s *CachedStorer) RemoveTags(ctx context.Context, id string, tags []string) error {
+	defer s.invalidate(ctx, id)
+	return s.next.RemoveTags(ctx, id, tags)
+}
+
+// read decodes the entry of the key into value, loading and caching it on a miss. The cache
+// failing falls back to loading, it's only there to spare the database
+func (s *CachedStorer) read(ctx context.Context, key string, value interface{}, load func() (interface{}, error)) error {
+	data, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		log.WarnContext(ctx, "could not read from the cache", "err", err, "key", key)
+	}
+	if found {
+		return json.Unmarshal(data, value)
+	}
+
+	loaded, err, _ := s.loads.Do(key, func() (interface{}, error) {
+		loaded, err := load()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(loaded)
+		if err != nil {
+			return nil, err
+		}
+		s.set(ctx, key, data)
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(loaded.([]byte), value)
+}
+
+func (s *CachedStorer) set(ctx context.Context, key string, data []byte) {
+	ttl := s.ttl
+	if string(data) == string(notFound) {
+		if s.negativeTTL < 0 {
+			return
+		}
+		ttl = s.negativeTTL
+	} else {
+		// up to a tenth off, so that entries cached together don't all expire together
+		ttl -= time.Duration(rand.Int63n(int64(ttl)/10 + 1))
+	}
+	if err := s.cache.Set(ctx, key, data, ttl); err != nil {
+		log.WarnContext(ctx, "could not write to the cache", "err", err, "key", key)
+	}
+}
+
+// generation returns the generation held by the key, starting one when there's none
+func (s *CachedStorer) generation(ctx context.Context, key string) string {
+	data, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		log.WarnContext(ctx, "could not read the cache generation", "err", err, "key", key)
+	}
+	if found {
+		return string(data)
+	}
+	return s.newGeneration(ctx, key)
+}
+
+func (s *CachedStorer) newGeneration(ctx context.Context, key string) string {
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(rand.Int63(), 36)
+	if err := s.cache.Set(ctx, key, []byte(generation), 0); err != nil {
+		log.WarnContext(ctx, "could not start a new cache generation", "err", err, "key", key)
+	}
+	return generation
+}
+
+// Invalidate drops the record and every listing
+func (s *CachedStorer) Invalidate(ctx context.Context, id string) {
+	s.invalidate(ctx, id)
+}
+
+// InvalidateAll drops every record and listing by starting new generations of both. Loads under
+// way are keyed by the old ones, so reads from now on don't share their stale result
+func (s *CachedStorer) InvalidateAll(ctx context.Context) {
+	s.newGeneration(ctx, recordsGenerationKey)
+	s.newGeneration(ctx, generationKey)
+}
+
+// invalidate drops the record and every listing. Loads of the record already under way are
+// forgotten so that reads from now on don't get their stale result
+func (s *CachedStorer) invalidate(ctx context.Context, id string) {
+	key := s.recordKey(ctx, id)
+	s.loads.Forget(key)
+	if err := s.cache.Delete(ctx, key); err != nil {
+		log.WarnContext(ctx, "could not invalidate the cached record", "err", err, "id", id)
+	}
+	s.newGeneration(ctx, generationKey)
+}
@@ -0,0 +1,181 @@
+package file
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/cache"
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newCachedStorer(repo FileInformationRepository) *CachedStorer {
+	return NewCachedStorer(NewFileInformationService(repo), cache.NewLRU(100), config.CacheConfig{})
+}
+
+func TestCachedStorer_FindById(t *testing.T) {
+	testCases := []struct {
+		name        string
+		record      *FileRecord
+		err         error
+		expectedErr error
+	}{
+		{name: "Found", record: &FileRecord{ID: 1, Filename: "a.mp3", Tags: []string{"calm"}}},
+		{name: "NotFound", record: (*FileRecord)(nil), err: NoRowsFoundError(""), expectedErr: ErrNoRowsFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			repo.On("FindById", mock.Anything, "1").Return(tc.record, tc.err)
+			storer := newCachedStorer(repo)
+
+			for i := 0; i < 3; i++ {
+				found, err := storer.FindById(ctx, "1")
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.Equal(t, tc.record, found)
+			}
+			repo.AssertNumberOfCalls(t, "FindById", 1)
+		})
+	}
+}
+
+func TestCachedStorer_NegativeTTL(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockFileInformationRepository)
+	repo.On("FindById", mock.Anything, "1").Return((*FileRecord)(nil), NoRowsFoundError(""))
+	storer := NewCachedStorer(NewFileInformationService(repo), cache.NewLRU(100), config.CacheConfig{NegativeTTL: -1})
+
+	storer.FindById(ctx, "1")
+	storer.FindById(ctx, "1")
+
+	repo.AssertNumberOfCalls(t, "FindById", 2)
+}
+
+func TestCachedStorer_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockFileInformationRepository)
+	repo.On("FindById", mock.Anything, "1").Return(&FileRecord{ID: 1}, nil)
+	repo.On("FindAll", mock.Anything, mock.Anything).Return([]*FileRecord{{ID: 1}}, nil)
+	repo.On("FindAllTags", mock.Anything).Return([]*Tag{{ID: 1, Name: "calm", Count: 1}}, nil)
+	repo.On("AddTags", mock.Anything, "1", []string{"calm"}).Return(nil)
+	storer := newCachedStorer(repo)
+
+	read := func() {
+		storer.FindById(ctx, "1")
+		storer.FindAll(ctx, Filter{Tags: []string{"Calm"}})
+		storer.FindAll(ctx, Filter{Tags: []string{"calm"}})
+		storer.FindAllTags(ctx)
+	}
+	read()
+	read()
+	repo.AssertNumberOfCalls(t, "FindById", 1)
+	repo.AssertNumberOfCalls(t, "FindAll", 1)
+	repo.AssertNumberOfCalls(t, "FindAllTags", 1)
+
+	assert.NoError(t, storer.AddTags(ctx, "1", []string{"calm"}))
+	read()
+	repo.AssertNumberOfCalls(t, "FindById", 2)
+	repo.AssertNumberOfCalls(t, "FindAll", 2)
+	repo.AssertNumberOfCalls(t, "FindAllTags", 2)
+}
+
+func TestCachedStorer_SharesLoads(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockFileInformationRepository)
+	// the first load is slow enough for the other reads to miss while it's under way
+	repo.On("FindById", mock.Anything, "1").After(50*time.Millisecond).Return(&FileRecord{ID: 1}, nil)
+	storer := newCachedStorer(repo)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, err := storer.FindById(ctx, "1")
+			assert.NoError(t, err)
+			assert.Equal(t, uint(1), found.ID)
+		}()
+	}
+	wg.Wait()
+
+	repo.AssertNumberOfCalls(t, "FindById", 1)
+}
+
+func TestCachedStorer_InvalidatedByServices(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(ctx context.Context, cache Invalidator) error
+	}{
+		{
+			name: "Rate",
+			write: func(ctx context.Context, cache Invalidator) error {
+				repo := new(MockFeedbackRepository)
+				repo.On("Rate", mock.Anything, "alice", "1", 4).Return(&Rating{Average: 4, Count: 1}, nil)
+				_, err := NewFeedbackService(repo, cache).Rate(ctx, "alice", "1", 4)
+				return err
+			},
+		},
+		{
+			name: "RemoveRating",
+			write: func(ctx context.Context, cache Invalidator) error {
+				repo := new(MockFeedbackRepository)
+				repo.On("RemoveRating", mock.Anything, "alice", "1").Return(&Rating{}, nil)
+				_, err := NewFeedbackService(repo, cache).RemoveRating(ctx, "alice", "1")
+				return err
+			},
+		},
+		{
+			name: "RenameCategory",
+			write: func(ctx context.Context, cache Invalidator) error {
+				repo := new(MockCategoryRepository)
+				funny := &Category{ID: 1, Name: "Funny", Slug: "funny"}
+				repo.On("FindCategoryBySlug", mock.Anything, "funny").Return(funny, nil)
+				repo.On("FindAllCategories", mock.Anything).Return([]*Category{funny}, nil)
+				repo.On("UpdateCategory", mock.Anything, mock.Anything).Return(&Category{ID: 1, Name: "Jokes", Slug: "jokes"}, nil)
+				_, err := NewCategoryService(repo, cache).UpdateCategory(ctx, "funny", Category{Name: "Jokes"})
+				return err
+			},
+		},
+		{
+			name: "DeleteCategory",
+			write: func(ctx context.Context, cache Invalidator) error {
+				repo := new(MockCategoryRepository)
+				repo.On("DeleteCategory", mock.Anything, "funny").Return(nil)
+				return NewCategoryService(repo, cache).DeleteCategory(ctx, "funny")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			repo.On("FindById", mock.Anything, "1").Return(&FileRecord{ID: 1}, nil)
+			repo.On("FindAll", mock.Anything, mock.Anything).Return([]*FileRecord{{ID: 1}}, nil)
+			storer := newCachedStorer(repo)
+
+			read := func() {
+				storer.FindById(ctx, "1")
+				storer.FindAll(ctx, Filter{Sort: SortRating})
+			}
+			read()
+			read()
+			repo.AssertNumberOfCalls(t, "FindById", 1)
+			repo.AssertNumberOfCalls(t, "FindAll", 1)
+
+			assert.NoError(t, tc.write(ctx, storer))
+			read()
+			repo.AssertNumberOfCalls(t, "FindById", 2)
+			repo.AssertNumberOfCalls(t, "FindAll", 2)
+		})
+	}
+}
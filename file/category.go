@@ -0,0 +1,230 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Category groups files into a curated catalogue. Categories can be nested through ParentID
+type Category struct {
+	ID          uint        `json:"id"`
+	Name        string      `json:"name"`
+	Slug        string      `json:"slug"`
+	Description string      `json:"description"`
+	ParentID    *uint       `json:"parentId,omitempty"`
+	SortOrder   int         `json:"sortOrder"`
+	Children    []*Category `json:"children,omitempty"`
+}
+
+var ErrCategoryNameMissing = errors.New("category name not given")
+var ErrCategorySlugInvalid = errors.New("category slug must contain at least one letter or digit")
+var ErrCategoryCycle = errors.New("a category can't be its own ancestor")
+var ErrParentCategoryNotFound = errors.New("parent category not found")
+
+type CategoryRepository interface {
+	CreateCategory(context.Context, Category) (*Category, error)
+	UpdateCategory(context.Context, Category) (*Category, error)
+	DeleteCategory(context.Context, string) error
+	FindCategoryBySlug(context.Context, string) (*Category, error)
+	FindAllCategories(context.Context) ([]*Category, error)
+}
+
+// CategoryStorer defines the API for curating the category catalogue
+type CategoryStorer interface {
+	CreateCategory(context.Context, Category) (*Category, error)
+	UpdateCategory(context.Context, string, Category) (*Category, error)
+	DeleteCategory(context.Context, string) error
+	FindCategoryBySlug(context.Context, string) (*Category, error)
+	FindAllCategories(context.Context) ([]*Category, error)
+	CategoryTree(context.Context) ([]*Category, error)
+}
+
+type CategoryService struct {
+	repo  CategoryRepository
+	cache Invalidator
+}
+
+// NewCategoryService creates the service. Renaming or deleting a category rewrites the category of
+// its files, the cache of the records is told about it. It's nil when files aren't cached
+func NewCategoryService(repo CategoryRepository, cache Invalidator) *CategoryService {
+	return &CategoryService{repo: repo, cache: cache}
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a category name into its URL friendly identifier ("Funny Stuff!" -> "funny-stuff").
+// The migration normalizing free-text categories uses the same rules in SQL
+func Slugify(name string) string {
+	slug := nonSlugCharacters.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+	return strings.Trim(slug, "-")
+}
+
+func (s *CategoryService) CreateCategory(ctx context.Context, category Category) (*Category, error) {
+	if err := prepareCategory(&category); err != nil {
+		return nil, err
+	}
+
+	if category.ParentID != nil {
+		categories, err := s.repo.FindAllCategories(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if findCategory(categories, *category.ParentID) == nil {
+			return nil, ErrParentCategoryNotFound
+		}
+	}
+
+	return s.repo.CreateCategory(ctx, category)
+}
+
+// UpdateCategory replaces the category identified by slug with the given values
+func (s *CategoryService) UpdateCategory(ctx context.Context, slug string, category Category) (*Category, error) {
+	existing, err := s.repo.FindCategoryBySlug(ctx, Slugify(slug))
+	if err != nil {
+		return nil, err
+	}
+	category.ID = existing.ID
+
+	if err := prepareCategory(&category); err != nil {
+		return nil, err
+	}
+
+	categories, err := s.repo.FindAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParent(categories, category); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.repo.UpdateCategory(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateFiles(ctx)
+	return saved, nil
+}
+
+func (s *CategoryService) DeleteCategory(ctx context.Context, slug string) error {
+	if err := s.repo.DeleteCategory(ctx, Slugify(slug)); err != nil {
+		return err
+	}
+	s.invalidateFiles(ctx)
+	return nil
+}
+
+// invalidateFiles drops every cached file, any number of them may have been in the category. The
+// category is written in a transaction, so nothing changed when the write failed
+func (s *CategoryService) invalidateFiles(ctx context.Context) {
+	if s.cache != nil {
+		s.cache.InvalidateAll(ctx)
+	}
+}
+
+func (s *CategoryService) FindCategoryBySlug(ctx context.Context, slug string) (*Category, error) {
+	return s.repo.FindCategoryBySlug(ctx, Slugify(slug))
+}
+
+func (s *CategoryService) FindAllCategories(ctx context.Context) ([]*Category, error) {
+	return s.repo.FindAllCategories(ctx)
+}
+
+// CategoryTree returns the root categories with their descendants nested under Children
+func (s *CategoryService) CategoryTree(ctx context.Context) ([]*Category, error) {
+	categories, err := s.repo.FindAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCategoryTree(categories), nil
+}
+
+// BuildCategoryTree nests the given flat list of categories by parent, ordering siblings by
+// sort order and then name. Categories whose parent isn't in the list are treated as roots
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[uint]*Category, len(categories))
+	for _, category := range categories {
+		node := *category
+		node.Children = nil
+		byID[category.ID] = &node
+	}
+
+	roots := []*Category{}
+	for _, category := range categories {
+		node := byID[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := byID[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	sortCategories(roots)
+	return roots
+}
+
+func sortCategories(categories []*Category) {
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].SortOrder != categories[j].SortOrder {
+			return categories[i].SortOrder < categories[j].SortOrder
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	for _, category := range categories {
+		sortCategories(category.Children)
+	}
+}
+
+func prepareCategory(category *Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return ErrCategoryNameMissing
+	}
+
+	if category.Slug == "" {
+		category.Slug = category.Name
+	}
+	category.Slug = Slugify(category.Slug)
+	if category.Slug == "" {
+		return ErrCategorySlugInvalid
+	}
+	category.Children = nil
+	return nil
+}
+
+// checkParent makes sure the category's new parent exists and isn't the category itself or one of its descendants
+func checkParent(categories []*Category, category Category) error {
+	parentID := category.ParentID
+	if parentID == nil {
+		return nil
+	}
+	if findCategory(categories, *parentID) == nil {
+		return ErrParentCategoryNotFound
+	}
+
+	// walking up is bounded by the number of categories in case the stored hierarchy is already broken
+	for i := 0; parentID != nil && i <= len(categories); i++ {
+		if *parentID == category.ID {
+			return ErrCategoryCycle
+		}
+		parent := findCategory(categories, *parentID)
+		if parent == nil {
+			return nil
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+func findCategory(categories []*Category, id uint) *Category {
+	for _, category := range categories {
+		if category.ID == id {
+			return category
+		}
+	}
+	return nil
+}
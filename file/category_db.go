@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+)
+
+const categoryColumns = "id, name, slug, description, parent_id, sort_order"
+
+func scanCategory(row rowScanner) (*Category, error) {
+	var category Category
+	var parentID sql.NullInt64
+	err := row.Scan(
+		&category.ID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&parentID,
+		&category.SortOrder,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := uint(parentID.Int64)
+		category.ParentID = &id
+	}
+	return &category, nil
+}
+
+// categoryError converts the driver's unique violation into a DuplicateKeyError
+func categoryError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return DuplicateKeyError("a category with this slug already exists")
+	}
+	return NewDBError(err)
+}
+
+func (p *PostgresStore) CreateCategory(ctx context.Context, category Category) (*Category, error) {
+	log.DebugContext(ctx, "Inserting a category into the DB", "category", category)
+	insertStmt := `INSERT INTO categories (name, slug, description, parent_id, sort_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + categoryColumns
+
+	saved, err := scanCategory(p.db.QueryRowContext(ctx, insertStmt,
+		category.Name,
+		category.Slug,
+		category.Description,
+		category.ParentID,
+		category.SortOrder,
+	))
+	if err != nil {
+		log.ErrorContext(ctx, "An error occurred while inserting category", "err", err)
+		return nil, categoryError(err)
+	}
+	return saved, nil
+}
+
+// UpdateCategory overwrites the category with the same ID. The file_info rows using it are
+// renamed along with it so that the denormalized category column stays in sync
+func (p *PostgresStore) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
+	log.DebugContext(ctx, "Updating a category in the DB", "category", category)
+	updateStmt := `UPDATE categories
+		SET name = $2, slug = $3, description = $4, parent_id = $5, sort_order = $6
+		WHERE id = $1
+		RETURNING ` + categoryColumns
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	saved, err := scanCategory(tx.QueryRowContext(ctx, updateStmt,
+		category.ID,
+		category.Name,
+		category.Slug,
+		category.Description,
+		category.ParentID,
+		category.SortOrder,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoRowsFoundError("")
+		}
+		log.ErrorContext(ctx, "An error occurred while updating category", "err", err, "id", category.ID)
+		return nil, categoryError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE file_info SET category = $2 WHERE category_id = $1", saved.ID, saved.Name)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, NewDBError(err)
+	}
+	return saved, nil
+}
+
+// DeleteCategory removes the category. Its children become root categories and its files become uncategorized
+func (p *PostgresStore) DeleteCategory(ctx context.Context, slug string) error {
+	log.DebugContext(ctx, "Deleting category from the DB", "slug", slug)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	// the foreign key nulls category_id, the free-text column has to follow
+	_, err = tx.ExecContext(ctx, "UPDATE file_info SET category = NULL WHERE category_id = (SELECT id FROM categories WHERE slug = $1)", slug)
+	if err != nil {
+		return NewDBError(err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE slug = $1", slug)
+	if err != nil {
+		log.ErrorContext(ctx, "An error occurred while deleting category", "err", err, "slug", slug)
+		return NewDBError(err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return NoRowsFoundError("")
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) FindCategoryBySlug(ctx context.Context, slug string) (*Category, error) {
+	selectStmt := "SELECT " + categoryColumns + " FROM categories WHERE slug = $1"
+	category, err := scanCategory(p.db.QueryRowContext(ctx, selectStmt, slug))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoRowsFoundError("")
+		}
+		return nil, NewDBError(err)
+	}
+	return category, nil
+}
+
+func (p *PostgresStore) FindAllCategories(ctx context.Context) ([]*Category, error) {
+	categories := []*Category{}
+	selectStmt := "SELECT " + categoryColumns + " FROM categories ORDER BY sort_order, name"
+
+	rows, err := p.db.QueryContext(ctx, selectStmt)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, NewDBError(err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, NewDBError(err)
+	}
+	return categories, nil
+}
@@ -0,0 +1,207 @@
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCategoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockCategoryRepository) CreateCategory(ctx context.Context, category Category) (*Category, error) {
+	args := m.Called(ctx, category)
+	return args.Get(0).(*Category), args.Error(1)
+}
+
+func (m *MockCategoryRepository) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
+	args := m.Called(ctx, category)
+	return args.Get(0).(*Category), args.Error(1)
+}
+
+func (m *MockCategoryRepository) DeleteCategory(ctx context.Context, slug string) error {
+	args := m.Called(ctx, slug)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepository) FindCategoryBySlug(ctx context.Context, slug string) (*Category, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(*Category), args.Error(1)
+}
+
+func (m *MockCategoryRepository) FindAllCategories(ctx context.Context) ([]*Category, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*Category), args.Error(1)
+}
+
+func uintPtr(id uint) *uint {
+	return &id
+}
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expectedSlug string
+	}{
+		{name: "Lowercased", input: "Funny", expectedSlug: "funny"},
+		{name: "SpacesTrimmed", input: "  funny  ", expectedSlug: "funny"},
+		{name: "PunctuationCollapsed", input: "Funny Stuff!!", expectedSlug: "funny-stuff"},
+		{name: "NothingLeft", input: "!!!", expectedSlug: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedSlug, Slugify(tc.input))
+		})
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	categories := []*Category{
+		{ID: 1, Name: "Moods", SortOrder: 2},
+		{ID: 2, Name: "Speakers", SortOrder: 1},
+		{ID: 3, Name: "Happy", ParentID: uintPtr(1), SortOrder: 0},
+		{ID: 4, Name: "Angry", ParentID: uintPtr(1), SortOrder: 0},
+		{ID: 5, Name: "Orphan", ParentID: uintPtr(99)},
+	}
+
+	tree := BuildCategoryTree(categories)
+
+	assert.Len(t, tree, 3)
+	assert.Equal(t, "Orphan", tree[0].Name)
+	assert.Equal(t, "Speakers", tree[1].Name)
+	assert.Equal(t, "Moods", tree[2].Name)
+	assert.Len(t, tree[2].Children, 2)
+	assert.Equal(t, "Angry", tree[2].Children[0].Name)
+	assert.Equal(t, "Happy", tree[2].Children[1].Name)
+	// the flat list given isn't modified
+	assert.Nil(t, categories[0].Children)
+}
+
+func TestCategoryService_UpdateCategory(t *testing.T) {
+	existing := []*Category{
+		{ID: 1, Name: "Moods", Slug: "moods"},
+		{ID: 2, Name: "Happy", Slug: "happy", ParentID: uintPtr(1)},
+		{ID: 3, Name: "Giddy", Slug: "giddy", ParentID: uintPtr(2)},
+	}
+
+	testCases := []struct {
+		name          string
+		slug          string
+		input         Category
+		expectedSaved *Category
+		expectedError error
+	}{
+		{
+			name:          "Renamed",
+			slug:          "happy",
+			input:         Category{Name: "Very Happy", ParentID: uintPtr(1)},
+			expectedSaved: &Category{ID: 2, Name: "Very Happy", Slug: "very-happy", ParentID: uintPtr(1)},
+		},
+		{
+			name:          "SlugSpelledDifferently",
+			slug:          "Happy",
+			input:         Category{Name: "Very Happy", ParentID: uintPtr(1)},
+			expectedSaved: &Category{ID: 2, Name: "Very Happy", Slug: "very-happy", ParentID: uintPtr(1)},
+		},
+		{
+			name:          "OwnParent",
+			slug:          "happy",
+			input:         Category{Name: "Happy", ParentID: uintPtr(2)},
+			expectedError: ErrCategoryCycle,
+		},
+		{
+			name:          "DescendantAsParent",
+			slug:          "moods",
+			input:         Category{Name: "Moods", ParentID: uintPtr(3)},
+			expectedError: ErrCategoryCycle,
+		},
+		{
+			name:          "MissingParent",
+			slug:          "happy",
+			input:         Category{Name: "Happy", ParentID: uintPtr(42)},
+			expectedError: ErrParentCategoryNotFound,
+		},
+		{
+			name:          "MissingName",
+			slug:          "happy",
+			input:         Category{Name: "  "},
+			expectedError: ErrCategoryNameMissing,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockCategoryRepository)
+			service := NewCategoryService(repo, nil)
+			repo.On("FindCategoryBySlug", mock.Anything, Slugify(tc.slug)).Return(findBySlug(existing, Slugify(tc.slug)), nil)
+			repo.On("FindAllCategories", mock.Anything).Return(existing, nil)
+			if tc.expectedSaved != nil {
+				repo.On("UpdateCategory", mock.Anything, *tc.expectedSaved).Return(tc.expectedSaved, nil)
+			}
+
+			saved, err := service.UpdateCategory(ctx, tc.slug, tc.input)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedSaved, saved)
+			}
+		})
+	}
+}
+
+// countingInvalidator counts how often the whole cache was dropped
+type countingInvalidator struct {
+	all int
+}
+
+func (c *countingInvalidator) Invalidate(ctx context.Context, id string) {}
+
+func (c *countingInvalidator) InvalidateAll(ctx context.Context) {
+	c.all++
+}
+
+func TestCategoryService_DeleteCategory(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		slug                  string
+		err                   error
+		expectedInvalidations int
+	}{
+		{name: "Deleted", slug: "funny", expectedInvalidations: 1},
+		{name: "SlugSpelledDifferently", slug: "Funny", expectedInvalidations: 1},
+		{name: "NotFound", slug: "funny", err: NoRowsFoundError(""), expectedInvalidations: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockCategoryRepository)
+			cache := &countingInvalidator{}
+			service := NewCategoryService(repo, cache)
+			repo.On("DeleteCategory", mock.Anything, "funny").Return(tc.err)
+
+			err := service.DeleteCategory(ctx, tc.slug)
+
+			assert.ErrorIs(t, err, tc.err)
+			repo.AssertExpectations(t)
+			assert.Equal(t, tc.expectedInvalidations, cache.all)
+		})
+	}
+}
+
+func findBySlug(categories []*Category, slug string) *Category {
+	for _, category := range categories {
+		if category.Slug == slug {
+			return category
+		}
+	}
+	return nil
+}
@@ -4,21 +4,51 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 
 	log "log/slog"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
 	"github.com/phllpmcphrsn/voice-quips/config"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
 type FileInformationRepository interface {
 	FindById(context.Context, string) (*FileRecord, error)
-	FindAll(context.Context) ([]*FileRecord, error)
+	FindByChecksum(context.Context, string) (*FileRecord, error)
+	FindAll(context.Context, Filter) ([]*FileRecord, error)
 	Create(context.Context, FileRecord) (*FileRecord, error)
+	UpdateMetadata(context.Context, string, Metadata, Status) error
+	UpdateStatus(context.Context, string, Status) error
 	Delete(context.Context, string) error
+	AddTags(context.Context, string, []string) error
+	RemoveTags(context.Context, string, []string) error
+	FindAllTags(context.Context) ([]*Tag, error)
 }
 
+// fileInfoColumns lists the file_info columns in the order scanFileRecord expects them.
+// Nullable text columns are coalesced so they can be scanned straight into strings
+const fileInfoColumns = `file_info.id,
+		COALESCE(file_info.filename, ''),
+		COALESCE(file_info.file_type, ''),
+		COALESCE(file_info.s3_link, ''),
+		COALESCE(file_info.category, ''),
+		file_info.category_id,
+		COALESCE(file_info.title, ''),
+		COALESCE(file_info.artist, ''),
+		COALESCE(file_info.album, ''),
+		COALESCE(file_info.year, 0),
+		file_info.upload_date,
+		file_info.status,
+		COALESCE(file_info.checksum, ''),
+		file_info.rating_average,
+		file_info.rating_count,
+		file_info.size,
+		file_info.duration_ms,
+		file_info.cover_type`
+
 type PostgresStore struct {
 	// will handle Postgres DB instance
 	db *sql.DB
@@ -34,7 +64,11 @@ func NewPostgresStore(config config.FileInformationStoreConfig) (*PostgresStore,
 	}
 
 	connStr := fmt.Sprintf("host=%s dbname=%s user=%s password=%s port=%d sslmode=%s", config.Host, config.Name, config.Credentials.User, string(config.Credentials.Password), config.Port, ssl)
-	db, err := sql.Open("postgres", connStr)
+	// every query, from any store sharing the pool, is a span
+	db, err := otelsql.Open("postgres", connStr,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBName(config.Name)),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -47,24 +81,46 @@ func NewPostgresStore(config config.FileInformationStoreConfig) (*PostgresStore,
 	return &PostgresStore{db: db}, nil
 }
 
+// DB returns the connection pool so that other stores can share it
+func (p *PostgresStore) DB() *sql.DB {
+	return p.db
+}
+
+// Close closes the connection pool, shared with the stores given DB
+func (p *PostgresStore) Close() error {
+	return p.db.Close()
+}
+
+// Ping checks that the database can be reached
+func (p *PostgresStore) Ping(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return NewDBError(err)
+	}
+	return nil
+}
+
 func (p *PostgresStore) CreateTable() error {
-	stmt := `CREATE TABLE IF NOT EXISTS file_info (
-		id serial primary key,
-		filename varchar(50),
-		file_type varchar(6),
-		s3_link varchar(200),
-		category varchar(50),
-		title varchar(100),
-		artist varchar(75),
-		album varchar(100),
-		year smallint,
-		upload_date timestamp
-	)`
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS file_info (
+			id serial primary key,
+			filename varchar(50),
+			file_type varchar(6),
+			s3_link varchar(200),
+			category varchar(50),
+			title varchar(100),
+			artist varchar(75),
+			album varchar(100),
+			year smallint,
+			upload_date timestamp
+		)`,
+	}
 
-	_, err := p.db.Exec(stmt)
-	if err != nil {
-		log.Error("An error occured while creating the file_info table", "err", err)
-		return err
+	for _, stmt := range stmts {
+		_, err := p.db.Exec(stmt)
+		if err != nil {
+			log.Error("An error occured while creating the file_info table", "err", err)
+			return err
+		}
 	}
 	return nil
 }
@@ -83,35 +139,154 @@ func (p *PostgresStore) CreateIndexOn(name string, columns []string) error {
 	return nil
 }
 
-func (p *PostgresStore) FindById(ctx context.Context, id string) (*FileRecord, error) {
-	log.Debug("Retrieving a file_info record from the DB", "id", id)
-	var fileInformation FileRecord
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	// Query for a single row
-	selectStmt := "SELECT * FROM file_info WHERE id = $1"
-	err := p.db.QueryRowContext(ctx, selectStmt, id).Scan(
+func scanFileRecord(row rowScanner) (*FileRecord, error) {
+	var fileInformation FileRecord
+	var categoryID sql.NullInt64
+	err := row.Scan(
 		&fileInformation.ID,
 		&fileInformation.Filename,
 		&fileInformation.FileType,
-		&fileInformation.FileType,
+		&fileInformation.S3Link,
 		&fileInformation.Category,
+		&categoryID,
+		&fileInformation.Title,
+		&fileInformation.Artist,
+		&fileInformation.Album,
+		&fileInformation.Year,
 		&fileInformation.UploadDate,
+		&fileInformation.Status,
+		&fileInformation.Checksum,
+		&fileInformation.Rating.Average,
+		&fileInformation.Rating.Count,
+		&fileInformation.Size,
+		&fileInformation.DurationMs,
+		&fileInformation.CoverType,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		id := uint(categoryID.Int64)
+		fileInformation.CategoryID = &id
+	}
+	fileInformation.Tags = []string{}
+	return &fileInformation, nil
+}
+
+func (p *PostgresStore) FindById(ctx context.Context, id string) (*FileRecord, error) {
+	log.DebugContext(ctx, "Retrieving a file_info record from the DB", "id", id)
+
+	// Query for a single row
+	selectStmt := "SELECT " + fileInfoColumns + " FROM file_info WHERE id = $1"
+	fileInformation, err := scanFileRecord(p.db.QueryRowContext(ctx, selectStmt, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, NoRowsFoundError("")
 		}
 		return nil, NewDBError(err)
 	}
-	return &fileInformation, nil
+
+	err = p.loadTags(ctx, []*FileRecord{fileInformation})
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	return fileInformation, nil
 }
 
-func (p *PostgresStore) FindAll(ctx context.Context) ([]*FileRecord, error) {
-	var fileInformations []*FileRecord
+// FindByChecksum returns the oldest record whose content has the given SHA-256 checksum
+func (p *PostgresStore) FindByChecksum(ctx context.Context, checksum string) (*FileRecord, error) {
+	log.DebugContext(ctx, "Retrieving a file_info record by checksum", "checksum", checksum)
 
-	// Query for all rows
-	selectStmt := "SELECT * FROM file_info"
-	rows, err := p.db.QueryContext(ctx, selectStmt)
+	selectStmt := "SELECT " + fileInfoColumns + " FROM file_info WHERE checksum = $1 ORDER BY id LIMIT 1"
+	fileInformation, err := scanFileRecord(p.db.QueryRowContext(ctx, selectStmt, checksum))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoRowsFoundError("")
+		}
+		return nil, NewDBError(err)
+	}
+
+	err = p.loadTags(ctx, []*FileRecord{fileInformation})
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	return fileInformation, nil
+}
+
+// fileInfoSearchVector is the text search document of a file_info row. It matches the expression
+// of file_info_search_index so that searches can use the index
+const fileInfoSearchVector = `to_tsvector('simple', COALESCE(title, '') || ' ' || COALESCE(filename, ''))`
+
+// buildFindAllQuery turns the filter into a SELECT statement and its positional arguments
+func buildFindAllQuery(filter Filter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags))
+		tagged := fmt.Sprintf(`SELECT file_tags.file_id FROM file_tags
+			JOIN tags ON tags.id = file_tags.tag_id
+			WHERE tags.name = ANY($%d)`, len(args))
+		if filter.MatchAllTags {
+			args = append(args, len(filter.Tags))
+			tagged += fmt.Sprintf(" GROUP BY file_tags.file_id HAVING COUNT(DISTINCT tags.name) = $%d", len(args))
+		}
+		conditions = append(conditions, "file_info.id IN ("+tagged+")")
+	}
+
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		conditions = append(conditions, fmt.Sprintf(`file_info.category_id IN (
+			WITH RECURSIVE subcategories AS (
+				SELECT id FROM categories WHERE slug = $%d
+				UNION ALL
+				SELECT categories.id FROM categories JOIN subcategories ON categories.parent_id = subcategories.id
+			)
+			SELECT id FROM subcategories)`, len(args)))
+	}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		args = append(args, search)
+		conditions = append(conditions, fmt.Sprintf(`(%[1]s @@ plainto_tsquery('simple', $%[2]d)
+			OR file_info.id IN (SELECT file_id FROM transcripts WHERE search @@ plainto_tsquery('simple', $%[2]d)))`,
+			fileInfoSearchVector, len(args)))
+	}
+
+	stmt := "SELECT " + fileInfoColumns + " FROM file_info"
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	switch filter.Sort {
+	case SortRating:
+		stmt += " ORDER BY file_info.rating_average DESC, file_info.rating_count DESC, file_info.id"
+	case SortNewest:
+		stmt += " ORDER BY file_info.upload_date DESC, file_info.id DESC"
+	default:
+		stmt += " ORDER BY file_info.id"
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		stmt += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	return stmt, args
+}
+
+func (p *PostgresStore) FindAll(ctx context.Context, filter Filter) ([]*FileRecord, error) {
+	fileInformations := []*FileRecord{}
+
+	// Query for all rows matching the filter
+	selectStmt, args := buildFindAllQuery(filter)
+	rows, err := p.db.QueryContext(ctx, selectStmt, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, NoRowsFoundError("")
@@ -120,16 +295,8 @@ func (p *PostgresStore) FindAll(ctx context.Context) ([]*FileRecord, error) {
 	}
 	defer rows.Close()
 
-	var fileInformation *FileRecord
 	for rows.Next() {
-		err = rows.Scan(
-			&fileInformation.ID,
-			&fileInformation.Filename,
-			&fileInformation.FileType,
-			&fileInformation.FileType,
-			&fileInformation.Category,
-			&fileInformation.UploadDate,
-		)
+		fileInformation, err := scanFileRecord(rows)
 		if err != nil {
 			return nil, NewDBError(err)
 		}
@@ -142,53 +309,291 @@ func (p *PostgresStore) FindAll(ctx context.Context) ([]*FileRecord, error) {
 	if err != nil {
 		return nil, NewDBError(err)
 	}
+
+	err = p.loadTags(ctx, fileInformations)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
 	return fileInformations, nil
 }
 
+// loadTags fills in the Tags of the given records with a single query
+func (p *PostgresStore) loadTags(ctx context.Context, records []*FileRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*FileRecord, len(records))
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+		ids = append(ids, int64(record.ID))
+	}
+
+	selectStmt := `SELECT file_tags.file_id, tags.name FROM file_tags
+		JOIN tags ON tags.id = file_tags.tag_id
+		WHERE file_tags.file_id = ANY($1)
+		ORDER BY tags.name`
+	rows, err := p.db.QueryContext(ctx, selectStmt, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID uint
+		var name string
+		if err := rows.Scan(&fileID, &name); err != nil {
+			return err
+		}
+		if record, ok := byID[fileID]; ok {
+			record.Tags = append(record.Tags, name)
+		}
+	}
+	return rows.Err()
+}
+
 func (p *PostgresStore) Create(ctx context.Context, fileInformation FileRecord) (*FileRecord, error) {
-	log.Debug("Inserting a file_info record into the DB", "record", fileInformation)
+	log.DebugContext(ctx, "Inserting a file_info record into the DB", "record", fileInformation)
 	insertStmt := `
 	INSERT INTO file_info (
 		filename,
 		file_type,
 		s3_link,
 		category,
-		upload_date
+		category_id,
+		title,
+		artist,
+		album,
+		year,
+		upload_date,
+		status,
+		checksum,
+		size,
+		duration_ms,
+		cover_type
 	)
-	VALUES ($1, $2, $3, $4, $5)
-	RETURNING *`
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15)
+	RETURNING id`
+
+	if fileInformation.Status == "" {
+		fileInformation.Status = StatusReady
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	// the free-text category given on upload must name an existing category; the stored
+	// value is the category's canonical name
+	fileInformation.CategoryID = nil
+	if fileInformation.Category != "" {
+		var categoryID uint
+		err = tx.QueryRowContext(ctx, "SELECT id, name FROM categories WHERE slug = $1", Slugify(fileInformation.Category)).
+			Scan(&categoryID, &fileInformation.Category)
+		if err == sql.ErrNoRows {
+			return nil, ErrUnknownCategory
+		}
+		if err != nil {
+			return nil, NewDBError(err)
+		}
+		fileInformation.CategoryID = &categoryID
+	}
 
-	var savedfileInformation *FileRecord
-	err := p.db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		insertStmt,
-		&fileInformation.Filename,
-		&fileInformation.FileType,
-		&fileInformation.S3Link,
-		&fileInformation.Category,
-		&fileInformation.UploadDate,
-	).Scan(&savedfileInformation)
+		fileInformation.Filename,
+		fileInformation.FileType,
+		fileInformation.S3Link,
+		fileInformation.Category,
+		fileInformation.CategoryID,
+		fileInformation.Title,
+		fileInformation.Artist,
+		fileInformation.Album,
+		fileInformation.Year,
+		fileInformation.UploadDate,
+		fileInformation.Status,
+		fileInformation.Checksum,
+		fileInformation.Size,
+		fileInformation.DurationMs,
+		fileInformation.CoverType,
+	).Scan(&fileInformation.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "An error occurred while inserting to db", "err", err)
+		return nil, NewDBError(err)
+	}
 
+	err = addTags(ctx, tx, fileInformation.ID, fileInformation.Tags)
 	if err != nil {
-		log.Error("An error occurred while inserting to db", "err", err)
-		return nil, err
+		log.ErrorContext(ctx, "An error occurred while tagging the inserted row", "err", err)
+		return nil, NewDBError(err)
 	}
 
-	log.Debug("Successfully inserted row", "record", savedfileInformation)
-	return savedfileInformation, nil
+	if err = tx.Commit(); err != nil {
+		return nil, NewDBError(err)
+	}
+
+	log.DebugContext(ctx, "Successfully inserted row", "record", fileInformation)
+	return &fileInformation, nil
 }
 
 func (p *PostgresStore) Delete(ctx context.Context, id string) error {
-	log.Debug("Deleting audio file record from the DB", "id", id)
+	log.DebugContext(ctx, "Deleting audio file record from the DB", "id", id)
 	deleteStmt := `DELETE FROM file_info WHERE id=$1`
 
-	_, err := p.db.ExecContext(ctx, deleteStmt, id)
+	result, err := p.db.ExecContext(ctx, deleteStmt, id)
 
 	if err != nil {
-		log.Error("An error occurred while deleting from db", "err", err, "id", id)
-		return err
+		log.ErrorContext(ctx, "An error occurred while deleting from db", "err", err, "id", id)
+		return NewDBError(err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return NoRowsFoundError("")
 	}
 
-	log.Debug("Successfully deleted row", "id", id)
+	log.DebugContext(ctx, "Successfully deleted row", "id", id)
 	return nil
 }
+
+// UpdateMetadata stores the metadata read from a file once it's been processed
+func (p *PostgresStore) UpdateMetadata(ctx context.Context, id string, metadata Metadata, status Status) error {
+	log.DebugContext(ctx, "Updating file_info metadata", "id", id, "metadata", metadata, "status", status)
+	updateStmt := `UPDATE file_info SET title = $2, artist = $3, album = $4, year = $5, status = $6, duration_ms = $7, cover_type = $8 WHERE id = $1`
+
+	return p.update(ctx, updateStmt, id, metadata.Title, metadata.Artist, metadata.Album, metadata.Year, status, metadata.DurationMs, metadata.CoverType)
+}
+
+func (p *PostgresStore) UpdateStatus(ctx context.Context, id string, status Status) error {
+	log.DebugContext(ctx, "Updating file_info status", "id", id, "status", status)
+	return p.update(ctx, `UPDATE file_info SET status = $2 WHERE id = $1`, id, status)
+}
+
+// update runs a statement changing the file_info row whose ID is the first argument
+func (p *PostgresStore) update(ctx context.Context, stmt string, id string, args ...any) error {
+	fileID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	result, err := p.db.ExecContext(ctx, stmt, append([]any{fileID}, args...)...)
+	if err != nil {
+		log.ErrorContext(ctx, "An error occurred while updating file_info", "err", err, "id", id)
+		return NewDBError(err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return NoRowsFoundError("")
+	}
+	return nil
+}
+
+// AddTags attaches the given tags to a file, creating any tag that doesn't exist yet
+func (p *PostgresStore) AddTags(ctx context.Context, id string, tags []string) error {
+	log.DebugContext(ctx, "Tagging file_info record", "id", id, "tags", tags)
+	fileID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM file_info WHERE id = $1)", fileID).Scan(&exists)
+	if err != nil {
+		return NewDBError(err)
+	}
+	if !exists {
+		return NoRowsFoundError("")
+	}
+
+	if err = addTags(ctx, tx, uint(fileID), tags); err != nil {
+		log.ErrorContext(ctx, "An error occurred while tagging file", "err", err, "id", id)
+		return NewDBError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return NewDBError(err)
+	}
+	return nil
+}
+
+func addTags(ctx context.Context, tx *sql.Tx, fileID uint, tags []string) error {
+	upsertTagStmt := `INSERT INTO tags (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`
+	linkStmt := `INSERT INTO file_tags (file_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	for _, tag := range tags {
+		var tagID uint
+		if err := tx.QueryRowContext(ctx, upsertTagStmt, tag).Scan(&tagID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, linkStmt, fileID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveTags detaches the given tags from a file. The tags themselves are kept so they can be reused
+func (p *PostgresStore) RemoveTags(ctx context.Context, id string, tags []string) error {
+	log.DebugContext(ctx, "Removing tags from file_info record", "id", id, "tags", tags)
+	deleteStmt := `DELETE FROM file_tags
+		WHERE file_id = $1 AND tag_id IN (SELECT id FROM tags WHERE name = ANY($2))`
+
+	fileID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	var exists bool
+	err = p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM file_info WHERE id = $1)", fileID).Scan(&exists)
+	if err != nil {
+		return NewDBError(err)
+	}
+	if !exists {
+		return NoRowsFoundError("")
+	}
+
+	_, err = p.db.ExecContext(ctx, deleteStmt, fileID, pq.Array(tags))
+	if err != nil {
+		log.ErrorContext(ctx, "An error occurred while removing tags", "err", err, "id", id)
+		return NewDBError(err)
+	}
+	return nil
+}
+
+// FindAllTags returns every tag along with the number of files using it, most used first
+func (p *PostgresStore) FindAllTags(ctx context.Context) ([]*Tag, error) {
+	tags := []*Tag{}
+	selectStmt := `SELECT tags.id, tags.name, COUNT(file_tags.file_id) AS usage
+		FROM tags
+		LEFT JOIN file_tags ON file_tags.tag_id = tags.id
+		GROUP BY tags.id, tags.name
+		ORDER BY usage DESC, tags.name`
+
+	rows, err := p.db.QueryContext(ctx, selectStmt)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Count); err != nil {
+			return nil, NewDBError(err)
+		}
+		tags = append(tags, &tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, NewDBError(err)
+	}
+	return tags, nil
+}
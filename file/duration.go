@@ -0,0 +1,235 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnknownDuration is returned for audio in a format Duration doesn't know
+var ErrUnknownDuration = errors.New("the duration of the audio can't be worked out from its headers")
+
+// Duration works out how long the audio plays from its headers and frames, for the formats
+// uploads come in: MP3, WAV, FLAC and Ogg (Vorbis or Opus)
+func Duration(audio io.ReadSeeker) (time.Duration, error) {
+	if _, err := audio.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// quips are short, reading them whole keeps the parsing simple
+	content, err := io.ReadAll(audio)
+	if err != nil {
+		return 0, err
+	}
+	content = skipID3v2(content)
+
+	switch {
+	case len(content) >= 12 && bytes.Equal(content[:4], []byte("RIFF")) && bytes.Equal(content[8:12], []byte("WAVE")):
+		return wavDuration(content[12:])
+	case bytes.HasPrefix(content, []byte("fLaC")):
+		return flacDuration(content[4:])
+	case bytes.HasPrefix(content, []byte("OggS")):
+		return oggDuration(content)
+	}
+	return mp3Duration(content)
+}
+
+// skipID3v2 returns the audio after the ID3v2 tag it starts with, if any
+func skipID3v2(content []byte) []byte {
+	if len(content) < 10 || !bytes.Equal(content[:3], []byte("ID3")) {
+		return content
+	}
+	// the tag size is a syncsafe integer (7 bits per byte) that leaves out the header and footer
+	size := 10 + int(content[6]&0x7f)<<21 | int(content[7]&0x7f)<<14 | int(content[8]&0x7f)<<7 | int(content[9]&0x7f)
+	if content[5]&0x10 != 0 {
+		size += 10
+	}
+	if size > len(content) {
+		return nil
+	}
+	return content[size:]
+}
+
+func samplesDuration(samples uint64, sampleRate uint64) time.Duration {
+	return time.Duration(samples * uint64(time.Second) / sampleRate)
+}
+
+// wavDuration divides the size of the data chunk by the byte rate of the fmt chunk
+func wavDuration(chunks []byte) (time.Duration, error) {
+	var byteRate, dataSize uint64
+	for len(chunks) >= 8 {
+		id, size := string(chunks[:4]), uint64(binary.LittleEndian.Uint32(chunks[4:8]))
+		chunks = chunks[8:]
+		switch {
+		case id == "fmt " && len(chunks) >= 12:
+			byteRate = uint64(binary.LittleEndian.Uint32(chunks[8:12]))
+		case id == "data":
+			dataSize = size
+		}
+		// chunks are padded to an even size
+		skip := size + size%2
+		if skip > uint64(len(chunks)) {
+			break
+		}
+		chunks = chunks[skip:]
+	}
+
+	if byteRate == 0 || dataSize == 0 {
+		return 0, ErrUnknownDuration
+	}
+	return time.Duration(dataSize * uint64(time.Second) / byteRate), nil
+}
+
+// flacDuration reads the sample rate and total samples of the STREAMINFO block, always the first
+func flacDuration(blocks []byte) (time.Duration, error) {
+	if len(blocks) < 4+18 || blocks[0]&0x7f != 0 {
+		return 0, ErrUnknownDuration
+	}
+	info := blocks[4:]
+	sampleRate := uint64(info[10])<<12 | uint64(info[11])<<4 | uint64(info[12])>>4
+	samples := uint64(info[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 || samples == 0 {
+		return 0, ErrUnknownDuration
+	}
+	return samplesDuration(samples, sampleRate), nil
+}
+
+// oggDuration divides the granule position of the last page by the sample rate of the
+// identification header in the first
+func oggDuration(pages []byte) (time.Duration, error) {
+	if len(pages) < 27 || len(pages) < 27+int(pages[26]) {
+		return 0, ErrUnknownDuration
+	}
+	packet := pages[27+int(pages[26]):]
+
+	var sampleRate, preSkip uint64
+	switch {
+	case len(packet) >= 16 && bytes.Equal(packet[:7], []byte("\x01vorbis")):
+		sampleRate = uint64(binary.LittleEndian.Uint32(packet[12:16]))
+	case len(packet) >= 12 && bytes.Equal(packet[:8], []byte("OpusHead")):
+		// Opus granule positions always count 48 kHz samples, starting with the ones to skip
+		sampleRate = 48000
+		preSkip = uint64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, ErrUnknownDuration
+	}
+
+	last := bytes.LastIndex(pages, []byte("OggS"))
+	if last < 0 || last+14 > len(pages) || sampleRate == 0 {
+		return 0, ErrUnknownDuration
+	}
+	granule := binary.LittleEndian.Uint64(pages[last+6 : last+14])
+	if granule <= preSkip {
+		return 0, ErrUnknownDuration
+	}
+	return samplesDuration(granule-preSkip, sampleRate), nil
+}
+
+// MPEG audio bitrates in kbit/s, by version and layer
+var (
+	mpeg1Bitrates = [3][16]uint64{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mpeg2Bitrates = [3][16]uint64{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mpegSampleRates = map[byte][3]uint64{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// mpegFrame is what the header of an MPEG audio frame tells
+type mpegFrame struct {
+	length     int
+	samples    uint64
+	sampleRate uint64
+}
+
+func parseMPEGFrame(header []byte) (mpegFrame, bool) {
+	if len(header) < 4 || header[0] != 0xff || header[1]&0xe0 != 0xe0 {
+		return mpegFrame{}, false
+	}
+	version := header[1] >> 3 & 0x03
+	layer := 4 - int(header[1]>>1&0x03) // 1, 2 or 3
+	bitrateIndex := header[2] >> 4
+	sampleRateIndex := header[2] >> 2 & 0x03
+	padding := int(header[2] >> 1 & 0x01)
+	rates, ok := mpegSampleRates[version]
+	if !ok || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	bitrates := mpeg1Bitrates
+	if version != 3 {
+		bitrates = mpeg2Bitrates
+	}
+	bitrate := bitrates[layer-1][bitrateIndex] * 1000
+	sampleRate := rates[sampleRateIndex]
+
+	frame := mpegFrame{sampleRate: sampleRate}
+	switch {
+	case layer == 1:
+		frame.samples = 384
+		frame.length = (int(12*bitrate/sampleRate) + padding) * 4
+	case layer == 3 && version != 3:
+		frame.samples = 576
+		frame.length = int(72*bitrate/sampleRate) + padding
+	default:
+		frame.samples = 1152
+		frame.length = int(144*bitrate/sampleRate) + padding
+	}
+	return frame, frame.length > 4
+}
+
+// mp3Duration adds up the samples of every frame, which holds for constant and variable bitrates alike
+func mp3Duration(content []byte) (time.Duration, error) {
+	start := 0
+	for ; start+4 <= len(content); start++ {
+		if _, ok := parseMPEGFrame(content[start:]); ok {
+			break
+		}
+	}
+
+	// samples are counted per sample rate so the duration is only rounded once
+	samples := map[uint64]uint64{}
+	for offset, first := start, true; offset+4 <= len(content); first = false {
+		frame, ok := parseMPEGFrame(content[offset:])
+		if !ok {
+			// trailing tags (ID3v1, APE...) end the frames
+			break
+		}
+		end := offset + frame.length
+		if end > len(content) {
+			end = len(content)
+		}
+		if !first || !isXingFrame(content[offset:end]) {
+			samples[frame.sampleRate] += frame.samples
+		}
+		offset += frame.length
+	}
+
+	var duration time.Duration
+	for sampleRate, count := range samples {
+		duration += samplesDuration(count, sampleRate)
+	}
+	if duration == 0 {
+		return 0, ErrUnknownDuration
+	}
+	return duration, nil
+}
+
+// isXingFrame tells the silent frame VBR encoders put first, holding the Xing or Info header
+func isXingFrame(frame []byte) bool {
+	// the header follows the side information, at most 36 bytes in
+	if len(frame) > 40 {
+		frame = frame[:40]
+	}
+	return bytes.Contains(frame, []byte("Xing")) || bytes.Contains(frame, []byte("Info"))
+}
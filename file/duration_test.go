@@ -0,0 +1,83 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mp3Frames is MPEG 1 layer III at 128 kbit/s and 44.1 kHz, 1152 samples a frame
+func mp3Frames(count int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	return bytes.Repeat(frame, count)
+}
+
+func wav(byteRate uint32, dataSize uint32) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+16+8+dataSize))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 1})           // PCM, mono
+	binary.Write(&buf, binary.LittleEndian, []uint32{8000, byteRate}) // sample rate, byte rate
+	binary.Write(&buf, binary.LittleEndian, []uint16{2, 16})          // block align, bits per sample
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, dataSize)
+	buf.Write(make([]byte, dataSize))
+	return buf.Bytes()
+}
+
+func flac(sampleRate uint32, samples uint32) []byte {
+	info := make([]byte, 34)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	info[12] = byte(sampleRate<<4) | 0x01 // mono, 16 bits per sample
+	info[13] = 0xf0
+	binary.BigEndian.PutUint32(info[14:18], samples)
+	return append([]byte("fLaC\x80\x00\x00\x22"), info...)
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OggS\x00\x00")
+	binary.Write(&buf, binary.LittleEndian, granule)
+	buf.Write(make([]byte, 12)) // serial, sequence and checksum
+	buf.Write([]byte{1, byte(len(packet))})
+	buf.Write(packet)
+	return buf.Bytes()
+}
+
+func TestDuration(t *testing.T) {
+	vorbis := append([]byte("\x01vorbis\x00\x00\x00\x00\x01"), binary.LittleEndian.AppendUint32(nil, 44100)...)
+	opus := []byte("OpusHead\x01\x01\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+
+	testCases := []struct {
+		name             string
+		audio            []byte
+		expectedDuration time.Duration
+		expectedErr      error
+	}{
+		{name: "MP3", audio: mp3Frames(10), expectedDuration: samplesDuration(11520, 44100)},
+		{name: "MP3WithID3", audio: append([]byte("ID3\x03\x00\x00\x00\x00\x00\x02\x00\x00"), mp3Frames(10)...), expectedDuration: samplesDuration(11520, 44100)},
+		{name: "MP3WithXingFrame", audio: append(append(mp3Frames(1)[:36], append([]byte("Xing"), make([]byte, 377)...)...), mp3Frames(10)...), expectedDuration: samplesDuration(11520, 44100)},
+		{name: "WAV", audio: wav(16000, 32000), expectedDuration: 2 * time.Second},
+		{name: "FLAC", audio: flac(44100, 88200), expectedDuration: 2 * time.Second},
+		{name: "OggVorbis", audio: append(oggPage(0, vorbis), oggPage(66150, []byte("audio"))...), expectedDuration: 1500 * time.Millisecond},
+		{name: "OggOpus", audio: append(oggPage(0, opus), oggPage(96312, []byte("audio"))...), expectedDuration: 2 * time.Second},
+		{name: "WAVWithoutData", audio: wav(16000, 0), expectedErr: ErrUnknownDuration},
+		{name: "Unknown", audio: []byte("not audio at all"), expectedErr: ErrUnknownDuration},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			duration, err := Duration(bytes.NewReader(tc.audio))
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedDuration, duration)
+		})
+	}
+}
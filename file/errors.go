@@ -1,6 +1,28 @@
 package file
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownCategory is returned when a file is saved under a category that hasn't been created
+var ErrUnknownCategory = errors.New("unknown category")
+
+// ErrNoCover is returned when the file's tags hold no cover art
+var ErrNoCover = errors.New("no cover art")
+
+// ErrInvalidID is returned when a file is looked up by an ID that isn't a number
+var ErrInvalidID = errors.New("id must be a number")
+
+// ErrMigrationsPending is returned when the database is behind the schema this build expects
+var ErrMigrationsPending = errors.New("database migrations pending")
+
+// Sentinels wrapped by the common DB errors below so callers can tell them apart with errors.Is
+var (
+	ErrNoRowsFound     = errors.New("no rows found")
+	ErrDuplicateKey    = errors.New("duplicate key")
+	ErrIndexNotCreated = errors.New("index not created")
+)
 
 type DBError struct {
 	Err error
@@ -11,20 +33,27 @@ func NewDBError(err error) *DBError {
 }
 
 func (de *DBError) Error() string {
-	return "an error occured while interacting with the database" + de.Err.Error()
+	if de.Err == nil {
+		return "an error occured while interacting with the database"
+	}
+	return "an error occured while interacting with the database: " + de.Err.Error()
+}
+
+func (de *DBError) Unwrap() error {
+	return de.Err
 }
 
 // Common DB errors
 func NoRowsFoundError(message string) *DBError {
-	return DBErrorMessage(message)
+	return wrapDBError(ErrNoRowsFound, message)
 }
 
 func DuplicateKeyError(message string) *DBError {
-	return DBErrorMessage(message)
+	return wrapDBError(ErrDuplicateKey, message)
 }
 
 func IndexNotCreatedError(message string) *DBError {
-	return DBErrorMessage(message)
+	return wrapDBError(ErrIndexNotCreated, message)
 }
 
 func DBErrorMessage(message string) *DBError {
@@ -33,3 +62,10 @@ func DBErrorMessage(message string) *DBError {
 	}
 	return &DBError{errors.New(message)}
 }
+
+func wrapDBError(sentinel error, message string) *DBError {
+	if message == "" {
+		return &DBError{sentinel}
+	}
+	return &DBError{fmt.Errorf("%w: %s", sentinel, message)}
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var ErrScoreOutOfRange = errors.New("score must be between 1 and 5")
+var ErrUserMissing = errors.New("favorites and ratings need an authenticated user")
+
+type FeedbackRepository interface {
+	AddFavorite(ctx context.Context, user string, fileID string) error
+	RemoveFavorite(ctx context.Context, user string, fileID string) error
+	FindFavorites(ctx context.Context, user string) ([]*FileRecord, error)
+	Rate(ctx context.Context, user string, fileID string, score int) (*Rating, error)
+	RemoveRating(ctx context.Context, user string, fileID string) (*Rating, error)
+}
+
+// FeedbackStorer defines the API for listeners favoriting and rating files
+type FeedbackStorer interface {
+	AddFavorite(ctx context.Context, user string, fileID string) error
+	RemoveFavorite(ctx context.Context, user string, fileID string) error
+	FindFavorites(ctx context.Context, user string) ([]*FileRecord, error)
+	Rate(ctx context.Context, user string, fileID string, score int) (*Rating, error)
+	RemoveRating(ctx context.Context, user string, fileID string) (*Rating, error)
+}
+
+type FeedbackService struct {
+	repo  FeedbackRepository
+	cache Invalidator
+}
+
+// NewFeedbackService creates the service. Ratings change the records they're aggregated into, the
+// cache of the records is told about them. It's nil when files aren't cached
+func NewFeedbackService(repo FeedbackRepository, cache Invalidator) *FeedbackService {
+	return &FeedbackService{repo: repo, cache: cache}
+}
+
+// AddFavorite marks the file as a favorite of the user. Favoriting it again is a no-op
+func (s *FeedbackService) AddFavorite(ctx context.Context, user string, fileID string) error {
+	if strings.TrimSpace(user) == "" {
+		return ErrUserMissing
+	}
+	return s.repo.AddFavorite(ctx, user, fileID)
+}
+
+func (s *FeedbackService) RemoveFavorite(ctx context.Context, user string, fileID string) error {
+	if strings.TrimSpace(user) == "" {
+		return ErrUserMissing
+	}
+	return s.repo.RemoveFavorite(ctx, user, fileID)
+}
+
+// FindFavorites returns the user's favorites, most recently favorited first
+func (s *FeedbackService) FindFavorites(ctx context.Context, user string) ([]*FileRecord, error) {
+	if strings.TrimSpace(user) == "" {
+		return nil, ErrUserMissing
+	}
+	return s.repo.FindFavorites(ctx, user)
+}
+
+// Rate sets the user's score for the file, replacing any earlier one, and returns the new aggregate
+func (s *FeedbackService) Rate(ctx context.Context, user string, fileID string, score int) (*Rating, error) {
+	if strings.TrimSpace(user) == "" {
+		return nil, ErrUserMissing
+	}
+	if score < 1 || score > 5 {
+		return nil, ErrScoreOutOfRange
+	}
+	defer s.invalidate(ctx, fileID)
+	return s.repo.Rate(ctx, user, fileID, score)
+}
+
+// RemoveRating withdraws the user's score for the file and returns the new aggregate
+func (s *FeedbackService) RemoveRating(ctx context.Context, user string, fileID string) (*Rating, error) {
+	if strings.TrimSpace(user) == "" {
+		return nil, ErrUserMissing
+	}
+	defer s.invalidate(ctx, fileID)
+	return s.repo.RemoveRating(ctx, user, fileID)
+}
+
+func (s *FeedbackService) invalidate(ctx context.Context, fileID string) {
+	if s.cache != nil {
+		s.cache.Invalidate(ctx, fileID)
+	}
+}
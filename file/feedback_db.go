@@ -0,0 +1,176 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+)
+
+// feedbackError converts the driver's foreign key violation into a NoRowsFoundError, the file
+// being favorited or rated doesn't exist
+func feedbackError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return NoRowsFoundError("")
+	}
+	return NewDBError(err)
+}
+
+func (p *PostgresStore) AddFavorite(ctx context.Context, user string, fileID string) error {
+	log.DebugContext(ctx, "Adding favorite", "user", user, "id", fileID)
+	id, err := strconv.ParseUint(fileID, 10, 0)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	insertStmt := `INSERT INTO favorites (user_name, file_id, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_name, file_id) DO NOTHING`
+	if _, err := p.db.ExecContext(ctx, insertStmt, user, id, time.Now().UTC()); err != nil {
+		log.ErrorContext(ctx, "An error occurred while adding favorite", "err", err, "id", fileID)
+		return feedbackError(err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) RemoveFavorite(ctx context.Context, user string, fileID string) error {
+	log.DebugContext(ctx, "Removing favorite", "user", user, "id", fileID)
+	id, err := strconv.ParseUint(fileID, 10, 0)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	result, err := p.db.ExecContext(ctx, "DELETE FROM favorites WHERE user_name = $1 AND file_id = $2", user, id)
+	if err != nil {
+		return NewDBError(err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return NoRowsFoundError("")
+	}
+	return nil
+}
+
+func (p *PostgresStore) FindFavorites(ctx context.Context, user string) ([]*FileRecord, error) {
+	selectStmt := `SELECT ` + fileInfoColumns + ` FROM file_info
+		JOIN favorites ON favorites.file_id = file_info.id
+		WHERE favorites.user_name = $1
+		ORDER BY favorites.created_at DESC, file_info.id`
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, user)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer rows.Close()
+
+	favorites := []*FileRecord{}
+	for rows.Next() {
+		fileInformation, err := scanFileRecord(rows)
+		if err != nil {
+			return nil, NewDBError(err)
+		}
+		favorites = append(favorites, fileInformation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewDBError(err)
+	}
+
+	if err := p.loadTags(ctx, favorites); err != nil {
+		return nil, NewDBError(err)
+	}
+	return favorites, nil
+}
+
+// Rate upserts the user's score and recomputes the file's aggregate in the same transaction
+func (p *PostgresStore) Rate(ctx context.Context, user string, fileID string, score int) (*Rating, error) {
+	log.DebugContext(ctx, "Rating file", "user", user, "id", fileID, "score", score)
+	id, err := strconv.ParseUint(fileID, 10, 0)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	upsertStmt := `INSERT INTO ratings (user_name, file_id, score, rated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_name, file_id) DO UPDATE SET score = EXCLUDED.score, rated_at = EXCLUDED.rated_at`
+	if _, err := tx.ExecContext(ctx, upsertStmt, user, id, score, time.Now().UTC()); err != nil {
+		log.ErrorContext(ctx, "An error occurred while rating file", "err", err, "id", fileID)
+		return nil, feedbackError(err)
+	}
+
+	rating, err := updateRating(ctx, tx, uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, NewDBError(err)
+	}
+	return rating, nil
+}
+
+func (p *PostgresStore) RemoveRating(ctx context.Context, user string, fileID string) (*Rating, error) {
+	log.DebugContext(ctx, "Removing rating", "user", user, "id", fileID)
+	id, err := strconv.ParseUint(fileID, 10, 0)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM ratings WHERE user_name = $1 AND file_id = $2", user, id)
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return nil, NoRowsFoundError("")
+	}
+
+	rating, err := updateRating(ctx, tx, uint(id))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, NewDBError(err)
+	}
+	return rating, nil
+}
+
+// updateRating recomputes the aggregate columns of file_info from the ratings table. The file_info
+// row is locked first so that concurrent ratings of the file take turns, the last one to get the
+// lock reading every rating committed before it
+func updateRating(ctx context.Context, tx *sql.Tx, fileID uint) (*Rating, error) {
+	var locked uint
+	err := tx.QueryRowContext(ctx, "SELECT id FROM file_info WHERE id = $1 FOR UPDATE", fileID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, NoRowsFoundError("")
+	}
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+
+	updateStmt := `UPDATE file_info SET
+			rating_average = COALESCE((SELECT AVG(score) FROM ratings WHERE file_id = $1), 0),
+			rating_count = (SELECT COUNT(*) FROM ratings WHERE file_id = $1)
+		WHERE id = $1
+		RETURNING rating_average, rating_count`
+
+	var rating Rating
+	err = tx.QueryRowContext(ctx, updateStmt, fileID).Scan(&rating.Average, &rating.Count)
+	if err == sql.ErrNoRows {
+		return nil, NoRowsFoundError("")
+	}
+	if err != nil {
+		return nil, NewDBError(err)
+	}
+	return &rating, nil
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFeedbackRepository struct {
+	mock.Mock
+}
+
+func (m *MockFeedbackRepository) AddFavorite(ctx context.Context, user string, fileID string) error {
+	args := m.Called(ctx, user, fileID)
+	return args.Error(0)
+}
+
+func (m *MockFeedbackRepository) RemoveFavorite(ctx context.Context, user string, fileID string) error {
+	args := m.Called(ctx, user, fileID)
+	return args.Error(0)
+}
+
+func (m *MockFeedbackRepository) FindFavorites(ctx context.Context, user string) ([]*FileRecord, error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).([]*FileRecord), args.Error(1)
+}
+
+func (m *MockFeedbackRepository) Rate(ctx context.Context, user string, fileID string, score int) (*Rating, error) {
+	args := m.Called(ctx, user, fileID, score)
+	return args.Get(0).(*Rating), args.Error(1)
+}
+
+func (m *MockFeedbackRepository) RemoveRating(ctx context.Context, user string, fileID string) (*Rating, error) {
+	args := m.Called(ctx, user, fileID)
+	return args.Get(0).(*Rating), args.Error(1)
+}
+
+func TestFeedbackService_Rate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		user           string
+		score          int
+		expectedRating *Rating
+		expectedError  error
+	}{
+		{
+			name:           "Rated",
+			user:           "alice",
+			score:          4,
+			expectedRating: &Rating{Average: 4.5, Count: 2},
+		},
+		{
+			name:          "ScoreTooLow",
+			user:          "alice",
+			score:         0,
+			expectedError: ErrScoreOutOfRange,
+		},
+		{
+			name:          "ScoreTooHigh",
+			user:          "alice",
+			score:         6,
+			expectedError: ErrScoreOutOfRange,
+		},
+		{
+			name:          "Anonymous",
+			user:          "",
+			score:         3,
+			expectedError: ErrUserMissing,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFeedbackRepository)
+			service := NewFeedbackService(repo, nil)
+			if tc.expectedRating != nil {
+				repo.On("Rate", mock.Anything, tc.user, "1", tc.score).Return(tc.expectedRating, nil)
+			}
+
+			rating, err := service.Rate(ctx, tc.user, "1", tc.score)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				repo.AssertNotCalled(t, "Rate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedRating, rating)
+				repo.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	testCases := []struct {
+		name          string
+		value         string
+		expectedSort  Sort
+		expectedError bool
+	}{
+		{name: "Default", value: "", expectedSort: SortDefault},
+		{name: "CaseInsensitive", value: "Rating", expectedSort: SortRating},
+		{name: "Newest", value: "newest", expectedSort: SortNewest},
+		{name: "Unknown", value: "loudest", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sort, err := ParseSort(tc.value)
+
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrUnknownSort)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedSort, sort)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package file
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	log "log/slog"
+)
+
+// migration is a versioned schema change applied on top of the tables made by CreateTable.
+// Migrations are applied in order, each within its own transaction, and are never edited once released
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// slugSQL mirrors Slugify so that existing free-text values end up with the same slugs
+// as categories created through the API
+func slugSQL(column string) string {
+	return fmt.Sprintf(`trim(both '-' from regexp_replace(lower(trim(%s)), '[^a-z0-9]+', '-', 'g'))`, column)
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "add hierarchical categories and normalize free-text file_info categories",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS categories (
+				id serial primary key,
+				name varchar(50) NOT NULL,
+				slug varchar(60) NOT NULL UNIQUE,
+				description text NOT NULL DEFAULT '',
+				parent_id integer REFERENCES categories(id) ON DELETE SET NULL,
+				sort_order integer NOT NULL DEFAULT 0
+			)`,
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS category_id integer REFERENCES categories(id) ON DELETE SET NULL`,
+			`CREATE INDEX IF NOT EXISTS file_info_category_id_index ON file_info(category_id)`,
+			// "Funny", " funny" and "FUNNY" all collapse into one category, named after the spelling
+			// that sorts first, trimmed and title-cased by initcap
+			`INSERT INTO categories (name, slug)
+				SELECT DISTINCT ON (slug) initcap(trim(category)), slug
+				FROM (SELECT category, ` + slugSQL("category") + ` AS slug FROM file_info WHERE category IS NOT NULL) AS existing
+				WHERE slug <> ''
+				ORDER BY slug, category
+			ON CONFLICT (slug) DO NOTHING`,
+			`UPDATE file_info SET category_id = categories.id, category = categories.name
+				FROM categories
+				WHERE categories.slug = ` + slugSQL("file_info.category"),
+		},
+	},
+	{
+		version:     2,
+		description: "add favorites and ratings with aggregate rating columns on file_info",
+		statements: []string{
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS rating_average numeric(3,2) NOT NULL DEFAULT 0`,
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0`,
+			`CREATE INDEX IF NOT EXISTS file_info_rating_index ON file_info(rating_average DESC, rating_count DESC)`,
+			`CREATE TABLE IF NOT EXISTS favorites (
+				user_name varchar(100) NOT NULL,
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				created_at timestamp NOT NULL,
+				PRIMARY KEY (user_name, file_id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS ratings (
+				user_name varchar(100) NOT NULL,
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				score smallint NOT NULL CHECK (score BETWEEN 1 AND 5),
+				rated_at timestamp NOT NULL,
+				PRIMARY KEY (user_name, file_id)
+			)`,
+		},
+	},
+	{
+		// transcripts live next to file_info because FindAll searches them
+		version:     3,
+		description: "add transcripts and full-text search indexes",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS transcripts (
+				file_id integer primary key REFERENCES file_info(id) ON DELETE CASCADE,
+				engine varchar(50) NOT NULL,
+				language varchar(20) NOT NULL DEFAULT '',
+				text text NOT NULL,
+				words jsonb NOT NULL DEFAULT '[]',
+				created_at timestamp NOT NULL,
+				search tsvector GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED
+			)`,
+			`CREATE INDEX IF NOT EXISTS transcripts_search_index ON transcripts USING GIN (search)`,
+			`CREATE INDEX IF NOT EXISTS file_info_search_index ON file_info USING GIN (` + fileInfoSearchVector + `)`,
+		},
+	},
+	{
+		version:     4,
+		description: "add processing status to file_info",
+		statements: []string{
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'ready'`,
+		},
+	},
+	{
+		version:     5,
+		description: "add content checksums to file_info",
+		statements: []string{
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS checksum char(64)`,
+			`CREATE INDEX IF NOT EXISTS file_info_checksum_index ON file_info(checksum)`,
+		},
+	},
+	{
+		version:     6,
+		description: "add size, duration and cover art type to file_info",
+		statements: []string{
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS size bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS duration_ms bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE file_info ADD COLUMN IF NOT EXISTS cover_type varchar(100) NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     7,
+		description: "add playlists and their items",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS playlists (
+				id serial primary key,
+				name varchar(100) NOT NULL,
+				owner varchar(100) NOT NULL,
+				public boolean NOT NULL DEFAULT false,
+				created_at timestamp NOT NULL,
+				updated_at timestamp NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS playlist_items (
+				playlist_id integer NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+				position integer NOT NULL,
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				hotkey varchar(16),
+				PRIMARY KEY (playlist_id, position)
+			)`,
+			`CREATE INDEX IF NOT EXISTS playlists_owner_index ON playlists(owner)`,
+		},
+	},
+	{
+		version:     8,
+		description: "add play events and their daily rollups",
+		statements: []string{
+			// append-only, rows are never updated
+			`CREATE TABLE IF NOT EXISTS play_events (
+				id bigserial primary key,
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				kind varchar(10) NOT NULL,
+				occurred_at timestamp NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS play_events_occurred_at_index ON play_events(occurred_at)`,
+			`CREATE TABLE IF NOT EXISTS daily_stats (
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				day date NOT NULL,
+				plays bigint NOT NULL DEFAULT 0,
+				downloads bigint NOT NULL DEFAULT 0,
+				PRIMARY KEY (file_id, day)
+			)`,
+			`CREATE INDEX IF NOT EXISTS daily_stats_day_index ON daily_stats(day)`,
+		},
+	},
+	{
+		version:     9,
+		description: "add the job queue",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS jobs (
+				id bigserial primary key,
+				kind varchar(50) NOT NULL,
+				file_id integer REFERENCES file_info(id) ON DELETE CASCADE,
+				payload jsonb NOT NULL DEFAULT '{}',
+				status varchar(20) NOT NULL,
+				attempts integer NOT NULL DEFAULT 0,
+				last_error text NOT NULL DEFAULT '',
+				run_at timestamp NOT NULL,
+				locked_until timestamp,
+				created_at timestamp NOT NULL,
+				updated_at timestamp NOT NULL
+			)`,
+			// only pending and running jobs are ever claimed, finished ones stay out of the index
+			`CREATE INDEX IF NOT EXISTS jobs_due_index ON jobs(run_at, id) WHERE status IN ('pending', 'running')`,
+		},
+	},
+	{
+		version:     10,
+		description: "add share links",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS share_links (
+				id serial primary key,
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				owner varchar(100) NOT NULL,
+				max_downloads integer NOT NULL DEFAULT 0,
+				downloads integer NOT NULL DEFAULT 0,
+				expires_at timestamp NOT NULL,
+				created_at timestamp NOT NULL,
+				revoked_at timestamp
+			)`,
+			`CREATE INDEX IF NOT EXISTS share_links_owner_index ON share_links(owner)`,
+		},
+	},
+	{
+		version:     11,
+		description: "add tags",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS tags (
+				id serial primary key,
+				name varchar(50) NOT NULL UNIQUE
+			)`,
+			`CREATE TABLE IF NOT EXISTS file_tags (
+				file_id integer NOT NULL REFERENCES file_info(id) ON DELETE CASCADE,
+				tag_id integer NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+				PRIMARY KEY (file_id, tag_id)
+			)`,
+			`CREATE INDEX IF NOT EXISTS file_tags_tag_id_index ON file_tags(tag_id)`,
+		},
+	},
+}
+
+// Migrate creates the schema_migrations table and applies every migration that hasn't run yet
+func (p *PostgresStore) Migrate(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer primary key,
+		description text NOT NULL,
+		applied_at timestamp NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		log.ErrorContext(ctx, "An error occured while creating the schema_migrations table", "err", err)
+		return NewDBError(err)
+	}
+
+	current, err := p.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		log.InfoContext(ctx, "Applying database migration", "version", m.version, "description", m.description)
+		if err := p.applyMigration(ctx, m); err != nil {
+			log.ErrorContext(ctx, "Database migration failed", "err", err, "version", m.version)
+			return NewDBError(err)
+		}
+	}
+	return nil
+}
+
+func (p *PostgresStore) applyMigration(ctx context.Context, m migration) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, description) VALUES ($1, $2)", m.version, m.description)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the version of the latest migration applied to the database
+func (p *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := p.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, NewDBError(err)
+	}
+	return int(version.Int64), nil
+}
+
+// CheckMigrations returns ErrMigrationsPending unless every migration has been applied
+func (p *PostgresStore) CheckMigrations(ctx context.Context) error {
+	version, err := p.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); version < latest {
+		return fmt.Errorf("%w: at version %d of %d", ErrMigrationsPending, version, latest)
+	}
+	return nil
+}
+
+// LatestSchemaVersion is the version the database will be at once every migration has been applied
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
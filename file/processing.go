@@ -0,0 +1,70 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+
+	log "log/slog"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// JobProcessUpload is the kind of job reading the metadata of an upload
+const JobProcessUpload = "process-upload"
+
+// UploadProcessor handles process-upload jobs. Once the metadata is stored the follow-up jobs
+// (transcription, waveforms...) are enqueued for the same object
+type UploadProcessor struct {
+	files     Processor
+	storage   s3.Streamer
+	bucket    string
+	queue     jobs.Queue
+	followUps []string
+}
+
+func NewUploadProcessor(files Processor, storage s3.Streamer, bucket string, queue jobs.Queue, followUps ...string) *UploadProcessor {
+	return &UploadProcessor{files: files, storage: storage, bucket: bucket, queue: queue, followUps: followUps}
+}
+
+func (u *UploadProcessor) Handle(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.ObjectPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	id := strconv.FormatUint(uint64(job.FileID), 10)
+
+	object, err := u.storage.StreamObject(ctx, payload.Object, u.bucket)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	// tags are read with seeks, which object bodies don't necessarily support
+	content, err := io.ReadAll(object.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := u.files.ProcessMetadata(ctx, id, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	for _, kind := range u.followUps {
+		followUp := jobs.Job{Kind: kind, FileID: job.FileID, Payload: job.Payload}
+		if _, err := u.queue.Enqueue(ctx, followUp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dead marks the upload as failed once its processing is dead-lettered
+func (u *UploadProcessor) Dead(ctx context.Context, job *jobs.Job) {
+	id := strconv.FormatUint(uint64(job.FileID), 10)
+	if err := u.files.MarkFailed(ctx, id); err != nil {
+		log.ErrorContext(ctx, "could not mark upload as failed", "err", err, "id", id)
+	}
+}
@@ -0,0 +1,118 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeStorage serves the given content for every object, or fails with err
+type fakeStorage struct {
+	content []byte
+	err     error
+}
+
+func (f *fakeStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	return nil
+}
+
+func (f *fakeStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.Object{Body: io.NopCloser(bytes.NewReader(f.content)), Size: int64(len(f.content))}, nil
+}
+
+func TestAudioFileService_ProcessMetadata(t *testing.T) {
+	testCases := []struct {
+		name             string
+		content          []byte
+		expectedMetadata Metadata
+	}{
+		{
+			name:             "Tagged",
+			content:          newTestAudioFile("Quip").content(),
+			expectedMetadata: Metadata{Title: "Quip"},
+		},
+		{
+			name:             "Untagged",
+			content:          append([]byte("RIFF....WAVE"), make([]byte, 256)...),
+			expectedMetadata: Metadata{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			service := NewFileInformationService(repo)
+			repo.On("UpdateMetadata", mock.Anything, "1", tc.expectedMetadata, StatusReady).Return(nil)
+
+			err := service.ProcessMetadata(ctx, "1", bytes.NewReader(tc.content))
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUploadProcessor(t *testing.T) {
+	testCases := []struct {
+		name              string
+		storage           *fakeStorage
+		expectedError     bool
+		expectedFollowUps int
+	}{
+		{name: "Processed", storage: &fakeStorage{content: newTestAudioFile("Quip").content()}, expectedFollowUps: 1},
+		{name: "StorageDown", storage: &fakeStorage{err: errors.New("connection refused")}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockFileInformationRepository)
+			repo.On("UpdateMetadata", mock.Anything, "3", Metadata{Title: "Quip"}, StatusReady).Return(nil)
+			queue := jobs.NewMemoryQueue()
+			processor := NewUploadProcessor(NewFileInformationService(repo), tc.storage, "quips", queue, "transcribe")
+
+			job, _ := jobs.NewJob(JobProcessUpload, 3, jobs.ObjectPayload{Object: "audio/abc.mp3", Filename: "quip.mp3"})
+			err := processor.Handle(ctx, &job)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				repo.AssertNotCalled(t, "UpdateMetadata")
+			} else {
+				assert.NoError(t, err)
+				repo.AssertExpectations(t)
+			}
+
+			followUp, err := queue.Claim(ctx, []string{"transcribe"}, time.Minute)
+			if tc.expectedFollowUps == 0 {
+				assert.ErrorIs(t, err, jobs.ErrNoJobs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(3), followUp.FileID)
+				assert.JSONEq(t, string(job.Payload), string(followUp.Payload))
+			}
+		})
+	}
+}
+
+func TestUploadProcessor_Dead(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockFileInformationRepository)
+	repo.On("UpdateStatus", mock.Anything, "3", StatusFailed).Return(nil)
+	processor := NewUploadProcessor(NewFileInformationService(repo), &fakeStorage{}, "quips", jobs.NewMemoryQueue())
+
+	processor.Dead(ctx, &jobs.Job{Kind: JobProcessUpload, FileID: 3})
+
+	repo.AssertExpectations(t)
+}
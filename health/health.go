@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each check unless told otherwise
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of a check and of the report
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Checker reports whether a dependency can be used, returning why not otherwise
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a plain func be used as a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check. It's down when any of them is
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Up reports whether every check passed
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Health runs the checks of the dependencies the service can't work without
+type Health struct {
+	timeout  time.Duration
+	checkers []namedChecker
+}
+
+// New returns a Health giving each check up to timeout to pass, DefaultTimeout when it's 0
+func New(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Health{timeout: timeout}
+}
+
+// Add registers a check under the name it's reported as
+func (h *Health) Add(name string, checker Checker) {
+	h.checkers = append(h.checkers, namedChecker{name, checker})
+}
+
+// Run runs every check at the same time and reports how each went
+func (h *Health) Run(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(h.checkers))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range h.checkers {
+		wg.Add(1)
+		go func(nc namedChecker) {
+			defer wg.Done()
+			result := h.run(ctx, nc.checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(nc)
+	}
+	wg.Wait()
+	return report
+}
+
+func (h *Health) run(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// don't wait on checks ignoring their context
+		err = ctx.Err()
+	}
+	result := Result{Status: StatusUp, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
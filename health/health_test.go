@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth_Run(t *testing.T) {
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	// hangs ignores its context, Run must not wait on it
+	hangs := CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	testCases := []struct {
+		name           string
+		checkers       map[string]Checker
+		expectedStatus string
+		expectedChecks map[string]string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "NoChecks",
+			checkers:       map[string]Checker{},
+			expectedStatus: StatusUp,
+			expectedChecks: map[string]string{},
+		},
+		{
+			name:           "AllUp",
+			checkers:       map[string]Checker{"postgres": up, "storage": up},
+			expectedStatus: StatusUp,
+			expectedChecks: map[string]string{"postgres": StatusUp, "storage": StatusUp},
+		},
+		{
+			name:           "OneDown",
+			checkers:       map[string]Checker{"postgres": up, "storage": down},
+			expectedStatus: StatusDown,
+			expectedChecks: map[string]string{"postgres": StatusUp, "storage": StatusDown},
+			expectedErrors: map[string]string{"storage": "connection refused"},
+		},
+		{
+			name:           "TimedOut",
+			checkers:       map[string]Checker{"postgres": hangs},
+			expectedStatus: StatusDown,
+			expectedChecks: map[string]string{"postgres": StatusDown},
+			expectedErrors: map[string]string{"postgres": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(10 * time.Millisecond)
+			for name, checker := range tc.checkers {
+				h.Add(name, checker)
+			}
+
+			report := h.Run(context.Background())
+
+			assert.Equal(t, tc.expectedStatus, report.Status)
+			assert.Equal(t, tc.expectedStatus == StatusUp, report.Up())
+			statuses := map[string]string{}
+			for name, result := range report.Checks {
+				statuses[name] = result.Status
+				assert.Equal(t, tc.expectedErrors[name], result.Error)
+				assert.Less(t, result.LatencyMs, 500.0)
+			}
+			assert.Equal(t, tc.expectedChecks, statuses)
+		})
+	}
+}
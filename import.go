@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/importer"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+const importUsage = `Usage: voice-quips import [flags] [directory]
+
+Uploads the audio in a directory (the configured audio directory by default) or listed in a
+manifest. Files whose content was uploaded before are skipped.
+
+Flags:
+`
+
+// runImport is the import subcommand, bulk importing an existing audio library
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", defaultConfigFilePath, configFilePathUsage)
+	manifest := flags.String("manifest", "", "CSV or JSON manifest listing the files to import with their titles, categories and tags")
+	concurrency := flags.Int("concurrency", importer.DefaultConcurrency, "Number of files uploaded at once")
+	checkpoint := flags.String("checkpoint", "import.checkpoint", "File remembering what was imported, so that an interrupted import can be resumed. Empty to disable")
+	dryRun := flags.Bool("dry-run", false, "Report what would be imported without uploading anything")
+	category := flags.String("category", "", "Category of the imported files, unless the manifest says otherwise")
+	tags := flags.String("tags", "", "Comma separated tags of the imported files, unless the manifest says otherwise")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	setLogger(config.GetLogLevel(cfg.Log.Level))
+
+	entries, err := importEntries(*manifest, flags.Arg(0), cfg.AudioDirectory)
+	if err != nil {
+		return err
+	}
+
+	store, err := initDB(cfg.Database.FileInfoConfig)
+	if err != nil {
+		return err
+	}
+	client, _, err := initS3Client(&cfg.Database.S3Config)
+	if err != nil {
+		return err
+	}
+
+	bulk := importer.New(importer.Config{
+		Bucket:   cfg.Database.S3Config.Bucket,
+		Category: *category,
+		Tags:     splitTags(*tags),
+	}, s3.NewMinioClient(client), file.NewFileInformationService(store))
+
+	// an interrupted import stops handing out files and still prints its summary
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	summary, err := bulk.Bulk(ctx, entries, importer.BulkOptions{
+		Concurrency: *concurrency,
+		DryRun:      *dryRun,
+		Checkpoint:  *checkpoint,
+		Progress:    os.Stderr,
+	})
+	summary.Write(os.Stdout)
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return errors.New("some files could not be imported, run the import again to retry them")
+	}
+	return nil
+}
+
+// importEntries lists the files of the manifest if there is one, otherwise the files in the directory
+func importEntries(manifest, directory, defaultDirectory string) ([]importer.Entry, error) {
+	if manifest != "" {
+		path, err := filepath.Abs(manifest)
+		if err != nil {
+			return nil, err
+		}
+		return importer.ReadManifest(path)
+	}
+
+	if directory == "" {
+		directory = defaultDirectory
+	}
+	// the checkpoint remembers absolute paths so that it works from any working directory
+	path, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+	return importer.Scan(path)
+}
+
+func splitTags(tags string) []string {
+	if strings.TrimSpace(tags) == "" {
+		return nil
+	}
+	return []string{tags}
+}
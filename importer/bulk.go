@@ -0,0 +1,407 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "log/slog"
+
+	"github.com/dhowden/tag"
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+// DefaultConcurrency is the number of files a bulk import uploads at once unless told otherwise
+const DefaultConcurrency = 4
+
+var ErrUnknownManifest = errors.New("manifests must be .csv or .json files")
+
+// Entry is a file to import, along with what's known about it beyond its tags
+type Entry struct {
+	Path     string   `json:"path"`
+	Title    string   `json:"title,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Outcome is what happened to an entry during a bulk import
+type Outcome string
+
+const (
+	OutcomeImported    Outcome = "imported"
+	OutcomeWouldImport Outcome = "would import"
+	OutcomeDuplicate   Outcome = "duplicate"
+	OutcomeDone        Outcome = "already done"
+	OutcomeUnsupported Outcome = "unsupported"
+	OutcomeFailed      Outcome = "failed"
+)
+
+// Result is the outcome of importing one entry. Record is the saved record, the earlier import
+// for duplicates or the metadata that would be saved during a dry run
+type Result struct {
+	Entry   Entry
+	Outcome Outcome
+	Record  *file.FileRecord
+	Err     error
+}
+
+// Summary counts the outcomes of a bulk import
+type Summary struct {
+	Total    int
+	Imported int
+	Skipped  int
+	Failed   int
+	Failures []Result
+	DryRun   bool
+}
+
+// Write prints the summary followed by every failure
+func (s Summary) Write(w io.Writer) {
+	imported := "imported"
+	if s.DryRun {
+		imported = "would import"
+	}
+	fmt.Fprintf(w, "%d files: %d %s, %d skipped, %d failed\n", s.Total, s.Imported, imported, s.Skipped, s.Failed)
+	for _, failure := range s.Failures {
+		fmt.Fprintf(w, "  %s: %v\n", failure.Entry.Path, failure.Err)
+	}
+}
+
+func (s *Summary) add(result Result) {
+	switch result.Outcome {
+	case OutcomeImported, OutcomeWouldImport:
+		s.Imported++
+	case OutcomeFailed:
+		s.Failed++
+		s.Failures = append(s.Failures, result)
+	default:
+		s.Skipped++
+	}
+}
+
+// BulkOptions tunes a bulk import
+type BulkOptions struct {
+	// Concurrency is the number of files imported at once, DefaultConcurrency when not set
+	Concurrency int
+	// DryRun reports what would happen without uploading or saving anything
+	DryRun bool
+	// Checkpoint is a file remembering the entries already imported, so that an interrupted
+	// import picks up where it stopped. No checkpoint is kept when it's empty
+	Checkpoint string
+	// Progress receives a line for every entry once it's handled
+	Progress io.Writer
+}
+
+// Bulk imports the entries, a few at a time. Entries already in the checkpoint are skipped, as
+// are files whose content was imported before. Failures are reported in the summary and retried
+// on the next run
+func (i *Importer) Bulk(ctx context.Context, entries []Entry, opts BulkOptions) (Summary, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Progress == nil {
+		opts.Progress = io.Discard
+	}
+
+	checkpoint, err := openCheckpoint(opts.Checkpoint, opts.DryRun)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer checkpoint.Close()
+
+	summary := Summary{Total: len(entries), DryRun: opts.DryRun}
+	var mu sync.Mutex
+	handled := 0
+	report := func(result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled++
+		summary.add(result)
+		line := fmt.Sprintf("[%d/%d] %s %s", handled, len(entries), result.Outcome, result.Entry.Path)
+		if result.Err != nil {
+			line += ": " + result.Err.Error()
+		}
+		fmt.Fprintln(opts.Progress, line)
+	}
+
+	pending := make(chan Entry)
+	var wg sync.WaitGroup
+	for n := 0; n < opts.Concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range pending {
+				result := i.bulkImport(ctx, entry, opts.DryRun)
+				if result.Outcome == OutcomeImported || result.Outcome == OutcomeDuplicate {
+					if err := checkpoint.Done(entry.Path); err != nil {
+						log.Error("could not update the import checkpoint", "err", err, "file", entry.Path)
+					}
+				}
+				report(result)
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		if checkpoint.IsDone(entry.Path) {
+			report(Result{Entry: entry, Outcome: OutcomeDone})
+			continue
+		}
+		select {
+		case pending <- entry:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	return summary, ctx.Err()
+}
+
+func (i *Importer) bulkImport(ctx context.Context, entry Entry, dryRun bool) Result {
+	var record *file.FileRecord
+	var err error
+	if dryRun {
+		record, err = i.preview(ctx, entry)
+	} else {
+		record, err = i.ImportEntry(ctx, entry)
+	}
+
+	result := Result{Entry: entry, Record: record}
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		result.Outcome = OutcomeDuplicate
+	case errors.Is(err, ErrUnsupported):
+		result.Outcome = OutcomeUnsupported
+	case err != nil:
+		result.Outcome = OutcomeFailed
+		result.Err = err
+	case dryRun:
+		result.Outcome = OutcomeWouldImport
+	default:
+		result.Outcome = OutcomeImported
+	}
+	return result
+}
+
+// preview returns the record an import would save, reading the file's tags without uploading it
+func (i *Importer) preview(ctx context.Context, entry Entry) (*file.FileRecord, error) {
+	if _, err := contentTypeOf(entry.Path); err != nil {
+		return nil, err
+	}
+
+	audio, err := os.Open(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	checksum, existing, err := i.findImported(ctx, audio)
+	if err != nil {
+		return existing, err
+	}
+
+	record := i.record(entry)
+	record.Checksum = checksum
+	metadata, err := file.GetMetadata(audio)
+	if err != nil && !errors.Is(err, tag.ErrNoTagsFound) {
+		return nil, err
+	}
+	if record.Title == "" {
+		record.Title = metadata.Title
+	}
+	record.Artist, record.Album, record.Year = metadata.Artist, metadata.Album, metadata.Year
+	record.DurationMs, record.CoverType = metadata.DurationMs, metadata.CoverType
+	if info, err := audio.Stat(); err == nil {
+		record.Size = info.Size()
+	}
+	record.Tags = file.NormalizeTags(record.Tags)
+	return &record, nil
+}
+
+// Scan lists the files under the directory that could be audio, skipping hidden and partially
+// downloaded files
+func Scan(directory string) ([]Entry, error) {
+	entries := []Entry{}
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != directory && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isCandidate(path) {
+			entries = append(entries, Entry{Path: path})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// ReadManifest reads the entries listed in a CSV or JSON manifest. CSV manifests start with a
+// header naming the path, title, category and tags columns (only path is required). Tags are
+// separated by commas. JSON manifests are an array of entries. Relative paths are relative to
+// the manifest
+func ReadManifest(path string) ([]Entry, error) {
+	manifest, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer manifest.Close()
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readCSVManifest(manifest)
+	case ".json":
+		err = json.NewDecoder(manifest).Decode(&entries)
+	default:
+		err = ErrUnknownManifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+
+	base := filepath.Dir(path)
+	for n, entry := range entries {
+		if entry.Path == "" {
+			return nil, fmt.Errorf("reading manifest %s: entry %d has no path", path, n+1)
+		}
+		if !filepath.IsAbs(entry.Path) {
+			entries[n].Path = filepath.Join(base, entry.Path)
+		}
+	}
+	return entries, nil
+}
+
+func readCSVManifest(manifest io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(manifest)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for n, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "path", "file", "filename":
+			columns["path"] = n
+		case "title":
+			columns["title"] = n
+		case "category":
+			columns["category"] = n
+		case "tags":
+			columns["tags"] = n
+		}
+	}
+	if _, ok := columns["path"]; !ok {
+		return nil, errors.New("the header has no path column")
+	}
+
+	entries := []Entry{}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(column string) string {
+			n, ok := columns[column]
+			if !ok || n >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[n])
+		}
+
+		entry := Entry{Path: value("path"), Title: value("title"), Category: value("category")}
+		if tags := value("tags"); tags != "" {
+			entry.Tags = []string{tags}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// checkpoint remembers the paths already imported, one per line, so that reruns skip them
+type checkpoint struct {
+	mu   sync.Mutex
+	done map[string]bool
+	file *os.File
+}
+
+// openCheckpoint loads the paths done by earlier runs. Read only checkpoints are never written to
+func openCheckpoint(path string, readOnly bool) (*checkpoint, error) {
+	c := &checkpoint{done: map[string]bool{}}
+	if path == "" {
+		return c, nil
+	}
+
+	existing, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				c.done[line] = true
+			}
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading checkpoint %s: %w", path, err)
+		}
+	}
+
+	if !readOnly {
+		c.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *checkpoint) IsDone(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[path]
+}
+
+func (c *checkpoint) Done(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[path] = true
+	if c.file == nil {
+		return nil
+	}
+	_, err := c.file.WriteString(path + "\n")
+	return err
+}
+
+func (c *checkpoint) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReadManifest(t *testing.T) {
+	testCases := []struct {
+		name            string
+		filename        string
+		content         string
+		expectedEntries []Entry
+		expectedError   bool
+	}{
+		{
+			name:     "CSV",
+			filename: "manifest.csv",
+			content:  "Title,File,Category,Tags\nGood morning,morning.mp3,greetings,\"happy, short\"\nBye,/abs/bye.wav,,\n",
+			expectedEntries: []Entry{
+				{Path: "morning.mp3", Title: "Good morning", Category: "greetings", Tags: []string{"happy, short"}},
+				{Path: "/abs/bye.wav", Title: "Bye"},
+			},
+		},
+		{
+			name:     "JSON",
+			filename: "manifest.json",
+			content:  `[{"path": "morning.mp3", "title": "Good morning", "tags": ["happy"]}]`,
+			expectedEntries: []Entry{
+				{Path: "morning.mp3", Title: "Good morning", Tags: []string{"happy"}},
+			},
+		},
+		{name: "CSVWithoutPath", filename: "manifest.csv", content: "title\nGood morning\n", expectedError: true},
+		{name: "JSONWithoutPath", filename: "manifest.json", content: `[{"title": "Good morning"}]`, expectedError: true},
+		{name: "UnknownFormat", filename: "manifest.txt", content: "morning.mp3", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tc.filename)
+			writeFile(t, path, tc.content)
+
+			entries, err := ReadManifest(path)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for n := range tc.expectedEntries {
+				if !filepath.IsAbs(tc.expectedEntries[n].Path) {
+					tc.expectedEntries[n].Path = filepath.Join(dir, tc.expectedEntries[n].Path)
+				}
+			}
+			assert.Equal(t, tc.expectedEntries, entries)
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".trash"), 0o755))
+	writeFile(t, filepath.Join(dir, "a.mp3"), "a")
+	writeFile(t, filepath.Join(dir, "nested", "b.wav"), "b")
+	writeFile(t, filepath.Join(dir, ".trash", "c.mp3"), "c")
+	writeFile(t, filepath.Join(dir, "d.mp3.part"), "d")
+
+	entries, err := Scan(dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Path: filepath.Join(dir, "a.mp3")}, {Path: filepath.Join(dir, "nested", "b.wav")}}, entries)
+}
+
+func TestImporter_Bulk(t *testing.T) {
+	testCases := []struct {
+		name               string
+		dryRun             bool
+		expectedSummary    string
+		expectedUploads    int
+		expectedCheckpoint []string
+	}{
+		{
+			name:               "Import",
+			expectedSummary:    "5 files: 1 imported, 3 skipped, 1 failed\n",
+			expectedUploads:    1,
+			expectedCheckpoint: []string{"done.mp3", "duplicate.mp3", "new.mp3"},
+		},
+		{
+			name:               "DryRun",
+			dryRun:             true,
+			expectedSummary:    "5 files: 1 would import, 3 skipped, 1 failed\n",
+			expectedCheckpoint: []string{"done.mp3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			entries := []Entry{}
+			for _, name := range []string{"done.mp3", "duplicate.mp3", "new.mp3", "notes.txt", "missing.mp3"} {
+				path := filepath.Join(dir, name)
+				// untagged audio must be long enough for the tag reader to look for an ID3v1 tag
+				if name != "missing.mp3" {
+					writeFile(t, path, name+strings.Repeat(" ", 128))
+				}
+				entries = append(entries, Entry{Path: path, Title: strings.TrimSuffix(name, ".mp3")})
+			}
+			checkpointPath := filepath.Join(dir, "import.checkpoint")
+			writeFile(t, checkpointPath, filepath.Join(dir, "done.mp3")+"\n")
+
+			duplicate, _ := file.Checksum(strings.NewReader("duplicate.mp3" + strings.Repeat(" ", 128)))
+			store := new(MockStore)
+			store.On("FindByChecksum", ctx, duplicate).Return(&file.FileRecord{ID: 2}, nil)
+			store.On("FindByChecksum", ctx, mock.Anything).Return((*file.FileRecord)(nil), file.NoRowsFoundError(""))
+			store.On("Save", ctx, mock.Anything, mock.MatchedBy(func(record file.FileRecord) bool {
+				return record.Title == "new"
+			})).Return(&file.FileRecord{ID: 3}, nil)
+			storage := &fakeStorage{}
+			importer := New(Config{Bucket: "quips"}, storage, store)
+
+			progress := &bytes.Buffer{}
+			summary, err := importer.Bulk(ctx, entries, BulkOptions{Concurrency: 2, DryRun: tc.dryRun, Checkpoint: checkpointPath, Progress: progress})
+
+			assert.NoError(t, err)
+			written := &bytes.Buffer{}
+			summary.Write(written)
+			assert.True(t, strings.HasPrefix(written.String(), tc.expectedSummary), written.String())
+			assert.Equal(t, 5, strings.Count(progress.String(), "\n"))
+			assert.Contains(t, progress.String(), "[5/5]")
+			assert.Equal(t, tc.expectedUploads, storage.uploads())
+
+			checkpoint, _ := os.ReadFile(checkpointPath)
+			for _, name := range tc.expectedCheckpoint {
+				assert.Contains(t, string(checkpoint), filepath.Join(dir, name)+"\n")
+			}
+			assert.Equal(t, len(tc.expectedCheckpoint), strings.Count(string(checkpoint), "\n"))
+		})
+	}
+}
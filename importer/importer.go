@@ -0,0 +1,386 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "log/slog"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// DefaultDebounce is used by New when Config.Debounce isn't set
+const DefaultDebounce = 2 * time.Second
+
+var ErrDuplicate = errors.New("a file with the same content was already imported")
+var ErrUnsupported = errors.New("not a supported audio file")
+
+// Store saves imported files and finds earlier imports of the same content
+type Store interface {
+	file.Saver
+	file.ChecksumFinder
+}
+
+// Config tunes what the importer watches and what it does with imported files
+type Config struct {
+	// Directory is watched for new audio, including its subdirectories
+	Directory string
+	// Bucket the audio is uploaded to
+	Bucket string
+	// Debounce is how long a file must go unchanged before it's imported
+	Debounce time.Duration
+	// ProcessedDirectory is where imported and duplicate files are moved to, under a folder named
+	// after the day. Files are left in place when it's empty
+	ProcessedDirectory string
+	Category           string
+	Tags               []string
+}
+
+// Importer uploads the audio found in a local directory and keeps watching it for new files
+type Importer struct {
+	cfg     Config
+	storage s3.Streamer
+	store   Store
+	now     func() time.Time
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	ready  chan string
+	done   chan struct{}
+}
+
+func New(cfg Config, storage s3.Streamer, store Store) *Importer {
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = DefaultDebounce
+	}
+
+	return &Importer{
+		cfg:     cfg,
+		storage: storage,
+		store:   store,
+		now:     time.Now,
+		timers:  map[string]*time.Timer{},
+		ready:   make(chan string),
+		done:    make(chan struct{}),
+	}
+}
+
+// Run imports the files already in the directory, then the ones written to it, until the context is done.
+// Imports happen one at a time
+func (i *Importer) Run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	defer i.stop()
+
+	// watch before scanning so that files written during the scan aren't missed
+	if err := i.watchTree(watcher, i.cfg.Directory); err != nil {
+		return err
+	}
+	log.Info("watching directory for audio to import", "directory", i.cfg.Directory)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			i.handleEvent(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error("error while watching directory", "err", err, "directory", i.cfg.Directory)
+		case path := <-i.ready:
+			i.importWhenStable(ctx, path)
+		}
+	}
+}
+
+// watchTree watches the directory and its subdirectories and schedules the files already in them
+func (i *Importer) watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if i.isProcessed(path) {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return watcher.Add(path)
+		}
+		i.schedule(path)
+		return nil
+	})
+}
+
+func (i *Importer) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if i.isProcessed(event.Name) {
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+		// a directory moved in may already hold files
+		if info.IsDir() {
+			if err := i.watchTree(watcher, event.Name); err != nil {
+				log.Error("could not watch directory", "err", err, "directory", event.Name)
+			}
+			return
+		}
+		i.schedule(event.Name)
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		i.cancel(event.Name)
+	}
+}
+
+// schedule imports the file once it hasn't been written to for the debounce period. Every write
+// pushes the import back
+func (i *Importer) schedule(path string) {
+	if !isCandidate(path) {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if timer, ok := i.timers[path]; ok {
+		timer.Reset(i.cfg.Debounce)
+		return
+	}
+	i.timers[path] = time.AfterFunc(i.cfg.Debounce, func() {
+		select {
+		case i.ready <- path:
+		case <-i.done:
+		}
+	})
+}
+
+func (i *Importer) cancel(path string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if timer, ok := i.timers[path]; ok {
+		timer.Stop()
+		delete(i.timers, path)
+	}
+}
+
+func (i *Importer) stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	close(i.done)
+	for path, timer := range i.timers {
+		timer.Stop()
+		delete(i.timers, path)
+	}
+}
+
+// importWhenStable imports the file unless it was modified during the debounce period, in which
+// case it's still being written and the import is pushed back
+func (i *Importer) importWhenStable(ctx context.Context, path string) {
+	i.cancel(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if i.now().Sub(info.ModTime()) < i.cfg.Debounce {
+		i.schedule(path)
+		return
+	}
+
+	saved, err := i.Import(ctx, path)
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		log.Info("skipping file that was already imported", "file", path, "id", saved.ID)
+		i.moveProcessed(path)
+	case errors.Is(err, ErrUnsupported):
+		log.Debug("skipping file that isn't audio", "file", path)
+	case err != nil:
+		log.Error("could not import file", "err", err, "file", path)
+	default:
+		log.Info("imported file", "file", path, "id", saved.ID, "object", saved.S3Link)
+		i.moveProcessed(path)
+	}
+}
+
+// Import uploads the file and saves its information. When the same content was imported before,
+// the earlier record is returned along with ErrDuplicate
+func (i *Importer) Import(ctx context.Context, path string) (*file.FileRecord, error) {
+	return i.ImportEntry(ctx, Entry{Path: path})
+}
+
+// ImportEntry imports a file the way Import does. The entry's title, category and tags are used
+// instead of the configured ones when they're set
+func (i *Importer) ImportEntry(ctx context.Context, entry Entry) (*file.FileRecord, error) {
+	contentType, err := contentTypeOf(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	audio, err := os.Open(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	checksum, existing, err := i.findImported(ctx, audio)
+	if err != nil {
+		return existing, err
+	}
+
+	info, err := audio.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := audio.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	objectName := s3.NewObjectName(entry.Path)
+	if err := i.storage.UploadStream(ctx, objectName, i.cfg.Bucket, audio, info.Size(), contentType); err != nil {
+		return nil, err
+	}
+
+	record := i.record(entry)
+	record.S3Link = objectName
+	record.Checksum = checksum
+	record.Size = info.Size()
+	return i.store.Save(ctx, audio, record)
+}
+
+// record is what's known about an entry before its metadata is read
+func (i *Importer) record(entry Entry) file.FileRecord {
+	record := file.FileRecord{
+		Filename: filepath.Base(entry.Path),
+		FileType: strings.TrimPrefix(strings.ToLower(filepath.Ext(entry.Path)), "."),
+		Category: i.cfg.Category,
+		Tags:     append([]string{}, i.cfg.Tags...),
+	}
+	record.Title = entry.Title
+	if entry.Category != "" {
+		record.Category = entry.Category
+	}
+	if len(entry.Tags) > 0 {
+		record.Tags = append([]string{}, entry.Tags...)
+	}
+	return record
+}
+
+// findImported checksums the audio and looks for an earlier import of it, returning ErrDuplicate
+// and the earlier record when there is one
+func (i *Importer) findImported(ctx context.Context, audio io.Reader) (string, *file.FileRecord, error) {
+	checksum, err := file.Checksum(audio)
+	if err != nil {
+		return "", nil, err
+	}
+	existing, err := i.store.FindByChecksum(ctx, checksum)
+	if err == nil {
+		return checksum, existing, ErrDuplicate
+	}
+	if !errors.Is(err, file.ErrNoRowsFound) {
+		return checksum, nil, err
+	}
+	return checksum, nil, nil
+}
+
+// moveProcessed moves an imported file out of the watched directory, if a processed directory is set
+func (i *Importer) moveProcessed(path string) {
+	if i.cfg.ProcessedDirectory == "" {
+		return
+	}
+
+	relative, err := filepath.Rel(i.cfg.Directory, path)
+	if err != nil {
+		relative = filepath.Base(path)
+	}
+	destination := filepath.Join(i.cfg.ProcessedDirectory, i.now().Format("2006-01-02"), relative)
+	if _, err := os.Stat(destination); err == nil {
+		ext := filepath.Ext(destination)
+		destination = strings.TrimSuffix(destination, ext) + "-" + strconv.FormatInt(i.now().UnixNano(), 10) + ext
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+		log.Error("could not create processed directory", "err", err, "directory", filepath.Dir(destination))
+		return
+	}
+	if err := moveFile(path, destination); err != nil {
+		log.Error("could not move imported file", "err", err, "file", path, "destination", destination)
+	}
+}
+
+func (i *Importer) isProcessed(path string) bool {
+	if i.cfg.ProcessedDirectory == "" {
+		return false
+	}
+	relative, err := filepath.Rel(i.cfg.ProcessedDirectory, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// contentTypeOf returns the content type of supported audio files and ErrUnsupported for anything else
+func contentTypeOf(path string) (string, error) {
+	contentType := s3.GetContentType(strings.ToLower(filepath.Ext(path)))
+	if contentType == "application/octet-stream" {
+		return "", ErrUnsupported
+	}
+	return contentType, nil
+}
+
+// isCandidate skips hidden files and the temporary files browsers and copy tools write to
+func isCandidate(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".part", ".tmp", ".crdownload", ".download":
+		return false
+	}
+	return true
+}
+
+// moveFile renames the file, copying it when the destination is on another device
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) Save(ctx context.Context, audio multipart.File, record file.FileRecord) (*file.FileRecord, error) {
+	args := m.Called(ctx, audio, record)
+	return args.Get(0).(*file.FileRecord), args.Error(1)
+}
+
+func (m *MockStore) FindByChecksum(ctx context.Context, checksum string) (*file.FileRecord, error) {
+	args := m.Called(ctx, checksum)
+	return args.Get(0).(*file.FileRecord), args.Error(1)
+}
+
+// fakeStorage remembers the objects uploaded to it
+type fakeStorage struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.objects == nil {
+		f.objects = map[string]string{}
+	}
+	f.objects[objectName] = string(content)
+	return nil
+}
+
+func (f *fakeStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeStorage) uploads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestImporter_Import(t *testing.T) {
+	checksum, _ := file.Checksum(strings.NewReader("audio"))
+
+	testCases := []struct {
+		name            string
+		filename        string
+		existing        *file.FileRecord
+		expectedError   error
+		expectedUploads int
+	}{
+		{name: "Imported", filename: "hello.MP3", expectedUploads: 1},
+		{name: "Duplicate", filename: "hello.mp3", existing: &file.FileRecord{ID: 4}, expectedError: ErrDuplicate},
+		{name: "Unsupported", filename: "notes.txt", expectedError: ErrUnsupported},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			path := filepath.Join(dir, tc.filename)
+			writeFile(t, path, "audio")
+
+			store := new(MockStore)
+			storage := &fakeStorage{}
+			importer := New(Config{Directory: dir, Bucket: "quips", Tags: []string{"imported"}}, storage, store)
+
+			if tc.existing != nil {
+				store.On("FindByChecksum", ctx, checksum).Return(tc.existing, nil)
+			} else {
+				store.On("FindByChecksum", ctx, checksum).Return((*file.FileRecord)(nil), file.NoRowsFoundError(""))
+			}
+			store.On("Save", ctx, mock.Anything, mock.MatchedBy(func(record file.FileRecord) bool {
+				return record.Filename == tc.filename &&
+					record.FileType == "mp3" &&
+					strings.HasPrefix(record.S3Link, "audio/") &&
+					record.Checksum == checksum &&
+					assert.ObjectsAreEqual([]string{"imported"}, record.Tags)
+			})).Return(&file.FileRecord{ID: 9}, nil)
+
+			saved, err := importer.Import(ctx, path)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(9), saved.ID)
+			}
+			assert.Equal(t, tc.expectedUploads, storage.uploads())
+		})
+	}
+}
+
+func TestImporter_Run(t *testing.T) {
+	dir := t.TempDir()
+	processed := t.TempDir()
+	writeFile(t, filepath.Join(dir, "existing.wav"), "existing audio")
+
+	store := new(MockStore)
+	store.On("FindByChecksum", mock.Anything, mock.Anything).Return((*file.FileRecord)(nil), file.NoRowsFoundError(""))
+	store.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(&file.FileRecord{ID: 1}, nil)
+	storage := &fakeStorage{}
+
+	importer := New(Config{Directory: dir, Bucket: "quips", Debounce: 50 * time.Millisecond, ProcessedDirectory: processed}, storage, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error)
+	go func() {
+		finished <- importer.Run(ctx)
+	}()
+
+	// a file written in several steps is only imported once it stops changing
+	path := filepath.Join(dir, "new.mp3")
+	writeFile(t, path, "part one")
+	time.Sleep(20 * time.Millisecond)
+	writeFile(t, path, "part one, part two")
+	// partial downloads are never imported
+	writeFile(t, filepath.Join(dir, "partial.mp3.part"), "partial")
+
+	assert.Eventually(t, func() bool { return storage.uploads() == 2 }, 3*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-finished)
+
+	day := time.Now().Format("2006-01-02")
+	assert.FileExists(t, filepath.Join(processed, day, "new.mp3"))
+	assert.FileExists(t, filepath.Join(processed, day, "existing.wav"))
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, filepath.Join(dir, "partial.mp3.part"))
+	assert.Contains(t, storage.objectContents(), "part one, part two")
+}
+
+func (f *fakeStorage) objectContents() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	contents := []string{}
+	for _, content := range f.objects {
+		contents = append(contents, content)
+	}
+	return contents
+}
@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Status is where a job is in its lifecycle. A failed attempt that will be retried goes back to pending
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	// StatusDead marks a dead-lettered job, it ran out of attempts or failed permanently
+	StatusDead Status = "dead"
+)
+
+var ErrNotFound = errors.New("job not found")
+var ErrNoJobs = errors.New("no jobs are due")
+
+// ErrDatabase wraps the errors of the Postgres queue's driver, like file.DBError does for the file store
+var ErrDatabase = errors.New("an error occured while interacting with the database")
+
+// ErrLeaseLost is returned when a job's outcome is recorded after its lease ran out and it was
+// claimed again, or finished, elsewhere
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Job is a unit of background work. FileID is set when the job processes an uploaded file
+type Job struct {
+	ID        int64           `json:"id"`
+	Kind      string          `json:"kind"`
+	FileID    uint            `json:"fileId,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Status    Status          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	RunAt     time.Time       `json:"runAt"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// ObjectPayload points a job at an uploaded object
+type ObjectPayload struct {
+	Object   string `json:"object"`
+	Filename string `json:"filename"`
+}
+
+// NewJob creates a job of the given kind with the payload encoded as JSON
+func NewJob(kind string, fileID uint, payload any) (Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{Kind: kind, FileID: fileID, Payload: encoded}, nil
+}
+
+// Decode unmarshals the job's payload into v
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Queue stores jobs until a worker claims them. Implementations must let several workers, possibly
+// in different processes, claim concurrently without handing out the same job twice
+type Queue interface {
+	// Enqueue stores a pending job, due at RunAt or immediately when it's zero
+	Enqueue(context.Context, Job) (*Job, error)
+	// Claim marks the next due job of one of the kinds as running and counts the attempt. The job is
+	// handed out again if it's still running once the lease is over, ErrNoJobs is returned when none are due.
+	// The claimed job's Attempts is the lease token passed back to Complete, Retry and Bury
+	Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error)
+	// Complete, Retry and Bury return ErrLeaseLost unless the job is still running under the given attempt
+	Complete(ctx context.Context, id int64, attempt int) error
+	// Retry puts the job back to pending until runAt
+	Retry(ctx context.Context, id int64, attempt int, runAt time.Time, reason string) error
+	// Bury dead-letters the job, it won't be claimed again
+	Bury(ctx context.Context, id int64, attempt int, reason string) error
+	Find(ctx context.Context, id int64) (*Job, error)
+}
+
+// Count is the number of jobs of a kind in a status
+type Count struct {
+	Kind   string
+	Status Status
+	Jobs   int64
+}
+
+// Counter reports how many jobs are waiting, running or dead-lettered, by kind. Succeeded jobs aren't counted
+type Counter interface {
+	Counts(context.Context) ([]Count, error)
+}
+
+// Handler does the work of one kind of job. Returning an error retries the job unless it's wrapped by Permanent
+type Handler interface {
+	Handle(context.Context, *Job) error
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(context.Context, *Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// DeadLetterHandler is implemented by handlers that clean up after a job of theirs is dead-lettered
+type DeadLetterHandler interface {
+	Dead(context.Context, *Job)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent marks an error that retrying won't fix, the job is dead-lettered straight away
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked by Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
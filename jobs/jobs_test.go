@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deadRecorder is a handler that always fails and remembers the jobs it was told are dead
+type deadRecorder struct {
+	mu   sync.Mutex
+	err  error
+	dead []*Job
+}
+
+func (d *deadRecorder) Handle(ctx context.Context, job *Job) error {
+	return d.err
+}
+
+func (d *deadRecorder) Dead(ctx context.Context, job *Job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dead = append(d.dead, job)
+}
+
+func (d *deadRecorder) deadJobs() []*Job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dead
+}
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		name          string
+		attempt       int
+		expectedDelay time.Duration
+	}{
+		{name: "FirstAttempt", attempt: 1, expectedDelay: time.Second},
+		{name: "Doubles", attempt: 3, expectedDelay: 4 * time.Second},
+		{name: "Capped", attempt: 10, expectedDelay: 30 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedDelay, Backoff(tc.attempt, time.Second, 30*time.Second))
+		})
+	}
+}
+
+func TestMemoryQueue_Claim(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 9, 15, 12, 0, 0, 0, time.UTC)
+	queue := NewMemoryQueue()
+	queue.now = func() time.Time { return now }
+
+	later, _ := queue.Enqueue(ctx, Job{Kind: "a", RunAt: now.Add(time.Minute)})
+	first, _ := queue.Enqueue(ctx, Job{Kind: "a"})
+	queue.Enqueue(ctx, Job{Kind: "b"})
+
+	claimed, err := queue.Claim(ctx, []string{"a"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, claimed.ID)
+	assert.Equal(t, StatusRunning, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	// the other job of kind a isn't due yet and b isn't asked for
+	_, err = queue.Claim(ctx, []string{"a"}, time.Minute)
+	assert.ErrorIs(t, err, ErrNoJobs)
+
+	// once the lease of the first job is over it's handed out again, ahead of the later job
+	now = now.Add(2 * time.Minute)
+	claimed, err = queue.Claim(ctx, []string{"a"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, claimed.ID)
+	assert.Equal(t, 2, claimed.Attempts)
+
+	claimed, _ = queue.Claim(ctx, []string{"a"}, time.Minute)
+	assert.Equal(t, later.ID, claimed.ID)
+}
+
+func TestMemoryQueue_LeaseLost(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 9, 15, 12, 0, 0, 0, time.UTC)
+	queue := NewMemoryQueue()
+	queue.now = func() time.Time { return now }
+
+	job, _ := queue.Enqueue(ctx, Job{Kind: "a"})
+	stale, _ := queue.Claim(ctx, []string{"a"}, time.Minute)
+
+	// the first worker overruns its lease and a second one takes the job
+	now = now.Add(2 * time.Minute)
+	current, err := queue.Claim(ctx, []string{"a"}, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, current.ID)
+
+	assert.ErrorIs(t, queue.Complete(ctx, stale.ID, stale.Attempts), ErrLeaseLost)
+	assert.ErrorIs(t, queue.Retry(ctx, stale.ID, stale.Attempts, now, "failed"), ErrLeaseLost)
+	assert.ErrorIs(t, queue.Bury(ctx, stale.ID, stale.Attempts, "failed"), ErrLeaseLost)
+	found, _ := queue.Find(ctx, job.ID)
+	assert.Equal(t, StatusRunning, found.Status)
+
+	// the current worker still records its outcome, after which the stale lease stays lost
+	assert.NoError(t, queue.Complete(ctx, current.ID, current.Attempts))
+	assert.ErrorIs(t, queue.Complete(ctx, stale.ID, stale.Attempts), ErrLeaseLost)
+	found, _ = queue.Find(ctx, job.ID)
+	assert.Equal(t, StatusSucceeded, found.Status)
+}
+
+func TestMemoryQueue_Counts(t *testing.T) {
+	ctx := context.Background()
+	queue := NewMemoryQueue()
+	for _, kind := range []string{"b", "a", "a", "a"} {
+		queue.Enqueue(ctx, Job{Kind: kind})
+	}
+	claimed, _ := queue.Claim(ctx, []string{"a"}, time.Minute)
+	done, _ := queue.Claim(ctx, []string{"a"}, time.Minute)
+	queue.Complete(ctx, done.ID, done.Attempts)
+	queue.Bury(ctx, claimed.ID, claimed.Attempts, "broken")
+
+	counts, err := queue.Counts(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Count{
+		{Kind: "a", Status: StatusDead, Jobs: 1},
+		{Kind: "a", Status: StatusPending, Jobs: 1},
+		{Kind: "b", Status: StatusPending, Jobs: 1},
+	}, counts)
+}
+
+func TestPool(t *testing.T) {
+	testCases := []struct {
+		name             string
+		err              error
+		expectedStatus   Status
+		expectedAttempts int
+		expectedDead     bool
+	}{
+		{name: "Succeeds", expectedStatus: StatusSucceeded, expectedAttempts: 1},
+		{name: "RetriedThenDeadLettered", err: errors.New("storage unavailable"), expectedStatus: StatusDead, expectedAttempts: 3, expectedDead: true},
+		{name: "Permanent", err: Permanent(errors.New("not audio")), expectedStatus: StatusDead, expectedAttempts: 1, expectedDead: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			queue := NewMemoryQueue()
+			handler := &deadRecorder{err: tc.err}
+			pool := NewPool(queue, PoolConfig{
+				Concurrency:  1,
+				PollInterval: time.Millisecond,
+				MaxAttempts:  3,
+				BackoffBase:  time.Millisecond,
+				BackoffMax:   time.Millisecond,
+			})
+			pool.Handle("process", handler)
+
+			job, _ := queue.Enqueue(ctx, Job{Kind: "process", FileID: 1})
+			finished := make(chan struct{})
+			go func() {
+				pool.Run()
+				close(finished)
+			}()
+
+			assert.Eventually(t, func() bool {
+				found, _ := queue.Find(ctx, job.ID)
+				return found.Status == tc.expectedStatus
+			}, time.Second, time.Millisecond)
+			pool.Close()
+			<-finished
+
+			found, _ := queue.Find(ctx, job.ID)
+			assert.Equal(t, tc.expectedAttempts, found.Attempts)
+			assert.Equal(t, tc.expectedDead, len(handler.deadJobs()) == 1)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryQueue keeps jobs in memory. It's meant for tests and single process development; jobs are
+// lost when the process exits
+type MemoryQueue struct {
+	mu          sync.Mutex
+	jobs        map[int64]*Job
+	lockedUntil map[int64]time.Time
+	nextID      int64
+	now         func() time.Time
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		jobs:        map[int64]*Job{},
+		lockedUntil: map[int64]time.Time{},
+		now:         time.Now,
+	}
+}
+
+func (m *MemoryQueue) Enqueue(ctx context.Context, job Job) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UTC()
+	m.nextID++
+	job.ID = m.nextID
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+
+	m.jobs[job.ID] = &job
+	saved := job
+	return &saved, nil
+}
+
+func (m *MemoryQueue) Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UTC()
+	due := []*Job{}
+	for _, job := range m.jobs {
+		if !contains(kinds, job.Kind) {
+			continue
+		}
+		pending := job.Status == StatusPending && !job.RunAt.After(now)
+		expired := job.Status == StatusRunning && m.lockedUntil[job.ID].Before(now)
+		if pending || expired {
+			due = append(due, job)
+		}
+	}
+	if len(due) == 0 {
+		return nil, ErrNoJobs
+	}
+
+	// same order as the Postgres queue: oldest due first
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].RunAt.Before(due[j].RunAt)
+	})
+
+	job := due[0]
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = now
+	m.lockedUntil[job.ID] = now.Add(lease)
+
+	claimed := *job
+	return &claimed, nil
+}
+
+func (m *MemoryQueue) Complete(ctx context.Context, id int64, attempt int) error {
+	return m.update(id, attempt, func(job *Job) {
+		job.Status = StatusSucceeded
+		job.LastError = ""
+	})
+}
+
+func (m *MemoryQueue) Retry(ctx context.Context, id int64, attempt int, runAt time.Time, reason string) error {
+	return m.update(id, attempt, func(job *Job) {
+		job.Status = StatusPending
+		job.RunAt = runAt.UTC()
+		job.LastError = reason
+	})
+}
+
+func (m *MemoryQueue) Bury(ctx context.Context, id int64, attempt int, reason string) error {
+	return m.update(id, attempt, func(job *Job) {
+		job.Status = StatusDead
+		job.LastError = reason
+	})
+}
+
+func (m *MemoryQueue) Find(ctx context.Context, id int64) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+func (m *MemoryQueue) Counts(ctx context.Context) ([]Count, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byKindAndStatus := map[Count]int64{}
+	for _, job := range m.jobs {
+		if job.Status != StatusSucceeded {
+			byKindAndStatus[Count{Kind: job.Kind, Status: job.Status}]++
+		}
+	}
+
+	counts := make([]Count, 0, len(byKindAndStatus))
+	for count, jobs := range byKindAndStatus {
+		count.Jobs = jobs
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Kind != counts[j].Kind {
+			return counts[i].Kind < counts[j].Kind
+		}
+		return counts[i].Status < counts[j].Status
+	})
+	return counts, nil
+}
+
+func (m *MemoryQueue) update(id int64, attempt int, change func(*Job)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if job.Status != StatusRunning || job.Attempts != attempt {
+		return ErrLeaseLost
+	}
+	change(job)
+	job.UpdatedAt = m.now().UTC()
+	delete(m.lockedUntil, id)
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "log/slog"
+)
+
+// Defaults used by NewPool for the zero values of PoolConfig
+const (
+	DefaultConcurrency  = 2
+	DefaultPollInterval = time.Second
+	DefaultLease        = 10 * time.Minute
+	DefaultMaxAttempts  = 5
+	DefaultBackoffBase  = 5 * time.Second
+	DefaultBackoffMax   = 30 * time.Minute
+)
+
+// PoolConfig tunes how jobs are run and retried
+type PoolConfig struct {
+	// Concurrency is the number of jobs running at the same time
+	Concurrency int
+	// PollInterval is how long an idle worker waits before looking for due jobs again
+	PollInterval time.Duration
+	// Lease is the longest a job may run. It's handed to another worker if it's still running after that
+	Lease time.Duration
+	// MaxAttempts is the number of times a job is tried before it's dead-lettered
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry, it doubles with every attempt up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// Pool runs the jobs of a queue with a fixed number of workers
+type Pool struct {
+	queue    Queue
+	handlers map[string]Handler
+	kinds    []string
+	cfg      PoolConfig
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewPool(queue Queue, cfg PoolConfig) *Pool {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = DefaultLease
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = DefaultBackoffBase
+	}
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = DefaultBackoffMax
+	}
+
+	return &Pool{
+		queue:    queue,
+		handlers: map[string]Handler{},
+		cfg:      cfg,
+		done:     make(chan struct{}),
+	}
+}
+
+// Handle registers the handler of a kind of job. It must be called before Run
+func (p *Pool) Handle(kind string, handler Handler) {
+	if _, ok := p.handlers[kind]; !ok {
+		p.kinds = append(p.kinds, kind)
+	}
+	p.handlers[kind] = handler
+}
+
+// Run works through due jobs until Close is called, then waits for the jobs in progress to finish
+func (p *Pool) Run() {
+	var wg sync.WaitGroup
+	for i := 0; i < p.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+	wg.Wait()
+}
+
+// Close stops Run. Jobs left in the queue are picked up the next time a pool runs
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *Pool) work() {
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+
+		job, err := p.queue.Claim(context.Background(), p.kinds, p.cfg.Lease)
+		if err != nil {
+			if !errors.Is(err, ErrNoJobs) {
+				log.Error("could not claim a job", "err", err)
+			}
+			select {
+			case <-p.done:
+				return
+			case <-time.After(p.cfg.PollInterval):
+			}
+			continue
+		}
+
+		p.run(job)
+	}
+}
+
+// run handles a claimed job and records the outcome
+func (p *Pool) run(job *Job) {
+	ctx := context.Background()
+	handler := p.handlers[job.Kind]
+
+	// a job whose worker died mid-run is claimed again once its lease is over, which counts as an attempt
+	var err error
+	if job.Attempts > p.cfg.MaxAttempts {
+		err = Permanent(fmt.Errorf("gave up after %d attempts", p.cfg.MaxAttempts))
+	} else {
+		err = p.handle(handler, job)
+	}
+
+	switch {
+	case err == nil:
+		if err := p.queue.Complete(ctx, job.ID, job.Attempts); err != nil {
+			log.Error("could not complete job", "err", err, "job", job.ID)
+		}
+	case IsPermanent(err) || job.Attempts >= p.cfg.MaxAttempts:
+		log.Error("dead-lettering job", "err", err, "job", job.ID, "kind", job.Kind, "attempts", job.Attempts)
+		if err := p.queue.Bury(ctx, job.ID, job.Attempts, err.Error()); err != nil {
+			// the job is still running, or owned by another worker, so it isn't dead yet
+			log.Error("could not dead-letter job", "err", err, "job", job.ID)
+			return
+		}
+		job.Status = StatusDead
+		job.LastError = err.Error()
+		if deadLetter, ok := handler.(DeadLetterHandler); ok {
+			deadLetter.Dead(ctx, job)
+		}
+	default:
+		delay := Backoff(job.Attempts, p.cfg.BackoffBase, p.cfg.BackoffMax)
+		log.Warn("job failed, retrying", "err", err, "job", job.ID, "kind", job.Kind, "attempts", job.Attempts, "in", delay)
+		if err := p.queue.Retry(ctx, job.ID, job.Attempts, time.Now().Add(delay), err.Error()); err != nil {
+			log.Error("could not reschedule job", "err", err, "job", job.ID)
+		}
+	}
+}
+
+// handle runs the handler within the job's lease, turning panics into errors
+func (p *Pool) handle(handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Lease)
+	defer cancel()
+
+	log.Debug("running job", "job", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	return handler.Handle(ctx, job)
+}
+
+// Backoff returns the delay before retrying after the given attempt: base, then doubling up to max
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+)
+
+const jobColumns = "id, kind, COALESCE(file_id, 0), payload, status, attempts, last_error, run_at, created_at, updated_at"
+
+// PostgresQueue stores jobs in the jobs table. Workers claim jobs with SELECT ... FOR UPDATE SKIP LOCKED
+// so that any number of them, in any number of processes, can share the table
+type PostgresQueue struct {
+	db *sql.DB
+}
+
+// NewPostgresQueue creates a queue sharing the connection pool of the file_info store. Its table
+// is created by the file_info store's migrations
+func NewPostgresQueue(db *sql.DB) *PostgresQueue {
+	return &PostgresQueue{db: db}
+}
+
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	var job Job
+	var payload []byte
+	err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&job.FileID,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	return &job, nil
+}
+
+func (p *PostgresQueue) Enqueue(ctx context.Context, job Job) (*Job, error) {
+	now := time.Now().UTC()
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if len(job.Payload) == 0 {
+		job.Payload = []byte("{}")
+	}
+	var fileID *uint
+	if job.FileID != 0 {
+		fileID = &job.FileID
+	}
+
+	insertStmt := `INSERT INTO jobs (kind, file_id, payload, status, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING ` + jobColumns
+
+	saved, err := scanJob(p.db.QueryRowContext(ctx, insertStmt, job.Kind, fileID, []byte(job.Payload), StatusPending, job.RunAt.UTC(), now))
+	if err != nil {
+		log.Error("An error occurred while enqueueing job", "err", err, "kind", job.Kind)
+		return nil, dbError(err)
+	}
+	return saved, nil
+}
+
+func (p *PostgresQueue) Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error) {
+	now := time.Now().UTC()
+	claimStmt := `UPDATE jobs
+		SET status = $4, attempts = attempts + 1, locked_until = $2, updated_at = $1
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($3)
+				AND ((status = $5 AND run_at <= $1) OR (status = $4 AND locked_until < $1))
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(p.db.QueryRowContext(ctx, claimStmt, now, now.Add(lease), pq.Array(kinds), StatusRunning, StatusPending))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoJobs
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return job, nil
+}
+
+func (p *PostgresQueue) Complete(ctx context.Context, id int64, attempt int) error {
+	return p.update(ctx, `UPDATE jobs SET status = $4, last_error = '', locked_until = NULL, updated_at = $5
+		WHERE id = $1 AND status = $2 AND attempts = $3`,
+		id, StatusRunning, attempt, StatusSucceeded, time.Now().UTC())
+}
+
+func (p *PostgresQueue) Retry(ctx context.Context, id int64, attempt int, runAt time.Time, reason string) error {
+	return p.update(ctx, `UPDATE jobs SET status = $4, run_at = $5, last_error = $6, locked_until = NULL, updated_at = $7
+		WHERE id = $1 AND status = $2 AND attempts = $3`,
+		id, StatusRunning, attempt, StatusPending, runAt.UTC(), reason, time.Now().UTC())
+}
+
+func (p *PostgresQueue) Bury(ctx context.Context, id int64, attempt int, reason string) error {
+	return p.update(ctx, `UPDATE jobs SET status = $4, last_error = $5, locked_until = NULL, updated_at = $6
+		WHERE id = $1 AND status = $2 AND attempts = $3`,
+		id, StatusRunning, attempt, StatusDead, reason, time.Now().UTC())
+}
+
+func (p *PostgresQueue) Find(ctx context.Context, id int64) (*Job, error) {
+	job, err := scanJob(p.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+	return job, nil
+}
+
+func (p *PostgresQueue) Counts(ctx context.Context) ([]Count, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT kind, status, COUNT(*) FROM jobs WHERE status <> $1 GROUP BY kind, status ORDER BY kind, status`, StatusSucceeded)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	counts := []Count{}
+	for rows.Next() {
+		var count Count
+		if err := rows.Scan(&count.Kind, &count.Status, &count.Jobs); err != nil {
+			return nil, dbError(err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return counts, nil
+}
+
+// update records the outcome of a claimed job. No row matches once another worker has taken over the lease
+func (p *PostgresQueue) update(ctx context.Context, stmt string, args ...any) error {
+	result, err := p.db.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return dbError(err)
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func dbError(err error) error {
+	return fmt.Errorf("%w: %w", ErrDatabase, err)
+}
@@ -1,17 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	log "log/slog"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/phllpmcphrsn/voice-quips/api"
+	"github.com/phllpmcphrsn/voice-quips/cache"
 	"github.com/phllpmcphrsn/voice-quips/config"
 	"github.com/phllpmcphrsn/voice-quips/file"
+	"github.com/phllpmcphrsn/voice-quips/health"
+	"github.com/phllpmcphrsn/voice-quips/importer"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/metrics"
+	"github.com/phllpmcphrsn/voice-quips/playlist"
+	"github.com/phllpmcphrsn/voice-quips/ratelimit"
 	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/phllpmcphrsn/voice-quips/share"
+	"github.com/phllpmcphrsn/voice-quips/stats"
+	"github.com/phllpmcphrsn/voice-quips/tracing"
+	"github.com/phllpmcphrsn/voice-quips/transcript"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -28,26 +48,22 @@ func init() {
 }
 
 func setLogger(level log.Level) {
-	logger := log.New(log.NewJSONHandler(os.Stdout, &log.HandlerOptions{Level: level}))
+	// records logged with a context carry the ids of its span
+	logger := log.New(tracing.LogHandler(log.NewJSONHandler(os.Stdout, &log.HandlerOptions{Level: level})))
 	log.SetDefault(logger)
 }
 
-//	@title			Kaggle 2023 Car Models API
-//	@version		1.0
-//	@description	REST API for Kaggle 2023 Car Models Dataset which can be found here
-//	@description	https://www.kaggle.com/datasets/peshimaammuzammil/2023-car-model-dataset-all-data-you-need?resource=download
-//	@termsOfService	http://swagger.io/terms/
-
-//	@contact.name	API Support
-//	@contact.url	https://github.com/phllpmcphrsn/KaggleCarAPI/issues
-//	@contact.email	phllpmcphrsn@yahoo.com
-
-//	@license.name	Apache 2.0
-//	@license.url	http://www.apache.org/licenses/LICENSE-2.0.html
-
-// @host		localhost:9090
-// @BasePath	/api/v1
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	// could place this in init() but it'll cause errors for tests
 	// error: "flag provided but not defined"
 	flag.Parse()
@@ -67,27 +83,279 @@ func main() {
 	logLevel := config.GetLogLevel(cfg.Log.Level)
 	setLogger(logLevel)
 
+	tracingConfig := tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}
+	stopTracing, err := tracing.Setup(context.Background(), tracingConfig)
+	if err != nil {
+		log.Error("There was an issue setting up tracing", "err", err, "exporter", cfg.Tracing.Exporter)
+		panic(err)
+	}
+
+	// SIGTERM, sent on deploys, and ctrl-c stop the server gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// initialize database and service for file information
 	store, err := initDB(cfg.Database.FileInfoConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	fileService := file.NewFileInformationService(store)
-
 	// initialize s3 client and service
 	// TODO figure out a better or more extensible way to define a client
 	// some layer should be in front of the s3 client such that I'm not coupling
 	// a client here. in other words, I need some client abstraction
-	client, err := initS3Client(&cfg.Database.S3Config)
+	client, s3Transport, err := initS3Client(&cfg.Database.S3Config)
 	if err != nil {
 		panic(err)
 	}
 
-	s3Service := s3.NewMinioClient(client)
+	minioService := s3.NewMinioClient(client)
+	var s3Service s3.Storage = minioService
+
+	var serverMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		serverMetrics = metrics.New(metrics.Config{Namespace: cfg.Metrics.Namespace, Buckets: cfg.Metrics.Buckets})
+		serverMetrics.CollectDB(store.DB(), cfg.Database.FileInfoConfig.Name)
+		s3Service = serverMetrics.Storage("minio", s3Service)
+	}
+	if tracingConfig.Exporter != "" {
+		s3Service = tracing.Storage("minio", s3Service)
+	}
+
+	// Redis is only reached when a feature is set to its backend
+	var redisClient *redis.Client
+	if usesRedis(cfg) {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Username: cfg.Redis.Username,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+	}
+
+	var fileService file.Storer = file.NewFileInformationService(store)
+	// ratings, categories and transcripts are written around the cache and invalidate through it
+	var fileCache file.Invalidator
+	var transcriptCache transcript.Invalidator
+	if cfg.Cache.Enabled {
+		cachedStorer := file.NewCachedStorer(fileService, initCache(cfg, redisClient, serverMetrics), cfg.Cache)
+		fileService, fileCache, transcriptCache = cachedStorer, cachedStorer, cachedStorer
+	}
+	categoryService := file.NewCategoryService(store, fileCache)
+	feedbackService := file.NewFeedbackService(store, fileCache)
+
+	playlistStore := playlist.NewPostgresStore(store.DB())
+	playlistService := playlist.NewPlaylistService(playlistStore)
+
+	statsStore := stats.NewPostgresStore(store.DB())
+	recorder := stats.NewRecorder(statsStore, stats.RecorderConfig{
+		BatchSize:     cfg.Stats.BatchSize,
+		BufferSize:    cfg.Stats.BufferSize,
+		FlushInterval: cfg.Stats.FlushInterval,
+	})
+	waitRecorder := background(recorder.Run)
+	waitRollups := background(func() { stats.RunRollups(ctx, statsStore, rollupInterval(cfg.Stats)) })
+
+	queue := initJobQueue(cfg.Jobs, store)
+	if counter, ok := queue.(jobs.Counter); ok && serverMetrics != nil {
+		serverMetrics.CollectJobs(counter)
+	}
+	pool := jobs.NewPool(queue, jobs.PoolConfig{
+		Concurrency:  cfg.Jobs.Concurrency,
+		PollInterval: cfg.Jobs.PollInterval,
+		Lease:        cfg.Jobs.Lease,
+		MaxAttempts:  cfg.Jobs.MaxAttempts,
+		BackoffBase:  cfg.Jobs.BackoffBase,
+		BackoffMax:   cfg.Jobs.BackoffMax,
+	})
+
+	// uploads are processed in the background; transcription follows once the metadata is stored
+	transcriptStore := transcript.NewPostgresStore(store.DB())
+	followUps := []string{}
+	if cfg.Transcription.Engine != "" {
+		engine, err := transcript.NewEngine(cfg.Transcription)
+		if err != nil {
+			log.Error("There was an issue creating the transcription engine", "err", err, "engine", cfg.Transcription.Engine)
+			panic(err)
+		}
+		pool.Handle(transcript.JobTranscribe, transcript.NewProcessor(engine, transcriptStore, s3Service, cfg.Database.S3Config.Bucket, cfg.Transcription.Timeout, transcriptCache))
+		followUps = append(followUps, transcript.JobTranscribe)
+	}
+	pool.Handle(file.JobProcessUpload, file.NewUploadProcessor(fileService, s3Service, cfg.Database.S3Config.Bucket, queue, followUps...))
+	waitPool := background(pool.Run)
+
+	waitImporter := func(context.Context) error { return nil }
+	if cfg.Importer.Enabled {
+		audioImporter := importer.New(importer.Config{
+			Directory:          cfg.AudioDirectory,
+			Bucket:             cfg.Database.S3Config.Bucket,
+			Debounce:           cfg.Importer.Debounce,
+			ProcessedDirectory: cfg.Importer.ProcessedDirectory,
+			Category:           cfg.Importer.Category,
+			Tags:               cfg.Importer.Tags,
+		}, s3Service, fileService)
+		waitImporter = background(func() {
+			if err := audioImporter.Run(ctx); err != nil {
+				log.Error("The audio importer stopped", "err", err, "directory", cfg.AudioDirectory)
+			}
+		})
+	}
+
+	options := []api.Option{
+		api.WithBucket(cfg.Database.S3Config.Bucket),
+		api.WithCategoryService(categoryService),
+		api.WithFeedbackService(feedbackService),
+		api.WithPlaylistService(playlistService),
+		api.WithStats(recorder, stats.NewStatsService(statsStore)),
+		api.WithJobs(queue),
+		api.WithTranscripts(transcriptStore),
+		api.WithHealth(newHealth(cfg, store, minioService)),
+		api.WithStorageUsage(minioService),
+	}
+	if cfg.API.Dashboard.Enabled {
+		options = append(options, api.WithDashboard())
+	}
+	if cfg.API.Listener.Enabled {
+		options = append(options, api.WithListener())
+	}
+	if cfg.Share.Enabled {
+		shareService, err := initShares(cfg.Share, store)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, api.WithShareService(shareService))
+	}
+	if tracingConfig.Exporter != "" {
+		serviceName := tracingConfig.ServiceName
+		if serviceName == "" {
+			serviceName = tracing.DefaultServiceName
+		}
+		options = append(options, api.WithTracing(serviceName))
+	}
+	var metricsServer *http.Server
+	if serverMetrics != nil {
+		var path string
+		path, metricsServer = serveMetrics(cfg.Metrics, serverMetrics)
+		options = append(options, api.WithMetrics(serverMetrics, path))
+	}
+
+	if cfg.RateLimit.Enabled {
+		options = append(options, initRateLimit(cfg, redisClient))
+	}
+
+	server := api.NewAPIServer(cfg.API, s3Service, fileService, options...)
+	err = server.Run(ctx)
+	if err != nil {
+		log.Error("The API server stopped", "err", err)
+	}
+	// the server may have stopped on its own, the workers watching the context must stop too
+	stop()
+
+	// workers go before the stores and clients they use
+	shutdown(server.ShutdownTimeout(),
+		shutdownStep{"importer", waitImporter},
+		shutdownStep{"job pool", func(ctx context.Context) error {
+			pool.Close()
+			return waitPool(ctx)
+		}},
+		shutdownStep{"analytics recorder", func(ctx context.Context) error {
+			recorder.Close()
+			return waitRecorder(ctx)
+		}},
+		shutdownStep{"analytics rollups", waitRollups},
+		shutdownStep{"metrics listener", func(ctx context.Context) error {
+			if metricsServer == nil {
+				return nil
+			}
+			return metricsServer.Shutdown(ctx)
+		}},
+		shutdownStep{"tracing", stopTracing},
+		shutdownStep{"database", closeWith(store.Close)},
+		shutdownStep{"redis client", closeWith(func() error {
+			if redisClient == nil {
+				return nil
+			}
+			return redisClient.Close()
+		})},
+		shutdownStep{"storage client", closeWith(func() error {
+			s3Transport.CloseIdleConnections()
+			return nil
+		})},
+	)
+	if err != nil {
+		os.Exit(1)
+	}
+}
 
-	server := api.NewAPIServer(cfg.API, s3Service, fileService)
-	server.StartRouter()
+// newHealth checks what the API can't serve requests without: the database, its schema and the bucket
+func newHealth(cfg *config.Config, store *file.PostgresStore, buckets s3.BucketChecker) *health.Health {
+	h := health.New(cfg.Health.Timeout)
+	h.Add("postgres", health.CheckerFunc(store.Ping))
+	h.Add("migrations", health.CheckerFunc(store.CheckMigrations))
+	h.Add("storage", health.CheckerFunc(func(ctx context.Context) error {
+		exists, err := buckets.BucketExists(ctx, cfg.Database.S3Config.Bucket)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("bucket %s does not exist", cfg.Database.S3Config.Bucket)
+		}
+		return nil
+	}))
+	return h
+}
+
+// usesRedis reports whether a feature is set to the redis backend
+func usesRedis(cfg *config.Config) bool {
+	return (cfg.RateLimit.Enabled && strings.EqualFold(cfg.RateLimit.Backend, "redis")) ||
+		(cfg.Cache.Enabled && strings.EqualFold(cfg.Cache.Backend, "redis"))
+}
+
+// initCache creates the file metadata cache of the backend named in the config, in memory unless
+// redis is asked for. Its lookups are counted when there are metrics
+func initCache(cfg *config.Config, redisClient *redis.Client, m *metrics.Metrics) cache.Cache {
+	var c cache.Cache
+	if strings.EqualFold(cfg.Cache.Backend, "redis") {
+		c = cache.NewRedis(redisClient)
+	} else {
+		c = cache.NewLRU(cfg.Cache.Size)
+	}
+	if m != nil {
+		c = m.Cache("files", c)
+	}
+	return c
+}
+
+// initRateLimit creates the limiter and quota of the backend named in the config, in memory unless
+// redis is asked for
+func initRateLimit(cfg *config.Config, redisClient *redis.Client) api.Option {
+	limits := api.RateLimits{
+		Default: rateLimitFrom(cfg.RateLimit.Default),
+		Routes:  map[string]ratelimit.Limit{},
+		Quota: ratelimit.QuotaLimit{
+			Uploads: cfg.RateLimit.Quota.DailyUploads,
+			Bytes:   cfg.RateLimit.Quota.DailyBytes,
+		},
+	}
+	for _, route := range cfg.RateLimit.Routes {
+		limits.Routes[strings.ToUpper(route.Method)+" "+route.Route] = rateLimitFrom(route.LimitConfig)
+	}
+
+	if !strings.EqualFold(cfg.RateLimit.Backend, "redis") {
+		log.Warn("rate limits are kept in memory, they aren't shared between instances")
+		return api.WithRateLimit(ratelimit.NewMemoryLimiter(), ratelimit.NewMemoryQuota(), limits)
+	}
+	return api.WithRateLimit(ratelimit.NewRedisLimiter(redisClient), ratelimit.NewRedisQuota(redisClient), limits)
+}
+
+func rateLimitFrom(cfg config.LimitConfig) ratelimit.Limit {
+	return ratelimit.Limit{Requests: cfg.Requests, Period: cfg.Period, Burst: cfg.Burst}
 }
 
 func initDB(cfg config.FileInformationStoreConfig) (*file.PostgresStore, error) {
@@ -104,6 +372,12 @@ func initDB(cfg config.FileInformationStoreConfig) (*file.PostgresStore, error)
 		return nil, err
 	}
 
+	err = store.Migrate(context.Background())
+	if err != nil {
+		log.Error("There was an issue migrating the database", "err", err)
+		return nil, err
+	}
+
 	indexedColumns := []string{"file_type", "category"}
 	indexName := "file_type_and_category_index"
 	err = store.CreateIndexOn(indexName, indexedColumns)
@@ -114,20 +388,78 @@ func initDB(cfg config.FileInformationStoreConfig) (*file.PostgresStore, error)
 	return store, nil
 }
 
+// initJobQueue creates the queue backend named in the config, Postgres unless memory is asked for
+func initJobQueue(cfg config.JobsConfig, store *file.PostgresStore) jobs.Queue {
+	if strings.EqualFold(cfg.Backend, "memory") {
+		log.Warn("using the in-memory job queue, jobs are lost when the server stops")
+		return jobs.NewMemoryQueue()
+	}
+	return jobs.NewPostgresQueue(store.DB())
+}
+
+func initShares(cfg config.ShareConfig, store *file.PostgresStore) (*share.Service, error) {
+	signer, err := share.NewSigner([]byte(cfg.Key))
+	if err != nil {
+		log.Error("There was an issue with the share link signing key", "err", err, "keyVar", cfg.KeyVar)
+		return nil, err
+	}
+
+	shareStore := share.NewPostgresStore(store.DB())
+	return share.NewService(shareStore, signer, share.Config{DefaultTTL: cfg.DefaultTTL, MaxTTL: cfg.MaxTTL}), nil
+}
+
+// serveMetrics starts the metrics listener when the config gives it an address. It returns the
+// path the API should serve the metrics at, empty when they have a listener of their own, and
+// that listener
+func serveMetrics(cfg config.MetricsConfig, m *metrics.Metrics) (string, *http.Server) {
+	path := cfg.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	if cfg.Address == "" {
+		return path, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, m.Handler())
+	server := &http.Server{Addr: cfg.Address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("The metrics listener stopped", "err", err, "address", cfg.Address)
+		}
+	}()
+	return "", server
+}
+
+func rollupInterval(cfg config.StatsConfig) time.Duration {
+	if cfg.RollupInterval <= 0 {
+		return time.Minute
+	}
+	return cfg.RollupInterval
+}
+
 // initialize the s3 client
-func initS3Client(cfg *config.S3Config) (*minio.Client, error) {
+func initS3Client(cfg *config.S3Config) (*minio.Client, *http.Transport, error) {
 	accessKey := cfg.Credentials.User
 	secretKey := cfg.Credentials.Password
 	endpoint := cfg.Endpoint
 
+	// the transport is kept so that its connections can be closed on shutdown
+	transport, err := minio.DefaultTransport(cfg.SSL.Enabled)
+	if err != nil {
+		log.Error("There was an issue creating the MinIO transport", "err", err)
+		return nil, nil, err
+	}
+
 	minioClient, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, string(secretKey), ""),
-		Secure: cfg.SSL.Enabled,
+		Creds:     credentials.NewStaticV4(accessKey, string(secretKey), ""),
+		Secure:    cfg.SSL.Enabled,
+		Transport: transport,
 	})
 	if err != nil {
 		log.Error("There was an issue initializing the MinIO client", "err", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return minioClient, nil
+	return minioClient, transport, nil
 }
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/cache"
+)
+
+// instrumentedCache counts the hits and misses of the cache it wraps
+type instrumentedCache struct {
+	next    cache.Cache
+	name    string
+	metrics *Metrics
+}
+
+// Cache wraps the cache so that its lookups are counted, labelled with the cache's name
+func (m *Metrics) Cache(name string, c cache.Cache) cache.Cache {
+	return &instrumentedCache{next: c, name: name, metrics: m}
+}
+
+func (c *instrumentedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, found, err := c.next.Get(ctx, key)
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case found:
+		result = "hit"
+	}
+	c.metrics.cacheLookups.WithLabelValues(c.name, result).Inc()
+	return value, found, err
+}
+
+func (c *instrumentedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.next.Set(ctx, key, value, ttl)
+}
+
+func (c *instrumentedCache) Delete(ctx context.Context, keys ...string) error {
+	return c.next.Delete(ctx, keys...)
+}
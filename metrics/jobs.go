@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	log "log/slog"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jobsTimeout bounds how long a scrape waits for the queue to count its jobs
+const jobsTimeout = 5 * time.Second
+
+// jobsCollector reports the depth of the job queue every time the metrics are scraped
+type jobsCollector struct {
+	counter jobs.Counter
+	jobs    *prometheus.Desc
+}
+
+// CollectJobs reports how many jobs of each kind are pending, running or dead-lettered
+func (m *Metrics) CollectJobs(counter jobs.Counter) {
+	m.registry.MustRegister(&jobsCollector{
+		counter: counter,
+		jobs: prometheus.NewDesc(
+			prometheus.BuildFQName(m.namespace, "jobs", "queued"),
+			"Jobs pending, running or dead-lettered, by kind and status.",
+			[]string{"kind", "status"}, nil,
+		),
+	})
+}
+
+func (j *jobsCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- j.jobs
+}
+
+func (j *jobsCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobsTimeout)
+	defer cancel()
+
+	counts, err := j.counter.Counts(ctx)
+	if err != nil {
+		log.Warn("could not count jobs for the metrics", "err", err)
+		metrics <- prometheus.NewInvalidMetric(j.jobs, err)
+		return
+	}
+	for _, count := range counts {
+		metrics <- prometheus.MustNewConstMetric(j.jobs, prometheus.GaugeValue, float64(count.Jobs), count.Kind, string(count.Status))
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultNamespace prefixes every metric name unless told otherwise
+const DefaultNamespace = "voice_quips"
+
+// Config tunes the metrics. Zero values fall back to defaults
+type Config struct {
+	Namespace string
+	// Buckets are the upper bounds, in seconds, of the latency histograms
+	Buckets []float64
+}
+
+// Metrics holds the Prometheus collectors of the service, registered with their own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+
+	storageOperations *prometheus.CounterVec
+	storageDuration   *prometheus.HistogramVec
+	uploadSize        *prometheus.HistogramVec
+
+	cacheLookups *prometheus.CounterVec
+
+	namespace string
+}
+
+func New(cfg Config) *Metrics {
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+
+	m := &Metrics{
+		registry:  prometheus.NewRegistry(),
+		namespace: cfg.Namespace,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests answered, by route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to answer HTTP requests, by route.",
+			Buckets:   cfg.Buckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests being answered.",
+		}),
+		storageOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "storage",
+			Name:      "operations_total",
+			Help:      "Object storage operations, by backend, operation and result.",
+		}, []string{"backend", "operation", "result"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Time taken by object storage operations, by backend and operation.",
+			Buckets:   cfg.Buckets,
+		}, []string{"backend", "operation"}),
+		uploadSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "storage",
+			Name:      "upload_size_bytes",
+			Help:      "Size of the objects uploaded to storage.",
+			// 1KiB to 16MiB
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		}, []string{"backend"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.Namespace,
+			Subsystem: "cache",
+			Name:      "lookups_total",
+			Help:      "Cache lookups, by cache and result (hit, miss or error).",
+		}, []string{"cache", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.storageOperations,
+		m.storageDuration,
+		m.uploadSize,
+		m.cacheLookups,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// CollectDB reports the connection pool statistics of the database, labelled with its name
+func (m *Metrics) CollectDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
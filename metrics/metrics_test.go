@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/phllpmcphrsn/voice-quips/cache"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStorage fails every operation when err is set
+type fakeStorage struct {
+	err error
+}
+
+func (f *fakeStorage) UploadObject(ctx context.Context, filename, bucket string) error {
+	return f.err
+}
+
+func (f *fakeStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	return f.err
+}
+
+func (f *fakeStorage) DownloadObject(ctx context.Context, objectName, bucket string) ([]byte, error) {
+	return nil, f.err
+}
+
+func (f *fakeStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	return &s3.Object{}, f.err
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New(Config{})
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/audio/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/audio/1", "/audio/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/audio/:id", "204")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestMetrics_Storage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedResult string
+		expectedSizes  int
+	}{
+		{name: "Success", expectedResult: "success", expectedSizes: 1},
+		{name: "Error", err: &s3.UploadError{Err: errors.New("bucket gone")}, expectedResult: "error", expectedSizes: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := New(Config{})
+			storage := m.Storage("minio", &fakeStorage{err: tc.err})
+
+			err := storage.UploadStream(ctx, "audio/a.mp3", "quips", strings.NewReader("audio"), 5, s3.MP3Header)
+			assert.Equal(t, tc.err, err)
+			storage.StreamObject(ctx, "audio/a.mp3", "quips")
+
+			for _, operation := range []string{OperationUploadStream, OperationStreamObject} {
+				assert.Equal(t, 1.0, testutil.ToFloat64(m.storageOperations.WithLabelValues("minio", operation, tc.expectedResult)), operation)
+			}
+			assert.Equal(t, tc.expectedSizes, testutil.CollectAndCount(m.uploadSize))
+		})
+	}
+}
+
+func TestMetrics_Cache(t *testing.T) {
+	ctx := context.Background()
+	m := New(Config{})
+	c := m.Cache("files", cache.NewLRU(10))
+
+	c.Get(ctx, "file:1")
+	c.Set(ctx, "file:1", []byte("{}"), time.Minute)
+	c.Get(ctx, "file:1")
+	c.Get(ctx, "file:1")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("files", "hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("files", "miss")))
+}
+
+func TestMetrics_CollectJobs(t *testing.T) {
+	ctx := context.Background()
+	queue := jobs.NewMemoryQueue()
+	queue.Enqueue(ctx, jobs.Job{Kind: "process_upload"})
+	queue.Enqueue(ctx, jobs.Job{Kind: "process_upload"})
+	queue.Enqueue(ctx, jobs.Job{Kind: "transcribe"})
+	m := New(Config{Namespace: "test"})
+
+	m.CollectJobs(queue)
+
+	expected := `
+# HELP test_jobs_queued Jobs pending, running or dead-lettered, by kind and status.
+# TYPE test_jobs_queued gauge
+test_jobs_queued{kind="process_upload",status="pending"} 2
+test_jobs_queued{kind="transcribe",status="pending"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "test_jobs_queued"))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New(Config{})
+	m.requests.WithLabelValues(http.MethodGet, "/ping", "200").Inc()
+	w := httptest.NewRecorder()
+
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `voice_quips_http_requests_total{method="GET",route="/ping",status="200"} 1`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// Storage operations, as labelled in the metrics
+const (
+	OperationUploadObject   = "upload_object"
+	OperationUploadStream   = "upload_stream"
+	OperationDownloadObject = "download_object"
+	OperationStreamObject   = "stream_object"
+)
+
+// instrumentedStorage counts and times the operations of the storage it wraps
+type instrumentedStorage struct {
+	next    s3.Storage
+	backend string
+	metrics *Metrics
+}
+
+// Storage wraps the storage so that its operations are counted and timed, labelled with the
+// backend's name (minio, s3...)
+func (m *Metrics) Storage(backend string, storage s3.Storage) s3.Storage {
+	return &instrumentedStorage{next: storage, backend: backend, metrics: m}
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	s.metrics.storageOperations.WithLabelValues(s.backend, operation, result).Inc()
+	s.metrics.storageDuration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStorage) UploadObject(ctx context.Context, filename, bucket string) error {
+	start := time.Now()
+	err := s.next.UploadObject(ctx, filename, bucket)
+	s.observe(OperationUploadObject, start, err)
+	return err
+}
+
+func (s *instrumentedStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	start := time.Now()
+	err := s.next.UploadStream(ctx, objectName, bucket, body, size, contentType)
+	s.observe(OperationUploadStream, start, err)
+	if err == nil && size >= 0 {
+		s.metrics.uploadSize.WithLabelValues(s.backend).Observe(float64(size))
+	}
+	return err
+}
+
+func (s *instrumentedStorage) DownloadObject(ctx context.Context, objectName, bucket string) ([]byte, error) {
+	start := time.Now()
+	data, err := s.next.DownloadObject(ctx, objectName, bucket)
+	s.observe(OperationDownloadObject, start, err)
+	return data, err
+}
+
+// StreamObject times how long the object takes to open, not how long it's read for
+func (s *instrumentedStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	start := time.Now()
+	object, err := s.next.StreamObject(ctx, objectName, bucket)
+	s.observe(OperationStreamObject, start, err)
+	return object, err
+}
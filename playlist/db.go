@@ -0,0 +1,227 @@
+package playlist
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+)
+
+const playlistColumns = "id, name, owner, public, created_at, updated_at"
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a playlist store sharing the connection pool of the file_info store.
+// Its tables are created by the file_info store's migrations
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// playlistError converts the driver's constraint violations into the package's errors. An item
+// whose playlist is gone means the playlist was deleted meanwhile, any other foreign key is the file
+func playlistError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return err
+	}
+	switch pqErr.Code {
+	case "23503":
+		if pqErr.Constraint == "playlist_items_playlist_id_fkey" {
+			return ErrNotFound
+		}
+		return ErrUnknownFile
+	case "22001":
+		return ErrNameTooLong
+	}
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPlaylist(row rowScanner) (*Playlist, error) {
+	var playlist Playlist
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.Name,
+		&playlist.Owner,
+		&playlist.Public,
+		&playlist.CreatedAt,
+		&playlist.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	playlist.Items = []Item{}
+	return &playlist, nil
+}
+
+func (p *PostgresStore) Create(ctx context.Context, playlist Playlist) (*Playlist, error) {
+	log.Debug("Inserting a playlist into the DB", "playlist", playlist)
+	insertStmt := `INSERT INTO playlists (name, owner, public, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING ` + playlistColumns
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	saved, err := scanPlaylist(tx.QueryRowContext(ctx, insertStmt, playlist.Name, playlist.Owner, playlist.Public, time.Now().UTC()))
+	if err != nil {
+		log.Error("An error occurred while inserting playlist", "err", err)
+		return nil, playlistError(err)
+	}
+	if err := insertItems(ctx, tx, saved.ID, playlist.Items); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (p *PostgresStore) Update(ctx context.Context, playlist Playlist) (*Playlist, error) {
+	log.Debug("Updating a playlist in the DB", "id", playlist.ID)
+	updateStmt := `UPDATE playlists SET name = $2, public = $3, updated_at = $4
+		WHERE id = $1
+		RETURNING ` + playlistColumns
+
+	saved, err := scanPlaylist(p.db.QueryRowContext(ctx, updateStmt, playlist.ID, playlist.Name, playlist.Public, time.Now().UTC()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		log.Error("An error occurred while updating playlist", "err", err, "id", playlist.ID)
+		return nil, playlistError(err)
+	}
+
+	if err := p.loadItems(ctx, saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (p *PostgresStore) Delete(ctx context.Context, id uint) error {
+	log.Debug("Deleting playlist from the DB", "id", id)
+	result, err := p.db.ExecContext(ctx, "DELETE FROM playlists WHERE id = $1", id)
+	if err != nil {
+		log.Error("An error occurred while deleting playlist", "err", err, "id", id)
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) FindById(ctx context.Context, id uint) (*Playlist, error) {
+	selectStmt := "SELECT " + playlistColumns + " FROM playlists WHERE id = $1"
+	playlist, err := scanPlaylist(p.db.QueryRowContext(ctx, selectStmt, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := p.loadItems(ctx, playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// FindVisibleTo returns the public playlists and the ones owned by the given user, without their items
+func (p *PostgresStore) FindVisibleTo(ctx context.Context, user string) ([]*Playlist, error) {
+	playlists := []*Playlist{}
+	selectStmt := "SELECT " + playlistColumns + " FROM playlists WHERE public OR owner = $1 ORDER BY name, id"
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		playlist, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, rows.Err()
+}
+
+// SetItems replaces the items of the playlist in a single transaction
+func (p *PostgresStore) SetItems(ctx context.Context, id uint, items []Item) error {
+	log.Debug("Replacing playlist items", "id", id, "items", len(items))
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM playlist_items WHERE playlist_id = $1", id); err != nil {
+		return err
+	}
+
+	if err := insertItems(ctx, tx, id, items); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE playlists SET updated_at = $2 WHERE id = $1", id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// insertItems adds the items to the playlist within the transaction
+func insertItems(ctx context.Context, tx *sql.Tx, id uint, items []Item) error {
+	insertStmt := `INSERT INTO playlist_items (playlist_id, position, file_id, hotkey) VALUES ($1, $2, $3, NULLIF($4, ''))`
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, insertStmt, id, item.Position, item.FileID, item.Hotkey); err != nil {
+			log.Error("An error occurred while inserting playlist item", "err", err, "id", id, "file", item.FileID)
+			return playlistError(err)
+		}
+	}
+	return nil
+}
+
+func (p *PostgresStore) loadItems(ctx context.Context, playlist *Playlist) error {
+	selectStmt := `SELECT playlist_items.position, playlist_items.file_id, COALESCE(playlist_items.hotkey, ''),
+			COALESCE(file_info.title, ''), COALESCE(file_info.artist, ''), COALESCE(file_info.filename, '')
+		FROM playlist_items
+		JOIN file_info ON file_info.id = playlist_items.file_id
+		WHERE playlist_items.playlist_id = $1
+		ORDER BY playlist_items.position`
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, playlist.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	playlist.Items = []Item{}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.Position, &item.FileID, &item.Hotkey, &item.Title, &item.Artist, &item.Filename); err != nil {
+			return err
+		}
+		// files deleted since the playlist was saved leave gaps, positions are always reported as 1..n
+		item.Position = len(playlist.Items) + 1
+		playlist.Items = append(playlist.Items, item)
+	}
+	return rows.Err()
+}
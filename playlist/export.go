@@ -0,0 +1,90 @@
+package playlist
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Content types of the supported export formats
+const (
+	M3U8ContentType = "application/vnd.apple.mpegurl"
+	XSPFContentType = "application/xspf+xml"
+)
+
+// StreamURLFunc returns the URL a quip can be streamed from
+type StreamURLFunc func(fileID uint) string
+
+// ExportM3U8 writes the playlist as an extended M3U playlist (UTF-8) pointing at the stream URLs
+func ExportM3U8(playlist *Playlist, streamURL StreamURLFunc) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&buf, "#PLAYLIST:%s\n", singleLine(playlist.Name))
+
+	for _, item := range playlist.Items {
+		// the duration isn't known so -1 is used, as the format allows
+		fmt.Fprintf(&buf, "#EXTINF:-1,%s\n", singleLine(itemLabel(item)))
+		buf.WriteString(streamURL(item.FileID))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version   string      `xml:"version,attr"`
+	Title     string      `xml:"title"`
+	Creator   string      `xml:"creator,omitempty"`
+	TrackList []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Title      string `xml:"title,omitempty"`
+	Creator    string `xml:"creator,omitempty"`
+	TrackNum   int    `xml:"trackNum"`
+	Annotation string `xml:"annotation,omitempty"`
+}
+
+// ExportXSPF writes the playlist as an XSPF (XML Shareable Playlist Format) document. Hotkey
+// labels are kept as track annotations
+func ExportXSPF(playlist *Playlist, streamURL StreamURLFunc) ([]byte, error) {
+	document := xspfPlaylist{
+		Version:   "1",
+		Title:     playlist.Name,
+		Creator:   playlist.Owner,
+		TrackList: []xspfTrack{},
+	}
+	for _, item := range playlist.Items {
+		document.TrackList = append(document.TrackList, xspfTrack{
+			Location:   streamURL(item.FileID),
+			Title:      itemLabel(item),
+			Creator:    item.Artist,
+			TrackNum:   item.Position,
+			Annotation: item.Hotkey,
+		})
+	}
+
+	output, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+func itemLabel(item Item) string {
+	label := item.Title
+	if label == "" {
+		label = item.Filename
+	}
+	if item.Artist != "" {
+		label = item.Artist + " - " + label
+	}
+	return label
+}
+
+// singleLine keeps user supplied names from breaking out of an M3U directive
+func singleLine(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,275 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxHotkeyLength is the longest label a soundboard grid position can be given
+const MaxHotkeyLength = 16
+
+// MaxNameLength is the longest name a playlist can be given
+const MaxNameLength = 100
+
+var ErrNameMissing = errors.New("playlist name not given")
+var ErrNameTooLong = errors.New("playlist names can be at most 100 characters")
+var ErrNotFound = errors.New("playlist not found")
+var ErrNotOwner = errors.New("only the playlist's owner can change it")
+var ErrUnknownFile = errors.New("playlist item refers to a quip that doesn't exist")
+var ErrItemNotFound = errors.New("no quip at this position in the playlist")
+var ErrHotkeyTooLong = errors.New("hotkey labels can be at most 16 characters")
+var ErrDuplicateHotkey = errors.New("hotkey label is already used in this playlist")
+
+// Playlist is an ordered collection of quips. Played as a soundboard, each item is a grid position
+type Playlist struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Public    bool      `json:"public"`
+	Items     []Item    `json:"items"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Item is a quip at a position of a playlist, optionally bound to a soundboard hotkey label.
+// Title, Artist and Filename are filled in from the file's information when read
+type Item struct {
+	Position int    `json:"position"`
+	FileID   uint   `json:"fileId"`
+	Hotkey   string `json:"hotkey,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Filename string `json:"name,omitempty"`
+}
+
+// Update holds the playlist properties to change. Nil fields are left as they are
+type Update struct {
+	Name   *string `json:"name"`
+	Public *bool   `json:"public"`
+}
+
+type Repository interface {
+	// Create stores the playlist along with its items, none of them when one can't be stored
+	Create(context.Context, Playlist) (*Playlist, error)
+	Update(context.Context, Playlist) (*Playlist, error)
+	Delete(context.Context, uint) error
+	FindById(context.Context, uint) (*Playlist, error)
+	FindVisibleTo(context.Context, string) ([]*Playlist, error)
+	SetItems(context.Context, uint, []Item) error
+}
+
+// Storer defines the API for managing playlists on behalf of a user
+type Storer interface {
+	Create(ctx context.Context, user string, playlist Playlist) (*Playlist, error)
+	Update(ctx context.Context, user string, id uint, update Update) (*Playlist, error)
+	Delete(ctx context.Context, user string, id uint) error
+	FindById(ctx context.Context, user string, id uint) (*Playlist, error)
+	FindAll(ctx context.Context, user string) ([]*Playlist, error)
+	AddItem(ctx context.Context, user string, id uint, item Item) (*Playlist, error)
+	RemoveItem(ctx context.Context, user string, id uint, position int) (*Playlist, error)
+	ReplaceItems(ctx context.Context, user string, id uint, items []Item) (*Playlist, error)
+}
+
+type PlaylistService struct {
+	repo Repository
+}
+
+func NewPlaylistService(repo Repository) *PlaylistService {
+	return &PlaylistService{repo: repo}
+}
+
+func (s *PlaylistService) Create(ctx context.Context, user string, playlist Playlist) (*Playlist, error) {
+	name, err := validName(playlist.Name)
+	if err != nil {
+		return nil, err
+	}
+	playlist.Name = name
+
+	items, err := normalizeItems(playlist.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist.Owner = user
+	playlist.Items = items
+	saved, err := s.repo.Create(ctx, playlist)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindById(ctx, saved.ID)
+}
+
+// Update renames the playlist and/or changes its visibility
+func (s *PlaylistService) Update(ctx context.Context, user string, id uint, update Update) (*Playlist, error) {
+	playlist, err := s.findOwned(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		name, err := validName(*update.Name)
+		if err != nil {
+			return nil, err
+		}
+		playlist.Name = name
+	}
+	if update.Public != nil {
+		playlist.Public = *update.Public
+	}
+
+	return s.repo.Update(ctx, *playlist)
+}
+
+func (s *PlaylistService) Delete(ctx context.Context, user string, id uint) error {
+	if _, err := s.findOwned(ctx, user, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// validName trims the name, which must be given and fit in the playlists table
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrNameMissing
+	}
+	if len([]rune(name)) > MaxNameLength {
+		return "", ErrNameTooLong
+	}
+	return name, nil
+}
+
+// FindById returns the playlist if it's public or owned by the user. Private playlists of
+// other users are reported as not found
+func (s *PlaylistService) FindById(ctx context.Context, user string, id uint) (*Playlist, error) {
+	playlist, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !playlist.Public && playlist.Owner != user {
+		return nil, ErrNotFound
+	}
+	return playlist, nil
+}
+
+// FindAll returns the public playlists and the ones owned by the user
+func (s *PlaylistService) FindAll(ctx context.Context, user string) ([]*Playlist, error) {
+	return s.repo.FindVisibleTo(ctx, user)
+}
+
+// AddItem inserts a quip at the item's position, shifting the following items down. Items
+// without a position are appended
+func (s *PlaylistService) AddItem(ctx context.Context, user string, id uint, item Item) (*Playlist, error) {
+	playlist, err := s.findOwned(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items := playlist.Items
+	index := len(items)
+	if item.Position > 0 && item.Position <= len(items) {
+		index = item.Position - 1
+	}
+	items = append(items[:index], append([]Item{item}, items[index:]...)...)
+
+	return s.saveItems(ctx, playlist, items)
+}
+
+// RemoveItem removes the quip at the given position, shifting the following items up
+func (s *PlaylistService) RemoveItem(ctx context.Context, user string, id uint, position int) (*Playlist, error) {
+	playlist, err := s.findOwned(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items := playlist.Items
+	if position < 1 || position > len(items) {
+		return nil, ErrItemNotFound
+	}
+	items = append(items[:position-1], items[position:]...)
+
+	return s.saveItems(ctx, playlist, items)
+}
+
+// ReplaceItems sets the playlist's items, and their order, to the given ones. This is how
+// playlists are reordered and how hotkeys are (re)assigned
+func (s *PlaylistService) ReplaceItems(ctx context.Context, user string, id uint, items []Item) (*Playlist, error) {
+	playlist, err := s.findOwned(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// items are placed by their position when every item has one, otherwise by their order in the request
+	ordered := make([]Item, len(items))
+	copy(ordered, items)
+	if allPositioned(ordered) {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Position < ordered[j].Position
+		})
+	}
+
+	return s.saveItems(ctx, playlist, ordered)
+}
+
+func (s *PlaylistService) saveItems(ctx context.Context, playlist *Playlist, items []Item) (*Playlist, error) {
+	items, err := normalizeItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetItems(ctx, playlist.ID, items); err != nil {
+		return nil, err
+	}
+	return s.repo.FindById(ctx, playlist.ID)
+}
+
+func (s *PlaylistService) findOwned(ctx context.Context, user string, id uint) (*Playlist, error) {
+	playlist, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.Owner != user {
+		if !playlist.Public {
+			return nil, ErrNotFound
+		}
+		return nil, ErrNotOwner
+	}
+	return playlist, nil
+}
+
+func allPositioned(items []Item) bool {
+	for _, item := range items {
+		if item.Position < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeItems numbers the items 1..n in their current order and validates their hotkeys
+func normalizeItems(items []Item) ([]Item, error) {
+	normalized := make([]Item, 0, len(items))
+	hotkeys := make(map[string]bool)
+
+	for i, item := range items {
+		item.Position = i + 1
+		item.Hotkey = strings.TrimSpace(item.Hotkey)
+
+		if item.Hotkey != "" {
+			if len([]rune(item.Hotkey)) > MaxHotkeyLength {
+				return nil, ErrHotkeyTooLong
+			}
+			key := strings.ToLower(item.Hotkey)
+			if hotkeys[key] {
+				return nil, ErrDuplicateHotkey
+			}
+			hotkeys[key] = true
+		}
+
+		normalized = append(normalized, Item{Position: item.Position, FileID: item.FileID, Hotkey: item.Hotkey})
+	}
+	return normalized, nil
+}
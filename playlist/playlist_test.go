@@ -0,0 +1,257 @@
+package playlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPlaylistRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlaylistRepository) Create(ctx context.Context, playlist Playlist) (*Playlist, error) {
+	args := m.Called(ctx, playlist)
+	return args.Get(0).(*Playlist), args.Error(1)
+}
+
+func (m *MockPlaylistRepository) Update(ctx context.Context, playlist Playlist) (*Playlist, error) {
+	args := m.Called(ctx, playlist)
+	return args.Get(0).(*Playlist), args.Error(1)
+}
+
+func (m *MockPlaylistRepository) Delete(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPlaylistRepository) FindById(ctx context.Context, id uint) (*Playlist, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Playlist), args.Error(1)
+}
+
+func (m *MockPlaylistRepository) FindVisibleTo(ctx context.Context, user string) ([]*Playlist, error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).([]*Playlist), args.Error(1)
+}
+
+func (m *MockPlaylistRepository) SetItems(ctx context.Context, id uint, items []Item) error {
+	args := m.Called(ctx, id, items)
+	return args.Error(0)
+}
+
+func soundboard() *Playlist {
+	return &Playlist{
+		ID:     1,
+		Name:   "Stream soundboard",
+		Owner:  "phllp",
+		Public: true,
+		Items: []Item{
+			{Position: 1, FileID: 10, Hotkey: "F1"},
+			{Position: 2, FileID: 20},
+			{Position: 3, FileID: 30},
+		},
+	}
+}
+
+func TestPlaylistService_Create(t *testing.T) {
+	testCases := []struct {
+		name          string
+		playlist      Playlist
+		expectedSaved Playlist
+		returnedError error
+		expectedError error
+	}{
+		{
+			name:          "WithItems",
+			playlist:      Playlist{Name: " Soundboard ", Items: []Item{{Position: 7, FileID: 10, Hotkey: " F1 "}, {FileID: 20}}},
+			expectedSaved: Playlist{Name: "Soundboard", Owner: "phllp", Items: []Item{{Position: 1, FileID: 10, Hotkey: "F1"}, {Position: 2, FileID: 20}}},
+		},
+		{
+			name:          "UnknownFile",
+			playlist:      Playlist{Name: "Soundboard", Items: []Item{{FileID: 99}}},
+			expectedSaved: Playlist{Name: "Soundboard", Owner: "phllp", Items: []Item{{Position: 1, FileID: 99}}},
+			returnedError: ErrUnknownFile,
+			expectedError: ErrUnknownFile,
+		},
+		{
+			name:          "NameMissing",
+			playlist:      Playlist{Name: " "},
+			expectedError: ErrNameMissing,
+		},
+		{
+			name:          "NameTooLong",
+			playlist:      Playlist{Name: strings.Repeat("a", MaxNameLength+1)},
+			expectedError: ErrNameTooLong,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockPlaylistRepository)
+			service := NewPlaylistService(repo)
+			if tc.expectedSaved.Name != "" {
+				// the playlist and its items are created in one go
+				repo.On("Create", ctx, tc.expectedSaved).Return(&Playlist{ID: 1}, tc.returnedError)
+			}
+			if tc.expectedError == nil {
+				repo.On("FindById", ctx, uint(1)).Return(&Playlist{ID: 1}, nil)
+			}
+
+			_, err := service.Create(ctx, "phllp", tc.playlist)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+			repo.AssertExpectations(t)
+			repo.AssertNotCalled(t, "SetItems")
+		})
+	}
+}
+
+func TestPlaylistService_AddItem(t *testing.T) {
+	testCases := []struct {
+		name          string
+		user          string
+		item          Item
+		expectedItems []Item
+		expectedError error
+	}{
+		{
+			name:          "Appended",
+			user:          "phllp",
+			item:          Item{FileID: 40},
+			expectedItems: []Item{{Position: 1, FileID: 10, Hotkey: "F1"}, {Position: 2, FileID: 20}, {Position: 3, FileID: 30}, {Position: 4, FileID: 40}},
+		},
+		{
+			name:          "InsertedAtPosition",
+			user:          "phllp",
+			item:          Item{Position: 2, FileID: 40, Hotkey: "F2"},
+			expectedItems: []Item{{Position: 1, FileID: 10, Hotkey: "F1"}, {Position: 2, FileID: 40, Hotkey: "F2"}, {Position: 3, FileID: 20}, {Position: 4, FileID: 30}},
+		},
+		{
+			name:          "DuplicateHotkey",
+			user:          "phllp",
+			item:          Item{FileID: 40, Hotkey: "f1"},
+			expectedError: ErrDuplicateHotkey,
+		},
+		{
+			name:          "HotkeyTooLong",
+			user:          "phllp",
+			item:          Item{FileID: 40, Hotkey: strings.Repeat("x", MaxHotkeyLength+1)},
+			expectedError: ErrHotkeyTooLong,
+		},
+		{
+			name:          "NotOwner",
+			user:          "someone-else",
+			item:          Item{FileID: 40},
+			expectedError: ErrNotOwner,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockPlaylistRepository)
+			service := NewPlaylistService(repo)
+			repo.On("FindById", ctx, uint(1)).Return(soundboard(), nil)
+			if tc.expectedItems != nil {
+				repo.On("SetItems", ctx, uint(1), tc.expectedItems).Return(nil)
+			}
+
+			_, err := service.AddItem(ctx, tc.user, 1, tc.item)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPlaylistService_ReplaceItems(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPlaylistRepository)
+	service := NewPlaylistService(repo)
+	repo.On("FindById", ctx, uint(1)).Return(soundboard(), nil)
+	repo.On("SetItems", ctx, uint(1), []Item{{Position: 1, FileID: 30, Hotkey: "F1"}, {Position: 2, FileID: 10}}).Return(nil)
+
+	_, err := service.ReplaceItems(ctx, "phllp", 1, []Item{{Position: 5, FileID: 10}, {Position: 2, FileID: 30, Hotkey: " F1 "}})
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestPlaylistService_FindById(t *testing.T) {
+	private := soundboard()
+	private.Public = false
+
+	testCases := []struct {
+		name          string
+		user          string
+		found         *Playlist
+		expectedError error
+	}{
+		{name: "PublicToAnyone", user: "", found: soundboard()},
+		{name: "PrivateToOwner", user: "phllp", found: private},
+		{name: "PrivateToOthers", user: "someone-else", found: private, expectedError: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockPlaylistRepository)
+			service := NewPlaylistService(repo)
+			repo.On("FindById", ctx, uint(1)).Return(tc.found, nil)
+
+			result, err := service.FindById(ctx, tc.user, 1)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.found, result)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	playlist := &Playlist{
+		Name:  "Soundboard",
+		Owner: "phllp",
+		Items: []Item{
+			{Position: 1, FileID: 1, Title: "Hello", Artist: "Phllp", Hotkey: "F1"},
+			{Position: 2, FileID: 2, Filename: "bye.mp3"},
+		},
+	}
+	streamURL := func(fileID uint) string {
+		return fmt.Sprintf("http://localhost:9090/audio/%d/stream", fileID)
+	}
+
+	t.Run("M3U8", func(t *testing.T) {
+		expected := "#EXTM3U\n" +
+			"#PLAYLIST:Soundboard\n" +
+			"#EXTINF:-1,Phllp - Hello\n" +
+			"http://localhost:9090/audio/1/stream\n" +
+			"#EXTINF:-1,bye.mp3\n" +
+			"http://localhost:9090/audio/2/stream\n"
+
+		assert.Equal(t, expected, string(ExportM3U8(playlist, streamURL)))
+	})
+
+	t.Run("XSPF", func(t *testing.T) {
+		document, err := ExportXSPF(playlist, streamURL)
+
+		assert.NoError(t, err)
+		assert.Contains(t, string(document), `<playlist xmlns="http://xspf.org/ns/0/" version="1">`)
+		assert.Contains(t, string(document), "<location>http://localhost:9090/audio/1/stream</location>")
+		assert.Contains(t, string(document), "<annotation>F1</annotation>")
+		assert.Contains(t, string(document), "<trackNum>2</trackNum>")
+	})
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many calls go by between removing the buckets nobody has used in a while
+const sweepEvery = 1000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryLimiter keeps the buckets in memory. Limits aren't shared between instances
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.capacity(), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = refill(limit, b.tokens, b.updated, now)
+	b.updated = now
+	b.limit = limit
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, allowed, b.tokens), nil
+}
+
+// sweep drops the buckets that have filled up again, they're the same as new ones
+func (m *MemoryLimiter) sweep(now time.Time) {
+	m.calls++
+	if m.calls < sweepEvery {
+		return
+	}
+	m.calls = 0
+	for key, b := range m.buckets {
+		if refill(b.limit, b.tokens, b.updated, now) >= b.limit.capacity() {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// MemoryQuota counts uploads in memory. Counts are lost on restart
+type MemoryQuota struct {
+	mu    sync.Mutex
+	day   string
+	usage map[string]Usage
+	now   func() time.Time
+}
+
+func NewMemoryQuota() *MemoryQuota {
+	return &MemoryQuota{usage: map[string]Usage{}, now: time.Now}
+}
+
+// today returns the usage of the current day, starting over once it's a new one
+func (m *MemoryQuota) today() (map[string]Usage, time.Duration) {
+	today, reset := day(m.now())
+	if today != m.day {
+		m.day = today
+		m.usage = map[string]Usage{}
+	}
+	return m.usage, reset
+}
+
+func (m *MemoryQuota) Consume(ctx context.Context, key string, size int64, limit QuotaLimit) (QuotaResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, reset := m.today()
+
+	current := usage[key]
+	if limit.exceeds(current, size) {
+		return QuotaResult{Allowed: false, Usage: current, Reset: reset}, nil
+	}
+	current.Uploads++
+	current.Bytes += size
+	usage[key] = current
+	return QuotaResult{Allowed: true, Usage: current, Reset: reset}, nil
+}
+
+func (m *MemoryQuota) Refund(ctx context.Context, key string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage, _ := m.today()
+
+	current, ok := usage[key]
+	if !ok {
+		return nil
+	}
+	if current.Uploads > 0 {
+		current.Uploads--
+	}
+	current.Bytes -= size
+	if current.Bytes < 0 {
+		current.Bytes = 0
+	}
+	usage[key] = current
+	return nil
+}
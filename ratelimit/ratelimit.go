@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket holding up to Burst tokens, refilled at Requests per Period. Every
+// request takes a token
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	// Burst defaults to Requests
+	Burst int
+}
+
+// Unlimited reports whether the limit lets everything through
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate is the number of tokens added per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of a caller's bucket after a request
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining is the number of requests that can be made right away
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this one wasn't
+	RetryAfter time.Duration
+}
+
+// newResult describes a bucket left with the given tokens
+func newResult(limit Limit, allowed bool, tokens float64) Result {
+	capacity, rate := limit.capacity(), limit.rate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int(capacity),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((capacity - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+// refill returns the tokens in a bucket last updated at the given time
+func refill(limit Limit, tokens float64, updated, now time.Time) float64 {
+	elapsed := now.Sub(updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(limit.capacity(), tokens+elapsed*limit.rate())
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Limiter takes a token from the bucket of the key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// QuotaLimit caps what an uploader can upload per day. Zero values aren't capped
+type QuotaLimit struct {
+	Uploads int
+	Bytes   int64
+}
+
+// Unlimited reports whether the quota lets everything through
+func (q QuotaLimit) Unlimited() bool {
+	return q.Uploads <= 0 && q.Bytes <= 0
+}
+
+// Usage is what an uploader has uploaded today
+type Usage struct {
+	Uploads int
+	Bytes   int64
+}
+
+// QuotaResult tells whether an upload fits in the uploader's quota
+type QuotaResult struct {
+	Allowed bool
+	Usage   Usage
+	// Reset is how long until the quota is renewed, at midnight UTC
+	Reset time.Duration
+}
+
+// exceeds reports whether one more upload of the size would go over the quota
+func (q QuotaLimit) exceeds(usage Usage, size int64) bool {
+	return (q.Uploads > 0 && usage.Uploads+1 > q.Uploads) || (q.Bytes > 0 && usage.Bytes+size > q.Bytes)
+}
+
+// Quota counts the uploads of every uploader per day
+type Quota interface {
+	// Consume counts an upload of the size, unless it doesn't fit in what's left of the quota
+	Consume(ctx context.Context, key string, size int64, limit QuotaLimit) (QuotaResult, error)
+	// Refund gives back an upload that was counted but failed
+	Refund(ctx context.Context, key string, size int64) error
+}
+
+// day is the UTC date usage is counted under, and how long until the next one starts
+func day(now time.Time) (string, time.Duration) {
+	now = now.UTC()
+	midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	return now.Format(time.DateOnly), midnight.Sub(now)
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is moved by the tests
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newRedisClient(t *testing.T) redis.Cmdable {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// limiters returns each backend, reading the time from the clock
+func limiters(t *testing.T, c *clock) map[string]Limiter {
+	memory := NewMemoryLimiter()
+	memory.now = c.Now
+	redisLimiter := NewRedisLimiter(newRedisClient(t))
+	redisLimiter.now = c.Now
+	return map[string]Limiter{"Memory": memory, "Redis": redisLimiter}
+}
+
+func quotas(t *testing.T, c *clock) map[string]Quota {
+	memory := NewMemoryQuota()
+	memory.now = c.Now
+	redisQuota := NewRedisQuota(newRedisClient(t))
+	redisQuota.now = c.Now
+	return map[string]Quota{"Memory": memory, "Redis": redisQuota}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	// 60 a minute is one a second, with bursts of 3
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 3}
+	c := &clock{now: time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)}
+
+	for name, limiter := range limiters(t, c) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c.now = time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)
+
+			for remaining := 2; remaining >= 0; remaining-- {
+				result, err := limiter.Allow(ctx, "alice", limit)
+				assert.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 3, result.Limit)
+				assert.Equal(t, remaining, result.Remaining)
+			}
+
+			result, err := limiter.Allow(ctx, "alice", limit)
+			assert.NoError(t, err)
+			assert.False(t, result.Allowed, "the burst is used up")
+			assert.Equal(t, time.Second, result.RetryAfter)
+			assert.Equal(t, 3*time.Second, result.Reset)
+
+			// others have buckets of their own
+			result, err = limiter.Allow(ctx, "bob", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+
+			c.now = c.now.Add(time.Second)
+			result, err = limiter.Allow(ctx, "alice", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed, "a token was added after a second")
+			assert.Equal(t, 0, result.Remaining)
+
+			result, err = limiter.Allow(ctx, "alice", Limit{})
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed, "an empty limit lets everything through")
+		})
+	}
+}
+
+func TestQuota_Consume(t *testing.T) {
+	limit := QuotaLimit{Uploads: 3, Bytes: 100}
+	c := &clock{}
+
+	for name, quota := range quotas(t, c) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c.now = time.Date(2024, 1, 13, 18, 0, 0, 0, time.UTC)
+
+			result, err := quota.Consume(ctx, "alice", 60, limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, Usage{Uploads: 1, Bytes: 60}, result.Usage)
+			assert.Equal(t, 6*time.Hour, result.Reset)
+
+			result, err = quota.Consume(ctx, "alice", 50, limit)
+			assert.NoError(t, err)
+			assert.False(t, result.Allowed, "the bytes would go over the quota")
+			assert.Equal(t, Usage{Uploads: 1, Bytes: 60}, result.Usage)
+
+			for i := 0; i < 2; i++ {
+				result, err = quota.Consume(ctx, "alice", 10, limit)
+				assert.NoError(t, err)
+				assert.True(t, result.Allowed)
+			}
+			result, err = quota.Consume(ctx, "alice", 0, limit)
+			assert.NoError(t, err)
+			assert.False(t, result.Allowed, "the uploads would go over the quota")
+
+			// a failed upload is given back
+			assert.NoError(t, quota.Refund(ctx, "alice", 10))
+			result, err = quota.Consume(ctx, "alice", 10, limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, Usage{Uploads: 3, Bytes: 80}, result.Usage)
+
+			c.now = c.now.Add(6 * time.Hour)
+			result, err = quota.Consume(ctx, "alice", 60, limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed, "the quota is renewed at midnight")
+			assert.Equal(t, Usage{Uploads: 1, Bytes: 60}, result.Usage)
+		})
+	}
+}
+
+func TestMemoryLimiter_Sweep(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter()
+	limiter.now = c.Now
+	limit := Limit{Requests: 1, Period: time.Second}
+
+	limiter.Allow(context.Background(), "alice", limit)
+	c.now = c.now.Add(time.Minute)
+	for i := 0; i < sweepEvery; i++ {
+		limiter.Allow(context.Background(), "bob", limit)
+	}
+
+	assert.NotContains(t, limiter.buckets, "alice", "full buckets are dropped")
+	assert.Contains(t, limiter.buckets, "bob")
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "voice-quips:"
+
+// tokenBucket refills and takes from the bucket in one step, so that instances sharing it
+// can't both take the last token. Floats don't survive being returned by a script, the tokens
+// left are returned as a string
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+
+local elapsed = math.max(0, now - updated) / 1000
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// consumeQuota counts the upload unless it goes over one of the caps, 0 meaning uncapped
+var consumeQuota = redis.NewScript(`
+local maxUploads = tonumber(ARGV[1])
+local maxBytes = tonumber(ARGV[2])
+local size = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'uploads', 'bytes')
+local uploads = tonumber(state[1]) or 0
+local bytes = tonumber(state[2]) or 0
+
+if (maxUploads > 0 and uploads + 1 > maxUploads) or (maxBytes > 0 and bytes + size > maxBytes) then
+	return {0, uploads, bytes}
+end
+
+uploads = redis.call('HINCRBY', KEYS[1], 'uploads', 1)
+bytes = redis.call('HINCRBY', KEYS[1], 'bytes', size)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {1, uploads, bytes}
+`)
+
+// refundQuota takes an upload back off the count. The day may have ended since, in which case
+// there's nothing to refund
+var refundQuota = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('HINCRBY', KEYS[1], 'uploads', -1) < 0 then
+	redis.call('HSET', KEYS[1], 'uploads', 0)
+end
+if redis.call('HINCRBY', KEYS[1], 'bytes', -tonumber(ARGV[1])) < 0 then
+	redis.call('HSET', KEYS[1], 'bytes', 0)
+end
+return 1
+`)
+
+// RedisLimiter keeps the buckets in Redis, sharing the limits between instances
+type RedisLimiter struct {
+	client redis.Cmdable
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client redis.Cmdable) *RedisLimiter {
+	return &RedisLimiter{client: client, now: time.Now}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	reply, err := tokenBucket.Run(ctx, r.client, []string{keyPrefix + "ratelimit:" + key},
+		limit.capacity(), limit.rate(), r.now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := reply[0].(int64)
+	tokens, err := strconv.ParseFloat(reply[1].(string), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, allowed == 1, tokens), nil
+}
+
+// RedisQuota counts uploads in Redis, sharing the quotas between instances
+type RedisQuota struct {
+	client redis.Cmdable
+	now    func() time.Time
+}
+
+func NewRedisQuota(client redis.Cmdable) *RedisQuota {
+	return &RedisQuota{client: client, now: time.Now}
+}
+
+func (r *RedisQuota) key(key string) (string, time.Duration) {
+	today, reset := day(r.now())
+	return keyPrefix + "quota:" + key + ":" + today, reset
+}
+
+func (r *RedisQuota) Consume(ctx context.Context, key string, size int64, limit QuotaLimit) (QuotaResult, error) {
+	redisKey, reset := r.key(key)
+	// kept a little past midnight so that clocks running behind don't start the day over
+	expiry := (reset + time.Hour).Milliseconds()
+
+	reply, err := consumeQuota.Run(ctx, r.client, []string{redisKey}, limit.Uploads, limit.Bytes, size, expiry).Slice()
+	if err != nil {
+		return QuotaResult{}, err
+	}
+	allowed, _ := reply[0].(int64)
+	uploads, _ := reply[1].(int64)
+	bytes, _ := reply[2].(int64)
+	return QuotaResult{Allowed: allowed == 1, Usage: Usage{Uploads: int(uploads), Bytes: bytes}, Reset: reset}, nil
+}
+
+func (r *RedisQuota) Refund(ctx context.Context, key string, size int64) error {
+	redisKey, _ := r.key(key)
+	return refundQuota.Run(ctx, r.client, []string{redisKey}, size).Err()
+}
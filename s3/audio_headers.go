@@ -1,6 +1,11 @@
 package s3
 
-import "strings"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
 
 // Constants for the content-type headers for audio files
 const (
@@ -33,3 +38,12 @@ func GetContentType(ext string) string {
 		return "application/octet-stream" // unknown format
 	}
 }
+
+// NewObjectName returns a unique object name for an audio file, keeping the file's extension
+func NewObjectName(filename string) string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return "audio/" + hex.EncodeToString(id) + strings.ToLower(filepath.Ext(filename))
+}
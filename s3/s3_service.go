@@ -2,12 +2,17 @@ package s3
 
 import (
 	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	log "log/slog"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 	"github.com/minio/minio-go/v7"
 )
 
@@ -26,10 +31,55 @@ type DownloadUploader interface {
 	Downloader
 }
 
-// S3Client is a struct that implements the DownloadUploader interface using AWS's S3 SDK for Go
+// Object is an object read from S3 storage. Body must be closed by the caller
+type Object struct {
+	Body         io.ReadCloser
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Streamer defines the API for moving objects in and out of S3 storage without holding them in memory
+type Streamer interface {
+	UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error
+	StreamObject(ctx context.Context, objectName, bucket string) (*Object, error)
+}
+
+// Storage defines everything the API needs from S3 storage
+type Storage interface {
+	DownloadUploader
+	Streamer
+}
+
+// BucketChecker reports whether a bucket exists, readiness checks use it
+type BucketChecker interface {
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+}
+
+// Usage is how much of a bucket is taken up
+type Usage struct {
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// UsageReporter adds up the objects of a bucket. Every object is listed, so it's slow on large buckets
+type UsageReporter interface {
+	Usage(ctx context.Context, bucket string) (Usage, error)
+}
+
+// S3API is the part of AWS's S3 client used by S3Client
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3Client is a struct that implements the Storage interface using AWS's S3 SDK for Go
 type S3Client struct {
 	// S3Client is the service client for Amazon S3
-	S3Client *s3.Client
+	S3Client S3API
 }
 
 func New(client *s3.Client) *S3Client {
@@ -38,12 +88,9 @@ func New(client *s3.Client) *S3Client {
 
 // UploadObject uploads to an AWS bucket with the given file
 func (a *S3Client) UploadObject(ctx context.Context, filename, bucket string) error {
-	var uploadError *UploadError
-
 	file, err := os.Open(filename)
 	if err != nil {
-		uploadError.Err = err
-		return uploadError
+		return &UploadError{Err: err}
 	}
 
 	defer file.Close()
@@ -59,40 +106,96 @@ func (a *S3Client) UploadObject(ctx context.Context, filename, bucket string) er
 		ContentType: &contentType,
 	})
 	if err != nil {
-		uploadError.Err = err
-		return uploadError
+		return &UploadError{Err: err}
 	}
 
-	log.Debug("response from object being uploaded", "metadata", response.ResultMetadata)
+	log.DebugContext(ctx, "response from object being uploaded", "metadata", response.ResultMetadata)
 	return nil
 }
 
-// DownloadObject downloads from the given file an AWS bucket
-func (a *S3Client) DownloadObject(ctx context.Context, objectName, bucket string) error {
-	var downloadError *DownloadError
+// UploadStream uploads the content read from body to an AWS bucket under the given object name
+func (a *S3Client) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	response, err := a.S3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &bucket,
+		Key:           &objectName,
+		Body:          body,
+		ContentLength: size,
+		ContentType:   &contentType,
+	})
+	if err != nil {
+		return &UploadError{Err: err}
+	}
+
+	log.DebugContext(ctx, "response from object being uploaded", "metadata", response.ResultMetadata)
+	return nil
+}
 
-	file, err := os.Open(objectName)
+// DownloadObject downloads from the given file an AWS bucket
+func (a *S3Client) DownloadObject(ctx context.Context, objectName, bucket string) ([]byte, error) {
+	object, err := a.StreamObject(ctx, objectName, bucket)
 	if err != nil {
-		downloadError.Err = err
-		return downloadError
+		return nil, err
 	}
+	defer object.Body.Close()
 
-	defer file.Close()
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, &DownloadError{Err: err}
+	}
+	return data, nil
+}
 
+// StreamObject opens an object in an AWS bucket for reading
+func (a *S3Client) StreamObject(ctx context.Context, objectName, bucket string) (*Object, error) {
 	response, err := a.S3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &bucket,
 		Key:    &objectName,
 	})
 	if err != nil {
-		downloadError.Err = err
-		return downloadError
+		return nil, &DownloadError{Err: err}
 	}
 
-	log.Debug("response from object being uploaded", "metadata", response.ResultMetadata)
-	return nil
+	log.DebugContext(ctx, "response from object being downloaded", "metadata", response.ResultMetadata)
+	return &Object{
+		Body:         response.Body,
+		Size:         response.ContentLength,
+		ContentType:  aws.ToString(response.ContentType),
+		ETag:         aws.ToString(response.ETag),
+		LastModified: aws.ToTime(response.LastModified),
+	}, nil
+}
+
+// BucketExists reports whether the AWS bucket exists
+func (a *S3Client) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	_, err := a.S3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchBucket") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Usage adds up the objects of the AWS bucket, a page at a time
+func (a *S3Client) Usage(ctx context.Context, bucket string) (Usage, error) {
+	var usage Usage
+	pages := s3.NewListObjectsV2Paginator(a.S3Client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return Usage{}, err
+		}
+		for _, object := range page.Contents {
+			usage.Objects++
+			usage.Bytes += object.Size
+		}
+	}
+	return usage, nil
 }
 
-// MinioClient is a struct that implements the DownloadUploader interface using MinIO's S3 SDK for Go
+// MinioClient is a struct that implements the Storage interface using MinIO's S3 SDK for Go
 type MinioClient struct {
 	// S3Client is the service client for MinIO
 	S3Client *minio.Client
@@ -104,12 +207,9 @@ func NewMinioClient(client *minio.Client) *MinioClient {
 
 // UploadObject uploads to an MinIO bucket with the given file
 func (uploader *MinioClient) UploadObject(ctx context.Context, filename, bucket string) error {
-	var uploadError *UploadError
-
 	file, err := os.Open(filename)
 	if err != nil {
-		uploadError.Err = err
-		return uploadError
+		return &UploadError{Err: err}
 	}
 
 	defer file.Close()
@@ -120,49 +220,85 @@ func (uploader *MinioClient) UploadObject(ctx context.Context, filename, bucket
 
 	info, err := file.Stat()
 	if err != nil {
-		uploadError.Err = err
-		return uploadError
+		return &UploadError{Err: err}
 	}
 
+	return uploader.UploadStream(ctx, filename, bucket, file, info.Size(), contentType)
+}
+
+// UploadStream uploads the content read from body to a MinIO bucket under the given object name
+func (uploader *MinioClient) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
 	response, err := uploader.S3Client.PutObject(
 		ctx,
 		bucket,
-		filename,
-		file,
-		info.Size(),
+		objectName,
+		body,
+		size,
 		minio.PutObjectOptions{ContentType: contentType},
 	)
 	if err != nil {
-		uploadError.Err = err
-		return uploadError
+		return &UploadError{Err: err}
 	}
 
-	log.Debug("response from object being uploaded", "metadata", response)
+	log.DebugContext(ctx, "response from object being uploaded", "metadata", response)
 	return nil
 }
 
 // DownloadObject downloads an object from a MinIO bucket with the given object name
-// TODO this needs to return the file/data received
 func (m *MinioClient) DownloadObject(ctx context.Context, objectName, bucket string) ([]byte, error) {
-	var downloadError *DownloadError
-	data := make([]byte, 1)
-	file, err := os.Open(objectName)
+	object, err := m.StreamObject(ctx, objectName, bucket)
 	if err != nil {
-		downloadError.Err = err
-		return data, downloadError
+		return nil, err
 	}
-	defer file.Close()
-
+	defer object.Body.Close()
 
-	opts := minio.GetObjectOptions{}
-	response, err := m.S3Client.GetObject(ctx, bucket, objectName, opts)
+	data, err := io.ReadAll(object.Body)
 	if err != nil {
-		downloadError.Err = err
-		return data, downloadError
+		return nil, &DownloadError{Err: err}
 	}
-	
-	defer response.Close()
-	log.Debug("completed download reqeust", "bucket", bucket, "file", objectName)
-	// TODO does minio have easily accessible metadata on downloads
+
+	log.DebugContext(ctx, "completed download reqeust", "bucket", bucket, "file", objectName)
 	return data, nil
 }
+
+// StreamObject opens an object in a MinIO bucket for reading. The returned Body is also an
+// io.ReadSeeker so range requests can be served from it
+func (m *MinioClient) StreamObject(ctx context.Context, objectName, bucket string) (*Object, error) {
+	response, err := m.S3Client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, &DownloadError{Err: err}
+	}
+
+	// MinIO doesn't reach out to the server until the object is used, stat-ing it surfaces missing objects here
+	info, err := response.Stat()
+	if err != nil {
+		response.Close()
+		return nil, &DownloadError{Err: err}
+	}
+
+	return &Object{
+		Body:         response,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// BucketExists reports whether the MinIO bucket exists
+func (m *MinioClient) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return m.S3Client.BucketExists(ctx, bucket)
+}
+
+// Usage adds up the objects of the MinIO bucket
+func (m *MinioClient) Usage(ctx context.Context, bucket string) (Usage, error) {
+	var usage Usage
+	for object := range m.S3Client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return Usage{}, object.Err
+		}
+		usage.Objects++
+		usage.Bytes += object.Size
+	}
+	return usage, nil
+}
@@ -3,9 +3,16 @@ package s3
 import (
 	"context"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,36 +21,58 @@ type MockS3Client struct {
 	mock.Mock
 }
 
-func (m *MockS3Client) PutObject(ctx context.Context, input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+func (m *MockS3Client) PutObject(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 	args := m.Called(ctx, input)
-	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
+	output, _ := args.Get(0).(*s3.PutObjectOutput)
+	return output, args.Error(1)
 }
 
-func (m *MockS3Client) GetObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+func (m *MockS3Client) GetObject(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 	args := m.Called(ctx, input)
-	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+	output, _ := args.Get(0).(*s3.GetObjectOutput)
+	return output, args.Error(1)
+}
+
+func (m *MockS3Client) HeadBucket(ctx context.Context, input *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	args := m.Called(ctx, input)
+	output, _ := args.Get(0).(*s3.HeadBucketOutput)
+	return output, args.Error(1)
+}
+
+func (m *MockS3Client) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	args := m.Called(ctx, input)
+	output, _ := args.Get(0).(*s3.ListObjectsV2Output)
+	return output, args.Error(1)
 }
 
 func TestAWSClient_UploadObject(t *testing.T) {
+	// UploadObject reads the file from disk
+	filename := filepath.Join(t.TempDir(), "example.mp3")
+	err := os.WriteFile(filename, []byte("quip"), 0o644)
+	assert.NoError(t, err)
+
 	testCases := []struct {
 		name          string
 		mockS3Client  *MockS3Client
 		filename      string
 		bucket        string
+		returnedError error
 		expectedError bool
 	}{
 		{
 			name:          "SuccessfulUpload",
 			mockS3Client:  new(MockS3Client),
-			filename:      "example.txt",
+			filename:      filename,
 			bucket:        "my-bucket",
+			returnedError: nil,
 			expectedError: false,
 		},
 		{
 			name:          "UploadError",
 			mockS3Client:  new(MockS3Client),
-			filename:      "example.txt",
+			filename:      filename,
 			bucket:        "my-bucket",
+			returnedError: errors.New("upload failed"),
 			expectedError: true,
 		},
 		// Add more test cases as needed
@@ -51,12 +80,14 @@ func TestAWSClient_UploadObject(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			tc.mockS3Client.On("PutObject", mock.Anything, mock.AnythingOfType("*s3.PutObjectInput")).Return(&s3.PutObjectOutput{}, tc.returnedError)
 			client := &S3Client{S3Client: tc.mockS3Client}
 
 			err := client.UploadObject(context.Background(), tc.filename, tc.bucket)
 
 			if tc.expectedError {
-				assert.Error(t, err)
+				var uploadError *UploadError
+				assert.ErrorAs(t, err, &uploadError)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -64,30 +95,42 @@ func TestAWSClient_UploadObject(t *testing.T) {
 	}
 }
 
+func TestAWSClient_UploadObject_MissingFile(t *testing.T) {
+	client := &S3Client{S3Client: new(MockS3Client)}
+
+	err := client.UploadObject(context.Background(), filepath.Join(t.TempDir(), "missing.mp3"), "my-bucket")
+
+	var uploadError *UploadError
+	assert.ErrorAs(t, err, &uploadError)
+}
+
 func TestAWSClient_DownloadObject(t *testing.T) {
 	testCases := []struct {
 		name          string
 		mockS3Client  *MockS3Client
 		objectName    string
 		bucket        string
+		output        *s3.GetObjectOutput
+		returnedError error
+		expectedData  []byte
 		expectedError bool
 	}{
 		{
-			name: "SuccessfulDownload",
-			mockS3Client: &MockS3Client{
-				On("GetObject", mock.Anything, mock.AnythingOfType("*s3.GetObjectInput")).Return(&s3.GetObjectOutput{}, nil),
-			},
-			objectName:    "example.txt",
+			name:          "SuccessfulDownload",
+			mockS3Client:  new(MockS3Client),
+			objectName:    "example.mp3",
 			bucket:        "my-bucket",
+			output:        &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("quip"))},
+			expectedData:  []byte("quip"),
 			expectedError: false,
 		},
 		{
-			name: "DownloadError",
-			mockS3Client: &MockS3Client{
-				On("GetObject", mock.Anything, mock.AnythingOfType("*s3.GetObjectInput")).Return(nil, errors.New("download failed")),
-			},
-			objectName:    "example.txt",
+			name:          "DownloadError",
+			mockS3Client:  new(MockS3Client),
+			objectName:    "example.mp3",
 			bucket:        "my-bucket",
+			output:        nil,
+			returnedError: errors.New("download failed"),
 			expectedError: true,
 		},
 		// Add more test cases as needed
@@ -95,15 +138,69 @@ func TestAWSClient_DownloadObject(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			tc.mockS3Client.On("GetObject", mock.Anything, mock.AnythingOfType("*s3.GetObjectInput")).Return(tc.output, tc.returnedError)
 			client := &S3Client{S3Client: tc.mockS3Client}
 
-			err := client.DownloadObject(context.Background(), tc.objectName, tc.bucket)
+			data, err := client.DownloadObject(context.Background(), tc.objectName, tc.bucket)
+
+			if tc.expectedError {
+				var downloadError *DownloadError
+				assert.ErrorAs(t, err, &downloadError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedData, data)
+			}
+		})
+	}
+}
+
+func TestAWSClient_BucketExists(t *testing.T) {
+	testCases := []struct {
+		name          string
+		returnedError error
+		expected      bool
+		expectedError bool
+	}{
+		{name: "Exists", expected: true},
+		{name: "Missing", returnedError: &smithy.GenericAPIError{Code: "NotFound"}, expected: false},
+		{name: "Unreachable", returnedError: errors.New("connection refused"), expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockS3Client := new(MockS3Client)
+			mockS3Client.On("HeadBucket", mock.Anything, mock.AnythingOfType("*s3.HeadBucketInput")).Return(&s3.HeadBucketOutput{}, tc.returnedError)
+			client := &S3Client{S3Client: mockS3Client}
+
+			exists, err := client.BucketExists(context.Background(), "my-bucket")
 
 			if tc.expectedError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 			}
+			assert.Equal(t, tc.expected, exists)
 		})
 	}
 }
+
+func TestAWSClient_Usage(t *testing.T) {
+	mockS3Client := new(MockS3Client)
+	mockS3Client.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return input.ContinuationToken == nil
+	})).Return(&s3.ListObjectsV2Output{
+		Contents:              []types.Object{{Size: 10}, {Size: 20}},
+		IsTruncated:           true,
+		NextContinuationToken: aws.String("page-2"),
+	}, nil)
+	mockS3Client.On("ListObjectsV2", mock.Anything, mock.MatchedBy(func(input *s3.ListObjectsV2Input) bool {
+		return aws.ToString(input.ContinuationToken) == "page-2"
+	})).Return(&s3.ListObjectsV2Output{Contents: []types.Object{{Size: 5}}}, nil)
+	client := &S3Client{S3Client: mockS3Client}
+
+	usage, err := client.Usage(context.Background(), "my-bucket")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Usage{Objects: 3, Bytes: 35}, usage)
+	mockS3Client.AssertNumberOfCalls(t, "ListObjectsV2", 2)
+}
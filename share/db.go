@@ -0,0 +1,116 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+)
+
+const linkColumns = "id, file_id, owner, max_downloads, downloads, expires_at, created_at, revoked_at"
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a share link store sharing the connection pool of the file_info store.
+// Its tables are created by the file_info store's migrations
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row rowScanner) (*Link, error) {
+	var link Link
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&link.ID,
+		&link.FileID,
+		&link.Owner,
+		&link.MaxDownloads,
+		&link.Downloads,
+		&link.ExpiresAt,
+		&link.CreatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if revokedAt.Valid {
+		link.RevokedAt = &revokedAt.Time
+	}
+	return &link, nil
+}
+
+func (p *PostgresStore) Create(ctx context.Context, link Link) (*Link, error) {
+	log.Debug("Inserting a share link into the DB", "file", link.FileID, "owner", link.Owner)
+	insertStmt := `INSERT INTO share_links (file_id, owner, max_downloads, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + linkColumns
+
+	saved, err := scanLink(p.db.QueryRowContext(ctx, insertStmt, link.FileID, link.Owner, link.MaxDownloads, link.ExpiresAt, link.CreatedAt))
+	if err != nil {
+		log.Error("An error occurred while inserting share link", "err", err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return nil, ErrUnknownFile
+		}
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (p *PostgresStore) FindById(ctx context.Context, id uint) (*Link, error) {
+	selectStmt := "SELECT " + linkColumns + " FROM share_links WHERE id = $1"
+	return scanLink(p.db.QueryRowContext(ctx, selectStmt, id))
+}
+
+// FindByOwner returns the links the user created, newest first
+func (p *PostgresStore) FindByOwner(ctx context.Context, owner string) ([]*Link, error) {
+	links := []*Link{}
+	selectStmt := "SELECT " + linkColumns + " FROM share_links WHERE owner = $1 ORDER BY created_at DESC, id DESC"
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (p *PostgresStore) Revoke(ctx context.Context, id uint, at time.Time) error {
+	log.Debug("Revoking share link", "id", id)
+	_, err := p.db.ExecContext(ctx, "UPDATE share_links SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL", id, at)
+	if err != nil {
+		log.Error("An error occurred while revoking share link", "err", err, "id", id)
+		return err
+	}
+	return nil
+}
+
+// Use counts a download in the same statement that checks the link can still be used, so
+// concurrent downloads can't go over the limit
+func (p *PostgresStore) Use(ctx context.Context, id uint, at time.Time) (*Link, error) {
+	updateStmt := `UPDATE share_links SET downloads = downloads + 1
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > $2
+			AND (max_downloads = 0 OR downloads < max_downloads)
+		RETURNING ` + linkColumns
+
+	return scanLink(p.db.QueryRowContext(ctx, updateStmt, id, at))
+}
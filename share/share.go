@@ -0,0 +1,222 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long links last when their creator doesn't say
+	DefaultTTL = 7 * 24 * time.Hour
+	// DefaultMaxTTL is the longest links can last unless configured otherwise
+	DefaultMaxTTL = 30 * 24 * time.Hour
+)
+
+var ErrNotFound = errors.New("share link not found")
+var ErrNotOwner = errors.New("only the link's creator can revoke it")
+var ErrUnknownFile = errors.New("share link refers to a quip that doesn't exist")
+var ErrInvalidToken = errors.New("share link token is invalid")
+var ErrExpired = errors.New("share link has expired")
+var ErrRevoked = errors.New("share link was revoked")
+var ErrExhausted = errors.New("share link has no downloads left")
+var ErrExpiryOutOfRange = errors.New("share links must expire in the future, within the maximum lifetime")
+var ErrMaxDownloadsNegative = errors.New("the download limit can't be negative")
+var ErrKeyTooShort = fmt.Errorf("share link signing keys must be at least %d bytes", MinKeyLength)
+
+// Link lets anyone holding its token listen to one quip until it expires, is revoked or runs out of downloads
+type Link struct {
+	ID     uint   `json:"id"`
+	FileID uint   `json:"fileId"`
+	Owner  string `json:"owner"`
+	// Token is signed when the link is read, it isn't stored
+	Token string `json:"token"`
+	// MaxDownloads of 0 doesn't limit the downloads
+	MaxDownloads int        `json:"maxDownloads,omitempty"`
+	Downloads    int        `json:"downloads"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Usable returns why the link can't be used anymore, if it can't
+func (l *Link) Usable(now time.Time) error {
+	switch {
+	case l.RevokedAt != nil:
+		return ErrRevoked
+	case !now.Before(l.ExpiresAt):
+		return ErrExpired
+	case l.MaxDownloads > 0 && l.Downloads >= l.MaxDownloads:
+		return ErrExhausted
+	}
+	return nil
+}
+
+// Request holds what the creator of a link chose. Zero values fall back to the defaults
+type Request struct {
+	// ExpiresIn is the link's lifetime in seconds
+	ExpiresIn    int `json:"expiresIn,omitempty"`
+	MaxDownloads int `json:"maxDownloads,omitempty"`
+}
+
+type Repository interface {
+	Create(context.Context, Link) (*Link, error)
+	FindById(context.Context, uint) (*Link, error)
+	FindByOwner(context.Context, string) ([]*Link, error)
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// Use counts a download of the link if it's still usable at the given time, returning ErrNotFound otherwise
+	Use(ctx context.Context, id uint, at time.Time) (*Link, error)
+}
+
+// Storer defines the API for sharing quips through links
+type Storer interface {
+	Create(ctx context.Context, user string, fileID uint, request Request) (*Link, error)
+	FindAll(ctx context.Context, user string) ([]*Link, error)
+	Revoke(ctx context.Context, user string, id uint) error
+	// Resolve returns the link of the token if it can still be used, without counting a download
+	Resolve(ctx context.Context, token string) (*Link, error)
+	// Use returns the link of the token, counting a download of it
+	Use(ctx context.Context, token string) (*Link, error)
+}
+
+// Config holds the lifetimes of links. Zero values fall back to the defaults
+type Config struct {
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+type Service struct {
+	repo       Repository
+	signer     Signer
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	now        func() time.Time
+}
+
+func NewService(repo Repository, signer Signer, cfg Config) *Service {
+	s := &Service{repo: repo, signer: signer, defaultTTL: cfg.DefaultTTL, maxTTL: cfg.MaxTTL, now: time.Now}
+	if s.maxTTL <= 0 {
+		s.maxTTL = DefaultMaxTTL
+	}
+	if s.defaultTTL <= 0 {
+		s.defaultTTL = DefaultTTL
+	}
+	if s.defaultTTL > s.maxTTL {
+		s.defaultTTL = s.maxTTL
+	}
+	return s
+}
+
+func (s *Service) Create(ctx context.Context, user string, fileID uint, request Request) (*Link, error) {
+	ttl := s.defaultTTL
+	if request.ExpiresIn != 0 {
+		ttl = time.Duration(request.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > s.maxTTL {
+		return nil, fmt.Errorf("%w (%s)", ErrExpiryOutOfRange, s.maxTTL)
+	}
+	if request.MaxDownloads < 0 {
+		return nil, ErrMaxDownloadsNegative
+	}
+
+	now := s.now().UTC()
+	saved, err := s.repo.Create(ctx, Link{
+		FileID:       fileID,
+		Owner:        user,
+		MaxDownloads: request.MaxDownloads,
+		// tokens carry the expiry in seconds
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.sign(saved), nil
+}
+
+// FindAll returns the links the user created, revoked and expired ones included
+func (s *Service) FindAll(ctx context.Context, user string) ([]*Link, error) {
+	links, err := s.repo.FindByOwner(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		s.sign(link)
+	}
+	return links, nil
+}
+
+func (s *Service) Revoke(ctx context.Context, user string, id uint) error {
+	link, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if link.Owner != user {
+		return ErrNotOwner
+	}
+	if link.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.Revoke(ctx, id, s.now().UTC())
+}
+
+func (s *Service) Resolve(ctx context.Context, token string) (*Link, error) {
+	id, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := s.repo.FindById(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		// holders of a token don't need to know the link was deleted
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.signer.Sign(link.ID, link.ExpiresAt) != token {
+		return nil, ErrInvalidToken
+	}
+	if err := link.Usable(s.now()); err != nil {
+		return nil, err
+	}
+	return s.sign(link), nil
+}
+
+func (s *Service) Use(ctx context.Context, token string) (*Link, error) {
+	link, err := s.Resolve(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := s.repo.Use(ctx, link.ID, s.now().UTC())
+	if errors.Is(err, ErrNotFound) {
+		// the link was revoked or ran out of downloads since it was resolved, tell which
+		if _, err := s.Resolve(ctx, token); err != nil {
+			return nil, err
+		}
+		return nil, ErrExhausted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.sign(used), nil
+}
+
+// verify checks the token's signature and expiry, returning the ID of its link
+func (s *Service) verify(token string) (uint, error) {
+	id, expiresAt, err := s.signer.Verify(token)
+	if err != nil {
+		return 0, err
+	}
+	if !s.now().Before(expiresAt) {
+		return 0, ErrExpired
+	}
+	return id, nil
+}
+
+func (s *Service) sign(link *Link) *Link {
+	link.Token = s.signer.Sign(link.ID, link.ExpiresAt)
+	return link
+}
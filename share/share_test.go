@@ -0,0 +1,259 @@
+package share
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLinkRepository struct {
+	mock.Mock
+}
+
+func (m *MockLinkRepository) Create(ctx context.Context, link Link) (*Link, error) {
+	args := m.Called(ctx, link)
+	return args.Get(0).(*Link), args.Error(1)
+}
+
+func (m *MockLinkRepository) FindById(ctx context.Context, id uint) (*Link, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*Link), args.Error(1)
+}
+
+func (m *MockLinkRepository) FindByOwner(ctx context.Context, owner string) ([]*Link, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).([]*Link), args.Error(1)
+}
+
+func (m *MockLinkRepository) Revoke(ctx context.Context, id uint, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockLinkRepository) Use(ctx context.Context, id uint, at time.Time) (*Link, error) {
+	args := m.Called(ctx, id, at)
+	return args.Get(0).(*Link), args.Error(1)
+}
+
+var testKey = []byte(strings.Repeat("k", MinKeyLength))
+
+var now = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService(repo Repository) *Service {
+	signer, _ := NewSigner(testKey)
+	service := NewService(repo, signer, Config{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour})
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestSigner(t *testing.T) {
+	signer, err := NewSigner(testKey)
+	assert.NoError(t, err)
+	otherSigner, _ := NewSigner([]byte(strings.Repeat("o", MinKeyLength)))
+	expiresAt := now.Add(time.Hour)
+	token := signer.Sign(42, expiresAt)
+
+	testCases := []struct {
+		name          string
+		signer        Signer
+		token         string
+		expectedError error
+	}{
+		{name: "Valid", signer: signer, token: token},
+		{name: "OtherKey", signer: otherSigner, token: token, expectedError: ErrInvalidToken},
+		{name: "Tampered", signer: signer, token: "B" + token[1:], expectedError: ErrInvalidToken},
+		{name: "Truncated", signer: signer, token: token[:20], expectedError: ErrInvalidToken},
+		{name: "NotBase64", signer: signer, token: "not a token!", expectedError: ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, verifiedExpiry, err := tc.signer.Verify(tc.token)
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.Equal(t, uint(42), id)
+				assert.True(t, expiresAt.Equal(verifiedExpiry))
+			}
+		})
+	}
+
+	_, err = NewSigner([]byte("short"))
+	assert.ErrorIs(t, err, ErrKeyTooShort)
+}
+
+func TestService_Create(t *testing.T) {
+	testCases := []struct {
+		name              string
+		request           Request
+		expectedExpiresAt time.Time
+		expectedError     error
+	}{
+		{name: "DefaultTTL", request: Request{}, expectedExpiresAt: now.Add(time.Hour)},
+		{name: "GivenTTL", request: Request{ExpiresIn: 600, MaxDownloads: 3}, expectedExpiresAt: now.Add(10 * time.Minute)},
+		{name: "OverMaxTTL", request: Request{ExpiresIn: 2 * 24 * 60 * 60}, expectedError: ErrExpiryOutOfRange},
+		{name: "NegativeTTL", request: Request{ExpiresIn: -1}, expectedError: ErrExpiryOutOfRange},
+		{name: "NegativeMaxDownloads", request: Request{MaxDownloads: -1}, expectedError: ErrMaxDownloadsNegative},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockLinkRepository)
+			service := newTestService(repo)
+			if tc.expectedError == nil {
+				expected := Link{FileID: 7, Owner: "alice", MaxDownloads: tc.request.MaxDownloads, ExpiresAt: tc.expectedExpiresAt, CreatedAt: now}
+				saved := expected
+				saved.ID = 1
+				repo.On("Create", mock.Anything, expected).Return(&saved, nil)
+			}
+
+			link, err := service.Create(ctx, "alice", 7, tc.request)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+			if tc.expectedError == nil {
+				assert.Equal(t, service.signer.Sign(1, tc.expectedExpiresAt), link.Token)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Resolve(t *testing.T) {
+	revokedAt := now.Add(-time.Minute)
+	expiresAt := now.Add(time.Hour)
+
+	testCases := []struct {
+		name          string
+		stored        *Link
+		findError     error
+		token         func(Signer) string
+		expectedError error
+	}{
+		{
+			name:   "Usable",
+			stored: &Link{ID: 1, ExpiresAt: expiresAt, MaxDownloads: 2, Downloads: 1},
+		},
+		{
+			name:          "ExpiredToken",
+			token:         func(s Signer) string { return s.Sign(1, now) },
+			expectedError: ErrExpired,
+		},
+		{
+			name:          "Revoked",
+			stored:        &Link{ID: 1, ExpiresAt: expiresAt, RevokedAt: &revokedAt},
+			expectedError: ErrRevoked,
+		},
+		{
+			name:          "Exhausted",
+			stored:        &Link{ID: 1, ExpiresAt: expiresAt, MaxDownloads: 2, Downloads: 2},
+			expectedError: ErrExhausted,
+		},
+		{
+			name:          "Deleted",
+			stored:        (*Link)(nil),
+			findError:     ErrNotFound,
+			expectedError: ErrInvalidToken,
+		},
+		{
+			name:          "OtherExpiry",
+			stored:        &Link{ID: 1, ExpiresAt: expiresAt.Add(time.Hour)},
+			expectedError: ErrInvalidToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockLinkRepository)
+			service := newTestService(repo)
+			token := service.signer.Sign(1, expiresAt)
+			if tc.token != nil {
+				token = tc.token(service.signer)
+			} else {
+				repo.On("FindById", mock.Anything, uint(1)).Return(tc.stored, tc.findError)
+			}
+
+			link, err := service.Resolve(ctx, token)
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.Equal(t, token, link.Token)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Use(t *testing.T) {
+	expiresAt := now.Add(time.Hour)
+
+	testCases := []struct {
+		name          string
+		afterUse      *Link
+		useError      error
+		expectedError error
+	}{
+		{
+			name:     "Counted",
+			afterUse: &Link{ID: 1, ExpiresAt: expiresAt, MaxDownloads: 2, Downloads: 2},
+		},
+		{
+			name:          "RanOutMeanwhile",
+			afterUse:      (*Link)(nil),
+			useError:      ErrNotFound,
+			expectedError: ErrExhausted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockLinkRepository)
+			service := newTestService(repo)
+			repo.On("FindById", mock.Anything, uint(1)).Return(&Link{ID: 1, ExpiresAt: expiresAt, MaxDownloads: 2, Downloads: 1}, nil)
+			repo.On("Use", mock.Anything, uint(1), now).Return(tc.afterUse, tc.useError)
+
+			link, err := service.Use(ctx, service.signer.Sign(1, expiresAt))
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.Equal(t, 2, link.Downloads)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Revoke(t *testing.T) {
+	testCases := []struct {
+		name          string
+		user          string
+		expectRevoke  bool
+		expectedError error
+	}{
+		{name: "Owner", user: "alice", expectRevoke: true},
+		{name: "NotOwner", user: "bob", expectedError: ErrNotOwner},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := new(MockLinkRepository)
+			service := newTestService(repo)
+			repo.On("FindById", mock.Anything, uint(1)).Return(&Link{ID: 1, Owner: "alice", ExpiresAt: now.Add(time.Hour)}, nil)
+			if tc.expectRevoke {
+				repo.On("Revoke", mock.Anything, uint(1), now).Return(nil)
+			}
+
+			err := service.Revoke(ctx, tc.user, 1)
+
+			assert.Equal(t, tc.expectedError, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+)
+
+// MinKeyLength is the shortest signing key accepted, in bytes
+const MinKeyLength = 32
+
+// tokenContext keeps the MACs of share tokens from being valid anywhere else the key is used
+const tokenContext = "voice-quips share v1\x00"
+
+const (
+	payloadLength = 16
+	// macLength truncates the HMAC-SHA256 to 128 bits, which keeps links short
+	macLength = 16
+)
+
+var encoding = base64.RawURLEncoding
+
+// Signer creates and checks share tokens: the ID and expiry of a link, signed with HMAC-SHA256.
+// Tokens of expired links are turned down without looking the link up
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) (Signer, error) {
+	if len(key) < MinKeyLength {
+		return Signer{}, ErrKeyTooShort
+	}
+	return Signer{key: key}, nil
+}
+
+// Sign returns the token of the link with the given ID and expiry
+func (s Signer) Sign(id uint, expiresAt time.Time) string {
+	payload := make([]byte, 0, payloadLength+macLength)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(id))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(expiresAt.Unix()))
+	return encoding.EncodeToString(append(payload, s.mac(payload)...))
+}
+
+// Verify returns the ID and expiry of the link the token was signed for
+func (s Signer) Verify(token string) (uint, time.Time, error) {
+	decoded, err := encoding.DecodeString(token)
+	if err != nil || len(decoded) != payloadLength+macLength {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+
+	payload, mac := decoded[:payloadLength], decoded[payloadLength:]
+	if !hmac.Equal(mac, s.mac(payload)) {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+	id := binary.BigEndian.Uint64(payload[:8])
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0).UTC()
+	return uint(id), expiresAt, nil
+}
+
+func (s Signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(tokenContext))
+	h.Write(payload)
+	return h.Sum(nil)[:macLength]
+}
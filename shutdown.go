@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	log "log/slog"
+)
+
+// shutdownStep is one thing to stop once the API server has drained its requests
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// shutdown runs the steps one after the other, the later ones depending on the earlier being
+// done. They share the grace period, a step still running when it's over is given up on
+func shutdown(grace time.Duration, steps ...shutdownStep) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	for _, step := range steps {
+		log.Info("Stopping", "step", step.name)
+		if err := step.stop(ctx); err != nil {
+			log.Error("Could not stop cleanly", "step", step.name, "err", err)
+		}
+	}
+	log.Info("Shutdown complete")
+}
+
+// background runs fn in its own goroutine. The returned func waits for fn to return
+func background(fn func()) func(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	return func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// closeWith turns a stop func that takes no context into a step
+func closeWith(stop func() error) func(ctx context.Context) error {
+	return func(context.Context) error {
+		return stop()
+	}
+}
@@ -0,0 +1,252 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	log "log/slog"
+
+	"github.com/lib/pq"
+	"github.com/phllpmcphrsn/voice-quips/file"
+)
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates an analytics store sharing the connection pool of the file_info store.
+// Its tables are created by the file_info store's migrations
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// InsertEvents copies the batch of events into play_events. Events of files deleted since they
+// were recorded are skipped, one of them would otherwise fail the whole COPY
+func (p *PostgresStore) InsertEvents(ctx context.Context, events []Event) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return file.NewDBError(err)
+	}
+	defer tx.Rollback()
+
+	events, err = existingFileEvents(ctx, tx, events)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("play_events", "file_id", "kind", "occurred_at"))
+	if err != nil {
+		return file.NewDBError(err)
+	}
+
+	for _, event := range events {
+		if _, err := stmt.ExecContext(ctx, event.FileID, event.Kind, event.OccurredAt); err != nil {
+			stmt.Close()
+			return file.NewDBError(err)
+		}
+	}
+
+	// flushes the COPY
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return file.NewDBError(err)
+	}
+	if err := stmt.Close(); err != nil {
+		return file.NewDBError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return file.NewDBError(err)
+	}
+	log.Debug("Inserted analytics events", "events", len(events))
+	return nil
+}
+
+// existingFileEvents drops the events whose file no longer exists. The remaining files are locked
+// against deletion until the transaction ends so the COPY can't trip over their foreign key
+func existingFileEvents(ctx context.Context, tx *sql.Tx, events []Event) ([]Event, error) {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, int64(event.FileID))
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM file_info WHERE id = ANY($1) FOR KEY SHARE", pq.Array(ids))
+	if err != nil {
+		return nil, file.NewDBError(err)
+	}
+	defer rows.Close()
+
+	existing := make(map[uint]bool)
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, file.NewDBError(err)
+		}
+		existing[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, file.NewDBError(err)
+	}
+
+	kept := make([]Event, 0, len(events))
+	for _, event := range events {
+		if existing[event.FileID] {
+			kept = append(kept, event)
+		}
+	}
+	if skipped := len(events) - len(kept); skipped > 0 {
+		log.Warn("Skipped analytics events of deleted files", "events", skipped)
+	}
+	return kept, nil
+}
+
+// Rollup recomputes the daily counts of every day from since onwards
+func (p *PostgresStore) Rollup(ctx context.Context, since time.Time) error {
+	rollupStmt := `INSERT INTO daily_stats (file_id, day, plays, downloads)
+		SELECT file_id,
+			occurred_at::date,
+			COUNT(*) FILTER (WHERE kind = $2),
+			COUNT(*) FILTER (WHERE kind = $3)
+		FROM play_events
+		WHERE occurred_at >= $1
+		GROUP BY file_id, occurred_at::date
+		ON CONFLICT (file_id, day) DO UPDATE
+		SET plays = EXCLUDED.plays, downloads = EXCLUDED.downloads`
+
+	_, err := p.db.ExecContext(ctx, rollupStmt, since, KindPlay, KindDownload)
+	if err != nil {
+		return file.NewDBError(err)
+	}
+	return nil
+}
+
+// RollupStart returns the last day with daily counts, or the day of the earliest event
+func (p *PostgresStore) RollupStart(ctx context.Context) (time.Time, error) {
+	selectStmt := `SELECT COALESCE((SELECT MAX(day) FROM daily_stats), (SELECT MIN(occurred_at)::date FROM play_events))`
+
+	var start sql.NullTime
+	if err := p.db.QueryRowContext(ctx, selectStmt).Scan(&start); err != nil {
+		return time.Time{}, file.NewDBError(err)
+	}
+	return start.Time, nil
+}
+
+func (p *PostgresStore) FileStats(ctx context.Context, fileID uint, since time.Time) (*FileStats, error) {
+	fileStats := FileStats{FileID: fileID, Daily: []DailyCount{}}
+
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM file_info WHERE id = $1)", fileID).Scan(&exists); err != nil {
+		return nil, file.NewDBError(err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	totalsStmt := `SELECT COALESCE(SUM(plays), 0), COALESCE(SUM(downloads), 0) FROM daily_stats WHERE file_id = $1`
+	err := p.db.QueryRowContext(ctx, totalsStmt, fileID).Scan(&fileStats.Plays, &fileStats.Downloads)
+	if err != nil {
+		return nil, file.NewDBError(err)
+	}
+
+	dailyStmt := `SELECT day, plays, downloads FROM daily_stats
+		WHERE file_id = $1 AND day >= $2
+		ORDER BY day`
+	rows, err := p.db.QueryContext(ctx, dailyStmt, fileID, since)
+	if err != nil {
+		return nil, file.NewDBError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var daily DailyCount
+		if err := rows.Scan(&daily.Day, &daily.Plays, &daily.Downloads); err != nil {
+			return nil, file.NewDBError(err)
+		}
+		fileStats.Daily = append(fileStats.Daily, daily)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, file.NewDBError(err)
+	}
+	return &fileStats, nil
+}
+
+// Top ranks quips by the sum of the metric over the days from since onwards
+func (p *PostgresStore) Top(ctx context.Context, since time.Time, metric Metric, limit int) ([]*TopEntry, error) {
+	// the metric is validated by ParseMetric so it's one of two known column names
+	orderBy := "plays"
+	if metric == MetricDownloads {
+		orderBy = "downloads"
+	}
+
+	selectStmt := fmt.Sprintf(`SELECT file_info.id, COALESCE(file_info.title, ''), COALESCE(file_info.filename, ''),
+			SUM(daily_stats.plays) AS plays, SUM(daily_stats.downloads) AS downloads
+		FROM daily_stats
+		JOIN file_info ON file_info.id = daily_stats.file_id
+		WHERE daily_stats.day >= $1
+		GROUP BY file_info.id
+		HAVING SUM(daily_stats.%[1]s) > 0
+		ORDER BY %[1]s DESC, file_info.id
+		LIMIT $2`, orderBy)
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, since, limit)
+	if err != nil {
+		return nil, file.NewDBError(err)
+	}
+	defer rows.Close()
+
+	entries := []*TopEntry{}
+	for rows.Next() {
+		var entry TopEntry
+		if err := rows.Scan(&entry.FileID, &entry.Title, &entry.Filename, &entry.Plays, &entry.Downloads); err != nil {
+			return nil, file.NewDBError(err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, file.NewDBError(err)
+	}
+	return entries, nil
+}
+
+// Trending ranks quips played since the given day by how their plays compare to the period
+// starting at previous. The score is smoothed so that new quips don't divide by zero
+func (p *PostgresStore) Trending(ctx context.Context, since, previous time.Time, limit int) ([]*TopEntry, error) {
+	selectStmt := `SELECT id, title, filename, plays, downloads, (plays + 1)::float / (previous_plays + 1) AS score
+		FROM (
+			SELECT file_info.id, COALESCE(file_info.title, '') AS title, COALESCE(file_info.filename, '') AS filename,
+				COALESCE(SUM(daily_stats.plays) FILTER (WHERE daily_stats.day >= $1), 0) AS plays,
+				COALESCE(SUM(daily_stats.downloads) FILTER (WHERE daily_stats.day >= $1), 0) AS downloads,
+				COALESCE(SUM(daily_stats.plays) FILTER (WHERE daily_stats.day < $1), 0) AS previous_plays
+			FROM daily_stats
+			JOIN file_info ON file_info.id = daily_stats.file_id
+			WHERE daily_stats.day >= $2
+			GROUP BY file_info.id
+		) AS periods
+		WHERE plays > 0
+		ORDER BY score DESC, plays DESC, id
+		LIMIT $3`
+
+	rows, err := p.db.QueryContext(ctx, selectStmt, since, previous, limit)
+	if err != nil {
+		return nil, file.NewDBError(err)
+	}
+	defer rows.Close()
+
+	entries := []*TopEntry{}
+	for rows.Next() {
+		var entry TopEntry
+		if err := rows.Scan(&entry.FileID, &entry.Title, &entry.Filename, &entry.Plays, &entry.Downloads, &entry.Score); err != nil {
+			return nil, file.NewDBError(err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, file.NewDBError(err)
+	}
+	return entries, nil
+}
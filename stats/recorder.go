@@ -0,0 +1,157 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "log/slog"
+)
+
+// Defaults used by NewRecorder for the zero values of RecorderConfig
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = 5 * time.Second
+	DefaultBufferSize    = 10000
+)
+
+// EventSink stores batches of events
+type EventSink interface {
+	InsertEvents(context.Context, []Event) error
+}
+
+// RecorderConfig tunes how events are batched before being inserted
+type RecorderConfig struct {
+	// BatchSize is the number of buffered events that triggers an insert
+	BatchSize int
+	// FlushInterval is the longest an event waits in the buffer
+	FlushInterval time.Duration
+	// BufferSize is the number of events that can be waiting. Events recorded while it's full are dropped
+	BufferSize int
+}
+
+// Recorder buffers events in memory and inserts them in batches so that serving audio never
+// waits on the events table
+type Recorder struct {
+	sink          EventSink
+	events        chan Event
+	batchSize     int
+	flushInterval time.Duration
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewRecorder(sink EventSink, cfg RecorderConfig) *Recorder {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.BufferSize < 1 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+
+	return &Recorder{
+		sink:          sink,
+		events:        make(chan Event, cfg.BufferSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// Record queues the event for the next batch. It never blocks; when the buffer is full the event is dropped
+func (r *Recorder) Record(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		log.Warn("dropping analytics event, buffer is full", "file", event.FileID, "kind", event.Kind)
+	}
+}
+
+// Run inserts batches until Close is called, flushing whatever is left before returning
+func (r *Recorder) Run() {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// events are best effort, a failed batch is logged rather than retried
+		if err := r.sink.InsertEvents(context.Background(), batch); err != nil {
+			log.Error("could not insert analytics events", "err", err, "events", len(batch))
+		}
+		batch = make([]Event, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case event := <-r.events:
+			batch = append(batch, event)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.done:
+			// drain what was recorded before Close
+			for {
+				select {
+				case event := <-r.events:
+					batch = append(batch, event)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close stops Run after the buffered events have been inserted
+func (r *Recorder) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// RunRollups aggregates events into daily counts every interval until the context is done.
+// Yesterday is recomputed as well so that events inserted around midnight are counted. The first
+// pass starts from where the last rollup got to, catching up on the days the server was down
+func RunRollups(ctx context.Context, repo Repository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since, err := repo.RollupStart(ctx)
+	if err != nil {
+		log.Error("could not find where analytics rollups left off", "err", err)
+	}
+	for {
+		yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+		if since.IsZero() || since.After(yesterday) {
+			since = yesterday
+		}
+		if err := repo.Rollup(ctx, since); err != nil {
+			log.Error("could not roll up analytics events", "err", err, "since", since)
+		} else {
+			since = time.Time{}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Kinds of events recorded when audio is served
+const (
+	KindPlay     = "play"
+	KindDownload = "download"
+)
+
+var ErrUnknownPeriod = errors.New("supported periods are day, week, month, year and all")
+var ErrUnknownMetric = errors.New("supported metrics are plays and downloads")
+var ErrNotFound = errors.New("quip not found")
+
+// Event is a single play or download of a quip
+type Event struct {
+	FileID     uint
+	Kind       string
+	OccurredAt time.Time
+}
+
+// DailyCount is the number of plays and downloads of a quip on one day
+type DailyCount struct {
+	Day       time.Time `json:"day"`
+	Plays     int64     `json:"plays"`
+	Downloads int64     `json:"downloads"`
+}
+
+// FileStats summarizes the plays and downloads of a quip
+type FileStats struct {
+	FileID    uint         `json:"fileId"`
+	Plays     int64        `json:"plays"`
+	Downloads int64        `json:"downloads"`
+	Daily     []DailyCount `json:"daily"`
+}
+
+// TopEntry is a quip ranked by its plays or downloads over a period. For trending quips Score
+// compares the period to the one before it
+type TopEntry struct {
+	FileID    uint    `json:"fileId"`
+	Title     string  `json:"title"`
+	Filename  string  `json:"name"`
+	Plays     int64   `json:"plays"`
+	Downloads int64   `json:"downloads"`
+	Score     float64 `json:"score,omitempty"`
+}
+
+// Period is how far back rankings look
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+	PeriodAll   Period = "all"
+)
+
+// ParsePeriod validates a period given by a client. An empty value defaults to a week
+func ParsePeriod(value string) (Period, error) {
+	if value == "" {
+		return PeriodWeek, nil
+	}
+
+	period := Period(strings.ToLower(value))
+	switch period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear, PeriodAll:
+		return period, nil
+	default:
+		return "", ErrUnknownPeriod
+	}
+}
+
+// Since returns the first day included in the period ending on the day of now. The zero time is
+// returned for PeriodAll
+func (p Period) Since(now time.Time) time.Time {
+	today := now.UTC().Truncate(24 * time.Hour)
+	switch p {
+	case PeriodDay:
+		return today
+	case PeriodWeek:
+		return today.AddDate(0, 0, -6)
+	case PeriodMonth:
+		return today.AddDate(0, -1, 1)
+	case PeriodYear:
+		return today.AddDate(-1, 0, 1)
+	default:
+		return time.Time{}
+	}
+}
+
+// Metric is what rankings are ordered by
+type Metric string
+
+const (
+	MetricPlays     Metric = "plays"
+	MetricDownloads Metric = "downloads"
+)
+
+// ParseMetric validates a metric given by a client. An empty value defaults to plays
+func ParseMetric(value string) (Metric, error) {
+	switch Metric(strings.ToLower(value)) {
+	case "", MetricPlays:
+		return MetricPlays, nil
+	case MetricDownloads:
+		return MetricDownloads, nil
+	default:
+		return "", ErrUnknownMetric
+	}
+}
+
+// EventRecorder accepts events without blocking the request serving the audio
+type EventRecorder interface {
+	Record(Event)
+}
+
+type Repository interface {
+	InsertEvents(context.Context, []Event) error
+	Rollup(context.Context, time.Time) error
+	// RollupStart returns the first day that may not be fully rolled up: the last day with daily
+	// counts, or the day of the earliest event when there are none. It's zero without events
+	RollupStart(context.Context) (time.Time, error)
+	FileStats(ctx context.Context, fileID uint, since time.Time) (*FileStats, error)
+	Top(ctx context.Context, since time.Time, metric Metric, limit int) ([]*TopEntry, error)
+	Trending(ctx context.Context, since, previous time.Time, limit int) ([]*TopEntry, error)
+}
+
+// Reporter defines the API for reading listening analytics
+type Reporter interface {
+	FileStats(ctx context.Context, fileID uint, days int) (*FileStats, error)
+	Top(ctx context.Context, period Period, metric Metric, limit int) ([]*TopEntry, error)
+	Trending(ctx context.Context, period Period, limit int) ([]*TopEntry, error)
+}
+
+type StatsService struct {
+	repo Repository
+	now  func() time.Time
+}
+
+func NewStatsService(repo Repository) *StatsService {
+	return &StatsService{repo: repo, now: time.Now}
+}
+
+// FileStats returns the all-time totals of a quip and its daily counts for the last given days
+func (s *StatsService) FileStats(ctx context.Context, fileID uint, days int) (*FileStats, error) {
+	if days < 1 {
+		days = 30
+	}
+	since := s.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	return s.repo.FileStats(ctx, fileID, since)
+}
+
+func (s *StatsService) Top(ctx context.Context, period Period, metric Metric, limit int) ([]*TopEntry, error) {
+	return s.repo.Top(ctx, period.Since(s.now()), metric, clampLimit(limit))
+}
+
+// Trending ranks quips by how much more they were played during the period than during the one before it
+func (s *StatsService) Trending(ctx context.Context, period Period, limit int) ([]*TopEntry, error) {
+	if period == PeriodAll {
+		return nil, ErrUnknownPeriod
+	}
+
+	// the previous period is as many days long as the current one and ends right before it
+	now := s.now()
+	since := period.Since(now)
+	previous := since.AddDate(0, 0, -daysBetween(since, now))
+	return s.repo.Trending(ctx, since, previous, clampLimit(limit))
+}
+
+// daysBetween counts the days from since up to and including the day of now
+func daysBetween(since, now time.Time) int {
+	return int(now.UTC().Truncate(24*time.Hour).Sub(since)/(24*time.Hour)) + 1
+}
+
+func clampLimit(limit int) int {
+	if limit < 1 {
+		return 10
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
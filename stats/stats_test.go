@@ -0,0 +1,216 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStatsRepository struct {
+	mock.Mock
+}
+
+func (m *MockStatsRepository) InsertEvents(ctx context.Context, events []Event) error {
+	args := m.Called(ctx, events)
+	return args.Error(0)
+}
+
+func (m *MockStatsRepository) Rollup(ctx context.Context, since time.Time) error {
+	args := m.Called(ctx, since)
+	return args.Error(0)
+}
+
+func (m *MockStatsRepository) RollupStart(ctx context.Context) (time.Time, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockStatsRepository) FileStats(ctx context.Context, fileID uint, since time.Time) (*FileStats, error) {
+	args := m.Called(ctx, fileID, since)
+	return args.Get(0).(*FileStats), args.Error(1)
+}
+
+func (m *MockStatsRepository) Top(ctx context.Context, since time.Time, metric Metric, limit int) ([]*TopEntry, error) {
+	args := m.Called(ctx, since, metric, limit)
+	return args.Get(0).([]*TopEntry), args.Error(1)
+}
+
+func (m *MockStatsRepository) Trending(ctx context.Context, since, previous time.Time, limit int) ([]*TopEntry, error) {
+	args := m.Called(ctx, since, previous, limit)
+	return args.Get(0).([]*TopEntry), args.Error(1)
+}
+
+// fakeSink collects the batches it's given
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]Event
+}
+
+func (f *fakeSink) InsertEvents(ctx context.Context, events []Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, events)
+	return nil
+}
+
+func (f *fakeSink) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sizes := []int{}
+	for _, batch := range f.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestParsePeriod(t *testing.T) {
+	testCases := []struct {
+		name           string
+		value          string
+		expectedPeriod Period
+		expectedError  bool
+	}{
+		{name: "DefaultsToWeek", value: "", expectedPeriod: PeriodWeek},
+		{name: "CaseInsensitive", value: "Month", expectedPeriod: PeriodMonth},
+		{name: "Unknown", value: "decade", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			period, err := ParsePeriod(tc.value)
+
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrUnknownPeriod)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedPeriod, period)
+			}
+		})
+	}
+}
+
+func TestPeriod_Since(t *testing.T) {
+	now := time.Date(2023, 9, 15, 18, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		period        Period
+		expectedSince time.Time
+	}{
+		{name: "Day", period: PeriodDay, expectedSince: time.Date(2023, 9, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "Week", period: PeriodWeek, expectedSince: time.Date(2023, 9, 9, 0, 0, 0, 0, time.UTC)},
+		{name: "Month", period: PeriodMonth, expectedSince: time.Date(2023, 8, 16, 0, 0, 0, 0, time.UTC)},
+		{name: "All", period: PeriodAll, expectedSince: time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedSince, tc.period.Since(now))
+		})
+	}
+}
+
+func TestStatsService_Trending(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockStatsRepository)
+	service := &StatsService{repo: repo, now: func() time.Time {
+		return time.Date(2023, 9, 15, 18, 30, 0, 0, time.UTC)
+	}}
+	since := time.Date(2023, 9, 9, 0, 0, 0, 0, time.UTC)
+	previous := time.Date(2023, 9, 2, 0, 0, 0, 0, time.UTC)
+	repo.On("Trending", ctx, since, previous, 10).Return([]*TopEntry{}, nil)
+
+	_, err := service.Trending(ctx, PeriodWeek, 0)
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+
+	_, err = service.Trending(ctx, PeriodAll, 10)
+	assert.ErrorIs(t, err, ErrUnknownPeriod)
+}
+
+func TestRecorder(t *testing.T) {
+	sink := &fakeSink{}
+	recorder := NewRecorder(sink, RecorderConfig{BatchSize: 2, FlushInterval: time.Hour})
+
+	finished := make(chan struct{})
+	go func() {
+		recorder.Run()
+		close(finished)
+	}()
+
+	for i := 0; i < 5; i++ {
+		recorder.Record(Event{FileID: uint(i), Kind: KindPlay})
+	}
+	recorder.Close()
+	<-finished
+
+	// two full batches, then whatever was left is flushed on close
+	assert.Equal(t, []int{2, 2, 1}, sink.batchSizes())
+	assert.False(t, sink.batches[0][0].OccurredAt.IsZero())
+}
+
+func TestRecorder_DropsWhenFull(t *testing.T) {
+	sink := &fakeSink{}
+	recorder := NewRecorder(sink, RecorderConfig{BatchSize: 10, BufferSize: 1})
+
+	// Run isn't started so nothing drains the buffer
+	recorder.Record(Event{FileID: 1, Kind: KindPlay})
+	recorder.Record(Event{FileID: 2, Kind: KindPlay})
+
+	assert.Len(t, recorder.events, 1)
+}
+
+func TestRunRollups(t *testing.T) {
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	lastRolledUp := yesterday.AddDate(0, 0, -5)
+
+	testCases := []struct {
+		name      string
+		start     time.Time
+		firstPass time.Time
+	}{
+		{name: "CatchesUpAfterOutage", start: lastRolledUp, firstPass: lastRolledUp},
+		{name: "NothingRolledUp", start: time.Time{}, firstPass: yesterday},
+		{name: "RolledUpToday", start: yesterday.AddDate(0, 0, 1), firstPass: yesterday},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var mu sync.Mutex
+			passes := []time.Time{}
+			repo := &MockStatsRepository{}
+			repo.On("RollupStart", mock.Anything).Return(tc.start, nil)
+			repo.On("Rollup", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				mu.Lock()
+				defer mu.Unlock()
+				passes = append(passes, args.Get(1).(time.Time))
+				if len(passes) == 2 {
+					cancel()
+				}
+			}).Return(nil)
+
+			done := make(chan struct{})
+			go func() {
+				RunRollups(ctx, repo, time.Millisecond)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("rollups did not stop")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []time.Time{tc.firstPass, yesterday}, passes)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+
+	log "log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logHandler adds the ids of the span in the context to every record logged with one
+type logHandler struct {
+	log.Handler
+}
+
+// LogHandler wraps the handler so that records logged with a context (log.InfoContext...) carry
+// the traceId and spanId of the span it holds
+func LogHandler(next log.Handler) log.Handler {
+	return logHandler{next}
+}
+
+func (h logHandler) Handle(ctx context.Context, record log.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			log.String("traceId", spanContext.TraceID().String()),
+			log.String("spanId", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []log.Attr) log.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) log.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
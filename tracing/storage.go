@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/phllpmcphrsn/voice-quips/tracing"
+
+// tracedStorage starts a span for every operation of the storage it wraps
+type tracedStorage struct {
+	next    s3.Storage
+	backend string
+}
+
+// Storage wraps the storage so that each of its operations is a span, labelled with the
+// backend's name (minio, s3...)
+func Storage(backend string, storage s3.Storage) s3.Storage {
+	return &tracedStorage{next: storage, backend: backend}
+}
+
+func (s *tracedStorage) start(ctx context.Context, operation, bucket, object string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.backend", s.backend),
+			attribute.String("storage.bucket", bucket),
+			attribute.String("storage.object", object),
+		),
+	)
+}
+
+func (s *tracedStorage) UploadObject(ctx context.Context, filename, bucket string) (err error) {
+	ctx, span := s.start(ctx, "UploadObject", bucket, filename)
+	defer func() { End(span, err) }()
+	return s.next.UploadObject(ctx, filename, bucket)
+}
+
+func (s *tracedStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) (err error) {
+	ctx, span := s.start(ctx, "UploadStream", bucket, objectName)
+	span.SetAttributes(attribute.Int64("storage.size", size), attribute.String("storage.content_type", contentType))
+	defer func() { End(span, err) }()
+	return s.next.UploadStream(ctx, objectName, bucket, body, size, contentType)
+}
+
+func (s *tracedStorage) DownloadObject(ctx context.Context, objectName, bucket string) (data []byte, err error) {
+	ctx, span := s.start(ctx, "DownloadObject", bucket, objectName)
+	defer func() { End(span, err) }()
+	return s.next.DownloadObject(ctx, objectName, bucket)
+}
+
+// StreamObject's span covers opening the object, not reading it
+func (s *tracedStorage) StreamObject(ctx context.Context, objectName, bucket string) (object *s3.Object, err error) {
+	ctx, span := s.start(ctx, "StreamObject", bucket, objectName)
+	defer func() { End(span, err) }()
+	return s.next.StreamObject(ctx, objectName, bucket)
+}
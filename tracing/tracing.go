@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName identifies the service in traces unless told otherwise
+const DefaultServiceName = "voice-quips"
+
+// Exporters spans can be sent to
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var ErrUnknownExporter = errors.New("supported trace exporters are otlp and stdout")
+
+// Config tunes tracing. Spans aren't exported when no exporter is given
+type Config struct {
+	Exporter string
+	// Endpoint is the host:port of the OTLP collector, the OTEL_EXPORTER_OTLP_ENDPOINT envvar or
+	// localhost:4318 when empty
+	Endpoint string
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure    bool
+	ServiceName string
+	// SampleRatio is the share of traces started here that are kept, all of them when it's 0.
+	// Traces started by a caller follow the caller's decision
+	SampleRatio float64
+}
+
+// Setup installs the W3C trace context propagator and a global tracer provider exporting spans
+// as configured. The returned func flushes the spans left and stops the exporter
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	default:
+		err = ErrUnknownExporter
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewTracerProvider(cfg, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a provider sampling and naming spans as configured. Tests pass
+// sdktrace.WithSyncer with an in-memory exporter to look at the spans
+func NewTracerProvider(cfg Config, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// End records the error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
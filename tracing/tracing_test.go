@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	log "log/slog"
+
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// fakeStorage fails every operation when err is set
+type fakeStorage struct {
+	err error
+}
+
+func (f *fakeStorage) UploadObject(ctx context.Context, filename, bucket string) error {
+	return f.err
+}
+
+func (f *fakeStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	return f.err
+}
+
+func (f *fakeStorage) DownloadObject(ctx context.Context, objectName, bucket string) ([]byte, error) {
+	return nil, f.err
+}
+
+func (f *fakeStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	return &s3.Object{}, f.err
+}
+
+// useRecorder installs a provider keeping spans in memory for the duration of the test
+func useRecorder(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(Config{}, sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestStorage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus codes.Code
+	}{
+		{name: "Success", expectedStatus: codes.Unset},
+		{name: "Failure", err: errors.New("bucket missing"), expectedStatus: codes.Error},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := useRecorder(t)
+			storage := Storage("minio", &fakeStorage{err: tc.err})
+
+			err := storage.UploadStream(context.Background(), "quip.mp3", "quips", bytes.NewReader(nil), 42, "audio/mpeg")
+			assert.Equal(t, tc.err, err)
+
+			spans := exporter.GetSpans()
+			if assert.Len(t, spans, 1) {
+				span := spans[0]
+				assert.Equal(t, "storage.UploadStream", span.Name)
+				assert.Equal(t, tc.expectedStatus, span.Status.Code)
+				assert.Contains(t, span.Attributes, attribute.String("storage.backend", "minio"))
+				assert.Contains(t, span.Attributes, attribute.String("storage.object", "quip.mp3"))
+				assert.Contains(t, span.Attributes, attribute.Int64("storage.size", 42))
+			}
+		})
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	useRecorder(t)
+	var buf bytes.Buffer
+	logger := log.New(LogHandler(log.NewJSONHandler(&buf, nil)))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	logger.InfoContext(ctx, "with span")
+	span.End()
+	logger.InfoContext(context.Background(), "without span")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	var withSpan, withoutSpan map[string]any
+	assert.NoError(t, json.Unmarshal(lines[0], &withSpan))
+	assert.NoError(t, json.Unmarshal(lines[1], &withoutSpan))
+	assert.Equal(t, span.SpanContext().TraceID().String(), withSpan["traceId"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), withSpan["spanId"])
+	assert.NotContains(t, withoutSpan, "traceId")
+}
+
+func TestSetup(t *testing.T) {
+	testCases := []struct {
+		name        string
+		exporter    string
+		expectedErr error
+	}{
+		{name: "Disabled"},
+		{name: "Stdout", exporter: ExporterStdout},
+		{name: "UnknownExporter", exporter: "zipkin", expectedErr: ErrUnknownExporter},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			previous := otel.GetTracerProvider()
+			t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+			shutdown, err := Setup(context.Background(), Config{Exporter: tc.exporter})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if err == nil {
+				assert.NoError(t, shutdown(context.Background()))
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package transcript
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	log "log/slog"
+)
+
+// PostgresStore reads and writes the transcripts table. The table is created by the file
+// package's migrations since searching files reads it too
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a transcript store sharing the connection pool of the file_info store
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Save stores the transcript, replacing an earlier one of the same file
+func (p *PostgresStore) Save(ctx context.Context, t Transcript) error {
+	words, err := json.Marshal(t.Words)
+	if err != nil {
+		return err
+	}
+
+	upsertStmt := `INSERT INTO transcripts (file_id, engine, language, text, words, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (file_id) DO UPDATE
+		SET engine = EXCLUDED.engine, language = EXCLUDED.language, text = EXCLUDED.text,
+			words = EXCLUDED.words, created_at = EXCLUDED.created_at`
+
+	_, err = p.db.ExecContext(ctx, upsertStmt, t.FileID, t.Engine, t.Language, t.Text, words, t.CreatedAt)
+	if err != nil {
+		log.Error("An error occurred while saving transcript", "err", err, "file", t.FileID)
+		return err
+	}
+	return nil
+}
+
+func (p *PostgresStore) FindByFileID(ctx context.Context, fileID uint) (*Transcript, error) {
+	selectStmt := `SELECT file_id, engine, language, text, words, created_at FROM transcripts WHERE file_id = $1`
+
+	var t Transcript
+	var words []byte
+	err := p.db.QueryRowContext(ctx, selectStmt, fileID).Scan(&t.FileID, &t.Engine, &t.Language, &t.Text, &words, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(words, &t.Words); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
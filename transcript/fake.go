@@ -0,0 +1,50 @@
+package transcript
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Timing of the words made up by FakeTranscriber
+const (
+	fakeWordLength = 350
+	fakeWordGap    = 50
+)
+
+// FakeTranscriber is a deterministic engine for tests and local development. It needs no model or
+// network, the same input always gives the same transcript
+type FakeTranscriber struct {
+	// Text is the transcript of every file. When empty the transcript is made up from the filename
+	Text string
+}
+
+func (f *FakeTranscriber) Name() string {
+	return EngineFake
+}
+
+func (f *FakeTranscriber) Transcribe(ctx context.Context, audio io.Reader, filename string) (*Transcript, error) {
+	// read the audio like a real engine would so that storage errors surface the same way
+	if _, err := io.Copy(io.Discard, audio); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	text := f.Text
+	if text == "" {
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		text = "transcript of " + strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	}
+
+	words := []Word{}
+	var start int64
+	for _, field := range strings.Fields(text) {
+		words = append(words, Word{Text: field, Start: start, End: start + fakeWordLength})
+		start += fakeWordLength + fakeWordGap
+	}
+
+	return &Transcript{Language: "en", Text: joinWords(words), Words: words}, nil
+}
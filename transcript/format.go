@@ -0,0 +1,79 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Content types of the subtitle formats
+const (
+	WebVTTContentType = "text/vtt; charset=utf-8"
+	SRTContentType    = "application/x-subrip; charset=utf-8"
+)
+
+// Limits of a single subtitle cue
+const (
+	maxCueWords    = 8
+	maxCueDuration = 4000
+)
+
+// Cue is a line of subtitles shown from Start until End, in milliseconds
+type Cue struct {
+	Start int64
+	End   int64
+	Text  string
+}
+
+// Cues groups the words of a transcript into subtitle lines short enough to read while they're spoken
+func Cues(t *Transcript) []Cue {
+	cues := []Cue{}
+	var current *Cue
+	words := 0
+
+	for _, word := range t.Words {
+		if current != nil && (words >= maxCueWords || word.End-current.Start > maxCueDuration) {
+			cues = append(cues, *current)
+			current = nil
+		}
+		if current == nil {
+			current = &Cue{Start: word.Start, Text: word.Text}
+			words = 0
+		} else {
+			current.Text += " " + word.Text
+		}
+		current.End = word.End
+		words++
+	}
+	if current != nil {
+		cues = append(cues, *current)
+	}
+	return cues
+}
+
+// WebVTT renders the transcript as WebVTT subtitles
+func WebVTT(t *Transcript) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, cue := range Cues(t) {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", i+1, timestamp(cue.Start, "."), timestamp(cue.End, "."), cue.Text)
+	}
+	return []byte(b.String())
+}
+
+// SRT renders the transcript as SubRip subtitles
+func SRT(t *Transcript) []byte {
+	var b strings.Builder
+	for i, cue := range Cues(t) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n", i+1, timestamp(cue.Start, ","), timestamp(cue.End, ","), cue.Text)
+	}
+	return []byte(b.String())
+}
+
+// timestamp formats milliseconds as hh:mm:ss followed by the separator and milliseconds. WebVTT
+// separates milliseconds with a period and SRT with a comma
+func timestamp(ms int64, separator string) string {
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
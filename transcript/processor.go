@@ -0,0 +1,77 @@
+package transcript
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	log "log/slog"
+
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+)
+
+// JobTranscribe is the kind of job transcribing an uploaded object
+const JobTranscribe = "transcribe"
+
+// Invalidator drops the cached listings of a file, searches match its transcript
+type Invalidator interface {
+	Invalidate(ctx context.Context, id string)
+}
+
+// Processor handles transcribe jobs so that uploading never waits on the engine
+type Processor struct {
+	engine  Transcriber
+	repo    Repository
+	storage s3.Streamer
+	bucket  string
+	timeout time.Duration
+	cache   Invalidator
+}
+
+// NewProcessor creates the transcribe job handler. A timeout of zero leaves the limit to the job's
+// lease. The cache is nil when files aren't cached
+func NewProcessor(engine Transcriber, repo Repository, storage s3.Streamer, bucket string, timeout time.Duration, cache Invalidator) *Processor {
+	return &Processor{engine: engine, repo: repo, storage: storage, bucket: bucket, timeout: timeout, cache: cache}
+}
+
+func (p *Processor) Handle(ctx context.Context, job *jobs.Job) error {
+	var payload jobs.ObjectPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	object, err := p.storage.StreamObject(ctx, payload.Object, p.bucket)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	started := time.Now()
+	result, err := p.engine.Transcribe(ctx, object.Body, payload.Filename)
+	if err != nil {
+		return err
+	}
+
+	result.FileID = job.FileID
+	result.Engine = p.engine.Name()
+	result.CreatedAt = time.Now().UTC()
+	if result.Words == nil {
+		result.Words = []Word{}
+	}
+	if err := p.repo.Save(ctx, *result); err != nil {
+		return err
+	}
+	if p.cache != nil {
+		p.cache.Invalidate(ctx, strconv.FormatUint(uint64(job.FileID), 10))
+	}
+
+	log.Info("transcribed file", "file", job.FileID, "engine", result.Engine, "words", len(result.Words), "took", time.Since(started))
+	return nil
+}
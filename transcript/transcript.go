@@ -0,0 +1,85 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/config"
+)
+
+// Engines that can be selected in the transcription config
+const (
+	EngineFake    = "fake"
+	EngineWhisper = "whisper"
+)
+
+var ErrNotFound = errors.New("transcript not found")
+var ErrUnknownEngine = errors.New("supported transcription engines are fake and whisper")
+
+// Word is a single spoken word and when it was said, in milliseconds from the start of the audio
+type Word struct {
+	Text  string `json:"text"`
+	Start int64  `json:"startMs"`
+	End   int64  `json:"endMs"`
+}
+
+// Transcript is the text spoken in a quip along with the timing of each word
+type Transcript struct {
+	FileID    uint      `json:"fileId"`
+	Engine    string    `json:"engine"`
+	Language  string    `json:"language,omitempty"`
+	Text      string    `json:"text"`
+	Words     []Word    `json:"words"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Transcriber turns speech into text. Engines only fill in the text, language and words; the
+// file and engine name are set by the caller
+type Transcriber interface {
+	// Name identifies the engine in stored transcripts
+	Name() string
+	Transcribe(ctx context.Context, audio io.Reader, filename string) (*Transcript, error)
+}
+
+type Repository interface {
+	Save(context.Context, Transcript) error
+	FindByFileID(context.Context, uint) (*Transcript, error)
+}
+
+// Finder defines the API for reading transcripts
+type Finder interface {
+	FindByFileID(context.Context, uint) (*Transcript, error)
+}
+
+// NewEngine creates the transcriber selected in the config
+func NewEngine(cfg config.TranscriptionConfig) (Transcriber, error) {
+	switch strings.ToLower(cfg.Engine) {
+	case EngineFake:
+		return &FakeTranscriber{}, nil
+	case EngineWhisper:
+		if cfg.Whisper.Model == "" {
+			return nil, fmt.Errorf("whisper engine needs a model: %w", ErrUnknownEngine)
+		}
+		return &WhisperCPP{
+			Binary:   cfg.Whisper.Binary,
+			Model:    cfg.Whisper.Model,
+			Language: cfg.Whisper.Language,
+			Threads:  cfg.Whisper.Threads,
+		}, nil
+	default:
+		return nil, ErrUnknownEngine
+	}
+}
+
+// joinWords rebuilds the text of a transcript from its words
+func joinWords(words []Word) string {
+	texts := make([]string, 0, len(words))
+	for _, word := range words {
+		texts = append(texts, word.Text)
+	}
+	return strings.Join(texts, " ")
+}
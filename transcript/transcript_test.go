@@ -0,0 +1,170 @@
+package transcript
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phllpmcphrsn/voice-quips/config"
+	"github.com/phllpmcphrsn/voice-quips/jobs"
+	"github.com/phllpmcphrsn/voice-quips/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTranscriptRepository struct {
+	mock.Mock
+}
+
+func (m *MockTranscriptRepository) Save(ctx context.Context, t Transcript) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockTranscriptRepository) FindByFileID(ctx context.Context, fileID uint) (*Transcript, error) {
+	args := m.Called(ctx, fileID)
+	return args.Get(0).(*Transcript), args.Error(1)
+}
+
+// fakeStorage serves the same audio for every object
+type fakeStorage struct{}
+
+func (f *fakeStorage) UploadStream(ctx context.Context, objectName, bucket string, body io.Reader, size int64, contentType string) error {
+	return nil
+}
+
+func (f *fakeStorage) StreamObject(ctx context.Context, objectName, bucket string) (*s3.Object, error) {
+	return &s3.Object{Body: io.NopCloser(strings.NewReader("audio")), Size: 5}, nil
+}
+
+func TestFakeTranscriber(t *testing.T) {
+	testCases := []struct {
+		name          string
+		engine        *FakeTranscriber
+		filename      string
+		expectedText  string
+		expectedWords int
+	}{
+		{
+			name:          "FromFilename",
+			engine:        &FakeTranscriber{},
+			filename:      "good_morning-folks.mp3",
+			expectedText:  "transcript of good morning folks",
+			expectedWords: 5,
+		},
+		{
+			name:          "FixedText",
+			engine:        &FakeTranscriber{Text: "hello there"},
+			filename:      "anything.wav",
+			expectedText:  "hello there",
+			expectedWords: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			first, err := tc.engine.Transcribe(context.Background(), strings.NewReader("audio"), tc.filename)
+			assert.NoError(t, err)
+			second, _ := tc.engine.Transcribe(context.Background(), strings.NewReader("audio"), tc.filename)
+
+			assert.Equal(t, tc.expectedText, first.Text)
+			assert.Len(t, first.Words, tc.expectedWords)
+			assert.Equal(t, first, second)
+		})
+	}
+}
+
+func TestSubtitles(t *testing.T) {
+	transcript := &Transcript{Words: []Word{
+		{Text: "hello", Start: 0, End: 400},
+		{Text: "there", Start: 450, End: 900},
+		// too far from the start of the first cue to share it
+		{Text: "friend", Start: 4500, End: 5250},
+	}}
+
+	expectedVTT := "WEBVTT\n\n1\n00:00:00.000 --> 00:00:00.900\nhello there\n\n2\n00:00:04.500 --> 00:00:05.250\nfriend\n"
+	expectedSRT := "1\n00:00:00,000 --> 00:00:00,900\nhello there\n\n2\n00:00:04,500 --> 00:00:05,250\nfriend\n"
+
+	assert.Equal(t, expectedVTT, string(WebVTT(transcript)))
+	assert.Equal(t, expectedSRT, string(SRT(transcript)))
+}
+
+func TestCues_SplitsLongLines(t *testing.T) {
+	words := []Word{}
+	for i := int64(0); i < 10; i++ {
+		words = append(words, Word{Text: "word", Start: i * 100, End: i*100 + 90})
+	}
+
+	cues := Cues(&Transcript{Words: words})
+
+	assert.Len(t, cues, 2)
+	assert.Equal(t, int64(800), cues[1].Start)
+}
+
+func TestParseWhisperJSON(t *testing.T) {
+	document := []byte(`{
+		"result": {"language": "en"},
+		"transcription": [
+			{"offsets": {"from": 0, "to": 320}, "text": " And"},
+			{"offsets": {"from": 320, "to": 320}, "text": ""},
+			{"offsets": {"from": 320, "to": 700}, "text": " so,"}
+		]
+	}`)
+
+	result, err := parseWhisperJSON(document)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "en", result.Language)
+	assert.Equal(t, "And so,", result.Text)
+	assert.Equal(t, []Word{{Text: "And", Start: 0, End: 320}, {Text: "so,", Start: 320, End: 700}}, result.Words)
+}
+
+func TestNewEngine(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cfg           config.TranscriptionConfig
+		expectedName  string
+		expectedError bool
+	}{
+		{name: "Fake", cfg: config.TranscriptionConfig{Engine: "Fake"}, expectedName: EngineFake},
+		{
+			name:         "Whisper",
+			cfg:          config.TranscriptionConfig{Engine: "whisper", Whisper: config.WhisperConfig{Model: "model.bin"}},
+			expectedName: EngineWhisper,
+		},
+		{name: "WhisperWithoutModel", cfg: config.TranscriptionConfig{Engine: "whisper"}, expectedError: true},
+		{name: "Unknown", cfg: config.TranscriptionConfig{Engine: "cloud"}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine, err := NewEngine(tc.cfg)
+
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrUnknownEngine)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedName, engine.Name())
+			}
+		})
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockTranscriptRepository)
+	repo.On("Save", mock.Anything, mock.MatchedBy(func(result Transcript) bool {
+		return result.FileID == 7 && result.Engine == EngineFake && result.Text == "hi" && !result.CreatedAt.IsZero()
+	})).Return(nil)
+
+	processor := NewProcessor(&FakeTranscriber{Text: "hi"}, repo, &fakeStorage{}, "quips", time.Minute, nil)
+	job, err := jobs.NewJob(JobTranscribe, 7, jobs.ObjectPayload{Object: "audio/abc.mp3", Filename: "hi.mp3"})
+	assert.NoError(t, err)
+
+	err = processor.Handle(ctx, &job)
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "log/slog"
+)
+
+// DefaultWhisperBinary is the name of the whisper.cpp command line tool looked up on the PATH
+const DefaultWhisperBinary = "whisper-cli"
+
+// WhisperCPP transcribes with a local whisper.cpp binary. The audio is written to a temporary file
+// and the binary is asked for one segment per word so that every word gets its own timing
+type WhisperCPP struct {
+	// Binary is the path of the whisper.cpp CLI, DefaultWhisperBinary when empty
+	Binary string
+	// Model is the path of the ggml model file
+	Model string
+	// Language spoken in the audio, detected by the model when empty
+	Language string
+	Threads  int
+}
+
+func (w *WhisperCPP) Name() string {
+	return EngineWhisper
+}
+
+func (w *WhisperCPP) Transcribe(ctx context.Context, audio io.Reader, filename string) (*Transcript, error) {
+	dir, err := os.MkdirTemp("", "voice-quips-whisper-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input"+strings.ToLower(filepath.Ext(filename)))
+	if err := writeFile(input, audio); err != nil {
+		return nil, err
+	}
+
+	output := filepath.Join(dir, "output")
+	binary := w.Binary
+	if binary == "" {
+		binary = DefaultWhisperBinary
+	}
+	language := w.Language
+	if language == "" {
+		language = "auto"
+	}
+	args := []string{
+		"--model", w.Model,
+		"--file", input,
+		"--language", language,
+		"--max-len", "1",
+		"--split-on-word",
+		"--output-json",
+		"--output-file", output,
+		"--no-prints",
+	}
+	if w.Threads > 0 {
+		args = append(args, "--threads", strconv.Itoa(w.Threads))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		log.Error("whisper.cpp failed", "err", err, "stderr", stderr.String(), "file", filename)
+		return nil, fmt.Errorf("whisper.cpp failed: %w", err)
+	}
+
+	result, err := os.ReadFile(output + ".json")
+	if err != nil {
+		return nil, err
+	}
+	return parseWhisperJSON(result)
+}
+
+// whisperOutput is the part of whisper.cpp's --output-json document that's used
+type whisperOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// parseWhisperJSON reads the words out of whisper.cpp's JSON output
+func parseWhisperJSON(document []byte) (*Transcript, error) {
+	var output whisperOutput
+	if err := json.Unmarshal(document, &output); err != nil {
+		return nil, err
+	}
+
+	words := []Word{}
+	for _, segment := range output.Transcription {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		words = append(words, Word{Text: text, Start: segment.Offsets.From, End: segment.Offsets.To})
+	}
+
+	return &Transcript{Language: output.Result.Language, Text: joinWords(words), Words: words}, nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}